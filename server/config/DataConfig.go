@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -16,6 +17,8 @@ import (
 var (
 	// ListenerPort web服务监听的端口
 	ListenerPort = ""
+	// ShutdownTimeout 退出时等待处理中的请求以及定时任务结束的最长时间
+	ShutdownTimeout = time.Second * 30
 )
 
 const (
@@ -27,12 +30,15 @@ const (
 	FilmPictureAccess    = "/api/upload/pic/poster/"
 )
 
-
 // -------------------------redis key-----------------------------------
-const (
+// 所有 redis key 在加载配置时都会添加 KeyPrefix 前缀, 因此定义为变量
+var (
 	// CategoryTreeKey 分类树 key
 	CategoryTreeKey = "CategoryTree"
-	FilmExpired     = time.Hour * 24 * 365 * 10
+	// CategoryMappingKey 已合并分类的映射关系, Hash结构 {原分类ID: 目标分类ID}
+	CategoryMappingKey = "CategoryMapping"
+	// FilmCategoryKey 单独调整过分类的影片, Hash结构 {影片ID: 分类ID}
+	FilmCategoryKey = "FilmCategory"
 	// MovieListInfoKey movies分类列表 key
 	MovieListInfoKey = "MovieList:Cid%d"
 
@@ -40,6 +46,10 @@ const (
 	MovieDetailKey = "MovieDetail:Cid%d:Id%d"
 	// MovieBasicInfoKey 影片基本信息, 简略版本
 	MovieBasicInfoKey = "MovieBasicInfo:Cid%d:Id%d"
+	// MovieOverlayKey 影片编辑信息(覆盖层), Hash结构 {mid: FilmOverlay}
+	MovieOverlayKey = "MovieOverlay"
+	// LocalFilmIdKey 本地影片ID计数器
+	LocalFilmIdKey = "Film:LocalId"
 
 	// MultipleSiteDetail 多站点影片信息存储key
 	MultipleSiteDetail = "MultipleSource:%s"
@@ -51,11 +61,72 @@ const (
 	SearchTitle = "Search:Pid%d:Title"
 	// SearchTag 影片剧情标签key
 	SearchTag = "Search:Pid%d:%s"
+	// SearchSuggestKey 搜索建议前缀索引, ZSet 结构 {mid: 权重}, 占位符为规范化后的前缀
+	SearchSuggestKey = "Search:Suggest:%s"
+	// SearchSuggestFilmKey 影片已写入的搜索建议前缀, Hash结构 {mid: [prefix]}
+	SearchSuggestFilmKey = "Search:SuggestFilm"
+	// SearchKeywordKey 每日的搜索关键字次数, SearchZeroKey 每日的无结果搜索关键字次数, ZSet 结构 {keyword: 次数}, 占位符为日期 20060102
+	SearchKeywordKey = "Search:Stats:Keyword:%s"
+	SearchZeroKey    = "Search:Stats:Zero:%s"
+	// SearchResultKey 每日搜索关键字最近一次的结果数量, Hash结构 {keyword: 数量}, 占位符为日期 20060102
+	SearchResultKey = "Search:Stats:Result:%s"
+	// SearchStatsUnionKey 多日搜索统计的合并结果缓存, 占位符为 统计类型:天数
+	SearchStatsUnionKey = "Search:Stats:Union:%s"
+	// SearchFacetsKey 标签检索分面统计结果的缓存, 占位符为筛选条件的哈希值
+	SearchFacetsKey = "Search:Facets:%s"
+	// SearchLookupKey 采集站搜索任务的状态信息, 同一关键字在过期前不重复搜索, 占位符为规范化后的关键字
+	SearchLookupKey = "Search:Lookup:%s"
+	// SearchLookupRateKey 每分钟触发的采集站搜索次数, 占位符为时间 200601021504
+	SearchLookupRateKey = "Search:LookupRate:%s"
 
 	// VirtualPictureKey 待同步图片临时存储 key
 	VirtualPictureKey = "VirtualPicture"
+)
+
+const (
+	FilmExpired = time.Hour * 24 * 365 * 10
 	// MaxScanCount redis Scan 操作每次扫描的数据量, 每次最多扫描300条数据
 	MaxScanCount = 300
+	// CustomCategoryIdStart 自定义分类的起始ID, 避免与采集站的分类ID冲突
+	CustomCategoryIdStart = 100000
+	// LocalFilmIdStart 本地添加与导入的影片的起始ID, 避免与采集站的影片ID冲突
+	LocalFilmIdStart = 10000000000
+	// LocalSourceId 本地添加与导入的影片所属的采集站标识, 用于匹配屏蔽规则
+	LocalSourceId = "local"
+	// FilmImportMaxSize 影片导入文件大小上限
+	FilmImportMaxSize = 10 << 20
+	// PersonNameMaxLength 影人姓名的最大长度, 超出时视为无效数据
+	PersonNameMaxLength = 64
+	// FilmVisibilityExpired 影片展示状态(隐藏分类 & 隐藏影片)的本地缓存时长, 分类或影片展示状态变更时立即失效
+	FilmVisibilityExpired = time.Second * 30
+	// CalendarDayMaxFilms 放送表中每天展示的最大影片数量
+	CalendarDayMaxFilms = 100
+	// SuggestPrefixMaxLength 搜索建议前缀索引的最大前缀长度
+	SuggestPrefixMaxLength = 16
+	// SuggestPrefixMaxFilms 每个搜索建议前缀保留的最大影片数量
+	SuggestPrefixMaxFilms = 200
+	// SuggestMaxCount 搜索建议最多返回的影片数量
+	SuggestMaxCount = 20
+	// SearchStatsExpired 每日搜索统计的保留时间
+	SearchStatsExpired = time.Hour * 24 * 31
+	// SearchStatsUnionExpired 多日搜索统计合并结果的缓存时间
+	SearchStatsUnionExpired = time.Minute
+	// SearchFacetsExpired 分面统计结果的缓存时间
+	SearchFacetsExpired = time.Minute
+	// SearchKeywordMaxLength 参与统计的搜索关键字最大长度, 超出时视为无效关键字
+	SearchKeywordMaxLength = 32
+	// SearchLookupExpired 采集站搜索任务状态的保留时间, 期间相同关键字不再重复搜索
+	SearchLookupExpired = time.Minute * 30
+	// SearchLookupRateLimit 每分钟最多触发的采集站搜索次数
+	SearchLookupRateLimit = 10
+	// SearchLookupConcurrency 同时执行的采集站搜索任务数量
+	SearchLookupConcurrency = 2
+	// FilmTextContentLimit 全文检索中保存的影片简介最大长度
+	FilmTextContentLimit = 1000
+	// FilmTextPinyinMaxLength 全文检索中片名全拼与拼音首字母字段的最大长度
+	FilmTextPinyinMaxLength = 255
+	// FilmTextMaxCandidates 全文检索每次参与相关度排序的最大影片数量
+	FilmTextMaxCandidates = 1000
 )
 
 const (
@@ -63,11 +134,9 @@ const (
 )
 
 // -------------------------manage 管理后台相关key----------------------------------
-const (
+var (
 	// FilmSourceListKey 采集 API 信息列表key
 	FilmSourceListKey = "Config:Collect:FilmSource"
-	// ManageConfigExpired 管理配置key 长期有效, 暂定10年
-	ManageConfigExpired = time.Hour * 24 * 365 * 10
 	// SiteConfigBasic 网站参数配置
 	SiteConfigBasic = "SystemConfig:SiteConfig:Basic"
 	// BannersKey 轮播组件key 你
@@ -75,6 +144,28 @@ const (
 
 	// FilmCrontabKey 定时任务列表信息
 	FilmCrontabKey = "Cron:Task:Film"
+
+	// TenantListKey 站点(租户)信息列表
+	TenantListKey = "Config:Tenants"
+)
+
+// -------------------------Tenant 多站点相关配置-----------------------------------
+const (
+	// DefaultTenantId 默认站点标识, 默认站点直接使用全局 key, 兼容单站点部署的已有数据
+	DefaultTenantId = "default"
+	// TenantHeader 指定请求所属站点的请求头
+	TenantHeader = "X-Tenant-Id"
+	// TenantCtxKey 当前请求站点信息在 gin.Context 中的 key
+	TenantCtxKey = "Tenant"
+	// BlockMatcherExpired 屏蔽规则匹配器的本地缓存时长, 当前实例添加或删除规则时立即失效
+	BlockMatcherExpired = time.Second * 30
+	// TenantResolveExpired 站点域名映射的本地缓存时长
+	TenantResolveExpired = time.Second * 30
+)
+
+const (
+	// ManageConfigExpired 管理配置key 长期有效, 暂定10年
+	ManageConfigExpired = time.Hour * 24 * 365 * 10
 	// DefaultUpdateSpec 每20分钟执行一次
 	DefaultUpdateSpec = "0 */20 * * * ?"
 	// EveryWeekSpec 每周日凌晨4点更新一次
@@ -84,11 +175,58 @@ const (
 )
 
 // -------------------------Web API相关redis key-----------------------------------
-const (
+var (
 	// IndexCacheKey , 首页数据缓存
 	IndexCacheKey = "IndexCache"
 )
 
+// -------------------------影片批量操作相关配置-----------------------------------
+var (
+	// FilmBatchJobKey 影片批量操作任务信息, Hash结构 {任务ID: FilmBatchJob}
+	FilmBatchJobKey = "Film:BatchJob"
+)
+
+const (
+	// FilmBatchJobRetain 保留的批量操作任务记录数量
+	FilmBatchJobRetain = 50
+	// FilmBatchErrorLimit 任务结果中保留的失败明细数量上限
+	FilmBatchErrorLimit = 200
+	// FilmBatchRecollectSize 重新采集时每次请求的影片数量
+	FilmBatchRecollectSize = 20
+)
+
+// -------------------------重复影片检测与合并相关配置-----------------------------------
+var (
+	// FilmDuplicateKey 疑似重复的影片分组, Hash结构 {分组ID: FilmDuplicateGroup}
+	FilmDuplicateKey = "Film:Duplicate"
+	// FilmDuplicateIgnoreKey 已标记为非重复的分组ID, Set结构
+	FilmDuplicateIgnoreKey = "Film:DuplicateIgnore"
+	// FilmDuplicateScanKey 最近一次重复影片检测任务信息
+	FilmDuplicateScanKey = "Film:DuplicateScan"
+	// FilmRedirectKey 已合并到其他影片的重复影片, Hash结构 {重复影片ID: FilmRedirect}
+	FilmRedirectKey = "Film:Redirect"
+	// FilmMergedKey 影片中合并的重复影片, Hash结构 {保留的影片ID: []FilmRedirect}
+	FilmMergedKey = "Film:Merged"
+)
+
+// -------------------------Backup 数据备份相关配置-----------------------------------
+const (
+	// BackupFormatVersion 备份文件格式版本, 备份文件结构发生不兼容变更时递增
+	BackupFormatVersion = 1
+	// BackupFileSuffix 备份文件后缀
+	BackupFileSuffix = ".tar.gz"
+)
+
+var (
+	// BackupDir 备份文件存放目录
+	BackupDir = "./data/backup"
+	// BackupSpec 定时备份的 cron 表达式, 为空则不开启定时备份
+	BackupSpec = ""
+	// BackupScope 定时备份的数据范围 all | config | library
+	BackupScope = "all"
+	// BackupKeep 定时备份保留的最大文件数量
+	BackupKeep = 7
+)
 
 // -------------------------Database Connection Params-----------------------------------
 const (
@@ -98,11 +236,79 @@ const (
 	UserIdInitialVal       = 10000
 	FileTableName          = "files"
 	FailureRecordTableName = "failure_records"
+	// FilmRecycleTableName 影片回收站
+	FilmRecycleTableName = "film_recycle"
+	// FilmBlockTableName 影片屏蔽规则, FilmBlockAuditTableName 屏蔽规则操作记录
+	FilmBlockTableName      = "film_blocks"
+	FilmBlockAuditTableName = "film_block_audits"
+	// FilmHiddenTableName 单独设置为隐藏的影片
+	FilmHiddenTableName = "film_hidden"
+	// PersonTableName 影人信息, FilmPersonTableName 影片与影人的关联信息
+	PersonTableName     = "persons"
+	FilmPersonTableName = "film_persons"
+	// ActorTableName 采集的演员资料, FilmRoleTableName 采集的影片角色, ArticleTableName 采集的文章资讯
+	ActorTableName    = "actors"
+	FilmRoleTableName = "film_roles"
+	ArticleTableName  = "articles"
+	// FilmTextTableName 影片全文检索信息, FilmTextFtsTableName SQLite 中对应的 FTS5 全文索引表
+	FilmTextTableName    = "film_texts"
+	FilmTextFtsTableName = "film_texts_fts"
+	// SchemaMigrationTableName 数据库版本迁移记录表
+	SchemaMigrationTableName = "schema_migrations"
+
+	// MigrateLockExpired 迁移锁的最长持有时间
+	MigrateLockExpired = time.Minute * 10
+	// MigrateLockWait 等待其他实例释放迁移锁的最长时间
+	MigrateLockWait = time.Minute * 10
+)
+
+const (
+	// StorageServer 存储模式-服务端, 使用独立部署的 Redis + MySQL
+	StorageServer = "server"
+	// StorageEmbedded 存储模式-嵌入式, 使用进程内 KV + SQLite, 适用于单机小规模部署
+	StorageEmbedded = "embedded"
+)
+
+const (
+	// DriverMysql 关系型数据库驱动-MySQL (默认)
+	DriverMysql = "mysql"
+	// DriverPostgres 关系型数据库驱动-PostgreSQL
+	DriverPostgres = "postgres"
+)
+
+var (
+	// MigrateLockKey 数据库迁移锁, 防止多实例同时执行迁移
+	MigrateLockKey = "System:Migrate:Lock"
 )
 
 var (
+	// KeyPrefix redis key 统一前缀, 多个实例共用同一个 redis DB 时用于隔离数据
+	KeyPrefix = ""
+
+	// StorageMode 数据存储模式 server | embedded
+	StorageMode = StorageServer
+	// SqlitePath 嵌入式模式下 SQLite 数据库文件路径
+	SqlitePath = "./data/gofilm.db"
+	// KVSnapshotPath 嵌入式模式下进程内 KV 数据快照文件路径
+	KVSnapshotPath = "./data/kv.snapshot"
+	// KVSnapshotInterval 进程内 KV 数据快照的保存周期
+	KVSnapshotInterval = time.Minute
+	// KVAppendPath 嵌入式模式下进程内 KV 写命令追加日志路径, 记录最近一次快照之后的全部写操作
+	KVAppendPath = "./data/kv.aof"
+	// KVAppendSyncInterval 追加日志刷盘周期, 进程异常退出时最多丢失该时间段内的写操作
+	KVAppendSyncInterval = time.Second
+	// DataLockPath 嵌入式模式下的数据文件锁, 位于 SQLite 数据库文件旁, 同一时刻只允许一个进程使用数据文件
+	DataLockPath = "./data/gofilm.db.lock"
+	// MigrateLockPath 嵌入式模式下的数据库迁移锁文件
+	MigrateLockPath = "./data/gofilm.db.migrate.lock"
+
+	// DBDriver 服务端模式下使用的关系型数据库驱动 mysql | postgres
+	DBDriver = DriverMysql
+
 	// mysql服务配置信息
 	MysqlDsn = ""
+	// PostgreSQL服务配置信息
+	PostgresDsn = ""
 
 	// Redis连接信息
 	RedisAddr     = ""
@@ -110,7 +316,6 @@ var (
 	RedisDBNo     = 0
 )
 
-
 func init() {
 	InitConfig()
 }
@@ -127,21 +332,39 @@ func InitConfig() {
 	}
 	fmt.Printf("[Config] 加载端口: %s\n", ListenerPort)
 
-	// 加载 MySQL 配置
-	mHost := os.Getenv("MYSQL_HOST")
-	mPort := os.Getenv("MYSQL_PORT")
-	mUser := os.Getenv("MYSQL_USER")
-	mPass := os.Getenv("MYSQL_PASSWORD")
-	mDB := os.Getenv("MYSQL_DBNAME")
+	// 加载备份配置
+	initBackupConfig()
 
-	if mHost == "" || mPort == "" || mUser == "" || mDB == "" {
-		panic(fmt.Sprintf("环境变量缺失: MYSQL_HOST=%s, MYSQL_PORT=%s, MYSQL_USER=%s, MYSQL_DBNAME=%s",
-			mHost, mPort, mUser, mDB))
+	// 加载 redis key 前缀, 嵌入式模式同样生效
+	if prefix := os.Getenv("REDIS_KEY_PREFIX"); prefix != "" {
+		applyKeyPrefix(prefix)
 	}
 
-	MysqlDsn = fmt.Sprintf("%s:%s@(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		mUser, mPass, mHost, mPort, mDB)
-	fmt.Printf("[Config] 加载 MySQL DSN: %s:%s@(%s:%s)/%s\n", mUser, "******", mHost, mPort, mDB)
+	// 加载存储模式, 嵌入式模式无需 MySQL 与 Redis 服务
+	if mode := os.Getenv("STORAGE_MODE"); mode != "" {
+		StorageMode = mode
+	}
+	switch StorageMode {
+	case StorageEmbedded:
+		initEmbeddedConfig()
+		return
+	case StorageServer:
+	default:
+		panic(fmt.Sprintf("环境变量异常: STORAGE_MODE=%s, 可选值 %s | %s", StorageMode, StorageServer, StorageEmbedded))
+	}
+
+	// 加载关系型数据库配置
+	if driver := os.Getenv("DB_DRIVER"); driver != "" {
+		DBDriver = driver
+	}
+	switch DBDriver {
+	case DriverMysql:
+		initMysqlConfig()
+	case DriverPostgres:
+		initPostgresConfig()
+	default:
+		panic(fmt.Sprintf("环境变量异常: DB_DRIVER=%s, 可选值 %s | %s", DBDriver, DriverMysql, DriverPostgres))
+	}
 
 	// 加载 Redis 配置
 	rHost := os.Getenv("REDIS_HOST")
@@ -165,6 +388,115 @@ func InitConfig() {
 	fmt.Printf("[Config] 加载 Redis 地址: %s, DB: %d\n", RedisAddr, RedisDBNo)
 }
 
+// applyKeyPrefix 为所有 redis key 添加统一前缀, 前缀与 key 之间使用 : 分隔
+func applyKeyPrefix(prefix string) {
+	if !strings.HasSuffix(prefix, ":") {
+		prefix += ":"
+	}
+	KeyPrefix = prefix
+	for _, k := range []*string{
+		&CategoryTreeKey, &CategoryMappingKey, &FilmCategoryKey, &MovieListInfoKey, &MovieDetailKey, &MovieBasicInfoKey, &MovieOverlayKey, &LocalFilmIdKey, &MultipleSiteDetail,
+		&SearchInfoTemp, &SearchTitle, &SearchTag, &SearchSuggestKey, &SearchSuggestFilmKey,
+		&SearchKeywordKey, &SearchZeroKey, &SearchResultKey, &SearchStatsUnionKey, &SearchFacetsKey, &SearchLookupKey, &SearchLookupRateKey, &VirtualPictureKey,
+		&FilmSourceListKey, &SiteConfigBasic, &BannersKey, &FilmCrontabKey, &TenantListKey, &IndexCacheKey, &FilmBatchJobKey, &MigrateLockKey,
+		&FilmDuplicateKey, &FilmDuplicateIgnoreKey, &FilmDuplicateScanKey, &FilmRedirectKey, &FilmMergedKey,
+		&OriginalFilmDetailKey, &FilmClassKey, &UserTokenKey,
+	} {
+		*k = prefix + *k
+	}
+	fmt.Printf("[Config] 加载 Redis Key 前缀: %s\n", KeyPrefix)
+}
+
+// TenantKey 生成站点独立的 key, 默认站点返回原 key, 其余站点在前缀后追加 Tenant:<id>:
+// 例: SystemConfig:Banners => Tenant:site2:SystemConfig:Banners
+func TenantKey(tid, key string) string {
+	if tid == "" || tid == DefaultTenantId {
+		return key
+	}
+	return fmt.Sprintf("%sTenant:%s:%s", KeyPrefix, tid, strings.TrimPrefix(key, KeyPrefix))
+}
 
+// KeyPattern 将带格式化占位符的 key 转换为 SCAN 匹配规则, 例: MovieDetail:Cid%d:Id%d => MovieDetail:Cid*
+func KeyPattern(format string) string {
+	if i := strings.Index(format, "%"); i >= 0 {
+		return format[:i] + "*"
+	}
+	return format
+}
 
+// initBackupConfig 加载数据备份相关配置
+func initBackupConfig() {
+	if dir := os.Getenv("BACKUP_DIR"); dir != "" {
+		BackupDir = dir
+	}
+	BackupSpec = os.Getenv("BACKUP_SPEC")
+	if scope := os.Getenv("BACKUP_SCOPE"); scope != "" {
+		BackupScope = scope
+	}
+	if keep := os.Getenv("BACKUP_KEEP"); keep != "" {
+		if n, err := strconv.Atoi(keep); err == nil && n > 0 {
+			BackupKeep = n
+		}
+	}
+}
 
+// initMysqlConfig 加载 MySQL 连接配置
+func initMysqlConfig() {
+	mHost := os.Getenv("MYSQL_HOST")
+	mPort := os.Getenv("MYSQL_PORT")
+	mUser := os.Getenv("MYSQL_USER")
+	mPass := os.Getenv("MYSQL_PASSWORD")
+	mDB := os.Getenv("MYSQL_DBNAME")
+
+	if mHost == "" || mPort == "" || mUser == "" || mDB == "" {
+		panic(fmt.Sprintf("环境变量缺失: MYSQL_HOST=%s, MYSQL_PORT=%s, MYSQL_USER=%s, MYSQL_DBNAME=%s",
+			mHost, mPort, mUser, mDB))
+	}
+
+	MysqlDsn = fmt.Sprintf("%s:%s@(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+		mUser, mPass, mHost, mPort, mDB)
+	fmt.Printf("[Config] 加载 MySQL DSN: %s:%s@(%s:%s)/%s\n", mUser, "******", mHost, mPort, mDB)
+}
+
+// initPostgresConfig 加载 PostgreSQL 连接配置
+func initPostgresConfig() {
+	pHost := os.Getenv("POSTGRES_HOST")
+	pPort := os.Getenv("POSTGRES_PORT")
+	pUser := os.Getenv("POSTGRES_USER")
+	pPass := os.Getenv("POSTGRES_PASSWORD")
+	pDB := os.Getenv("POSTGRES_DBNAME")
+	sslMode := os.Getenv("POSTGRES_SSLMODE")
+
+	if pHost == "" || pPort == "" || pUser == "" || pDB == "" {
+		panic(fmt.Sprintf("环境变量缺失: POSTGRES_HOST=%s, POSTGRES_PORT=%s, POSTGRES_USER=%s, POSTGRES_DBNAME=%s",
+			pHost, pPort, pUser, pDB))
+	}
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+
+	PostgresDsn = fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s TimeZone=Local",
+		pHost, pPort, pUser, pPass, pDB, sslMode)
+	fmt.Printf("[Config] 加载 PostgreSQL DSN: host=%s port=%s user=%s password=%s dbname=%s\n", pHost, pPort, pUser, "******", pDB)
+}
+
+// initEmbeddedConfig 加载嵌入式存储模式的相关配置
+func initEmbeddedConfig() {
+	if p := os.Getenv("SQLITE_PATH"); p != "" {
+		SqlitePath = p
+	}
+	DataLockPath = SqlitePath + ".lock"
+	MigrateLockPath = SqlitePath + ".migrate.lock"
+	if p := os.Getenv("KV_SNAPSHOT_PATH"); p != "" {
+		KVSnapshotPath = p
+	}
+	if p := os.Getenv("KV_AOF_PATH"); p != "" {
+		KVAppendPath = p
+	}
+	if v := os.Getenv("KV_SNAPSHOT_INTERVAL"); v != "" {
+		if sec, err := strconv.Atoi(v); err == nil && sec > 0 {
+			KVSnapshotInterval = time.Duration(sec) * time.Second
+		}
+	}
+	fmt.Printf("[Config] 嵌入式存储模式: SQLite=%s, KV快照=%s, KV日志=%s\n", SqlitePath, KVSnapshotPath, KVAppendPath)
+}
@@ -20,5 +20,9 @@ MEgCQQDTZ7IaFGk9oBTa4oZhXZgtXAQrNVyxXORZ9zHzTkd2jhgztKIV5W5LuiK/
 const (
 	Issuer           = "Bracket"
 	AuthTokenExpires = 10 * 24 // 单位 h
-	UserTokenKey     = "User:Token:%d"
+)
+
+var (
+	// UserTokenKey 用户登录凭证 key
+	UserTokenKey = "User:Token:%d"
 )
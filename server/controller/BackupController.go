@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"fmt"
+	"path/filepath"
+	"server/logic"
+	"server/model/system"
+	"server/plugin/backup"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ------------------------------------------------------ 数据备份管理 ------------------------------------------------------
+
+// BackupList 获取备份文件列表
+func BackupList(c *gin.Context) {
+	list, err := logic.BL.GetBackupList()
+	if err != nil {
+		system.Failed(fmt.Sprint("备份列表获取失败: ", err.Error()), c)
+		return
+	}
+	system.Success(list, "备份列表获取成功", c)
+}
+
+// BackupCreate 创建数据备份
+func BackupCreate(c *gin.Context) {
+	var vo system.BackupVo
+	if err := c.ShouldBindJSON(&vo); err != nil {
+		system.Failed("请求参数异常!!!", c)
+		return
+	}
+	if vo.Scope == "" {
+		vo.Scope = backup.ScopeAll
+	}
+	fi, err := logic.BL.CreateBackup(vo.Scope)
+	if err != nil {
+		system.Failed(fmt.Sprint("数据备份失败: ", err.Error()), c)
+		return
+	}
+	system.Success(fi, "数据备份成功", c)
+}
+
+// BackupRestore 从备份文件中恢复数据
+func BackupRestore(c *gin.Context) {
+	var vo system.BackupVo
+	if err := c.ShouldBindJSON(&vo); err != nil || vo.Name == "" {
+		system.Failed("请求参数异常, 备份文件名称不能为空", c)
+		return
+	}
+	if vo.Scope == "" {
+		vo.Scope = backup.ScopeAll
+	}
+	if err := backup.ValidScope(vo.Scope); err != nil {
+		system.Failed(err.Error(), c)
+		return
+	}
+	res, err := logic.BL.RestoreBackup(vo.Name, vo.Scope)
+	if err != nil {
+		system.Failed(fmt.Sprint("数据恢复失败: ", err.Error()), c)
+		return
+	}
+	system.Success(res, "数据恢复成功", c)
+}
+
+// BackupUpload 上传备份文件到备份目录
+func BackupUpload(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		system.Failed(err.Error(), c)
+		return
+	}
+	src, err := file.Open()
+	if err != nil {
+		system.Failed(err.Error(), c)
+		return
+	}
+	defer src.Close()
+	name := filepath.Base(file.Filename)
+	if err = logic.BL.UploadBackup(name, src); err != nil {
+		system.Failed(fmt.Sprint("备份文件上传失败: ", err.Error()), c)
+		return
+	}
+	system.Success(name, "备份文件上传成功", c)
+}
+
+// BackupDownload 下载备份文件
+func BackupDownload(c *gin.Context) {
+	name := c.DefaultQuery("name", "")
+	path, err := logic.BL.GetBackupPath(name)
+	if err != nil {
+		system.Failed(err.Error(), c)
+		return
+	}
+	c.FileAttachment(path, name)
+}
+
+// BackupDel 删除备份文件
+func BackupDel(c *gin.Context) {
+	name := c.DefaultQuery("name", "")
+	if err := logic.BL.DelBackup(name); err != nil {
+		system.Failed(fmt.Sprint("备份文件删除失败: ", err.Error()), c)
+		return
+	}
+	system.SuccessOnlyMsg("备份文件已删除", c)
+}
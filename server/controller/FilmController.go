@@ -1,7 +1,10 @@
 package controller
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"server/config"
 	"server/logic"
 	"server/model/system"
 	"server/plugin/spider"
@@ -105,6 +108,93 @@ func FilmAdd(c *gin.Context) {
 	system.SuccessOnlyMsg("影片信息添加成功", c)
 }
 
+// FilmImport 导入 m3u 播放列表, 文本剧集列表, CSV 或 JSON 格式的影片信息
+// 表单参数: file 导入文件 | content 文本内容(未上传文件时使用), format 导入格式(默认按文件后缀推断),
+// film 影片默认信息(FilmDetailVo JSON), preview 是否仅预览(默认 true)
+func FilmImport(c *gin.Context) {
+	var base = system.FilmDetailVo{}
+	if f := c.PostForm("film"); f != "" {
+		if err := json.Unmarshal([]byte(f), &base); err != nil {
+			system.Failed("影片导入失败, 影片默认信息格式异常", c)
+			return
+		}
+	}
+	var name string
+	var data []byte
+	if file, err := c.FormFile("file"); err == nil {
+		if file.Size > config.FilmImportMaxSize {
+			system.Failed(fmt.Sprintf("影片导入失败, 文件大小不能超过 %dMB", config.FilmImportMaxSize>>20), c)
+			return
+		}
+		f, err := file.Open()
+		if err != nil {
+			system.Failed(fmt.Sprint("影片导入失败: ", err.Error()), c)
+			return
+		}
+		defer f.Close()
+		if data, err = io.ReadAll(f); err != nil {
+			system.Failed(fmt.Sprint("影片导入失败: ", err.Error()), c)
+			return
+		}
+		name = file.Filename
+	} else {
+		data = []byte(c.PostForm("content"))
+	}
+	if len(data) == 0 {
+		system.Failed("影片导入失败, 导入内容不能为空", c)
+		return
+	}
+	res, err := logic.FL.ImportFilm(c.PostForm("format"), name, data, base, c.DefaultPostForm("preview", "true") != "false")
+	if err != nil {
+		system.Failed(fmt.Sprint("影片导入失败: ", err.Error()), c)
+		return
+	}
+	system.Success(res, "影片导入文件解析完成", c)
+}
+
+// FilmEditFind 获取影片的编辑信息
+func FilmEditFind(c *gin.Context) {
+	id, err := strconv.ParseInt(c.DefaultQuery("id", ""), 10, 64)
+	if err != nil {
+		system.Failed("影片信息获取失败, 影片ID参数异常", c)
+		return
+	}
+	res, err := logic.FL.GetFilmEdit(id)
+	if err != nil {
+		system.Failed(fmt.Sprint("影片信息获取失败: ", err.Error()), c)
+		return
+	}
+	system.Success(res, "影片编辑信息获取成功", c)
+}
+
+// FilmUpdate 编辑影片信息, 修改的字段默认锁定, 重新采集时保留修改后的值
+func FilmUpdate(c *gin.Context) {
+	var vo = system.FilmEditVo{}
+	if err := c.ShouldBindJSON(&vo); err != nil || vo.Id == 0 {
+		system.Failed("影片更新失败, 影片参数提交异常", c)
+		return
+	}
+	if err := logic.FL.UpdateFilm(vo); err != nil {
+		system.Failed(fmt.Sprint("影片更新失败: ", err.Error()), c)
+		return
+	}
+	system.SuccessOnlyMsg("影片信息更新成功", c)
+}
+
+// FilmEditReset 撤销影片的所有编辑信息
+func FilmEditReset(c *gin.Context) {
+	id, err := strconv.ParseInt(c.DefaultQuery("id", ""), 10, 64)
+	if err != nil {
+		system.Failed("撤销失败, 影片ID参数异常", c)
+		return
+	}
+	if err = logic.FL.ResetFilmEdit(id); err != nil {
+		system.Failed(fmt.Sprint("撤销失败: ", err.Error()), c)
+		return
+	}
+	system.SuccessOnlyMsg("影片编辑信息已撤销", c)
+}
+
 // FilmDelete 删除影片检索信息, 逻辑删除
 func FilmDelete(c *gin.Context) {
 	// 获取影片ID
@@ -119,13 +209,219 @@ func FilmDelete(c *gin.Context) {
 		return
 	}
 	// 通过ID删除对应影片检索信息
-	if err = logic.FL.DelFilm(id); err != nil {
+	if err = logic.FL.DelFilm(id, system.CurrentOperator(c)); err != nil {
 		system.Failed(fmt.Sprintln("删除失败: ", err.Error()), c)
 		return
 	}
 	system.SuccessOnlyMsg("影片删除成功", c)
 }
 
+//----------------------------------------------------影片批量操作----------------------------------------------------
+
+// FilmBatch 创建影片批量操作任务
+func FilmBatch(c *gin.Context) {
+	var vo = system.FilmBatchVo{}
+	if err := c.ShouldBindJSON(&vo); err != nil {
+		system.Failed("请求参数异常!!!", c)
+		return
+	}
+	job, err := logic.FL.BatchFilm(vo, system.CurrentOperator(c))
+	if err != nil {
+		system.Failed(fmt.Sprint("批量操作失败: ", err.Error()), c)
+		return
+	}
+	system.Success(job, "批量操作任务已开始执行", c)
+}
+
+// FilmBatchList 批量操作任务列表
+func FilmBatchList(c *gin.Context) {
+	system.Success(logic.FL.GetFilmBatchJobs(), "批量操作任务获取成功", c)
+}
+
+// FilmBatchFind 获取批量操作任务的执行进度与结果
+func FilmBatchFind(c *gin.Context) {
+	job := logic.FL.GetFilmBatchJob(c.DefaultQuery("id", ""))
+	if job == nil {
+		system.Failed("批量操作任务不存在", c)
+		return
+	}
+	system.Success(job, "批量操作任务获取成功", c)
+}
+
+//----------------------------------------------------重复影片检测与合并----------------------------------------------------
+
+// FilmDuplicateScan 开始执行重复影片检测
+func FilmDuplicateScan(c *gin.Context) {
+	scan, err := logic.FL.ScanDuplicateFilm(system.CurrentOperator(c))
+	if err != nil {
+		system.Failed(fmt.Sprint("重复影片检测失败: ", err.Error()), c)
+		return
+	}
+	system.Success(scan, "重复影片检测任务已开始执行", c)
+}
+
+// FilmDuplicateList 疑似重复影片分组列表
+func FilmDuplicateList(c *gin.Context) {
+	page := system.Page{}
+	page.Current, _ = strconv.Atoi(c.DefaultQuery("current", "1"))
+	page.PageSize, _ = strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+	if page.Current <= 0 || page.PageSize <= 0 || page.PageSize > 500 {
+		page = system.Page{Current: 1, PageSize: 10}
+	}
+	list, scan := logic.FL.GetDuplicatePage(&page)
+	system.Success(gin.H{"list": list, "page": page, "scan": scan}, "疑似重复影片获取成功", c)
+}
+
+// FilmDuplicateMerge 合并重复影片
+func FilmDuplicateMerge(c *gin.Context) {
+	var vo = system.FilmMergeVo{}
+	if err := c.ShouldBindJSON(&vo); err != nil {
+		system.Failed("请求参数异常!!!", c)
+		return
+	}
+	if err := logic.FL.MergeDuplicateFilm(vo, system.CurrentOperator(c)); err != nil {
+		system.Failed(fmt.Sprint("合并失败: ", err.Error()), c)
+		return
+	}
+	system.SuccessOnlyMsg("重复影片已合并", c)
+}
+
+// FilmDuplicateIgnore 将疑似重复分组标记为非重复
+func FilmDuplicateIgnore(c *gin.Context) {
+	if err := logic.FL.IgnoreDuplicate(c.DefaultQuery("id", "")); err != nil {
+		system.Failed(fmt.Sprint("操作失败: ", err.Error()), c)
+		return
+	}
+	system.SuccessOnlyMsg("已标记为非重复影片", c)
+}
+
+//----------------------------------------------------影人信息----------------------------------------------------
+
+// FilmPersonRebuild 使用影片详情重建所有影片的影人信息
+func FilmPersonRebuild(c *gin.Context) {
+	if err := logic.FL.RebuildFilmPersons(); err != nil {
+		system.Failed(fmt.Sprint("影人信息重建失败: ", err.Error()), c)
+		return
+	}
+	system.SuccessOnlyMsg("影人信息重建任务已开始执行", c)
+}
+
+// FilmTextRebuild 使用影片详情重建所有影片的全文检索信息与搜索建议
+func FilmTextRebuild(c *gin.Context) {
+	if err := logic.FL.RebuildFilmTexts(); err != nil {
+		system.Failed(fmt.Sprint("全文检索信息重建失败: ", err.Error()), c)
+		return
+	}
+	system.SuccessOnlyMsg("全文检索信息重建任务已开始执行", c)
+}
+
+// FilmSearchZero 最近 days 天无搜索结果的关键字, 用于补充影片或启用采集站
+func FilmSearchZero(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "7"))
+	if err != nil || days <= 0 {
+		days = 7
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	system.Success(gin.H{"list": logic.FL.GetZeroKeywords(days, limit), "days": days}, "无结果搜索统计获取成功", c)
+}
+
+//----------------------------------------------------回收站 & 屏蔽规则----------------------------------------------------
+
+// FilmRecycleList 回收站影片分页数据
+func FilmRecycleList(c *gin.Context) {
+	page := system.Page{}
+	page.Current, _ = strconv.Atoi(c.DefaultQuery("current", "1"))
+	page.PageSize, _ = strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+	if page.Current <= 0 || page.PageSize <= 0 || page.PageSize > 500 {
+		page = system.Page{Current: 1, PageSize: 10}
+	}
+	list := logic.FL.GetRecyclePage(c.DefaultQuery("name", ""), &page)
+	system.Success(gin.H{"list": list, "page": page}, "回收站数据获取成功", c)
+}
+
+// FilmRecycleRestore 从回收站中恢复影片
+func FilmRecycleRestore(c *gin.Context) {
+	id, err := strconv.ParseUint(c.DefaultQuery("id", ""), 10, 64)
+	if err != nil {
+		system.Failed("恢复失败, ID参数异常", c)
+		return
+	}
+	if err = logic.FL.RestoreFilm(uint(id)); err != nil {
+		system.Failed(fmt.Sprint("恢复失败: ", err.Error()), c)
+		return
+	}
+	system.SuccessOnlyMsg("影片已恢复", c)
+}
+
+// FilmRecyclePurge 彻底删除回收站中的影片
+func FilmRecyclePurge(c *gin.Context) {
+	id, err := strconv.ParseUint(c.DefaultQuery("id", ""), 10, 64)
+	if err != nil {
+		system.Failed("删除失败, ID参数异常", c)
+		return
+	}
+	if err = logic.FL.PurgeFilm(uint(id)); err != nil {
+		system.Failed(fmt.Sprint("删除失败: ", err.Error()), c)
+		return
+	}
+	system.SuccessOnlyMsg("影片已彻底删除", c)
+}
+
+// FilmBlockList 屏蔽规则分页数据
+func FilmBlockList(c *gin.Context) {
+	page := system.Page{}
+	page.Current, _ = strconv.Atoi(c.DefaultQuery("current", "1"))
+	page.PageSize, _ = strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+	if page.Current <= 0 || page.PageSize <= 0 || page.PageSize > 500 {
+		page = system.Page{Current: 1, PageSize: 10}
+	}
+	list := logic.FL.GetFilmBlockPage(c.DefaultQuery("type", ""), &page)
+	system.Success(gin.H{"list": list, "page": page}, "屏蔽规则获取成功", c)
+}
+
+// FilmBlockAdd 添加屏蔽规则
+func FilmBlockAdd(c *gin.Context) {
+	var b = system.FilmBlock{}
+	if err := c.ShouldBindJSON(&b); err != nil {
+		system.Failed("请求参数异常!!!", c)
+		return
+	}
+	if err := logic.FL.AddFilmBlock(b, system.CurrentOperator(c)); err != nil {
+		system.Failed(fmt.Sprint("屏蔽规则添加失败: ", err.Error()), c)
+		return
+	}
+	system.SuccessOnlyMsg("屏蔽规则添加成功, 已入库的匹配影片将在后台下架", c)
+}
+
+// FilmBlockDel 删除屏蔽规则
+func FilmBlockDel(c *gin.Context) {
+	id, err := strconv.ParseUint(c.DefaultQuery("id", ""), 10, 64)
+	if err != nil {
+		system.Failed("删除失败, ID参数异常", c)
+		return
+	}
+	if err = logic.FL.DelFilmBlock(uint(id), system.CurrentOperator(c)); err != nil {
+		system.Failed(fmt.Sprint("删除失败: ", err.Error()), c)
+		return
+	}
+	system.SuccessOnlyMsg("屏蔽规则已删除", c)
+}
+
+// FilmBlockAuditList 屏蔽规则操作记录
+func FilmBlockAuditList(c *gin.Context) {
+	page := system.Page{}
+	page.Current, _ = strconv.Atoi(c.DefaultQuery("current", "1"))
+	page.PageSize, _ = strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+	if page.Current <= 0 || page.PageSize <= 0 || page.PageSize > 500 {
+		page = system.Page{Current: 1, PageSize: 10}
+	}
+	list := logic.FL.GetFilmBlockAuditPage(&page)
+	system.Success(gin.H{"list": list, "page": page}, "操作记录获取成功", c)
+}
+
 //----------------------------------------------------影片分类处理----------------------------------------------------
 
 // FilmClassTree 影片分类树数据
@@ -179,6 +475,79 @@ func UpdateFilmClass(c *gin.Context) {
 	system.SuccessOnlyMsg("影片分类信息更新成功", c)
 }
 
+// AddFilmClass 添加自定义影片分类
+func AddFilmClass(c *gin.Context) {
+	var class = system.Category{}
+	if err := c.ShouldBindJSON(&class); err != nil {
+		system.Failed("添加失败, 请求参数异常", c)
+		return
+	}
+	res, err := logic.FL.AddClass(class)
+	if err != nil {
+		system.Failed(err.Error(), c)
+		return
+	}
+	spider.ClearCache()
+	system.Success(res, "影片分类添加成功", c)
+}
+
+// SortFilmClass 设置影片分类的排序值
+func SortFilmClass(c *gin.Context) {
+	var list []system.Category
+	if err := c.ShouldBindJSON(&list); err != nil || len(list) == 0 {
+		system.Failed("排序失败, 请求参数异常", c)
+		return
+	}
+	if err := logic.FL.SortClass(list); err != nil {
+		system.Failed(err.Error(), c)
+		return
+	}
+	spider.ClearCache()
+	system.SuccessOnlyMsg("影片分类排序已更新", c)
+}
+
+// MoveFilmClass 将影片分类移动到其他分类下
+func MoveFilmClass(c *gin.Context) {
+	id, err := strconv.ParseInt(c.DefaultQuery("id", ""), 10, 64)
+	if err != nil {
+		system.Failed("移动失败, 参数分类Id格式异常", c)
+		return
+	}
+	pid, err := strconv.ParseInt(c.DefaultQuery("pid", "0"), 10, 64)
+	if err != nil {
+		system.Failed("移动失败, 参数目标分类Id格式异常", c)
+		return
+	}
+	count, err := logic.FL.MoveClass(id, pid)
+	if err != nil {
+		system.Failed(fmt.Sprint("移动失败: ", err.Error()), c)
+		return
+	}
+	spider.ClearCache()
+	system.Success(gin.H{"count": count}, "影片分类移动成功", c)
+}
+
+// MergeFilmClass 将影片分类合并到目标分类
+func MergeFilmClass(c *gin.Context) {
+	from, err := strconv.ParseInt(c.DefaultQuery("from", ""), 10, 64)
+	if err != nil {
+		system.Failed("合并失败, 参数分类Id格式异常", c)
+		return
+	}
+	to, err := strconv.ParseInt(c.DefaultQuery("to", ""), 10, 64)
+	if err != nil {
+		system.Failed("合并失败, 参数目标分类Id格式异常", c)
+		return
+	}
+	count, err := logic.FL.MergeClass(from, to)
+	if err != nil {
+		system.Failed(fmt.Sprint("合并失败: ", err.Error()), c)
+		return
+	}
+	spider.ClearCache()
+	system.Success(gin.H{"count": count}, "影片分类合并成功", c)
+}
+
 // DelFilmClass 删除指定ID对应的影片分类
 func DelFilmClass(c *gin.Context) {
 	idStr := c.DefaultQuery("id", "")
@@ -197,5 +566,6 @@ func DelFilmClass(c *gin.Context) {
 		system.Failed(err.Error(), c)
 		return
 	}
+	spider.ClearCache()
 	system.SuccessOnlyMsg("当前分类已删除成功", c)
 }
@@ -1,10 +1,12 @@
 package controller
 
 import (
+	"server/config"
 	"server/logic"
 	"server/model/system"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,14 +19,14 @@ const (
 // Index 首页数据
 func Index(c *gin.Context) {
 	// 获取首页所需数据
-	data := logic.IL.IndexPage()
+	data := logic.IL.IndexPage(system.CurrentTenant(c))
 	system.Success(data, "首页数据获取成功", c)
 }
 
 // CategoriesInfo 分类信息获取
 func CategoriesInfo(c *gin.Context) {
 	//data := logic.IL.GetCategoryInfo()
-	data := logic.IL.GetNavCategory()
+	data := logic.IL.GetNavCategory(system.CurrentTenant(c))
 	if len(data) <= 0 {
 		system.Failed("暂无分类信息", c)
 		return
@@ -41,10 +43,10 @@ func FilmDetail(c *gin.Context) {
 		return
 	}
 	// 获取影片详情信息
-	detail := logic.IL.GetFilmDetail(id)
+	detail := logic.IL.GetFilmDetail(system.CurrentTenant(c), id)
 	// 获取相关推荐影片数据
 	page := system.Page{Current: 0, PageSize: 14}
-	relateMovie := logic.IL.RelateMovie(detail.MovieDetail, &page)
+	relateMovie := logic.IL.RelateMovie(system.CurrentTenant(c), detail.MovieDetail, &page)
 	system.Success(gin.H{
 		"detail": detail,
 		"relate": relateMovie,
@@ -62,7 +64,7 @@ func FilmPlayInfo(c *gin.Context) {
 		return
 	}
 	// 获取影片详情信息
-	detail := logic.IL.GetFilmDetail(id)
+	detail := logic.IL.GetFilmDetail(system.CurrentTenant(c), id)
 	// 如果 playFrom 为空, 则设置默认播放源和默认影片数据
 	if len(playFrom) <= 1 && len(detail.List) > 0 {
 		playFrom = detail.List[0].Id
@@ -75,27 +77,43 @@ func FilmPlayInfo(c *gin.Context) {
 			currentPlay = v.LinkList[episode]
 		}
 	}
+	// 当前集数的分集剧情
+	currentPlot := detail.GetEpisodePlot(currentPlay.Episode, episode)
 
 	// 推荐影片信息
 	page := system.Page{Current: 0, PageSize: 14}
-	relateMovie := logic.IL.RelateMovie(detail.MovieDetail, &page)
+	relateMovie := logic.IL.RelateMovie(system.CurrentTenant(c), detail.MovieDetail, &page)
 	system.Success(gin.H{
 		"detail":          detail,
 		"current":         currentPlay,
 		"currentPlayFrom": playFrom,
 		"currentEpisode":  episode,
+		"currentPlot":     currentPlot,
 		"relate":          relateMovie,
 	}, "影片播放信息获取成功", c)
 }
 
-// SearchFilm 通过片名模糊匹配库存中的信息
+// SearchFilm 通过关键字全文检索库存中的影片, 检索范围包含片名, 别名, 英文名, 主演, 导演以及简介
 func SearchFilm(c *gin.Context) {
 	keyword := c.DefaultQuery("keyword", "")
 	currStr := c.DefaultQuery("current", "1")
 	current, _ := strconv.Atoi(currStr)
-	page := system.Page{PageSize: 10, Current: current}
-	bl := logic.IL.SearchFilmInfo(strings.TrimSpace(keyword), &page)
+	page := system.Page{PageSize: 10, Current: max(current, 1)}
+	// highlight 为 1 时返回命中字段的高亮片段
+	highlight := c.DefaultQuery("highlight", "0") == "1"
+	bl := logic.IL.SearchFilmInfo(system.CurrentTenant(c), strings.TrimSpace(keyword), highlight, &page)
 	if page.Total <= 0 {
+		// 本地无搜索结果时在采集站中搜索影片, 前端可通过 /searchLookup 轮询搜索状态
+		if page.Current == 1 {
+			if l := logic.IL.LookupFilm(keyword); l != nil {
+				msg := "暂无相关影片信息"
+				if l.Status == system.LookupPending || l.Status == system.LookupRunning {
+					msg = "暂无相关影片信息, 正在从资源站中搜索"
+				}
+				system.FailedWithData(gin.H{"lookup": l}, msg, c)
+				return
+			}
+		}
 		system.Failed("暂无相关影片信息", c)
 		return
 	}
@@ -103,45 +121,170 @@ func SearchFilm(c *gin.Context) {
 	system.Success(gin.H{"list": bl, "page": page}, "影片搜索成功", c)
 }
 
+// SearchLookup 采集站搜索任务状态, status 为 done 且 found 大于 0 时可重新搜索获取新入库的影片
+func SearchLookup(c *gin.Context) {
+	l := logic.IL.GetLookup(strings.TrimSpace(c.DefaultQuery("keyword", "")))
+	if l == nil {
+		system.Failed("未找到对应的搜索任务", c)
+		return
+	}
+	system.Success(l, "搜索任务状态获取成功", c)
+}
+
+// SearchSuggest 搜索建议, 支持片名, 拼音以及拼音首字母前缀
+func SearchSuggest(c *gin.Context) {
+	keyword := strings.TrimSpace(c.DefaultQuery("keyword", ""))
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit <= 0 || limit > config.SuggestMaxCount {
+		limit = 10
+	}
+	system.Success(logic.IL.SearchSuggest(system.CurrentTenant(c), keyword, limit), "搜索建议获取成功", c)
+}
+
+// SearchHot 热门搜索, window 为 day(当日) | week(最近7天)
+func SearchHot(c *gin.Context) {
+	days := 1
+	if c.DefaultQuery("window", "day") == "week" {
+		days = 7
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit <= 0 || limit > config.SuggestMaxCount {
+		limit = 10
+	}
+	system.Success(logic.IL.GetHotKeywords(days, limit), "热门搜索获取成功", c)
+}
+
+// PersonDetail 影人信息
+func PersonDetail(c *gin.Context) {
+	id, err := strconv.ParseUint(c.DefaultQuery("id", ""), 10, 64)
+	if err != nil {
+		system.Failed("请求异常,影人请求参数异常!!!", c)
+		return
+	}
+	p := logic.IL.GetPerson(system.CurrentTenant(c), uint(id))
+	if p == nil {
+		system.Failed("暂无相关影人信息", c)
+		return
+	}
+	system.Success(p, "影人信息获取成功", c)
+}
+
+// PersonFilms 影人参与的影片, role 可选 actor | director | writer
+func PersonFilms(c *gin.Context) {
+	id, err := strconv.ParseUint(c.DefaultQuery("id", ""), 10, 64)
+	if err != nil {
+		system.Failed("请求异常,影人请求参数异常!!!", c)
+		return
+	}
+	current, _ := strconv.Atoi(c.DefaultQuery("current", "1"))
+	page := system.Page{PageSize: 49, Current: max(current, 1)}
+	list := logic.IL.GetPersonFilms(system.CurrentTenant(c), uint(id), c.DefaultQuery("role", ""), &page)
+	system.Success(gin.H{"list": list, "page": page}, "影人作品获取成功", c)
+}
+
+// PersonSearch 通过姓名检索影人
+func PersonSearch(c *gin.Context) {
+	keyword := strings.TrimSpace(c.DefaultQuery("keyword", ""))
+	if keyword == "" {
+		system.Failed("检索关键字不能为空", c)
+		return
+	}
+	current, _ := strconv.Atoi(c.DefaultQuery("current", "1"))
+	page := system.Page{PageSize: 10, Current: max(current, 1)}
+	list := logic.IL.SearchPerson(system.CurrentTenant(c), keyword, &page)
+	if page.Total <= 0 {
+		system.Failed("暂无相关影人信息", c)
+		return
+	}
+	system.Success(gin.H{"list": list, "page": page}, "影人搜索成功", c)
+}
+
+// FilmToday 今日更新的影片, pid 为空时包含所有分类
+func FilmToday(c *gin.Context) {
+	pid, _ := strconv.ParseInt(c.DefaultQuery("pid", "0"), 10, 64)
+	current, _ := strconv.Atoi(c.DefaultQuery("current", "1"))
+	page := system.Page{PageSize: 49, Current: max(current, 1)}
+	list := logic.IL.GetTodayFilms(system.CurrentTenant(c), pid, &page)
+	system.Success(gin.H{"list": list, "page": page}, "今日更新影片获取成功", c)
+}
+
+// FilmCalendar 连载中影片的每周放送表, pid 为空时包含所有分类
+func FilmCalendar(c *gin.Context) {
+	pid, _ := strconv.ParseInt(c.DefaultQuery("pid", "0"), 10, 64)
+	// 今天对应的星期, 周一为 1
+	today := (int(time.Now().Weekday())+6)%7 + 1
+	system.Success(gin.H{"days": logic.IL.GetAiringCalendar(system.CurrentTenant(c), pid), "today": today}, "放送表获取成功", c)
+}
+
+// ArticleList 文章资讯分页数据, 可通过标题关键字与分类名称筛选
+func ArticleList(c *gin.Context) {
+	current, _ := strconv.Atoi(c.DefaultQuery("current", "1"))
+	page := system.Page{PageSize: 20, Current: max(current, 1)}
+	list, types := logic.IL.GetArticleList(strings.TrimSpace(c.DefaultQuery("keyword", "")), c.DefaultQuery("type", ""), &page)
+	system.Success(gin.H{"list": list, "types": types, "page": page}, "文章列表获取成功", c)
+}
+
+// ArticleDetail 文章详情
+func ArticleDetail(c *gin.Context) {
+	id, err := strconv.ParseUint(c.DefaultQuery("id", ""), 10, 64)
+	if err != nil {
+		system.Failed("请求异常,文章请求参数异常!!!", c)
+		return
+	}
+	a := logic.IL.GetArticle(system.CurrentTenant(c), uint(id))
+	if a == nil {
+		system.Failed("暂无相关文章信息", c)
+		return
+	}
+	system.Success(a, "文章详情获取成功", c)
+}
+
 // FilmTagSearch 通过tag获取满足条件的对应影片
 func FilmTagSearch(c *gin.Context) {
-	params := system.SearchTagsVO{}
-	pidStr := c.DefaultQuery("Pid", "")
-	cidStr := c.DefaultQuery("Category", "")
-	yStr := c.DefaultQuery("Year", "")
-	if pidStr == "" {
+	// 获取请求参数, 筛选条件的多个值使用 , 分隔, 以 ! 开头的值为排除条件, 年份与评分支持 min-max 范围
+	raw := make(map[string]string)
+	for _, k := range []string{"Pid", "Category", "Plot", "Area", "Language", "Year", "Score", "Actor", "Status", "Quality", "Version", "Subtitle"} {
+		raw[k] = c.DefaultQuery(k, "")
+	}
+	raw["Sort"] = c.DefaultQuery("Sort", "update_stamp")
+	if raw["Pid"] == "" {
 		system.Failed("缺少分类信息", c)
 		return
 	}
-	params.Pid, _ = strconv.ParseInt(pidStr, 10, 64)
-	params.Cid, _ = strconv.ParseInt(cidStr, 10, 64)
-	params.Plot = c.DefaultQuery("Plot", "")
-	params.Area = c.DefaultQuery("Area", "")
-	params.Language = c.DefaultQuery("Language", "")
-	params.Year, _ = strconv.ParseInt(yStr, 10, 64)
-	params.Sort = c.DefaultQuery("Sort", "update_stamp")
+	params := system.SearchTagsVO{
+		Category: system.ParseTagFilter(raw["Category"]),
+		Plot:     system.ParseTagFilter(raw["Plot"]),
+		Area:     system.ParseTagFilter(raw["Area"]),
+		Language: system.ParseTagFilter(raw["Language"]),
+		Year:     system.ParseTagFilter(raw["Year"]),
+		Score:    system.ParseTagFilter(raw["Score"]),
+		Actor:    raw["Actor"],
+		Status:   system.ParseTagFilter(raw["Status"]),
+		Quality:  system.ParseTagFilter(raw["Quality"]),
+		Version:  system.ParseTagFilter(raw["Version"]),
+		Subtitle: system.ParseTagFilter(raw["Subtitle"]),
+		Sort:     raw["Sort"],
+	}
+	params.Pid, _ = strconv.ParseInt(raw["Pid"], 10, 64)
+	category := logic.IL.GetPidCategory(system.CurrentTenant(c), params.Pid)
+	if category == nil {
+		system.Failed("分类信息不存在", c)
+		return
+	}
 
 	// 设置分页信息
 	currentStr := c.DefaultQuery("current", "1")
 	current, _ := strconv.Atoi(currentStr)
 	page := system.Page{PageSize: 49, Current: current}
-	logic.IL.GetFilmsByTags(params, &page)
 	// 获取当前分类Title
-	// 返回对应信息
+	// 返回对应信息, facets 为当前筛选条件下各维度选项的影片数量
 	system.Success(gin.H{
-		"title":  logic.IL.GetPidCategory(params.Pid).Category,
-		"list":   logic.IL.GetFilmsByTags(params, &page),
-		"search": logic.IL.SearchTags(params.Pid),
-		"params": map[string]string{
-			"Pid":      pidStr,
-			"Category": cidStr,
-			"Plot":     params.Plot,
-			"Area":     params.Area,
-			"Language": params.Language,
-			"Year":     yStr,
-			"Sort":     params.Sort,
-		},
-		"page": page,
+		"title":  category.Category,
+		"list":   logic.IL.GetFilmsByTags(system.CurrentTenant(c), params, &page),
+		"search": logic.IL.SearchTags(system.CurrentTenant(c), params.Pid),
+		"facets": logic.IL.SearchFacets(system.CurrentTenant(c), params),
+		"params": raw,
+		"page":   page,
 	}, "分类影片数据获取成功", c)
 }
 
@@ -154,13 +297,13 @@ func FilmClassify(c *gin.Context) {
 	}
 	// 1. 顶部Title数据
 	pid, _ := strconv.ParseInt(pidStr, 10, 64)
-	title := logic.IL.GetPidCategory(pid)
+	title := logic.IL.GetPidCategory(system.CurrentTenant(c), pid)
 	// 2. 设置分页信息
 	page := system.Page{PageSize: 21, Current: 1}
 	// 3. 获取当前分类下的 最新上映, 排行榜, 最近更新 影片信息
 	system.Success(gin.H{
 		"title":   title,
-		"content": logic.IL.GetFilmClassify(pid, &page),
+		"content": logic.IL.GetFilmClassify(system.CurrentTenant(c), pid, &page),
 	}, "分类影片信息获取成功", c)
 }
 
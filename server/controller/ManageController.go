@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"server/logic"
 	"server/model/system"
-	"server/plugin/SystemInit"
 	"server/plugin/common/util"
 
 	"github.com/gin-gonic/gin"
@@ -19,7 +18,7 @@ func ManageIndex(c *gin.Context) {
 
 // SiteBasicConfig  网站基本配置
 func SiteBasicConfig(c *gin.Context) {
-	system.Success(logic.ML.GetSiteBasicConfig(), "网站基本信息获取成功", c)
+	system.Success(logic.ML.GetSiteBasicConfig(system.CurrentTenant(c).Id), "网站基本信息获取成功", c)
 }
 
 // UpdateSiteBasic 更新网站配置信息
@@ -42,7 +41,7 @@ func UpdateSiteBasic(c *gin.Context) {
 	}
 
 	// 保存更新后的配置信息
-	if err := logic.ML.UpdateSiteBasic(bc); err != nil {
+	if err := logic.ML.UpdateSiteBasic(system.CurrentTenant(c).Id, bc); err != nil {
 		system.Failed(fmt.Sprint("网站配置更新失败:  ", err), c)
 		return
 	}
@@ -51,8 +50,11 @@ func UpdateSiteBasic(c *gin.Context) {
 
 // ResetSiteBasic 重置网站配置信息为初始化状态
 func ResetSiteBasic(c *gin.Context) {
-	// 执行配置初始化方法直接覆盖当前基本配置信息
-	SystemInit.BasicConfigInit()
+	// 使用初始配置直接覆盖当前站点的基本配置信息
+	if err := logic.ML.ResetSiteBasic(system.CurrentTenant(c).Id); err != nil {
+		system.Failed(fmt.Sprint("网站配置重置失败:  ", err), c)
+		return
+	}
 	system.SuccessOnlyMsg("配置信息重置成功", c)
 }
 
@@ -60,7 +62,7 @@ func ResetSiteBasic(c *gin.Context) {
 
 // BannerList 获取轮播图数据
 func BannerList(c *gin.Context) {
-	bl := logic.ML.GetBanners()
+	bl := logic.ML.GetBanners(system.CurrentTenant(c).Id)
 	system.Success(bl, "配置信息重置成功", c)
 }
 
@@ -71,7 +73,7 @@ func BannerFind(c *gin.Context) {
 		system.Failed("Banner信息获取失败, ID信息异常", c)
 		return
 	}
-	bl := logic.ML.GetBanners()
+	bl := logic.ML.GetBanners(system.CurrentTenant(c).Id)
 	for _, b := range bl {
 		if b.Id == id {
 			system.Success(b, "Banner信息获取成功", c)
@@ -90,13 +92,13 @@ func BannerAdd(c *gin.Context) {
 	}
 	// 为新增的banner生成Id
 	b.Id = util.GenerateSalt()
-	bl := logic.ML.GetBanners()
+	bl := logic.ML.GetBanners(system.CurrentTenant(c).Id)
 	if len(bl) > 6 {
 		system.Failed("Banners最大阈值为6, 无法添加新的banner信息", c)
 		return
 	}
 	bl = append(bl, b)
-	if err := logic.ML.SaveBanners(bl); err != nil {
+	if err := logic.ML.SaveBanners(system.CurrentTenant(c).Id, bl); err != nil {
 		system.Failed(fmt.Sprintln("Banners信息添加失败,", err), c)
 		return
 	}
@@ -110,11 +112,11 @@ func BannerUpdate(c *gin.Context) {
 		system.Failed("Banner参数提交异常", c)
 		return
 	}
-	bl := logic.ML.GetBanners()
+	bl := logic.ML.GetBanners(system.CurrentTenant(c).Id)
 	for i, b := range bl {
 		if b.Id == banner.Id {
 			bl[i] = banner
-			if err := logic.ML.SaveBanners(bl); err != nil {
+			if err := logic.ML.SaveBanners(system.CurrentTenant(c).Id, bl); err != nil {
 				system.Failed("海报信息更新失败", c)
 			} else {
 				system.SuccessOnlyMsg("海报信息更新成功", c)
@@ -133,11 +135,11 @@ func BannerDel(c *gin.Context) {
 		system.Failed("Banner信息获取失败, ID信息异常", c)
 		return
 	}
-	bl := logic.ML.GetBanners()
+	bl := logic.ML.GetBanners(system.CurrentTenant(c).Id)
 	for i, b := range bl {
 		if b.Id == id {
 			bl = append(bl[:i], bl[i+1:]...)
-			_ = logic.ML.SaveBanners(bl)
+			_ = logic.ML.SaveBanners(system.CurrentTenant(c).Id, bl)
 			system.SuccessOnlyMsg("海报信息删除成功", c)
 			return
 		}
@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"fmt"
+	"server/logic"
+	"server/model/system"
+	"server/plugin/common/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ------------------------------------------------------ 多站点管理 ------------------------------------------------------
+
+// TenantList 获取所有站点信息
+func TenantList(c *gin.Context) {
+	system.Success(logic.TL.GetTenants(), "站点列表获取成功", c)
+}
+
+// TenantFind 通过Id获取站点信息
+func TenantFind(c *gin.Context) {
+	id := c.DefaultQuery("id", "")
+	if id == "" {
+		system.Failed("站点信息获取失败, 站点标识不能为空", c)
+		return
+	}
+	t, err := logic.TL.GetTenant(id)
+	if err != nil {
+		system.Failed(fmt.Sprint("站点信息获取失败: ", err.Error()), c)
+		return
+	}
+	system.Success(t, "站点信息获取成功", c)
+}
+
+// TenantAdd 添加站点
+func TenantAdd(c *gin.Context) {
+	var vo = system.TenantVo{}
+	if err := c.ShouldBindJSON(&vo); err != nil {
+		system.Failed("请求参数异常!!!", c)
+		return
+	}
+	if !util.ValidDomain(vo.Domain) && !util.ValidIPHost(vo.Domain) {
+		system.Failed("域名格式校验失败", c)
+		return
+	}
+	if len(vo.SiteName) <= 0 {
+		system.Failed("网站名称不能为空", c)
+		return
+	}
+	if err := logic.TL.AddTenant(vo); err != nil {
+		system.Failed(fmt.Sprint("站点添加失败: ", err.Error()), c)
+		return
+	}
+	system.SuccessOnlyMsg("站点添加成功", c)
+}
+
+// TenantUpdate 更新站点信息
+func TenantUpdate(c *gin.Context) {
+	var t = system.Tenant{}
+	if err := c.ShouldBindJSON(&t); err != nil {
+		system.Failed("请求参数异常!!!", c)
+		return
+	}
+	if err := logic.TL.UpdateTenant(t); err != nil {
+		system.Failed(fmt.Sprint("站点更新失败: ", err.Error()), c)
+		return
+	}
+	system.SuccessOnlyMsg("站点更新成功", c)
+}
+
+// TenantDel 删除站点
+func TenantDel(c *gin.Context) {
+	id := c.DefaultQuery("id", "")
+	if id == "" {
+		system.Failed("站点删除失败, 站点标识不能为空", c)
+		return
+	}
+	if err := logic.TL.DelTenant(id); err != nil {
+		system.Failed(fmt.Sprint("站点删除失败: ", err.Error()), c)
+		return
+	}
+	system.SuccessOnlyMsg("站点删除成功", c)
+}
@@ -44,7 +44,7 @@ func Logout(c *gin.Context) {
 		system.Failed("注销失败, 身份信息格式化异常!!!", c)
 		return
 	}
-	err := system.ClearUserToken(uc.UserID)
+	err := system.Repo.User.ClearToken(uc.UserID)
 	if err != nil {
 		log.Println("user logOut err: ", err)
 	}
@@ -0,0 +1,54 @@
+package logic
+
+import (
+	"io"
+	"server/model/system"
+	"server/plugin/SystemInit"
+	"server/plugin/backup"
+	"server/plugin/spider"
+)
+
+type BackupLogic struct {
+}
+
+var BL *BackupLogic
+
+// GetBackupList 获取备份文件列表
+func (bl *BackupLogic) GetBackupList() ([]backup.FileInfo, error) {
+	return backup.List()
+}
+
+// CreateBackup 创建指定范围的数据备份
+func (bl *BackupLogic) CreateBackup(scope string) (backup.FileInfo, error) {
+	return backup.Create(scope)
+}
+
+// RestoreBackup 从备份文件恢复指定范围的数据
+func (bl *BackupLogic) RestoreBackup(name, scope string) (backup.Result, error) {
+	if scope != backup.ScopeLibrary {
+		// 恢复配置数据会覆盖定时任务, 先移除当前运行中的定时任务, 恢复完成后按照新的任务信息重新注册
+		for _, task := range system.Repo.CronTask.All() {
+			spider.RemoveCron(task.Cid)
+		}
+		defer SystemInit.CollectCrontabInit()
+	}
+	// 恢复的分类树, 隐藏影片与屏蔽规则需立即生效
+	defer system.ResetFilmVisibility()
+	defer system.ResetBlockMatcher()
+	return backup.RestoreFile(name, scope)
+}
+
+// GetBackupPath 获取备份文件路径
+func (bl *BackupLogic) GetBackupPath(name string) (string, error) {
+	return backup.Path(name)
+}
+
+// UploadBackup 保存上传的备份文件
+func (bl *BackupLogic) UploadBackup(name string, src io.Reader) error {
+	return backup.Save(name, src)
+}
+
+// DelBackup 删除备份文件
+func (bl *BackupLogic) DelBackup(name string) error {
+	return backup.Remove(name)
+}
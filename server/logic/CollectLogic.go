@@ -16,28 +16,28 @@ var CollectL *CollectLogic
 // GetFilmSourceList 获取采集站列表数据
 func (cl *CollectLogic) GetFilmSourceList() []system.FilmSource {
 	// 返回当前已添加的采集站列表信息
-	return system.GetCollectSourceList()
+	return system.Repo.Source.List()
 }
 
 // GetFilmSource 获取ID对应的采集源信息
 func (cl *CollectLogic) GetFilmSource(id string) *system.FilmSource {
-	return system.FindCollectSourceById(id)
+	return system.Repo.Source.FindById(id)
 }
 
 // UpdateFilmSource 更新采集源信息
 func (cl *CollectLogic) UpdateFilmSource(s system.FilmSource) error {
-	return system.UpdateCollectSource(s)
+	return system.Repo.Source.Update(s)
 }
 
 // SaveFilmSource  保存采集源信息
 func (cl *CollectLogic) SaveFilmSource(s system.FilmSource) error {
-	return system.AddCollectSource(s)
+	return system.Repo.Source.Add(s)
 }
 
 // DelFilmSource  删除采集源信息
 func (cl *CollectLogic) DelFilmSource(id string) error {
 	// 先查找是否存在对应ID的站点信息
-	s := system.FindCollectSourceById(id)
+	s := system.Repo.Source.FindById(id)
 	if s == nil {
 		return errors.New("当前资源站信息不存在, 请勿重复操作")
 	}
@@ -45,7 +45,7 @@ func (cl *CollectLogic) DelFilmSource(id string) error {
 	if s.Grade == system.MasterCollect {
 		return errors.New("主站点无法直接删除, 请先降级为附属站点再进行删除")
 	}
-	system.DelCollectResource(id)
+	system.Repo.Source.Delete(id)
 	return nil
 }
 
@@ -53,7 +53,7 @@ func (cl *CollectLogic) DelFilmSource(id string) error {
 
 // GetRecordList 获取采集记录列表
 func (cl *CollectLogic) GetRecordList(params system.RecordRequestVo) []system.FailureRecord {
-	return system.FailureRecordList(params)
+	return system.Repo.Record.List(params)
 }
 
 // GetRecordOptions 获取采集记录筛选参数
@@ -64,7 +64,7 @@ func (cl *CollectLogic) GetRecordOptions() system.OptionGroup {
 	options["status"] = []system.Option{{"全部", -1}, {"待重试", 1}, {"已处理", 0}}
 	// 获取全部采集站
 	var originOptions = []system.Option{{"全部", ""}}
-	for _, v := range system.GetCollectSourceList() {
+	for _, v := range system.Repo.Source.List() {
 		originOptions = append(originOptions, system.Option{Name: v.Name, Value: v.Id})
 	}
 	options["origin"] = originOptions
@@ -74,7 +74,7 @@ func (cl *CollectLogic) GetRecordOptions() system.OptionGroup {
 // CollectRecover 恢复采集
 func (cl *CollectLogic) CollectRecover(id int) error {
 	// 通过ID获取完整的失败记录信息
-	fr := system.FindRecordById(uint(id))
+	fr := system.Repo.Record.FindById(uint(id))
 	// 如果获取失败记录信息为空, 则不进行后续操作
 	if fr == nil {
 		return errors.New("采集重试执行失败: 失败记录信息获取异常")
@@ -96,11 +96,11 @@ func (cl *CollectLogic) RecoverAll() {
 // ClearDoneRecord 清除已处理完成的记录信息	(将记录表中已经完成处理的记录删除)
 func (cl *CollectLogic) ClearDoneRecord() {
 	//  <逻辑删除 or 真实删除> 为避免ID中断暂定逻辑删除
-	system.DelDoneRecord()
+	system.Repo.Record.DelDone()
 }
 
 // ClearAllRecord 清除所有记录信息	(直接对记录表直接进行截断处理)
 func (cl *CollectLogic) ClearAllRecord() {
 	// 重置记录表状态, 删除所有数据并将自增ID归零
-	system.TruncateRecordTable()
+	system.Repo.Record.Truncate()
 }
@@ -50,7 +50,7 @@ func (cl *CronLogic) AddFilmCrontab(cv system.FilmCronVo) error {
 		task.Cid = cid
 	}
 	// 如果没有异常则将当前定时任务信息记录到redis中
-	system.SaveFilmTask(task)
+	system.Repo.CronTask.Save(task)
 	return nil
 }
 
@@ -59,7 +59,7 @@ func (cl *CronLogic) GetFilmCrontab() []system.CronTaskVo {
 	// 获取东八区上海时区
 	cst := time.FixedZone("UTC", 8*3600)
 	var l []system.CronTaskVo
-	tl := system.GetAllFilmTask()
+	tl := system.Repo.CronTask.All()
 	for _, t := range tl {
 		e := spider.GetEntryById(t.Cid)
 		taskVo := system.CronTaskVo{FilmCollectTask: t, PreV: e.Prev.In(cst).Format(time.DateTime), Next: e.Next.In(cst).Format(time.DateTime)}
@@ -70,7 +70,7 @@ func (cl *CronLogic) GetFilmCrontab() []system.CronTaskVo {
 
 // GetFilmCrontabById 通过ID获取对应的定时任务信息
 func (cl *CronLogic) GetFilmCrontabById(id string) (system.FilmCollectTask, error) {
-	t, err := system.GetFilmTaskById(id)
+	t, err := system.Repo.CronTask.FindById(id)
 	//e := spider.GetEntryById(t.Cid)
 	//taskVo := system.CronTaskVo{FilmCollectTask: t, PreV: e.Prev.Format(time.DateTime), Next: e.Next.Format(time.DateTime)}
 	return t, err
@@ -79,31 +79,31 @@ func (cl *CronLogic) GetFilmCrontabById(id string) (system.FilmCollectTask, erro
 // ChangeFilmCrontab 改变定时任务的状态 开启 | 停止
 func (cl *CronLogic) ChangeFilmCrontab(id string, state bool) error {
 	// 通过定时任务信息的唯一标识获取对应的定时任务信息
-	ft, err := system.GetFilmTaskById(id)
+	ft, err := system.Repo.CronTask.FindById(id)
 	if err != nil {
 		return errors.New(fmt.Sprintf("定时任务停止失败: %s", err.Error()))
 	}
 	// 修改当前定时任务的状态为 false, 则在定时执行方法时不会执行具体逻辑
 	ft.State = state
-	system.UpdateFilmTask(ft)
+	system.Repo.CronTask.Update(ft)
 	return err
 }
 
 // UpdateFilmCron 更新定时任务的状态信息
 func (cl *CronLogic) UpdateFilmCron(t system.FilmCollectTask) {
-	system.UpdateFilmTask(t)
+	system.Repo.CronTask.Update(t)
 }
 
 // DelFilmCrontab 删除定时任务
 func (cl *CronLogic) DelFilmCrontab(id string) error {
 	// 通过定时任务信息的唯一Id标识获取对应的定时任务信息
-	ft, err := system.GetFilmTaskById(id)
+	ft, err := system.Repo.CronTask.FindById(id)
 	if err != nil {
 		return errors.New(fmt.Sprintf("定时任务删除失败: %s", err.Error()))
 	}
 	// 通过定时任务EntryID移出对应的定时任务
 	spider.RemoveCron(ft.Cid)
 	// 将定时任务相关信息删除
-	system.DelFilmTask(id)
+	system.Repo.CronTask.Delete(id)
 	return nil
 }
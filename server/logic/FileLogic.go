@@ -20,7 +20,7 @@ func (fl *FileLogic) SingleFileUpload(fileName string, uid int) string {
 	f.Fid = strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName))
 	f.FileType = strings.TrimPrefix(filepath.Ext(fileName), ".")
 	// 记录图片信息到系统表中
-	system.SaveGallery(f)
+	system.Repo.File.Save(f)
 	return f.Link
 }
 
@@ -28,19 +28,19 @@ func (fl *FileLogic) SingleFileUpload(fileName string, uid int) string {
 func (fl *FileLogic) GetPhotoPage(page *system.Page) []system.FileInfo {
 	// 设置必要参数
 	var tl = []string{"jpeg", "jpg", "png", "webp"}
-	return system.GetFileInfoPage(tl, page)
+	return system.Repo.File.Page(tl, page)
 }
 
 // RemoveFileById 删除文件信息
 func (fl *FileLogic) RemoveFileById(id uint) error {
 	// 首先获取对应图片信息
-	f := system.GetFileInfoById(id)
+	f := system.Repo.File.FindById(id)
 	// 通过f删除本地图片
 	err := util.RemoveFile(f.StoragePath())
 	if err != nil {
 		return err
 	}
 	// 删除图片的关联信息
-	system.DelFileInfo(id)
+	system.Repo.File.Delete(id)
 	return err
 }
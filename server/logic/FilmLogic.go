@@ -1,10 +1,19 @@
 package logic
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
+	"path/filepath"
+	"server/config"
 	"server/model/system"
 	"server/plugin/common/conver"
+	"server/plugin/common/util"
+	"server/plugin/spider"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -22,7 +31,7 @@ var FL *FilmLogic
 // GetFilmPage 获取影片检索信息分页数据
 func (fl *FilmLogic) GetFilmPage(s system.SearchVo) []system.SearchInfo {
 	// 获取影片检索信息分页数据
-	sl := system.GetSearchPage(s)
+	sl := system.Repo.Search.Page(s)
 	return sl
 }
 
@@ -30,7 +39,7 @@ func (fl *FilmLogic) GetFilmPage(s system.SearchVo) []system.SearchInfo {
 func (fl *FilmLogic) GetSearchOptions() map[string]any {
 	var options = make(map[string]any)
 	// 获取分类 options
-	tree := system.GetCategoryTree()
+	tree := system.Repo.Film.GetCategoryTree()
 	tree.Name = "全部分类"
 	options["class"] = conver.ConvertCategoryList(tree)
 	options["remarks"] = []map[string]string{{"Name": `全部`, "Value": ``}, {"Name": `完结`, "Value": `完结`}, {"Name": `未完结`, "Value": `未完结`}}
@@ -38,9 +47,9 @@ func (fl *FilmLogic) GetSearchOptions() map[string]any {
 	var tagGroup = make(map[int64]map[string]any)
 	// 遍历一级分类获取对应的标签组信息
 	for _, t := range tree.Children {
-		option := system.GetSearchOptions(t.Id)
+		option := system.Repo.Search.Options(t.Id)
 		if len(option) > 0 {
-			tagGroup[t.Id] = system.GetSearchOptions(t.Id)
+			tagGroup[t.Id] = system.Repo.Search.Options(t.Id)
 			// 如果年份信息不存在则独立一份年份信息
 			if _, ok := options["year"]; !ok {
 				options["year"] = tagGroup[t.Id]["Year"]
@@ -58,9 +67,13 @@ func (fl *FilmLogic) SaveFilmDetail(fd system.FilmDetailVo) error {
 	now := time.Now()
 	fd.UpdateTime = now.Format(time.DateTime)
 	fd.AddTime = fd.UpdateTime
-	// 生成ID, 由于是自定义上传的影片, 避免和采集站点的影片冲突, 以当前时间时间戳作为ID
+	// 生成ID, 由于是自定义上传的影片, 避免和采集站点的影片冲突, 使用本地影片的ID区间
 	if fd.Id == 0 {
-		fd.Id = now.Unix()
+		id, err := system.Repo.Film.NextLocalId()
+		if err != nil {
+			return fmt.Errorf("影片ID生成失败: %s", err.Error())
+		}
+		fd.Id = id
 	}
 	// 生成影片详情信息
 	detail, err := conver.CovertFilmDetailVo(fd)
@@ -69,119 +82,859 @@ func (fl *FilmLogic) SaveFilmDetail(fd system.FilmDetailVo) error {
 	}
 
 	// 保存影片信息
-	return system.SaveDetail(detail)
+	return system.Repo.Film.SaveDetail(detail)
+}
+
+// ImportFilm 解析导入文件并校验影片信息, preview 为 false 时通过采集入库流程保存校验通过的影片
+func (fl *FilmLogic) ImportFilm(format, filename string, data []byte, base system.FilmDetailVo, preview bool) (*system.FilmImportResult, error) {
+	if format == "" {
+		format = conver.ImportFormat(filename)
+	}
+	// 播放列表文件未指定片名时使用文件名作为片名
+	if base.Name == "" && (format == conver.ImportM3U || format == conver.ImportText) {
+		base.Name = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	}
+	films, err := conver.ParseImportFile(format, data, base)
+	if err != nil {
+		return nil, err
+	}
+	if len(films) == 0 {
+		return nil, errors.New("导入文件中未解析到影片信息")
+	}
+	res := &system.FilmImportResult{Format: format, Preview: preview, Total: len(films)}
+	tree := system.Repo.Film.GetCategoryTree()
+	now := time.Now().Format(time.DateTime)
+	ids := make(map[int64]bool)
+	var list []system.MovieDetail
+	for i, f := range films {
+		f.Film.UpdateTime, f.Film.AddTime = now, now
+		detail, _ := conver.CovertFilmDetailVo(f.Film)
+		item := system.FilmImportItem{Index: i + 1, Detail: detail, Errors: f.Errors}
+		item.Errors = append(item.Errors, validImportFilm(&item.Detail, tree)...)
+		if detail.Id != 0 {
+			if ids[detail.Id] {
+				item.Errors = append(item.Errors, fmt.Sprintf("影片ID %d 重复", detail.Id))
+			}
+			ids[detail.Id] = true
+		}
+		if len(item.Errors) == 0 {
+			res.Valid++
+			if !preview && item.Detail.Id == 0 {
+				if item.Detail.Id, err = system.Repo.Film.NextLocalId(); err != nil {
+					return nil, fmt.Errorf("影片ID生成失败: %s", err.Error())
+				}
+			}
+			list = append(list, item.Detail)
+		}
+		res.Items = append(res.Items, item)
+	}
+	if preview || len(list) == 0 {
+		return res, nil
+	}
+	// 与采集的影片使用相同的入库流程: 屏蔽规则过滤 => 保存详情 => 同步检索信息
+	saved := make(map[int64]bool)
+	for _, d := range system.Repo.Block.Filter(config.LocalSourceId, list) {
+		saved[d.Id] = true
+	}
+	list = list[:0]
+	for i, item := range res.Items {
+		if len(item.Errors) > 0 {
+			continue
+		}
+		if !saved[item.Detail.Id] {
+			res.Items[i].Errors = append(res.Items[i].Errors, "影片匹配屏蔽规则, 已跳过")
+			continue
+		}
+		list = append(list, item.Detail)
+	}
+	if len(list) > 0 {
+		if err = system.Repo.Film.SaveDetails(list); err != nil {
+			return nil, fmt.Errorf("影片保存失败: %s", err.Error())
+		}
+		system.Repo.Search.Sync(1)
+		spider.ClearCache()
+	}
+	res.Saved = len(list)
+	return res, nil
+}
+
+// validImportFilm 校验导入的影片信息并补全分类信息
+func validImportFilm(d *system.MovieDetail, tree system.CategoryTree) []string {
+	var errs []string
+	if strings.TrimSpace(d.Name) == "" {
+		errs = append(errs, "片名不能为空")
+	}
+	if d.Id != 0 && d.Id < config.LocalFilmIdStart {
+		errs = append(errs, fmt.Sprintf("本地影片ID需大于等于 %d", config.LocalFilmIdStart))
+	}
+	if c := tree.Find(d.Cid); c == nil || c.Id == tree.Id {
+		errs = append(errs, fmt.Sprintf("分类 %d 不存在", d.Cid))
+	} else {
+		d.Pid, _ = tree.RootId(c.Id)
+		d.CName = c.Name
+	}
+	if len(d.PlayList) == 0 {
+		errs = append(errs, "缺少有效的播放地址, 仅支持 m3u8 与 mp4 格式")
+	}
+	for _, pl := range d.PlayList {
+		for _, e := range pl {
+			if !strings.HasPrefix(e.Link, "http://") && !strings.HasPrefix(e.Link, "https://") || !util.ValidURL(e.Link) {
+				errs = append(errs, fmt.Sprintf("%s 的播放地址异常: %s", e.Episode, e.Link))
+			}
+		}
+	}
+	return errs
 }
 
-// DelFilm 删除分类影片
-func (fl *FilmLogic) DelFilm(id int64) error {
+// DelFilm 删除影片, 影片检索信息逻辑删除后移入回收站
+func (fl *FilmLogic) DelFilm(id int64, operator string) error {
 	// 通过id查询对应影片信息是否存在
-	s := system.GetSearchInfoById(id)
+	s := system.Repo.Search.FindById(id)
 	if s == nil {
 		return errors.New("影片信息不存在")
 	}
-	// 通过id删除对应影片信息
-	return system.DelFilmSearch(id)
+	return deleteFilm(*s, operator)
 }
 
-//----------------------------------------------------影片分类业务逻辑----------------------------------------------------
+// deleteFilm 逻辑删除影片检索信息并移入回收站
+func deleteFilm(s system.SearchInfo, operator string) error {
+	if err := system.Repo.Search.Delete(int64(s.ID)); err != nil {
+		return err
+	}
+	return system.Repo.Recycle.Save(system.FilmRecycle{Mid: s.Mid, Cid: s.Cid, Pid: s.Pid, Name: s.Name, CName: s.CName, Operator: operator})
+}
 
-// GetFilmClassTree 获取影片分类信息
-func (fl *FilmLogic) GetFilmClassTree() system.CategoryTree {
-	// 获取原本的影片分类信息
-	return system.GetCategoryTree()
+//----------------------------------------------------回收站 & 屏蔽规则----------------------------------------------------
+
+// GetRecyclePage 获取回收站影片分页数据
+func (fl *FilmLogic) GetRecyclePage(name string, page *system.Page) []system.FilmRecycle {
+	return system.Repo.Recycle.List(name, page)
 }
 
-// GetFilmClassById 通过ID获取影片分类信息
-func (fl *FilmLogic) GetFilmClassById(id int64) *system.CategoryTree {
-	tree := system.GetCategoryTree()
-	for _, c := range tree.Children {
-		// 如果是一级分类, 则相等时直接返回
-		if c.Id == id {
-			return c
-		}
-		// 如果当前分类含有子分类, 则继续遍历匹配
-		if c.Children != nil {
-			for _, subC := range c.Children {
-				if subC.Id == id {
-					return subC
-				}
+// RestoreFilm 从回收站中恢复影片
+func (fl *FilmLogic) RestoreFilm(id uint) error {
+	fr := system.Repo.Recycle.FindById(id)
+	if fr == nil {
+		return errors.New("回收站中不存在该影片")
+	}
+	if err := restoreFilm(*fr); err != nil {
+		return err
+	}
+	spider.ClearCache()
+	return nil
+}
+
+// restoreFilm 恢复影片检索信息并移除回收站记录
+func restoreFilm(fr system.FilmRecycle) error {
+	if err := system.Repo.Recycle.Restore(fr.Mid); err != nil {
+		return err
+	}
+	return system.Repo.Recycle.Delete(fr.ID)
+}
+
+// PurgeFilm 彻底删除回收站中的影片, 影片后续仍可能被重新采集, 需永久下架请添加屏蔽规则
+func (fl *FilmLogic) PurgeFilm(id uint) error {
+	fr := system.Repo.Recycle.FindById(id)
+	if fr == nil {
+		return errors.New("回收站中不存在该影片")
+	}
+	if err := system.Repo.Recycle.Purge(fr.Mid, fr.Cid); err != nil {
+		return err
+	}
+	return system.Repo.Recycle.Delete(id)
+}
+
+// AddFilmBlock 添加屏蔽规则, 并在后台下架已入库的匹配影片
+func (fl *FilmLogic) AddFilmBlock(b system.FilmBlock, operator string) error {
+	if err := b.Valid(); err != nil {
+		return err
+	}
+	b.Operator = operator
+	if err := system.Repo.Block.Add(&b); err != nil {
+		return err
+	}
+	go takedownFilms(b)
+	return nil
+}
+
+// DelFilmBlock 删除屏蔽规则, 已下架的影片在后续采集时重新入库
+func (fl *FilmLogic) DelFilmBlock(id uint, operator string) error {
+	return system.Repo.Block.Delete(id, operator)
+}
+
+// GetFilmBlockPage 获取屏蔽规则分页数据
+func (fl *FilmLogic) GetFilmBlockPage(t string, page *system.Page) []system.FilmBlock {
+	return system.Repo.Block.List(t, page)
+}
+
+// GetFilmBlockAuditPage 获取屏蔽规则操作记录分页数据
+func (fl *FilmLogic) GetFilmBlockAuditPage(page *system.Page) []system.FilmBlockAudit {
+	return system.Repo.Block.Audits(page)
+}
+
+// takedownFilms 彻底删除已入库的匹配屏蔽规则的影片以及附属站点中对应的播放源
+func takedownFilms(b system.FilmBlock) {
+	m := system.NewBlockMatcher([]system.FilmBlock{b})
+	// 检索信息中包含主站点与本地添加的影片, 按照影片ID区分所属的采集站
+	masterId := ""
+	if master := system.Repo.Source.ListByGrade(system.MasterCollect); len(master) > 0 {
+		masterId = master[0].Id
+	}
+	// 先收集匹配的影片再删除, 避免分批扫描过程中删除数据导致遗漏
+	var matched []system.SearchInfo
+	err := system.Repo.Search.Scan(func(list []system.SearchInfo) {
+		for _, s := range list {
+			d := system.MovieDetail{Id: s.Mid, Name: s.Name}
+			// 检索信息中不包含豆瓣ID, 需从影片详情中获取
+			if b.Type == system.BlockDbId {
+				d = system.Repo.Film.GetOriginDetailByKey(fmt.Sprintf(config.MovieDetailKey, s.Cid, s.Mid))
 			}
+			sourceId := masterId
+			if s.Mid >= config.LocalFilmIdStart {
+				sourceId = config.LocalSourceId
+			}
+			if m.Match(sourceId, d) {
+				matched = append(matched, s)
+			}
+		}
+	})
+	if err != nil {
+		log.Println("Takedown Film Error: ", err)
+	}
+	var count int
+	// 彻底删除影片时一并删除附属站点中匹配该影片的播放源
+	for _, s := range matched {
+		if err = system.Repo.Recycle.Purge(s.Mid, s.Cid); err != nil {
+			log.Println("Takedown Film Error: ", err)
+			continue
+		}
+		count++
+	}
+	// 附属站点仅保存了播放列表, 屏蔽附属站点中的影片时需先获取影片信息, 再删除该站点中对应的播放源
+	if s := system.Repo.Source.FindById(b.SourceId); b.Type == system.BlockSource && s != nil && s.Grade == system.SlaveCollect {
+		list, err := spider.FetchFilms(s, strconv.FormatInt(b.VodId, 10))
+		if err != nil {
+			log.Println("Takedown Film Error: ", err)
+		}
+		for _, d := range list {
+			if err = system.Repo.Film.DelMultiplePlay(d, s.Id); err != nil {
+				log.Println("Takedown Film Error: ", err)
+				continue
+			}
+			count++
+		}
+	}
+	if count > 0 {
+		spider.ClearCache()
+	}
+	log.Printf("[Block] 规则 %s 已下架 %d 部影片\n", b.String(), count)
+}
+
+// GetFilmEdit 获取影片的编辑信息, 包含采集的原始数据, 应用编辑后的数据以及覆盖层
+func (fl *FilmLogic) GetFilmEdit(mid int64) (map[string]any, error) {
+	s := system.Repo.Search.FindByMid(mid)
+	if s == nil {
+		return nil, errors.New("影片信息不存在")
+	}
+	key := fmt.Sprintf(config.MovieDetailKey, s.Cid, s.Mid)
+	overlay := system.Repo.Film.GetOverlay(mid)
+	if overlay == nil {
+		overlay = &system.FilmOverlay{Mid: mid}
+	}
+	return map[string]any{
+		"origin":  system.Repo.Film.GetOriginDetailByKey(key),
+		"detail":  system.Repo.Film.GetDetailByKey(key),
+		"overlay": overlay,
+		"fields":  system.FilmEditableFields(),
+	}, nil
+}
+
+// UpdateFilm 编辑影片信息, 修改内容保存为覆盖层, 锁定的字段在重新采集时不会被覆盖
+func (fl *FilmLogic) UpdateFilm(vo system.FilmEditVo) error {
+	s := system.Repo.Search.FindByMid(vo.Id)
+	if s == nil {
+		return errors.New("影片信息不存在")
+	}
+	key := fmt.Sprintf(config.MovieDetailKey, s.Cid, s.Mid)
+	o := system.FilmOverlay{Mid: vo.Id, Fields: make(map[string]json.RawMessage)}
+	if old := system.Repo.Film.GetOverlay(vo.Id); old != nil {
+		o.Locks = old.Locks
+		for k, v := range old.Fields {
+			o.Fields[k] = v
+		}
+	}
+	// 合并本次修改, 值为 null 表示撤销该字段的修改
+	var edited []string
+	for k, v := range vo.Fields {
+		if string(v) == "null" {
+			delete(o.Fields, k)
+			continue
+		}
+		o.Fields[k] = v
+		edited = append(edited, k)
+	}
+	if vo.Locks != nil {
+		o.Locks = vo.Locks
+	} else {
+		for _, k := range edited {
+			if !o.Locked(k) {
+				o.Locks = append(o.Locks, k)
+			}
+		}
+	}
+	if err := system.ValidFilmOverlay(o); err != nil {
+		return err
+	}
+	// 锁定未修改的字段时固定其当前的采集值, 避免后续采集覆盖
+	origin := system.Repo.Film.GetOriginDetailByKey(key)
+	for _, k := range o.Locks {
+		if _, ok := o.Fields[k]; !ok {
+			o.Fields[k], _ = system.FilmFieldValue(origin, k)
 		}
 	}
+	if err := system.Repo.Film.SaveOverlay(o); err != nil {
+		return err
+	}
+	return syncFilmSearch(key)
+}
+
+// ResetFilmEdit 撤销影片的所有编辑信息, 恢复为采集的原始数据
+func (fl *FilmLogic) ResetFilmEdit(mid int64) error {
+	s := system.Repo.Search.FindByMid(mid)
+	if s == nil {
+		return errors.New("影片信息不存在")
+	}
+	system.Repo.Film.DelOverlay(mid)
+	return syncFilmSearch(fmt.Sprintf(config.MovieDetailKey, s.Cid, s.Mid))
+}
+
+// syncFilmSearch 使用应用编辑信息后的影片数据更新检索信息, 并清除首页缓存
+func syncFilmSearch(key string) error {
+	detail := system.Repo.Film.GetDetailByKey(key)
+	info := system.ConvertSearchInfo(detail)
+	if err := system.Repo.Search.Update(info); err != nil {
+		return err
+	}
+	system.SaveSearchTag(info)
+	if err := system.Repo.Person.Link(detail); err != nil {
+		return err
+	}
+	if err := system.Repo.Search.Index(detail); err != nil {
+		return err
+	}
+	spider.ClearCache()
 	return nil
 }
 
-// UpdateClass 更新分类信息
-func (fl *FilmLogic) UpdateClass(class system.CategoryTree) error {
-	// 遍历影片分类信息
-	tree := system.GetCategoryTree()
-	for _, c := range tree.Children {
-		// 如果是一级分类, 则相等时直接修改对应的name和show属性
-		if c.Id == class.Id {
-			if class.Name != "" {
-				c.Name = class.Name
-			}
-			c.Show = class.Show
-			if err := system.SaveCategoryTree(&tree); err != nil {
-				return fmt.Errorf("影片分类信息更新失败: %s", err.Error())
-			}
-			return nil
-		}
-		// 如果当前分类含有子分类, 则继续遍历匹配
-		if c.Children != nil {
-			for _, subC := range c.Children {
-				if subC.Id == class.Id {
-					if class.Name != "" {
-						subC.Name = class.Name
+//----------------------------------------------------影片批量操作----------------------------------------------------
+
+// BatchFilm 创建影片批量操作任务并在后台执行, 返回任务信息
+func (fl *FilmLogic) BatchFilm(vo system.FilmBatchVo, operator string) (*system.FilmBatchJob, error) {
+	// 校验操作参数
+	switch vo.Action {
+	case system.BatchDelete, system.BatchRestore, system.BatchVisible, system.BatchRecollect:
+	case system.BatchCategory:
+		tree := system.Repo.Film.GetCategoryTree()
+		if c := tree.Find(vo.Cid); c == nil || c.Id == tree.Id {
+			return nil, errors.New("目标分类不存在")
+		}
+	case system.BatchPoster:
+		if vo.Picture == "" {
+			return nil, errors.New("海报图片地址不能为空")
+		}
+	default:
+		return nil, fmt.Errorf("批量操作类型异常: %s", vo.Action)
+	}
+	// 获取需要处理的影片ID, 未指定ID时通过筛选条件查询
+	mids := vo.Ids
+	if len(mids) == 0 {
+		if vo.Filter == nil {
+			return nil, errors.New("影片ID和筛选条件不能同时为空")
+		}
+		var err error
+		if mids, err = system.Repo.Search.FindMids(*vo.Filter, vo.Action == system.BatchRestore); err != nil {
+			return nil, fmt.Errorf("影片筛选失败: %s", err.Error())
+		}
+	}
+	if len(mids) == 0 {
+		return nil, errors.New("没有需要处理的影片")
+	}
+	vo.Ids = nil
+	job := system.NewFilmBatchJob(util.GenerateSalt(), vo, len(mids), operator)
+	if err := system.Repo.Batch.Save(job); err != nil {
+		return nil, fmt.Errorf("批量操作任务创建失败: %s", err.Error())
+	}
+	system.Repo.Batch.Prune()
+	go runFilmBatch(job, mids)
+	return &job, nil
+}
+
+// GetFilmBatchJobs 获取批量操作任务列表
+func (fl *FilmLogic) GetFilmBatchJobs() []system.FilmBatchJob {
+	return system.Repo.Batch.List()
+}
+
+// GetFilmBatchJob 获取批量操作任务的执行进度与结果
+func (fl *FilmLogic) GetFilmBatchJob(id string) *system.FilmBatchJob {
+	return system.Repo.Batch.FindById(id)
+}
+
+// runFilmBatch 逐部执行影片批量操作并记录执行进度
+func runFilmBatch(job system.FilmBatchJob, mids []int64) {
+	save := func() {
+		if err := system.Repo.Batch.Save(job); err != nil {
+			log.Println("Save Film Batch Job Error: ", err)
+		}
+	}
+	// 重新采集按批次请求采集站, 其余操作逐部执行
+	if job.Action == system.BatchRecollect {
+		for i := 0; i < len(mids); i += config.FilmBatchRecollectSize {
+			chunk := mids[i:min(i+config.FilmBatchRecollectSize, len(mids))]
+			// 本地添加与导入的影片不存在于采集站中, 不请求采集站
+			var ids []int64
+			for _, mid := range chunk {
+				if mid >= config.LocalFilmIdStart {
+					job.Fail(mid, errors.New("本地添加与导入的影片不支持重新采集"))
+					continue
+				}
+				ids = append(ids, mid)
+			}
+			if len(ids) > 0 {
+				// 仅采集站返回并成功保存的影片计为成功
+				res, err := spider.CollectFilms(ids)
+				for _, mid := range ids {
+					e := err
+					if e == nil {
+						e = res[mid]
 					}
-					// 如果所属分类为二级分类且show属性进行了变化
-					if class.Show {
-						// 如果show为ture则将其分类下的影片信息进行恢复
-						if err := system.RecoverFilmSearch(class.Id); err != nil {
-							return err
-						}
+					if e != nil {
+						job.Fail(mid, e)
 					} else {
-						// 如果show为false则将其分类下的影片信息进行屏蔽
-						if err := system.ShieldFilmSearch(class.Id); err != nil {
-							return err
-						}
-					}
-					subC.Show = class.Show
-					if err := system.SaveCategoryTree(&tree); err != nil {
-						return fmt.Errorf("影片分类信息更新失败: %s", err.Error())
+						job.Success++
 					}
-					return nil
 				}
 			}
+			job.Done += len(chunk)
+			save()
+		}
+	} else {
+		for i, mid := range mids {
+			if err := batchFilm(job.Params, mid, job.Operator); err != nil {
+				job.Fail(mid, err)
+			} else {
+				job.Success++
+			}
+			job.Done++
+			// 每处理一定数量的影片更新一次进度
+			if (i+1)%config.MaxScanCount == 0 {
+				save()
+			}
 		}
 	}
-	return errors.New("需要更新的分类信息不存在")
+	job.Status = system.BatchFinished
+	job.EndTime = time.Now().Unix()
+	save()
+	if job.Success > 0 {
+		spider.ClearCache()
+	}
+	log.Printf("[Batch] 批量操作 %s 执行完成, 成功: %d, 失败: %d\n", job.Action, job.Success, job.Failed)
 }
 
-// DelClass 删除分类信息
-func (fl *FilmLogic) DelClass(id int64) error {
-	tree := system.GetCategoryTree()
-	for i, c := range tree.Children {
-		// 如果是一级分类, 则相等时直接返回
-		if c.Id == id {
-			tree.Children = append(tree.Children[:i], tree.Children[i+1:]...)
-			if err := system.SaveCategoryTree(&tree); err != nil {
-				return fmt.Errorf("影片分类信息删除失败: %s", err.Error())
-			}
-			return nil
-		}
-		// 如果当前分类含有子分类, 则继续遍历匹配
-		if c.Children != nil {
-			for j, subC := range c.Children {
-				if subC.Id == id {
-					c.Children = append(c.Children[:j], c.Children[j+1:]...)
-					if err := system.SaveCategoryTree(&tree); err != nil {
-						return fmt.Errorf("影片分类信息删除失败: %s", err.Error())
-					}
-					return nil
+// batchFilm 对单部影片执行批量操作
+func batchFilm(vo system.FilmBatchVo, mid int64, operator string) error {
+	if vo.Action == system.BatchRestore {
+		fr := system.Repo.Recycle.FindByMid(mid)
+		if fr == nil {
+			return errors.New("回收站中不存在该影片")
+		}
+		return restoreFilm(*fr)
+	}
+	s := system.Repo.Search.FindByMid(mid)
+	if s == nil {
+		return errors.New("影片信息不存在")
+	}
+	switch vo.Action {
+	case system.BatchDelete:
+		return deleteFilm(*s, operator)
+	case system.BatchCategory:
+		tree := system.Repo.Film.GetCategoryTree()
+		c := tree.Find(vo.Cid)
+		if c == nil {
+			return errors.New("目标分类不存在")
+		}
+		root, _ := tree.RootId(c.Id)
+		if err := system.Repo.Film.MoveCategory(mid, s.Cid, c.Id, root, c.Name); err != nil {
+			return err
+		}
+		// 记录调整后的分类, 重新采集时不会恢复为采集站的分类
+		return system.Repo.Film.SaveFilmCategory(mid, c.Id)
+	case system.BatchVisible:
+		if vo.Visible {
+			return system.Repo.Film.Show(mid)
+		}
+		return system.Repo.Film.Hide(mid, operator)
+	case system.BatchPoster:
+		// 海报通过编辑信息覆盖影片图片并锁定, 重新采集时保留
+		picture, _ := json.Marshal(vo.Picture)
+		return FL.UpdateFilm(system.FilmEditVo{Id: mid, Fields: map[string]json.RawMessage{"picture": picture}})
+	}
+	return fmt.Errorf("批量操作类型异常: %s", vo.Action)
+}
+
+//----------------------------------------------------重复影片检测与合并----------------------------------------------------
+
+// duplicateScanLock 同一时间只执行一个重复影片检测任务
+var duplicateScanLock sync.Mutex
+
+// ScanDuplicateFilm 在后台执行重复影片检测
+func (fl *FilmLogic) ScanDuplicateFilm(operator string) (*system.FilmDuplicateScan, error) {
+	if !duplicateScanLock.TryLock() {
+		return nil, errors.New("重复影片检测任务正在执行中")
+	}
+	scan := system.FilmDuplicateScan{Status: system.BatchRunning, Operator: operator, StartTime: time.Now().Unix()}
+	if err := system.Repo.Duplicate.SaveScan(scan); err != nil {
+		duplicateScanLock.Unlock()
+		return nil, fmt.Errorf("检测任务创建失败: %s", err.Error())
+	}
+	go runDuplicateScan(scan)
+	return &scan, nil
+}
+
+// runDuplicateScan 遍历有效的检索信息进行分组并保存检测结果
+func runDuplicateScan(scan system.FilmDuplicateScan) {
+	defer duplicateScanLock.Unlock()
+	var list []system.SearchInfo
+	err := system.Repo.Search.Scan(func(sl []system.SearchInfo) {
+		for _, s := range sl {
+			// 回收站中的影片不参与检测
+			if !s.DeletedAt.Valid {
+				list = append(list, s)
+			}
+		}
+		scan.Scanned = len(list)
+		_ = system.Repo.Duplicate.SaveScan(scan)
+	})
+	if err == nil {
+		scan.Groups, err = system.Repo.Duplicate.SaveGroups(system.FindDuplicateFilms(list))
+	}
+	if err != nil {
+		log.Println("Duplicate Film Scan Error: ", err)
+	}
+	scan.Status = system.BatchFinished
+	scan.EndTime = time.Now().Unix()
+	_ = system.Repo.Duplicate.SaveScan(scan)
+	log.Printf("[Duplicate] 重复影片检测完成, 检测影片: %d, 疑似重复分组: %d\n", scan.Scanned, scan.Groups)
+}
+
+// GetDuplicatePage 获取疑似重复分组分页数据以及最近一次检测任务信息
+func (fl *FilmLogic) GetDuplicatePage(page *system.Page) ([]system.FilmDuplicateGroup, *system.FilmDuplicateScan) {
+	gl := system.Repo.Duplicate.List()
+	page.Total = len(gl)
+	page.PageCount = (page.Total + page.PageSize - 1) / page.PageSize
+	start := min((page.Current-1)*page.PageSize, len(gl))
+	return gl[start:min(start+page.PageSize, len(gl))], system.Repo.Duplicate.GetScan()
+}
+
+// MergeDuplicateFilm 将重复影片合并到保留的影片中
+func (fl *FilmLogic) MergeDuplicateFilm(vo system.FilmMergeVo, operator string) error {
+	if len(vo.Mids) == 0 {
+		return errors.New("需要合并的影片不能为空")
+	}
+	if system.Repo.Search.FindByMid(vo.Keep) == nil {
+		return errors.New("保留的影片信息不存在")
+	}
+	if system.Repo.Film.GetRedirect(vo.Keep) != nil {
+		return errors.New("保留的影片已被合并到其他影片中")
+	}
+	var list []system.SearchInfo
+	seen := make(map[int64]bool)
+	for _, mid := range vo.Mids {
+		if mid == vo.Keep {
+			return errors.New("保留的影片不能合并到自身")
+		}
+		if seen[mid] {
+			continue
+		}
+		seen[mid] = true
+		s := system.Repo.Search.FindByMid(mid)
+		if s == nil {
+			return fmt.Errorf("影片 %d 信息不存在", mid)
+		}
+		list = append(list, *s)
+	}
+	for _, s := range list {
+		r := system.FilmRedirect{Mid: s.Mid, Cid: s.Cid, Name: s.Name, Target: vo.Keep, Operator: operator}
+		if err := system.Repo.Film.Merge(r); err != nil {
+			return fmt.Errorf("影片 %d 合并失败: %s", s.Mid, err.Error())
+		}
+		log.Printf("[Duplicate] %s 将影片 %d %s 合并到 %d\n", operator, s.Mid, s.Name, vo.Keep)
+	}
+	if vo.Id != "" {
+		system.Repo.Duplicate.Delete(vo.Id)
+	}
+	spider.ClearCache()
+	return nil
+}
+
+// IgnoreDuplicate 将疑似重复分组标记为非重复
+func (fl *FilmLogic) IgnoreDuplicate(id string) error {
+	return system.Repo.Duplicate.Ignore(id)
+}
+
+//----------------------------------------------------影人信息----------------------------------------------------
+
+// personRebuildLock 同一时间只执行一个影人信息重建任务
+var personRebuildLock sync.Mutex
+
+// RebuildFilmPersons 在后台使用影片详情重建所有影片的影人信息
+func (fl *FilmLogic) RebuildFilmPersons() error {
+	if !personRebuildLock.TryLock() {
+		return errors.New("影人信息重建任务正在执行中")
+	}
+	go func() {
+		defer personRebuildLock.Unlock()
+		count := 0
+		err := system.Repo.Search.Scan(func(sl []system.SearchInfo) {
+			var list []system.MovieDetail
+			for _, s := range sl {
+				// 跳过详情数据已不存在的影片
+				if d := system.Repo.Film.GetDetailByKey(fmt.Sprintf(config.MovieDetailKey, s.Cid, s.Mid)); d.Id != 0 {
+					list = append(list, d)
 				}
 			}
+			if err := system.Repo.Person.Link(list...); err != nil {
+				log.Println("Link Film Persons Error: ", err)
+			}
+			count += len(sl)
+		})
+		if err == nil {
+			err = system.Repo.Person.Prune()
+		}
+		if err != nil {
+			log.Println("Rebuild Film Persons Error: ", err)
+		}
+		log.Printf("[Person] 影人信息重建完成, 处理影片: %d\n", count)
+	}()
+	return nil
+}
+
+// GetZeroKeywords 获取最近 days 天无搜索结果的关键字
+func (fl *FilmLogic) GetZeroKeywords(days, n int) []system.KeywordStat {
+	return system.Repo.Search.ZeroKeywords(days, n)
+}
+
+// filmTextRebuildLock 同一时间只执行一个全文检索信息重建任务
+var filmTextRebuildLock sync.Mutex
+
+// RebuildFilmTexts 在后台使用影片详情重建所有影片的全文检索信息与搜索建议
+func (fl *FilmLogic) RebuildFilmTexts() error {
+	if !filmTextRebuildLock.TryLock() {
+		return errors.New("全文检索信息重建任务正在执行中")
+	}
+	go func() {
+		defer filmTextRebuildLock.Unlock()
+		count := 0
+		err := system.Repo.Search.Scan(func(sl []system.SearchInfo) {
+			var list []system.MovieDetail
+			for _, s := range sl {
+				// 跳过详情数据已不存在的影片, 应用编辑信息后生成检索文本
+				if d := system.Repo.Film.GetDetailByKey(fmt.Sprintf(config.MovieDetailKey, s.Cid, s.Mid)); d.Id != 0 {
+					list = append(list, d)
+				}
+			}
+			if err := system.Repo.Search.Index(list...); err != nil {
+				log.Println("Index Film Texts Error: ", err)
+			}
+			count += len(sl)
+		})
+		if err != nil {
+			log.Println("Rebuild Film Texts Error: ", err)
+		}
+		log.Printf("[Search] 全文检索信息与搜索建议重建完成, 处理影片: %d\n", count)
+	}()
+	return nil
+}
+
+// BackfillIndexes 升级后已有影片尚未生成全文检索信息或影人信息时, 在后台使用影片详情补全
+func (fl *FilmLogic) BackfillIndexes() {
+	if !system.Repo.Search.Exist() {
+		return
+	}
+	if !system.Repo.Search.Indexed() {
+		log.Println("[Search] 已有影片尚未生成全文检索信息, 开始后台重建")
+		if err := fl.RebuildFilmTexts(); err != nil {
+			log.Println("Backfill Film Texts Error: ", err)
+		}
+	}
+	if !system.Repo.Person.Linked() {
+		log.Println("[Person] 已有影片尚未生成影人信息, 开始后台重建")
+		if err := fl.RebuildFilmPersons(); err != nil {
+			log.Println("Backfill Film Persons Error: ", err)
 		}
 	}
-	return errors.New("需要删除的分类信息不存在")
+}
+
+//----------------------------------------------------影片分类业务逻辑----------------------------------------------------
+
+// GetFilmClassTree 获取影片分类信息
+func (fl *FilmLogic) GetFilmClassTree() system.CategoryTree {
+	// 获取原本的影片分类信息
+	return system.Repo.Film.GetCategoryTree()
+}
+
+// GetFilmClassById 通过ID获取影片分类信息
+func (fl *FilmLogic) GetFilmClassById(id int64) *system.CategoryTree {
+	tree := system.Repo.Film.GetCategoryTree()
+	if id == tree.Id {
+		return nil
+	}
+	return tree.Find(id)
+}
+
+// UpdateClass 更新分类信息
+func (fl *FilmLogic) UpdateClass(class system.CategoryTree) error {
+	tree := system.Repo.Film.GetCategoryTree()
+	c := tree.Find(class.Id)
+	if c == nil || c.Id == tree.Id {
+		return errors.New("需要更新的分类信息不存在")
+	}
+	if class.Name != "" {
+		c.Name = class.Name
+	}
+	// 分类展示状态在查询影片时生效, 无需修改影片检索信息
+	c.Show = class.Show
+	if err := system.Repo.Film.SaveCategoryTree(&tree); err != nil {
+		return fmt.Errorf("影片分类信息更新失败: %s", err.Error())
+	}
+	return nil
+}
+
+// AddClass 添加自定义分类, Pid 为 0 时作为一级分类
+func (fl *FilmLogic) AddClass(class system.Category) (*system.Category, error) {
+	if strings.TrimSpace(class.Name) == "" {
+		return nil, errors.New("分类名称不能为空")
+	}
+	tree := system.Repo.Film.GetCategoryTree()
+	if tree.Category == nil {
+		return nil, errors.New("分类信息不存在, 请先采集影片分类")
+	}
+	parent := tree.Find(class.Pid)
+	if parent == nil {
+		return nil, errors.New("父级分类不存在")
+	}
+	// 自定义分类使用独立的ID区间, 避免与采集站后续新增的分类冲突
+	class.Id = max(tree.MaxId()+1, config.CustomCategoryIdStart)
+	class.Name = strings.TrimSpace(class.Name)
+	class.Custom = true
+	parent.Append(&system.CategoryTree{Category: &class})
+	if err := system.Repo.Film.SaveCategoryTree(&tree); err != nil {
+		return nil, fmt.Errorf("影片分类信息保存失败: %s", err.Error())
+	}
+	return &class, nil
+}
+
+// SortClass 设置分类的排序值, 同级分类按排序值升序排列
+func (fl *FilmLogic) SortClass(list []system.Category) error {
+	tree := system.Repo.Film.GetCategoryTree()
+	for _, c := range list {
+		node := tree.Find(c.Id)
+		if node == nil || node.Id == tree.Id {
+			return fmt.Errorf("分类信息不存在: %d", c.Id)
+		}
+		node.Sort = c.Sort
+	}
+	tree.SortChildren()
+	if err := system.Repo.Film.SaveCategoryTree(&tree); err != nil {
+		return fmt.Errorf("影片分类排序保存失败: %s", err.Error())
+	}
+	return nil
+}
+
+// MoveClass 将分类(包含其子分类)移动到目标分类下, pid 为 0 时移动为一级分类, 返回一级分类发生变化的影片数量
+func (fl *FilmLogic) MoveClass(id, pid int64) (int, error) {
+	tree := system.Repo.Film.GetCategoryTree()
+	if err := system.ValidCategoryMove(&tree, id, pid); err != nil {
+		return 0, err
+	}
+	node := tree.Remove(id)
+	tree.Find(pid).Append(node)
+	if err := system.Repo.Film.SaveCategoryTree(&tree); err != nil {
+		return 0, fmt.Errorf("影片分类信息保存失败: %s", err.Error())
+	}
+	// 分类层级变化后同步修改影片的一级分类ID
+	root, _ := tree.RootId(id)
+	return reassignFilms(&tree, node, root)
+}
+
+// MergeClass 将分类合并到目标分类, 原分类的影片和子分类转移到目标分类下, 原分类被移除
+// 后续采集到的原分类影片同样归入目标分类, 返回转移的影片数量
+func (fl *FilmLogic) MergeClass(from, to int64) (int, error) {
+	tree := system.Repo.Film.GetCategoryTree()
+	if from == to {
+		return 0, errors.New("不能合并到当前分类")
+	}
+	if err := system.ValidCategoryMove(&tree, from, to); err != nil {
+		return 0, err
+	}
+	node := tree.Remove(from)
+	target := tree.Find(to)
+	// 原分类的子分类转移到目标分类下
+	target.Append(node.Children...)
+	if err := system.Repo.Film.SaveCategoryTree(&tree); err != nil {
+		return 0, fmt.Errorf("影片分类信息保存失败: %s", err.Error())
+	}
+	if err := system.Repo.Film.SaveCategoryMapping(from, to); err != nil {
+		return 0, fmt.Errorf("分类合并关系保存失败: %s", err.Error())
+	}
+	root, _ := tree.RootId(to)
+	count, err := system.Repo.Film.ReassignCategory(from, to, root, target.Name)
+	if err != nil {
+		return count, err
+	}
+	for _, c := range node.Children {
+		n, err := reassignFilms(&tree, c, root)
+		count += n
+		if err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// reassignFilms 修改分类及其子孙分类下影片的一级分类ID
+func reassignFilms(tree *system.CategoryTree, node *system.CategoryTree, root int64) (int, error) {
+	var count int
+	for _, cid := range node.Ids() {
+		c := tree.Find(cid)
+		n, err := system.Repo.Film.ReassignCategory(cid, cid, root, c.Name)
+		count += n
+		if err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// DelClass 删除分类信息, 仅允许删除不包含子分类和影片的自定义分类
+func (fl *FilmLogic) DelClass(id int64) error {
+	tree := system.Repo.Film.GetCategoryTree()
+	c := tree.Find(id)
+	if c == nil || c.Id == tree.Id {
+		return errors.New("需要删除的分类信息不存在")
+	}
+	if len(c.Children) > 0 {
+		return errors.New("当前分类下存在子分类, 请先移动或删除子分类")
+	}
+	// 采集站分类删除后仍会采集到对应的影片, 只能隐藏或合并到其他分类
+	if !c.Custom {
+		return errors.New("采集站分类不允许删除, 可以隐藏该分类或将其合并到其他分类")
+	}
+	if system.Repo.Search.CountByCid(id) > 0 {
+		return errors.New("当前分类下存在影片, 请将其合并到其他分类")
+	}
+	tree.Remove(id)
+	if err := system.Repo.Film.SaveCategoryTree(&tree); err != nil {
+		return fmt.Errorf("影片分类信息删除失败: %s", err.Error())
+	}
+	return nil
 }
@@ -3,10 +3,8 @@ package logic
 import (
 	"fmt"
 	"github.com/gin-gonic/gin"
-	"regexp"
 	"server/config"
 	"server/model/system"
-	"server/plugin/db"
 	"server/plugin/spider"
 	"strings"
 )
@@ -22,17 +20,17 @@ type IndexLogic struct {
 var IL *IndexLogic
 
 // IndexPage 首页数据处理
-func (i *IndexLogic) IndexPage() map[string]interface{} {
+func (i *IndexLogic) IndexPage(t system.Tenant) map[string]interface{} {
 	// 首页请求时长较高, 采用redis进行缓存, 在定时任务更新影片时清除对应缓存
-	// 判断是否存在缓存数据, 存在则直接将数据返回
-	Info := system.GetCacheData(config.IndexCacheKey)
+	// 判断是否存在缓存数据, 存在则直接将数据返回, 各站点分别缓存
+	Info := system.Repo.Cache.Get(t.Key(config.IndexCacheKey))
 	if Info != nil {
 		return Info
 	}
 	Info = make(map[string]interface{})
 	// 1. 首页分类数据处理 导航分类数据处理, 只提供 电影 电视剧 综艺 动漫 四大顶级分类和其子分类
 	tree := system.CategoryTree{Category: &system.Category{Id: 0, Name: "分类信息"}}
-	sysTree := system.GetCategoryTree()
+	sysTree := t.CategoryTree(system.Repo.Film.GetCategoryTree())
 	// 只展示show=true的分页影片信息
 	for _, c := range sysTree.Children {
 		// 只针对一级分类进行处理
@@ -53,15 +51,15 @@ func (i *IndexLogic) IndexPage() map[string]interface{} {
 		if c.Children != nil {
 			// 如果有子分类, 则通过Pid获取对应影片
 			// 获取当前分类的最新上映影片
-			movies = system.GetMovieListByPid(c.Id, &page)
+			movies = system.Repo.Search.MovieListByPid(t, c.Id, &page)
 			// 获取当前分类的本月热门影片
-			hotMovies = system.GetHotMovieByPid(c.Id, &page)
+			hotMovies = system.Repo.Search.HotMovieByPid(t, c.Id, &page)
 		} else {
 			// 如果当前分类为一级分类且没有子分类,则通过Cid获取对应数据
 			// 获取当前分类的最新上映影片
-			movies = system.GetMovieListByCid(c.Id, &page)
+			movies = system.Repo.Search.MovieListByCid(t, c.Id, &page)
 			// 获取当前分类的本月热门影片
-			hotMovies = system.GetHotMovieByCid(c.Id, &page)
+			hotMovies = system.Repo.Search.HotMovieByCid(t, c.Id, &page)
 		}
 
 		item := map[string]interface{}{"nav": c, "movies": movies, "hot": hotMovies}
@@ -69,9 +67,9 @@ func (i *IndexLogic) IndexPage() map[string]interface{} {
 	}
 	Info["content"] = list
 	// 3. 获取首页轮播数据
-	Info["banners"] = system.GetBanners()
+	Info["banners"] = system.GetBanners(t.Id)
 	// 不存在首页数据缓存时将查询数据缓存到redis中
-	system.DataCache(config.IndexCacheKey, Info)
+	system.Repo.Cache.Set(t.Key(config.IndexCacheKey), Info)
 	return Info
 }
 
@@ -82,24 +80,30 @@ func (i *IndexLogic) ClearIndexCache() {
 }
 
 // GetFilmDetail 影片详情信息页面处理
-func (i *IndexLogic) GetFilmDetail(id int) system.MovieDetailVo {
+func (i *IndexLogic) GetFilmDetail(t system.Tenant, id int) system.MovieDetailVo {
+	// 已合并的重复影片重定向到保留的影片
+	if r := system.Repo.Film.GetRedirect(int64(id)); r != nil {
+		id = int(r.Target)
+	}
 	// 通过Id 获取影片search信息
 	search := system.SearchInfo{}
-	db.Mdb.Where("mid", id).First(&search)
+	if s := system.Repo.Search.FindByMid(int64(id)); s != nil {
+		search = *s
+	}
 	// 获取redis中的完整影视信息 MovieDetail:Cid11:Id24676
-	movieDetail := system.GetDetailByKey(fmt.Sprintf(config.MovieDetailKey, search.Cid, search.Mid))
-	var res = system.MovieDetailVo{MovieDetail: movieDetail}
+	movieDetail := system.Repo.Film.GetDetailByKey(fmt.Sprintf(config.MovieDetailKey, search.Cid, search.Mid))
+	var res = system.MovieDetailVo{MovieDetail: movieDetail, Persons: system.Repo.Person.ByFilm(search.Mid), Roles: system.Repo.Person.Roles(search.Mid)}
 	//查找其他站点是否存在影片对应的播放源
-	res.List = multipleSource(&movieDetail)
+	res.List = multipleSource(t, &movieDetail)
 	return res
 }
 
 // GetCategoryInfo 分类信息获取, 组装导航栏需要的信息
-func (i *IndexLogic) GetCategoryInfo() gin.H {
+func (i *IndexLogic) GetCategoryInfo(t system.Tenant) gin.H {
 	// 组装nav导航所需的信息
 	nav := gin.H{}
 	// 1.获取所有分类信息
-	tree := system.GetCategoryTree()
+	tree := t.CategoryTree(system.Repo.Film.GetCategoryTree())
 	// 2. 过滤出主页四大分类的tree信息
 	for _, t := range tree.Children {
 		switch t.Category.Name {
@@ -118,9 +122,9 @@ func (i *IndexLogic) GetCategoryInfo() gin.H {
 }
 
 // GetNavCategory 获取导航分类信息
-func (i *IndexLogic) GetNavCategory() []*system.Category {
+func (i *IndexLogic) GetNavCategory(t system.Tenant) []*system.Category {
 	// 1.获取所有分类信息
-	tree := system.GetCategoryTree()
+	tree := t.CategoryTree(system.Repo.Film.GetCategoryTree())
 	// 遍历一级分类返回可展示的分类数据
 	var cl []*system.Category
 	for _, c := range tree.Children {
@@ -133,33 +137,123 @@ func (i *IndexLogic) GetNavCategory() []*system.Category {
 }
 
 // SearchFilmInfo 获取关键字匹配的影片信息
-func (i *IndexLogic) SearchFilmInfo(key string, page *system.Page) []system.MovieBasicInfo {
-	// 1. 从mysql中获取满足条件的数据, 每页10条
-	sl := system.SearchFilmKeyword(key, page)
+func (i *IndexLogic) SearchFilmInfo(t system.Tenant, key string, highlight bool, page *system.Page) []system.FilmSearchVo {
+	// 1. 全文检索满足条件的影片, 按相关度排序
+	hl := system.Repo.Search.Keyword(t, key, highlight, page)
+	// 只统计首页的搜索, 翻页不重复计数
+	if page.Current == 1 {
+		system.Repo.Search.Record(key, page.Total)
+	}
 	// 2. 获取redis中的basicMovieInfo信息
-	var bl []system.MovieBasicInfo
-	for _, s := range sl {
-		bl = append(bl, system.GetBasicInfoByKey(fmt.Sprintf(config.MovieBasicInfoKey, s.Cid, s.Mid)))
+	var bl []system.FilmSearchVo
+	for _, h := range hl {
+		bl = append(bl, system.FilmSearchVo{
+			MovieBasicInfo: system.Repo.Film.GetBasicInfoByKey(fmt.Sprintf(config.MovieBasicInfoKey, h.Cid, h.Mid)),
+			Score:          h.Score,
+			Highlights:     h.Highlights,
+		})
 	}
 	return bl
 }
 
+// LookupFilm 本地无搜索结果时在后台通过采集站搜索影片, 返回搜索任务状态, 关键字无效或超出触发频率时返回 nil
+func (i *IndexLogic) LookupFilm(key string) *system.SearchLookup {
+	l, started := system.Repo.Search.StartLookup(key)
+	if started {
+		go spider.LookupFilm(l.Keyword)
+	}
+	return l
+}
+
+// GetLookup 获取关键字的采集站搜索任务状态
+func (i *IndexLogic) GetLookup(key string) *system.SearchLookup {
+	return system.Repo.Search.Lookup(key)
+}
+
+// SearchSuggest 获取关键字前缀匹配的搜索建议
+func (i *IndexLogic) SearchSuggest(t system.Tenant, key string, n int) []system.FilmSuggestVo {
+	var res []system.FilmSuggestVo
+	for _, b := range system.Repo.Film.GetBasicInfoBySearchInfos(system.Repo.Search.Suggest(t, key, n)...) {
+		res = append(res, system.FilmSuggestVo{Id: b.Id, Name: b.Name, Year: b.Year, CName: b.CName, Picture: b.Picture})
+	}
+	return res
+}
+
+// GetHotKeywords 获取最近 days 天的热门搜索关键字
+func (i *IndexLogic) GetHotKeywords(days, n int) []system.KeywordStat {
+	return system.Repo.Search.HotKeywords(days, n)
+}
+
+// GetPerson 获取影人信息
+func (i *IndexLogic) GetPerson(t system.Tenant, id uint) *system.PersonVo {
+	return system.Repo.Person.FindById(t, id)
+}
+
+// GetPersonFilms 获取影人参与的影片信息
+func (i *IndexLogic) GetPersonFilms(t system.Tenant, id uint, role string, page *system.Page) []system.MovieBasicInfo {
+	return system.Repo.Film.GetBasicInfoBySearchInfos(system.Repo.Person.Films(t, id, role, page)...)
+}
+
+// SearchPerson 通过姓名检索影人信息
+func (i *IndexLogic) SearchPerson(t system.Tenant, keyword string, page *system.Page) []system.PersonVo {
+	return system.Repo.Person.Search(t, keyword, page)
+}
+
+// GetTodayFilms 获取今日更新的影片
+func (i *IndexLogic) GetTodayFilms(t system.Tenant, pid int64, page *system.Page) []system.MovieBasicInfo {
+	return system.Repo.Film.GetBasicInfoBySearchInfos(system.Repo.Search.Today(t, pid, page)...)
+}
+
+// GetAiringCalendar 获取连载中影片的每周放送表, 每天的影片按照播出平台分组, 平台按其最近更新的影片排列
+func (i *IndexLogic) GetAiringCalendar(t system.Tenant, pid int64) []system.CalendarDay {
+	var days []system.CalendarDay
+	for w := 1; w <= 7; w++ {
+		day := system.CalendarDay{Weekday: w, Channels: make([]system.CalendarChannel, 0)}
+		index := make(map[string]int)
+		sl := system.Repo.Search.Weekday(t, w, pid)
+		for j, b := range system.Repo.Film.GetBasicInfoBySearchInfos(sl...) {
+			tv := strings.TrimSpace(sl[j].Tv)
+			if _, ok := index[tv]; !ok {
+				index[tv] = len(day.Channels)
+				day.Channels = append(day.Channels, system.CalendarChannel{Tv: tv})
+			}
+			day.Channels[index[tv]].List = append(day.Channels[index[tv]].List, b)
+		}
+		days = append(days, day)
+	}
+	return days
+}
+
+// GetArticleList 获取文章分页数据以及文章分类
+func (i *IndexLogic) GetArticleList(keyword, typeName string, page *system.Page) ([]system.Article, []string) {
+	return system.Repo.Article.List(keyword, typeName, page), system.Repo.Article.Types()
+}
+
+// GetArticle 获取文章详情以及关联影片的基本信息
+func (i *IndexLogic) GetArticle(t system.Tenant, id uint) *system.ArticleVo {
+	a := system.Repo.Article.FindById(id)
+	if a == nil {
+		return nil
+	}
+	return &system.ArticleVo{Article: *a, Films: system.Repo.Film.GetBasicInfoBySearchInfos(system.Repo.Article.Films(t, *a)...)}
+}
+
 // GetFilmCategory 根据Pid或Cid获取指定的分页数据
-func (i *IndexLogic) GetFilmCategory(id int64, idType string, page *system.Page) []system.MovieBasicInfo {
+func (i *IndexLogic) GetFilmCategory(t system.Tenant, id int64, idType string, page *system.Page) []system.MovieBasicInfo {
 	// 1. 根据不同类型进不同的查找
 	var basicList []system.MovieBasicInfo
 	switch idType {
 	case "pid":
-		basicList = system.GetMovieListByPid(id, page)
+		basicList = system.Repo.Search.MovieListByPid(t, id, page)
 	case "cid":
-		basicList = system.GetMovieListByCid(id, page)
+		basicList = system.Repo.Search.MovieListByCid(t, id, page)
 	}
 	return basicList
 }
 
 // GetPidCategory 获取pid对应的分类信息
-func (i *IndexLogic) GetPidCategory(pid int64) *system.CategoryTree {
-	tree := system.GetCategoryTree()
+func (i *IndexLogic) GetPidCategory(t system.Tenant, pid int64) *system.CategoryTree {
+	tree := t.CategoryTree(system.Repo.Film.GetCategoryTree())
 	for _, t := range tree.Children {
 		if t.Id == pid {
 			return t
@@ -169,7 +263,7 @@ func (i *IndexLogic) GetPidCategory(pid int64) *system.CategoryTree {
 }
 
 // RelateMovie 根据当前影片信息匹配相关的影片
-func (i *IndexLogic) RelateMovie(detail system.MovieDetail, page *system.Page) []system.MovieBasicInfo {
+func (i *IndexLogic) RelateMovie(t system.Tenant, detail system.MovieDetail, page *system.Page) []system.MovieBasicInfo {
 	/*
 		根据当前影片信息匹配相关的影片
 		1. 分类Cid,
@@ -185,13 +279,26 @@ func (i *IndexLogic) RelateMovie(detail system.MovieDetail, page *system.Page) [
 		Area:     detail.Area,
 		Language: detail.Language,
 	}
-	return system.GetRelateMovieBasicInfo(search, page)
+	return system.Repo.Search.RelateMovie(t, search, page)
 }
 
 // SearchTags 整合对应分类的搜索tag
-func (i *IndexLogic) SearchTags(pid int64) map[string]interface{} {
+func (i *IndexLogic) SearchTags(t system.Tenant, pid int64) map[string]interface{} {
 	// 通过pid 获取对应分类的 tags
-	return system.GetSearchTag(pid)
+	res := system.Repo.Search.Tags(pid)
+	// 站点单独设置了分类展示状态时, 按站点的分类树重新生成子分类tag
+	if tags, ok := res["tags"].(map[string]interface{}); ok && len(t.Categories) > 0 {
+		var cl []string
+		if pt := i.GetPidCategory(t, pid); pt != nil {
+			for _, c := range pt.Children {
+				if c.Show {
+					cl = append(cl, fmt.Sprintf("%s:%d", c.Name, c.Id))
+				}
+			}
+		}
+		tags["Category"] = system.HandleTagStr("Category", cl...)
+	}
+	return res
 }
 
 /*
@@ -200,38 +307,24 @@ func (i *IndexLogic) SearchTags(pid int64) map[string]interface{} {
 	 2. 仅对主站点影片name进行映射关系处理并将结果添加到map中
 	    例如: xxx第一季  xxx
 */
-func multipleSource(detail *system.MovieDetail) []system.PlayLinkVo {
-	// 生成多站点的播放源信息
-	master := system.GetCollectSourceListByGrade(system.MasterCollect)
-	var playList = []system.PlayLinkVo{{master[0].Id, master[0].Name, detail.PlayList[0]}}
-
-	// 整合多播放源, 初始化存储key map
-	names := make(map[string]int)
-	// 1. 判断detail的dbId是否存在, 存在则添加到names中作为匹配条件
-	if detail.DbId > 0 {
-		names[system.GenerateHashKey(detail.DbId)] = 0
-	}
-	// 2. 对name进行去除特殊格式处理
-	names[system.GenerateHashKey(detail.Name)] = 0
-	// 3. 对包含第一季的name进行处理
-	names[system.GenerateHashKey(regexp.MustCompile(`第一季$`).ReplaceAllString(detail.Name, ""))] = 0
-
-	// 4. 将subtitle进行切分,放入names中
-	if len(detail.SubTitle) > 0 && strings.Contains(detail.SubTitle, ",") {
-		for _, v := range strings.Split(detail.SubTitle, ",") {
-			names[system.GenerateHashKey(v)] = 0
-		}
-	}
-	if len(detail.SubTitle) > 0 && strings.Contains(detail.SubTitle, "/") {
-		for _, v := range strings.Split(detail.SubTitle, "/") {
-			names[system.GenerateHashKey(v)] = 0
-		}
+func multipleSource(t system.Tenant, detail *system.MovieDetail) []system.PlayLinkVo {
+	// 生成多站点的播放源信息, 仅保留当前站点可用的采集站播放源
+	var playList []system.PlayLinkVo
+	master := system.Repo.Source.ListByGrade(system.MasterCollect)
+	if len(master) > 0 && len(detail.PlayList) > 0 && t.UseSource(master[0].Id) {
+		playList = append(playList, system.PlayLinkVo{Id: master[0].Id, Name: master[0].Name, LinkList: detail.PlayList[0]})
 	}
+
+	// 整合多播放源, 通过 豆瓣ID, 片名以及别名 匹配附属站点的播放源
+	names := system.MultiplePlayKeys(*detail)
 	// 遍历所有附属站点列表
-	sc := system.GetCollectSourceListByGrade(system.SlaveCollect)
+	sc := system.Repo.Source.ListByGrade(system.SlaveCollect)
 	for _, s := range sc {
-		for k, _ := range names {
-			pl := system.GetMultiplePlay(s.Id, k)
+		if !t.UseSource(s.Id) {
+			continue
+		}
+		for _, k := range names {
+			pl := system.Repo.Film.GetMultiplePlay(s.Id, k)
 			if len(pl) > 0 {
 				// 如果当前站点已经匹配到数据则直接退出当前循环
 				//detail.PlayList = append(detail.PlayList, pl)
@@ -245,22 +338,27 @@ func multipleSource(detail *system.MovieDetail) []system.PlayLinkVo {
 }
 
 // GetFilmsByTags 通过searchTag 返回满足条件的分页影片信息
-func (i *IndexLogic) GetFilmsByTags(st system.SearchTagsVO, page *system.Page) []system.MovieBasicInfo {
+func (i *IndexLogic) GetFilmsByTags(t system.Tenant, st system.SearchTagsVO, page *system.Page) []system.MovieBasicInfo {
 	// 获取满足条件的影片id 列表
-	sl := system.GetSearchInfosByTags(st, page)
+	sl := system.Repo.Search.ByTags(t, st, page)
 	// 通过key 获取对应影片的基本信息
-	return system.GetBasicInfoBySearchInfos(sl...)
+	return system.Repo.Film.GetBasicInfoBySearchInfos(sl...)
+}
+
+// SearchFacets 获取当前筛选条件下各维度选项的影片数量
+func (i *IndexLogic) SearchFacets(t system.Tenant, st system.SearchTagsVO) map[string]map[string]int64 {
+	return system.Repo.Search.Facets(t, st)
 }
 
 // GetFilmClassify 通过Pid返回当前所属分类下的首页展示数据
-func (i *IndexLogic) GetFilmClassify(pid int64, page *system.Page) map[string]interface{} {
+func (i *IndexLogic) GetFilmClassify(t system.Tenant, pid int64, page *system.Page) map[string]interface{} {
 	res := make(map[string]interface{})
 	// 最新上映 (上映时间)
-	res["news"] = system.GetMovieListBySort(0, pid, page)
+	res["news"] = system.Repo.Search.MovieListBySort(t, 0, pid, page)
 	// 排行榜 (暂定为热度排行)
-	res["top"] = system.GetMovieListBySort(1, pid, page)
+	res["top"] = system.Repo.Search.MovieListBySort(t, 1, pid, page)
 	// 最近更新 (更新时间)
-	res["recent"] = system.GetMovieListBySort(2, pid, page)
+	res["recent"] = system.Repo.Search.MovieListBySort(t, 2, pid, page)
 
 	return res
 
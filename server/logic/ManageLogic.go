@@ -1,7 +1,10 @@
 package logic
 
 import (
+	"fmt"
+	"server/config"
 	"server/model/system"
+	"server/plugin/SystemInit"
 )
 
 type ManageLogic struct {
@@ -9,22 +12,38 @@ type ManageLogic struct {
 
 var ML *ManageLogic
 
-// GetSiteBasicConfig 获取网站基本配置信息
-func (ml *ManageLogic) GetSiteBasicConfig() system.BasicConfig {
-	return system.GetSiteBasic()
+// GetSiteBasicConfig 获取站点的网站基本配置信息
+func (ml *ManageLogic) GetSiteBasicConfig(tid string) system.BasicConfig {
+	return system.GetSiteBasic(tid)
 }
 
-// UpdateSiteBasic 更新网站配置信息
-func (ml *ManageLogic) UpdateSiteBasic(c system.BasicConfig) error {
-	return system.SaveSiteBasic(c)
+// UpdateSiteBasic 更新站点的网站配置信息
+func (ml *ManageLogic) UpdateSiteBasic(tid string, c system.BasicConfig) error {
+	// 同一域名只能绑定一个站点
+	if id := system.FindTenantByHost(c.Domain); id != "" && id != tid {
+		return fmt.Errorf("域名已绑定站点 %s", id)
+	}
+	return system.SaveSiteBasic(tid, c)
 }
 
-// GetBanners 获取轮播组件信息
-func (ml *ManageLogic) GetBanners() system.Banners {
-	return system.GetBanners()
+// ResetSiteBasic 重置站点的网站配置信息为初始化状态, 非默认站点保留当前域名以免站点无法被解析
+func (ml *ManageLogic) ResetSiteBasic(tid string) error {
+	bc := SystemInit.DefaultBasicConfig()
+	if tid != config.DefaultTenantId {
+		bc.Domain = system.GetSiteBasic(tid).Domain
+	}
+	return system.SaveSiteBasic(tid, bc)
 }
 
-// SaveBanners 保存轮播信息
-func (ml *ManageLogic) SaveBanners(bl system.Banners) error {
-	return system.SaveBanners(bl)
+// GetBanners 获取站点的轮播组件信息
+func (ml *ManageLogic) GetBanners(tid string) system.Banners {
+	return system.GetBanners(tid)
+}
+
+// SaveBanners 保存站点的轮播信息
+func (ml *ManageLogic) SaveBanners(tid string, bl system.Banners) error {
+	err := system.SaveBanners(tid, bl)
+	// 轮播信息属于首页数据, 更新后清除站点首页缓存
+	system.Repo.Cache.Remove(config.TenantKey(tid, config.IndexCacheKey))
+	return err
 }
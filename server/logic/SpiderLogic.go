@@ -20,7 +20,7 @@ func (sl *SpiderLogic) BatchCollect(time int, ids []string) {
 // StartCollect 执行对指定站点的采集任务
 func (sl *SpiderLogic) StartCollect(id string, h int) error {
 	// 先判断采集站是否存在于系统数据中
-	fs := system.FindCollectSourceById(id)
+	fs := system.Repo.Source.FindById(id)
 	if fs == nil {
 		return errors.New("采集任务开启失败，采集站信息不存在")
 	}
@@ -60,7 +60,7 @@ func (sl *SpiderLogic) SyncCollect(ids string) {
 
 // FilmClassCollect 影视分类采集, 直接覆盖当前分类数据
 func (sl *SpiderLogic) FilmClassCollect() error {
-	l := system.GetCollectSourceListByGrade(system.MasterCollect)
+	l := system.Repo.Source.ListByGrade(system.MasterCollect)
 	if l == nil {
 		return errors.New("未获取到主采集站信息")
 	}
@@ -0,0 +1,95 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"server/config"
+	"server/model/system"
+	"server/plugin/common/util"
+)
+
+type TenantLogic struct {
+}
+
+var TL *TenantLogic
+
+// GetTenants 获取所有站点信息以及站点绑定的域名
+func (tl *TenantLogic) GetTenants() []system.TenantVo {
+	var list []system.TenantVo
+	for _, t := range system.GetTenants() {
+		bc := system.GetSiteBasic(t.Id)
+		list = append(list, system.TenantVo{Tenant: t, SiteName: bc.SiteName, Domain: bc.Domain})
+	}
+	return list
+}
+
+// GetTenant 通过Id获取站点信息
+func (tl *TenantLogic) GetTenant(id string) (system.TenantVo, error) {
+	if !system.ExistTenant(id) {
+		return system.TenantVo{}, errors.New("站点信息不存在")
+	}
+	bc := system.GetSiteBasic(id)
+	return system.TenantVo{Tenant: system.GetTenantById(id), SiteName: bc.SiteName, Domain: bc.Domain}, nil
+}
+
+// AddTenant 添加站点, 新站点的网站配置与轮播信息以默认站点为模板生成
+func (tl *TenantLogic) AddTenant(vo system.TenantVo) error {
+	if system.ExistTenant(vo.Id) {
+		return fmt.Errorf("站点标识 %s 已存在", vo.Id)
+	}
+	if tid := system.FindTenantByHost(vo.Domain); tid != "" {
+		return fmt.Errorf("域名已绑定站点 %s", tid)
+	}
+	if err := validTenant(vo.Tenant); err != nil {
+		return err
+	}
+	if err := system.SaveTenant(vo.Tenant); err != nil {
+		return err
+	}
+	bc := system.GetSiteBasic(config.DefaultTenantId)
+	bc.SiteName, bc.Domain = vo.SiteName, vo.Domain
+	if err := system.SaveSiteBasic(vo.Id, bc); err != nil {
+		return err
+	}
+	return system.SaveBanners(vo.Id, system.GetBanners(config.DefaultTenantId))
+}
+
+// UpdateTenant 更新站点信息, 站点的网站配置通过 /manage/config/basic 单独维护
+func (tl *TenantLogic) UpdateTenant(t system.Tenant) error {
+	if !system.ExistTenant(t.Id) {
+		return errors.New("站点信息不存在")
+	}
+	if err := validTenant(t); err != nil {
+		return err
+	}
+	if err := system.SaveTenant(t); err != nil {
+		return err
+	}
+	// 分类展示状态和播放源范围会影响首页数据, 更新后清除站点首页缓存
+	system.Repo.Cache.Remove(t.Key(config.IndexCacheKey))
+	return nil
+}
+
+// DelTenant 删除站点以及站点独立的配置数据
+func (tl *TenantLogic) DelTenant(id string) error {
+	if !system.ExistTenant(id) {
+		return errors.New("站点信息不存在")
+	}
+	return system.DelTenant(id)
+}
+
+// validTenant 校验站点标识以及站点可用的采集站
+func validTenant(t system.Tenant) error {
+	if !util.ValidTenantId(t.Id) {
+		return errors.New("站点标识只能包含字母, 数字, _ 和 -")
+	}
+	if !t.ShareLibrary && len(t.Sources) == 0 {
+		return errors.New("未共享影片资源的站点至少需要指定一个采集站")
+	}
+	for _, id := range t.Sources {
+		if system.Repo.Source.FindById(id) == nil {
+			return fmt.Errorf("采集站 %s 不存在", id)
+		}
+	}
+	return nil
+}
@@ -14,7 +14,7 @@ var UL *UserLogic
 // UserLogin 用户登录
 func (ul *UserLogic) UserLogin(account, password string) (token string, err error) {
 	// 根据 username 或 email 查询用户信息
-	var u *system.User = system.GetUserByNameOrEmail(account)
+	var u *system.User = system.Repo.User.FindByNameOrEmail(account)
 	// 用户信息不存在则返回提示信息
 	if u == nil {
 		return "", errors.New(" 用户信息不存在!!!")
@@ -25,7 +25,7 @@ func (ul *UserLogic) UserLogin(account, password string) (token string, err erro
 	}
 	// 密码校验成功后下发token
 	token, err = system.GenToken(u.ID, u.UserName)
-	err = system.SaveUserToken(token, u.ID)
+	err = system.Repo.User.SaveToken(token, u.ID)
 	return
 }
 
@@ -38,7 +38,7 @@ func (ul *UserLogic) UserLogout() {
 // ChangePassword 修改密码
 func (ul *UserLogic) ChangePassword(account, password, newPassword string) error {
 	// 根据 username 或 email 查询用户信息
-	var u *system.User = system.GetUserByNameOrEmail(account)
+	var u *system.User = system.Repo.User.FindByNameOrEmail(account)
 	// 用户信息不存在则返回提示信息
 	if u == nil {
 		return errors.New(" 用户信息不存在!!!")
@@ -53,14 +53,14 @@ func (ul *UserLogic) ChangePassword(account, password, newPassword string) error
 	// 将新密码进行加密
 	newUser.Password = util.PasswordEncrypt(newPassword, u.Salt)
 	// 更新用户信息
-	system.UpdateUserInfo(newUser)
+	system.Repo.User.Update(newUser)
 	return nil
 }
 
 // GetUserInfo 获取用户基本信息
 func (ul *UserLogic) GetUserInfo(id uint) system.UserInfoVo {
 	// 通过用户ID查询对应的用户信息
-	u := system.GetUserById(id)
+	u := system.Repo.User.FindById(id)
 	// 去除user信息中的不必要信息
 	var vo = system.UserInfoVo{Id: u.ID, UserName: u.UserName, Email: u.Email, Gender: u.Gender, NickName: u.NickName, Avatar: u.Avatar, Status: u.Status}
 	return vo
@@ -69,7 +69,7 @@ func (ul *UserLogic) GetUserInfo(id uint) system.UserInfoVo {
 // VerifyUserPassword 校验密码
 func (ul *UserLogic) VerifyUserPassword(id uint, password string) bool {
 	// 获取当前登录的用户全部信息
-	u := system.GetUserById(id)
+	u := system.Repo.User.FindById(id)
 	// 校验密码是否正确
 	return util.PasswordEncrypt(password, u.Salt) == u.Password
 }
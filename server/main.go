@@ -1,24 +1,48 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"server/config"
-	"server/model/system"
+	"server/logic"
 	"server/plugin/SystemInit"
+	"server/plugin/backup"
 	"server/plugin/db"
+	"server/plugin/migrate"
+	"server/plugin/spider"
 	"server/router"
 )
 
 func init() {
+	// 嵌入式模式使用进程内 KV + SQLite, 无需等待外部服务
+	if config.StorageMode == config.StorageEmbedded {
+		// 数据文件只允许一个进程使用, 服务运行期间执行的命令行子命令会在此处退出
+		if err := db.LockDataDir(); err != nil {
+			log.Fatalln("[Init]", err)
+		}
+		if err := db.InitEmbeddedKV(); err != nil {
+			panic(fmt.Errorf("进程内 KV 启动失败: %w", err))
+		}
+		if err := db.InitSqlite(); err != nil {
+			panic(fmt.Errorf("SQLite 初始化失败: %w", err))
+		}
+		log.Println("[Init] 嵌入式存储初始化完成")
+		return
+	}
 	// 等待 Redis 就绪（最多重试 30 次，每次间隔 2s）
 	if err := waitForRedis(30, 2*time.Second); err != nil {
 		panic(err)
 	}
-	// 等待 MySQL 就绪
-	if err := waitForMySQL(30, 2*time.Second); err != nil {
+	// 等待关系型数据库 (MySQL | PostgreSQL) 就绪
+	if err := waitForDatabase(30, 2*time.Second); err != nil {
 		panic(err)
 	}
 }
@@ -37,36 +61,65 @@ func waitForRedis(maxRetries int, interval time.Duration) error {
 	return fmt.Errorf("Redis 连接失败，已重试 %d 次: %w", maxRetries, err)
 }
 
-func waitForMySQL(maxRetries int, interval time.Duration) error {
+func waitForDatabase(maxRetries int, interval time.Duration) error {
 	var err error
 	for i := 1; i <= maxRetries; i++ {
-		err = db.InitMysql()
+		err = db.InitDatabase()
 		if err == nil {
-			log.Printf("[Init] MySQL 连接成功 (第 %d 次尝试)", i)
+			log.Printf("[Init] %s 连接成功 (第 %d 次尝试)", config.DBDriver, i)
 			return nil
 		}
-		log.Printf("[Init] MySQL 连接失败 (%d/%d): %v", i, maxRetries, err)
+		log.Printf("[Init] %s 连接失败 (%d/%d): %v", config.DBDriver, i, maxRetries, err)
 		time.Sleep(interval)
 	}
-	return fmt.Errorf("MySQL 连接失败，已重试 %d 次: %w", maxRetries, err)
+	return fmt.Errorf("%s 连接失败，已重试 %d 次: %w", config.DBDriver, maxRetries, err)
+}
+
+// commands 命令行子命令
+var commands = map[string]func(args []string) error{
+	"migrate": migrate.RunCommand,
+	"backup":  backup.RunCommand,
 }
 
 func main() {
+	// 子命令: server migrate list | apply | rollback, server backup list | create | restore
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			err := cmd(os.Args[2:])
+			// 关闭存储, 嵌入式模式下保存命令执行后的 KV 数据
+			closeStorage()
+			if err != nil {
+				log.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
 	start()
 }
 
 func start() {
+	// 监听退出信号, 初始化期间收到的信号在初始化完成后处理
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 	// 启动前先执行数据库内容的初始化工作
 	DefaultDataInit()
 	// 开启路由监听
-	r := router.SetupRouter()
-	_ = r.Run(fmt.Sprintf(":%s", config.ListenerPort))
+	srv := &http.Server{Addr: fmt.Sprintf(":%s", config.ListenerPort), Handler: router.SetupRouter()}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Println("Server Listen Error: ", err)
+			stop()
+		}
+	}()
+	<-ctx.Done()
+	shutdown(srv)
 }
 
 func DefaultDataInit() {
-	// 1. 数据库表结构初始化 (仅在用户表不存在时执行)
-	if !system.ExistUserTable() {
-		SystemInit.TableInIt()
+	// 1. 数据库表结构初始化 (执行未应用的版本迁移)
+	if err := SystemInit.TableInIt(); err != nil {
+		panic(err)
 	}
 
 	// 2. 网站基础配置和轮播图 (改为检查 Redis Key 是否存在，确保清空 Redis 后能自动恢复)
@@ -75,4 +128,42 @@ func DefaultDataInit() {
 
 	// 3. 初始化影视来源和定时任务 (内部已带有存在性检查)
 	SystemInit.SpiderInit()
+
+	// 4. 开启定时备份 (配置 BACKUP_SPEC 时生效)
+	if err := backup.InitSchedule(); err != nil {
+		log.Println("Backup Schedule Init Failed: ", err)
+	}
+
+	// 5. 升级后补全已有影片的检索数据
+	logic.FL.BackfillIndexes()
+}
+
+// shutdown 停止接收新请求并等待处理中的请求以及定时任务结束, 随后关闭存储
+func shutdown(srv *http.Server) {
+	log.Println("[Shutdown] 服务正在退出...")
+	ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Println("Server Shutdown Error: ", err)
+	}
+	for _, done := range []context.Context{spider.CronCollect.Stop(), backup.StopSchedule()} {
+		select {
+		case <-done.Done():
+		case <-ctx.Done():
+			log.Println("[Shutdown] 等待定时任务结束超时")
+		}
+	}
+	closeStorage()
+	log.Println("[Shutdown] 服务已退出")
+}
+
+// closeStorage 关闭数据库连接, 嵌入式模式下保存进程内 KV 快照并释放数据文件锁
+func closeStorage() {
+	if err := db.CloseDatabase(); err != nil {
+		log.Println("Close Database Error: ", err)
+	}
+	db.CloseKV()
+	if err := db.UnlockDataDir(); err != nil {
+		log.Println("Unlock Data Dir Error: ", err)
+	}
 }
@@ -0,0 +1,78 @@
+package collect
+
+/*
+ 演员 角色 文章 接口序列化 struct
+ MacCMS 通过接口路径区分资源类型 provide/actor | provide/role | provide/art, ac=detail 时返回完整信息
+*/
+
+//-------------------------------------------------Json 格式-------------------------------------------------
+
+// ResourcePage 演员 角色 文章 的分页数据
+type ResourcePage[T any] struct {
+	Code      int    `json:"code"`      // 响应状态码
+	Msg       string `json:"msg"`       // 数据类型
+	Page      any    `json:"page"`      // 页码
+	PageCount int    `json:"pagecount"` // 总页数
+	Limit     any    `json:"limit"`     // 每页数据量
+	Total     int    `json:"total"`     // 总数据量
+	List      []T    `json:"list"`      // 数据List集合
+}
+
+// ActorDetail 演员详情
+type ActorDetail struct {
+	ActorID        int64  `json:"actor_id"`        // 演员ID
+	TypeID         int64  `json:"type_id"`         // 分类ID
+	ActorName      string `json:"actor_name"`      // 姓名
+	ActorEn        string `json:"actor_en"`        // 姓名拼音
+	ActorAlias     string `json:"actor_alias"`     // 别名
+	ActorSex       string `json:"actor_sex"`       // 性别
+	ActorArea      string `json:"actor_area"`      // 地区
+	ActorHeight    string `json:"actor_height"`    // 身高
+	ActorWeight    string `json:"actor_weight"`    // 体重
+	ActorBirthday  string `json:"actor_birthday"`  // 生日
+	ActorBirthArea string `json:"actor_birtharea"` // 出生地
+	ActorBlood     string `json:"actor_blood"`     // 血型
+	ActorStarSign  string `json:"actor_starsign"`  // 星座
+	ActorSchool    string `json:"actor_school"`    // 毕业院校
+	ActorWorks     string `json:"actor_works"`     // 代表作品
+	ActorTag       string `json:"actor_tag"`       // 标签
+	ActorClass     string `json:"actor_class"`     // 扩展分类
+	ActorPic       string `json:"actor_pic"`       // 照片
+	ActorBlurb     string `json:"actor_blurb"`     // 简介
+	ActorRemarks   string `json:"actor_remarks"`   // 备注
+	ActorContent   string `json:"actor_content"`   // 详细介绍
+	ActorTime      any    `json:"actor_time"`      // 更新时间
+}
+
+// RoleDetail 角色详情
+type RoleDetail struct {
+	RoleID      int64  `json:"role_id"`      // 角色ID
+	RoleRid     int64  `json:"role_rid"`     // 所属影片ID
+	RoleName    string `json:"role_name"`    // 角色名称
+	RoleEn      string `json:"role_en"`      // 角色名称拼音
+	RoleActor   string `json:"role_actor"`   // 扮演者
+	RoleRemarks string `json:"role_remarks"` // 备注
+	RolePic     string `json:"role_pic"`     // 角色图片
+	RoleSort    int    `json:"role_sort"`    // 排序
+	RoleContent string `json:"role_content"` // 角色介绍
+	RoleTime    any    `json:"role_time"`    // 更新时间
+}
+
+// ArticleDetail 文章详情
+type ArticleDetail struct {
+	ArtID      int64  `json:"art_id"`      // 文章ID
+	TypeID     int64  `json:"type_id"`     // 分类ID
+	TypeName   string `json:"type_name"`   // 分类名称
+	ArtName    string `json:"art_name"`    // 标题
+	ArtSub     string `json:"art_sub"`     // 副标题
+	ArtFrom    string `json:"art_from"`    // 来源
+	ArtAuthor  string `json:"art_author"`  // 作者
+	ArtTag     string `json:"art_tag"`     // 标签
+	ArtClass   string `json:"art_class"`   // 扩展分类
+	ArtPic     string `json:"art_pic"`     // 封面图
+	ArtBlurb   string `json:"art_blurb"`   // 简介
+	ArtRemarks string `json:"art_remarks"` // 备注
+	ArtRelVod  string `json:"art_rel_vod"` // 关联影片 ids
+	ArtContent string `json:"art_content"` // 正文
+	ArtTime    any    `json:"art_time"`    // 更新时间
+}
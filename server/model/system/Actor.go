@@ -0,0 +1,146 @@
+package system
+
+import (
+	"fmt"
+	"log"
+	"server/config"
+	"server/plugin/db"
+
+	"gorm.io/gorm/clause"
+)
+
+/*
+	采集的演员资料与影片角色
+	演员资料以姓名作为唯一标识, 与影人信息通过姓名关联, 多个采集站存在同名演员时以最近一次采集的资料为准
+	角色信息通过所属影片ID与影片关联, 通过扮演者姓名与影人关联, 所属影片ID为采集站中的影片ID, 需与主站点的影片ID一致
+*/
+
+// Actor 采集的演员资料
+type Actor struct {
+	ID          uint   `json:"id" gorm:"primarykey"`
+	Sid         string `json:"sid"`         // 来源采集站ID
+	OriginId    int64  `json:"originId"`    // 采集站中的演员ID
+	Name        string `json:"name"`        // 姓名
+	En          string `json:"en"`          // 姓名拼音
+	Alias       string `json:"alias"`       // 别名
+	Sex         string `json:"sex"`         // 性别
+	Area        string `json:"area"`        // 地区
+	Height      string `json:"height"`      // 身高
+	Weight      string `json:"weight"`      // 体重
+	Birthday    string `json:"birthday"`    // 生日
+	BirthArea   string `json:"birthArea"`   // 出生地
+	Blood       string `json:"blood"`       // 血型
+	StarSign    string `json:"starSign"`    // 星座
+	School      string `json:"school"`      // 毕业院校
+	Works       string `json:"works"`       // 代表作品
+	Tag         string `json:"tag"`         // 标签
+	Pic         string `json:"pic"`         // 照片
+	Blurb       string `json:"blurb"`       // 简介
+	Content     string `json:"content"`     // 详细介绍
+	UpdateStamp int64  `json:"updateStamp"` // 采集站中的更新时间
+}
+
+// TableName 演员资料表名
+func (Actor) TableName() string {
+	return config.ActorTableName
+}
+
+// FilmRole 采集的影片角色信息
+type FilmRole struct {
+	ID          uint   `json:"id" gorm:"primarykey"`
+	Sid         string `json:"sid"`         // 来源采集站ID
+	OriginId    int64  `json:"originId"`    // 采集站中的角色ID
+	Mid         int64  `json:"mid"`         // 所属影片ID
+	Name        string `json:"name"`        // 角色名称
+	Actor       string `json:"actor"`       // 扮演者
+	Pic         string `json:"pic"`         // 角色图片
+	Remarks     string `json:"remarks"`     // 备注
+	Content     string `json:"content"`     // 角色介绍
+	Sort        int    `json:"sort"`        // 排序
+	UpdateStamp int64  `json:"updateStamp"` // 采集站中的更新时间
+}
+
+// TableName 影片角色表名
+func (FilmRole) TableName() string {
+	return config.FilmRoleTableName
+}
+
+// FilmRoleVo 影片角色以及扮演者对应的影人ID, 扮演者不在影人信息中时为 0
+type FilmRoleVo struct {
+	FilmRole `gorm:"embedded"`
+	PersonId uint `json:"personId"`
+}
+
+// ------------------------------------------------------ MySQL ------------------------------------------------------
+
+// SaveActors 保存演员资料, 同名演员已存在时更新资料
+func SaveActors(list []Actor) error {
+	// 同一批数据中的同名演员保留最后一条, 避免 upsert 语句中存在重复的冲突行
+	index := make(map[string]int)
+	var al []Actor
+	for _, a := range list {
+		if i, ok := index[a.Name]; ok {
+			al[i] = a
+			continue
+		}
+		index[a.Name] = len(al)
+		al = append(al, a)
+	}
+	if len(al) == 0 {
+		return nil
+	}
+	return db.Mdb.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "name"}}, UpdateAll: true}).
+		CreateInBatches(&al, config.MaxScanCount).Error
+}
+
+// GetActorByName 获取指定姓名的演员资料
+func GetActorByName(name string) *Actor {
+	var a Actor
+	if err := db.Mdb.Where("name = ?", name).First(&a).Error; err != nil {
+		return nil
+	}
+	return &a
+}
+
+// SaveFilmRoles 保存影片角色信息, 同一采集站的角色已存在时更新角色信息
+func SaveFilmRoles(list []FilmRole) error {
+	type key struct {
+		sid string
+		id  int64
+	}
+	index := make(map[key]int)
+	var rl []FilmRole
+	for _, r := range list {
+		k := key{r.Sid, r.OriginId}
+		if i, ok := index[k]; ok {
+			rl[i] = r
+			continue
+		}
+		index[k] = len(rl)
+		rl = append(rl, r)
+	}
+	if len(rl) == 0 {
+		return nil
+	}
+	return db.Mdb.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "sid"}, {Name: "origin_id"}}, UpdateAll: true}).
+		CreateInBatches(&rl, config.MaxScanCount).Error
+}
+
+// GetFilmRoles 获取影片的角色信息, 包含合并到该影片中的影片角色
+func GetFilmRoles(mid int64) []FilmRoleVo {
+	mids := []int64{mid}
+	for _, r := range GetMergedFilms(mid) {
+		mids = append(mids, r.Mid)
+	}
+	var list []FilmRoleVo
+	err := db.Mdb.Model(&FilmRole{}).
+		Select(fmt.Sprintf("%s.*, COALESCE(%s.id, 0) AS person_id", config.FilmRoleTableName, config.PersonTableName)).
+		Joins(fmt.Sprintf("LEFT JOIN %s ON %s.name = %s.actor", config.PersonTableName, config.PersonTableName, config.FilmRoleTableName)).
+		Where(fmt.Sprintf("%s.mid IN ?", config.FilmRoleTableName), mids).
+		Order(fmt.Sprintf("%s.sort, %s.id", config.FilmRoleTableName, config.FilmRoleTableName)).
+		Scan(&list).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return list
+}
@@ -0,0 +1,141 @@
+package system
+
+import (
+	"fmt"
+	"log"
+	"server/config"
+	"server/plugin/db"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm/clause"
+)
+
+/*
+	采集的文章资讯
+	文章以 采集站ID + 采集站中的文章ID 作为唯一标识, 重复采集时更新文章内容
+	文章中的关联影片为采集站中的影片ID, 需与主站点的影片ID一致
+*/
+
+// Article 采集的文章资讯
+type Article struct {
+	ID          uint   `json:"id" gorm:"primarykey"`
+	Sid         string `json:"sid"`               // 来源采集站ID
+	OriginId    int64  `json:"originId"`          // 采集站中的文章ID
+	TypeName    string `json:"typeName"`          // 分类名称
+	Name        string `json:"name"`              // 标题
+	Sub         string `json:"sub"`               // 副标题
+	From        string `json:"from"`              // 来源
+	Author      string `json:"author"`            // 作者
+	Tag         string `json:"tag"`               // 标签
+	Pic         string `json:"pic"`               // 封面图
+	Blurb       string `json:"blurb"`             // 简介
+	Remarks     string `json:"remarks"`           // 备注
+	RelVod      string `json:"relVod"`            // 关联影片ID, 使用 , 分隔
+	Content     string `json:"content,omitempty"` // 正文, 列表数据中不包含正文
+	UpdateStamp int64  `json:"updateStamp"`       // 采集站中的更新时间
+}
+
+// TableName 文章资讯表名
+func (Article) TableName() string {
+	return config.ArticleTableName
+}
+
+// ArticleVo 文章详情以及关联影片的基本信息
+type ArticleVo struct {
+	Article
+	Films []MovieBasicInfo `json:"films"` // 关联的可展示影片
+}
+
+// RelatedMids 文章关联的影片ID, 已合并的影片替换为合并后的影片
+func (a Article) RelatedMids() []int64 {
+	var mids []int64
+	for _, s := range strings.Split(a.RelVod, ",") {
+		if mid, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64); err == nil && mid > 0 {
+			mids = append(mids, mid)
+		}
+	}
+	redirects := GetFilmRedirects(mids...)
+	for i, mid := range mids {
+		if r, ok := redirects[mid]; ok {
+			mids[i] = r.Target
+		}
+	}
+	return mids
+}
+
+// ------------------------------------------------------ MySQL ------------------------------------------------------
+
+// SaveArticles 保存文章资讯, 同一采集站的文章已存在时更新文章内容
+func SaveArticles(list []Article) error {
+	type key struct {
+		sid string
+		id  int64
+	}
+	index := make(map[key]int)
+	var al []Article
+	for _, a := range list {
+		k := key{a.Sid, a.OriginId}
+		if i, ok := index[k]; ok {
+			al[i] = a
+			continue
+		}
+		index[k] = len(al)
+		al = append(al, a)
+	}
+	if len(al) == 0 {
+		return nil
+	}
+	return db.Mdb.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "sid"}, {Name: "origin_id"}}, UpdateAll: true}).
+		CreateInBatches(&al, config.MaxScanCount).Error
+}
+
+// GetArticleList 文章分页数据, 按照更新时间倒序排列
+func GetArticleList(keyword, typeName string, page *Page) []Article {
+	qw := db.Mdb.Model(&Article{})
+	if keyword != "" {
+		qw = qw.Where(db.Like("name"), fmt.Sprint(`%`, keyword, `%`))
+	}
+	if typeName != "" {
+		qw = qw.Where("type_name = ?", typeName)
+	}
+	GetPage(qw, page)
+	var list []Article
+	if err := qw.Omit("content").Limit(page.PageSize).Offset((page.Current - 1) * page.PageSize).Order("update_stamp DESC, id DESC").Find(&list).Error; err != nil {
+		log.Println(err)
+		return nil
+	}
+	return list
+}
+
+// GetArticleTypes 获取文章的所有分类名称
+func GetArticleTypes() []string {
+	var types []string
+	if err := db.Mdb.Model(&Article{}).Where("type_name <> ''").Distinct("type_name").Order("type_name").Pluck("type_name", &types).Error; err != nil {
+		log.Println(err)
+	}
+	return types
+}
+
+// GetArticleById 通过ID获取文章详情
+func GetArticleById(id uint) *Article {
+	var a Article
+	if err := db.Mdb.First(&a, id).Error; err != nil {
+		return nil
+	}
+	return &a
+}
+
+// GetArticleFilms 获取文章关联的可展示影片
+func GetArticleFilms(t Tenant, a Article) []SearchInfo {
+	mids := a.RelatedMids()
+	if len(mids) == 0 {
+		return nil
+	}
+	var sl []SearchInfo
+	if err := db.Mdb.Model(&SearchInfo{}).Scopes(VisibleFilm(t)).Where("mid IN ?", mids).Order("update_stamp DESC").Find(&sl).Error; err != nil {
+		log.Println(err)
+		return nil
+	}
+	return sl
+}
@@ -2,17 +2,23 @@ package system
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"server/config"
 	"server/plugin/db"
+	"sort"
+	"strconv"
 )
 
 // Category 分类信息
 type Category struct {
-	Id   int64  `json:"id"`   // 分类ID
-	Pid  int64  `json:"pid"`  // 父级分类ID
-	Name string `json:"name"` // 分类名称
-	Show bool   `json:"show"` // 是否展示
+	Id     int64  `json:"id"`     // 分类ID
+	Pid    int64  `json:"pid"`    // 父级分类ID
+	Name   string `json:"name"`   // 分类名称
+	Show   bool   `json:"show"`   // 是否展示
+	Sort   int    `json:"sort"`   // 排序值, 同级分类按升序排列
+	Custom bool   `json:"custom"` // 是否为自定义分类(非采集站提供)
 }
 
 // CategoryTree 分类信息树形结构
@@ -26,6 +32,7 @@ type CategoryTree struct {
 // SaveCategoryTree 保存影片分类信息
 func SaveCategoryTree(tree *CategoryTree) error {
 	data, _ := json.Marshal(tree)
+	defer ResetFilmVisibility()
 	return db.Rdb.Set(db.Cxt, config.CategoryTreeKey, data, config.FilmExpired).Err()
 }
 
@@ -46,6 +53,309 @@ func ExistsCategoryTree() bool {
 	return exists == 1
 }
 
+// Find 查找指定ID对应的分类节点
+func (t *CategoryTree) Find(id int64) *CategoryTree {
+	if t.Category == nil {
+		return nil
+	}
+	if t.Id == id {
+		return t
+	}
+	for _, c := range t.Children {
+		if n := c.Find(id); n != nil {
+			return n
+		}
+	}
+	return nil
+}
+
+// RootId 获取分类所属的一级分类ID, 一级分类返回自身ID
+func (t *CategoryTree) RootId(id int64) (int64, bool) {
+	for _, c := range t.Children {
+		if c.Find(id) != nil {
+			return c.Id, true
+		}
+	}
+	return 0, false
+}
+
+// Ids 获取当前分类及其所有子孙分类的ID
+func (t *CategoryTree) Ids() []int64 {
+	var ids []int64
+	t.walk(func(c *Category) { ids = append(ids, c.Id) })
+	return ids
+}
+
+// MaxId 获取分类树中最大的分类ID
+func (t *CategoryTree) MaxId() int64 {
+	var max int64
+	t.walk(func(c *Category) {
+		if c.Id > max {
+			max = c.Id
+		}
+	})
+	return max
+}
+
+// Remove 从分类树中移除指定分类节点(包含其子分类), 返回被移除的节点
+func (t *CategoryTree) Remove(id int64) *CategoryTree {
+	for i, c := range t.Children {
+		if c.Id == id {
+			t.Children = append(t.Children[:i], t.Children[i+1:]...)
+			return c
+		}
+		if n := c.Remove(id); n != nil {
+			return n
+		}
+	}
+	return nil
+}
+
+// Append 将分类节点添加到当前节点下并按排序值重新排列
+func (t *CategoryTree) Append(nodes ...*CategoryTree) {
+	for _, n := range nodes {
+		n.Pid = t.Id
+		t.Children = append(t.Children, n)
+	}
+	sort.SliceStable(t.Children, func(i, j int) bool { return t.Children[i].Sort < t.Children[j].Sort })
+}
+
+// SortChildren 按照排序值重新排列所有层级的子分类
+func (t *CategoryTree) SortChildren() {
+	sort.SliceStable(t.Children, func(i, j int) bool { return t.Children[i].Sort < t.Children[j].Sort })
+	for _, c := range t.Children {
+		c.SortChildren()
+	}
+}
+
+// MergeCollected 合并采集站最新的分类信息, 已存在的分类保留管理员的设置, 仅追加新增的分类
+// mapping 中记录的分类已被合并到其他分类, 不再重新添加
+func (t *CategoryTree) MergeCollected(src *CategoryTree, mapping map[int64]int64) {
+	var merge func(node *CategoryTree)
+	merge = func(node *CategoryTree) {
+		for _, c := range node.Children {
+			if _, merged := mapping[c.Id]; !merged && t.Find(c.Id) == nil {
+				parent := t.Find(c.Pid)
+				if parent == nil {
+					parent = t
+				}
+				category := *c.Category
+				parent.Append(&CategoryTree{Category: &category})
+			}
+			merge(c)
+		}
+	}
+	merge(src)
+}
+
+// HiddenIds 获取所有隐藏分类的ID, 父级分类隐藏时其子分类同样视为隐藏
+func (t *CategoryTree) HiddenIds() []int64 {
+	var ids []int64
+	var find func(node *CategoryTree, hidden bool)
+	find = func(node *CategoryTree, hidden bool) {
+		for _, c := range node.Children {
+			h := hidden || !c.Show
+			if h {
+				ids = append(ids, c.Id)
+			}
+			find(c, h)
+		}
+	}
+	if t.Category != nil {
+		find(t, false)
+	}
+	return ids
+}
+
+// walk 遍历分类树中的所有分类节点
+func (t *CategoryTree) walk(fn func(c *Category)) {
+	if t.Category == nil {
+		return
+	}
+	fn(t.Category)
+	for _, c := range t.Children {
+		c.walk(fn)
+	}
+}
+
+// CategoryIds 获取分类及其所有子孙分类的ID, 用于按分类查询影片
+func CategoryIds(cid int64) []int64 {
+	tree := GetCategoryTree()
+	if n := tree.Find(cid); n != nil {
+		return n.Ids()
+	}
+	return []int64{cid}
+}
+
+// ResolveFilmCategory 按照当前分类树修正影片的所属分类
+// 已合并的分类转移到目标分类, 一级分类ID以分类树中的实际层级为准
+func ResolveFilmCategory(list []MovieDetail) {
+	tree := GetCategoryTree()
+	if tree.Category == nil {
+		return
+	}
+	mapping := GetCategoryMapping()
+	var mids []int64
+	for _, d := range list {
+		mids = append(mids, d.Id)
+	}
+	films := GetFilmCategories(mids...)
+	for i := range list {
+		d := &list[i]
+		// 单独调整过分类的影片使用调整后的分类
+		if cid, ok := films[d.Id]; ok && tree.Find(cid) != nil {
+			d.Cid = cid
+			d.CName = tree.Find(cid).Name
+		}
+		if to, ok := mapping[d.Cid]; ok {
+			d.Cid = to
+			if n := tree.Find(to); n != nil {
+				d.CName = n.Name
+			}
+		}
+		if root, ok := tree.RootId(d.Cid); ok {
+			d.Pid = root
+		}
+	}
+}
+
+// ------------------------------------------------------ Redis ------------------------------------------------------
+
+// SaveCategoryMapping 记录分类合并关系, 已指向原分类的映射同步指向新的目标分类
+func SaveCategoryMapping(from, to int64) error {
+	fields := map[string]any{strconv.FormatInt(from, 10): to}
+	for k, v := range GetCategoryMapping() {
+		if v == from {
+			fields[strconv.FormatInt(k, 10)] = to
+		}
+	}
+	// 目标分类此前被合并过时移除其映射, 避免形成环
+	db.Rdb.HDel(db.Cxt, config.CategoryMappingKey, strconv.FormatInt(to, 10))
+	return db.Rdb.HSet(db.Cxt, config.CategoryMappingKey, fields).Err()
+}
+
+// GetCategoryMapping 获取分类合并关系 {原分类ID: 目标分类ID}
+func GetCategoryMapping() map[int64]int64 {
+	res := make(map[int64]int64)
+	for k, v := range db.Rdb.HGetAll(db.Cxt, config.CategoryMappingKey).Val() {
+		from, err1 := strconv.ParseInt(k, 10, 64)
+		to, err2 := strconv.ParseInt(v, 10, 64)
+		if err1 == nil && err2 == nil {
+			res[from] = to
+		}
+	}
+	return res
+}
+
+// SaveFilmCategory 记录单部影片调整后的分类, 重新采集时保持调整后的分类
+func SaveFilmCategory(mid, cid int64) error {
+	return db.Rdb.HSet(db.Cxt, config.FilmCategoryKey, strconv.FormatInt(mid, 10), cid).Err()
+}
+
+// GetFilmCategories 批量获取影片调整后的分类 {mid: cid}
+func GetFilmCategories(mids ...int64) map[int64]int64 {
+	res := make(map[int64]int64)
+	if len(mids) == 0 || db.Rdb.Exists(db.Cxt, config.FilmCategoryKey).Val() == 0 {
+		return res
+	}
+	var fields []string
+	for _, mid := range mids {
+		fields = append(fields, strconv.FormatInt(mid, 10))
+	}
+	for i, v := range db.Rdb.HMGet(db.Cxt, config.FilmCategoryKey, fields...).Val() {
+		if s, ok := v.(string); ok {
+			if cid, err := strconv.ParseInt(s, 10, 64); err == nil {
+				res[mids[i]] = cid
+			}
+		}
+	}
+	return res
+}
+
+// MoveFilmCategory 将单部影片转移到目标分类, 同步修改检索信息以及 key 中包含分类ID的影片数据
+func MoveFilmCategory(mid, from, to, pid int64, cName string) error {
+	moveFilmKeys(mid, from, to, pid, cName)
+	err := db.Mdb.Unscoped().Model(&SearchInfo{}).Where("mid = ?", mid).
+		Updates(map[string]any{"cid": to, "pid": pid, "c_name": cName}).Error
+	if err != nil {
+		return err
+	}
+	return db.Mdb.Model(&FilmRecycle{}).Where("mid = ?", mid).
+		Updates(map[string]any{"cid": to, "pid": pid, "c_name": cName}).Error
+}
+
+// ReassignFilmCategory 将分类下的影片转移到目标分类, 同步修改检索信息以及 key 中包含分类ID的影片数据
+// from 与 to 相同时仅修改影片的一级分类ID, 返回转移的影片数量
+func ReassignFilmCategory(from, to, pid int64, cName string) (int, error) {
+	// 先获取全部影片ID, 避免分批查询过程中修改分类导致数据遗漏
+	var mids []int64
+	if err := db.Mdb.Unscoped().Model(&SearchInfo{}).Where("cid = ?", from).Pluck("mid", &mids).Error; err != nil {
+		return 0, err
+	}
+	for _, mid := range mids {
+		moveFilmKeys(mid, from, to, pid, cName)
+	}
+	if len(mids) == 0 {
+		return 0, nil
+	}
+	err := db.Mdb.Unscoped().Model(&SearchInfo{}).Where("cid = ?", from).
+		Updates(map[string]any{"cid": to, "pid": pid, "c_name": cName}).Error
+	if err != nil {
+		return 0, err
+	}
+	err = db.Mdb.Model(&FilmRecycle{}).Where("cid = ?", from).
+		Updates(map[string]any{"cid": to, "pid": pid, "c_name": cName}).Error
+	return len(mids), err
+}
+
+// moveFilmKeys 修改影片详情和基本信息中的分类信息
+func moveFilmKeys(mid, from, to, pid int64, cName string) {
+	moveFilmKey(config.MovieDetailKey, from, to, mid, func(data []byte) any {
+		d := MovieDetail{}
+		_ = json.Unmarshal(data, &d)
+		d.Cid, d.Pid, d.CName = to, pid, cName
+		return d
+	})
+	moveFilmKey(config.MovieBasicInfoKey, from, to, mid, func(data []byte) any {
+		b := MovieBasicInfo{}
+		_ = json.Unmarshal(data, &b)
+		b.Cid, b.Pid, b.CName = to, pid, cName
+		return b
+	})
+}
+
+// moveFilmKey 修改影片数据中的分类信息, 分类ID变化时同时迁移对应的 key
+func moveFilmKey(pattern string, from, to, mid int64, fn func(data []byte) any) {
+	key := fmt.Sprintf(pattern, from, mid)
+	data, err := db.Rdb.Get(db.Cxt, key).Bytes()
+	if err != nil {
+		return
+	}
+	val, _ := json.Marshal(fn(data))
+	if err = db.Rdb.Set(db.Cxt, fmt.Sprintf(pattern, to, mid), val, config.FilmExpired).Err(); err != nil {
+		log.Println("Move Film Category Error: ", err)
+		return
+	}
+	if from != to {
+		db.Rdb.Del(db.Cxt, key)
+	}
+}
+
+// ValidCategoryMove 校验分类是否可以移动到目标分类下
+func ValidCategoryMove(tree *CategoryTree, id, target int64) error {
+	node := tree.Find(id)
+	if node == nil || id == tree.Id {
+		return errors.New("分类信息不存在")
+	}
+	if tree.Find(target) == nil {
+		return errors.New("目标分类不存在")
+	}
+	if node.Find(target) != nil {
+		return errors.New("不能移动到当前分类或其子分类下")
+	}
+	return nil
+}
+
 // GetChildrenTree 根据影片Id获取对应分类的子分类信息
 func GetChildrenTree(id int64) []*CategoryTree {
 	tree := GetCategoryTree()
@@ -1,7 +1,6 @@
 package system
 
 import (
-	"fmt"
 	"gorm.io/gorm"
 	"log"
 	"server/config"
@@ -26,17 +25,6 @@ func (fr FailureRecord) TableName() string {
 	return config.FailureRecordTableName
 }
 
-// CreateFailureRecordTable 创建失效记录表
-func CreateFailureRecordTable() {
-	var fl = &FailureRecord{}
-	// 不存在则创建FailureRecord表
-	if !db.Mdb.Migrator().HasTable(fl) {
-		if err := db.Mdb.AutoMigrate(fl); err != nil {
-			log.Println("Create Table failure_record failed:", err)
-		}
-	}
-}
-
 // SaveFailureRecord 添加采集失效记录
 func SaveFailureRecord(fl FailureRecord) {
 	// 数据量不多但存在并发问题, 开启事务
@@ -139,7 +127,7 @@ func DelDoneRecord() {
 // TruncateRecordTable  截断 record table
 func TruncateRecordTable() {
 	var s FailureRecord
-	err := db.Mdb.Exec(fmt.Sprintf("TRUNCATE Table %s", s.TableName())).Error
+	err := db.TruncateTable(s.TableName())
 	if err != nil {
 		log.Println("TRUNCATE TABLE Error: ", err)
 	}
@@ -0,0 +1,80 @@
+package system
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/*
+	分集剧情
+	采集站的分集剧情名称与播放列表中的集数名称并不总是一致, 统一解析出集数序号后按序号匹配
+*/
+
+// EpisodePlot 分集剧情信息
+type EpisodePlot struct {
+	Episode int    `json:"episode"` // 集数序号, 从 1 开始
+	Name    string `json:"name"`    // 分集名称
+	Content string `json:"content"` // 剧情介绍
+}
+
+var (
+	// episodeNumPattern 第x集 | 第x话 | 第x期 | 第x回 形式的集数名称
+	episodeNumPattern = regexp.MustCompile(`第\s*([0-9]+|[零〇一二两三四五六七八九十百千]+)\s*[集话話期回]`)
+	// episodeEpPattern EPxx | Exx 形式的集数名称
+	episodeEpPattern = regexp.MustCompile(`(?i)\bEP?\s*0*([0-9]+)\b`)
+	// episodeDigitPattern 仅包含数字的集数名称
+	episodeDigitPattern = regexp.MustCompile(`^0*([0-9]+)\s*[集话話期回]?$`)
+)
+
+// chineseDigits 中文数字对应的数值
+var chineseDigits = map[rune]int{'零': 0, '〇': 0, '一': 1, '二': 2, '两': 2, '三': 3, '四': 4, '五': 5, '六': 6, '七': 7, '八': 8, '九': 9}
+
+// EpisodeNumber 解析集数名称中的集数序号, 无法解析时返回 0
+func EpisodeNumber(name string) int {
+	name = strings.TrimSpace(name)
+	for _, p := range []*regexp.Regexp{episodeNumPattern, episodeEpPattern, episodeDigitPattern} {
+		if m := p.FindStringSubmatch(name); m != nil {
+			if n, err := strconv.Atoi(m[1]); err == nil {
+				return n
+			}
+			return chineseNumber(m[1])
+		}
+	}
+	return 0
+}
+
+// chineseNumber 将千以内的中文数字转换为数值, 例: 十二 -> 12, 一百零五 -> 105
+func chineseNumber(s string) int {
+	total, digit := 0, 0
+	for _, r := range s {
+		switch r {
+		case '十':
+			total += max(digit, 1) * 10
+			digit = 0
+		case '百':
+			total += digit * 100
+			digit = 0
+		case '千':
+			total += digit * 1000
+			digit = 0
+		default:
+			digit = chineseDigits[r]
+		}
+	}
+	return total + digit
+}
+
+// GetEpisodePlot 获取播放列表中指定集数的剧情, 集数名称无法解析序号时使用其在播放列表中的位置
+func (d MovieDetail) GetEpisodePlot(name string, index int) *EpisodePlot {
+	n := EpisodeNumber(name)
+	if n == 0 {
+		n = index + 1
+	}
+	for i := range d.Plots {
+		if d.Plots[i].Episode == n {
+			return &d.Plots[i]
+		}
+	}
+	return nil
+}
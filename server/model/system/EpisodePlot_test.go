@@ -0,0 +1,43 @@
+package system
+
+import "testing"
+
+func TestEpisodeNumber(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want int
+	}{
+		{"阿拉伯数字", "第1集", 1},
+		{"数字前后空格", "第 12 集", 12},
+		{"前导零", "第05期", 5},
+		{"中文数字", "第三话", 3},
+		{"中文数字十位", "第十集", 10},
+		{"中文数字十几", "第十二集", 12},
+		{"中文数字几十", "第二十集", 20},
+		{"中文数字百位", "第一百零五集", 105},
+		{"中文数字千位", "第一千集", 1000},
+		{"两", "第两回", 2},
+		{"繁体话", "第十一話", 11},
+		{"带后缀说明", "第3集（上）", 3},
+		{"首尾空白", "  第7集  ", 7},
+		{"EP", "EP03", 3},
+		{"EP小写带空格", "ep 7", 7},
+		{"E", "E12", 12},
+		{"纯数字", "05", 5},
+		{"数字加集", "12集", 12},
+		{"零集", "第零集", 0},
+		{"空字符串", "", 0},
+		{"缺少集数", "第集", 0},
+		{"非集数名称", "预告", 0},
+		{"画质不视为集数", "1080P", 0},
+		{"单词中的E不视为集数", "HE12", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EpisodeNumber(tt.in); got != tt.want {
+				t.Errorf("EpisodeNumber(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
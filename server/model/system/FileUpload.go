@@ -50,23 +50,6 @@ func (f *FileInfo) StoragePath() string {
 	return storage
 }
 
-// CreateFileTable 创建图片关联信息存储表
-func CreateFileTable() {
-	// 如果不存在则创建表 并设置自增ID初始值为10000
-	if !ExistFileTable() {
-		err := db.Mdb.AutoMigrate(&FileInfo{})
-		if err != nil {
-			log.Println("Create Table FileInfo Failed: ", err)
-		}
-	}
-}
-
-// ExistFileTable 是否存在Picture表
-func ExistFileTable() bool {
-	// 1. 判断表中是否存在当前表
-	return db.Mdb.Migrator().HasTable(&FileInfo{})
-}
-
 // SaveGallery 保存图片关联信息
 func SaveGallery(f FileInfo) {
 	db.Mdb.Create(&f)
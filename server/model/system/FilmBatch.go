@@ -0,0 +1,135 @@
+package system
+
+import (
+	"encoding/json"
+	"server/config"
+	"server/plugin/db"
+	"sort"
+	"time"
+)
+
+/*
+	影片批量操作任务
+	批量操作在后台逐部影片执行, 执行进度与结果报告保存在 redis 中, 仅保留最近的任务记录
+*/
+
+const (
+	BatchDelete    = "delete"    // 删除影片(移入回收站)
+	BatchRestore   = "restore"   // 从回收站恢复影片
+	BatchCategory  = "category"  // 修改影片分类
+	BatchVisible   = "visible"   // 设置影片展示状态
+	BatchRecollect = "recollect" // 从主采集站重新采集
+	BatchPoster    = "poster"    // 绑定影片海报
+)
+
+const (
+	BatchRunning  = "running"  // 执行中
+	BatchFinished = "finished" // 已完成
+)
+
+// FilmBatchVo 批量操作请求参数, Ids 与 Filter 二选一, Ids 优先
+type FilmBatchVo struct {
+	Ids     []int64   `json:"ids"`     // 影片ID(mid)
+	Filter  *SearchVo `json:"filter"`  // 与影片检索列表相同的筛选条件
+	Action  string    `json:"action"`  // 操作类型 delete | restore | category | visible | recollect | poster
+	Cid     int64     `json:"cid"`     // 目标分类ID, category 操作使用
+	Visible bool      `json:"visible"` // 是否展示, visible 操作使用
+	Picture string    `json:"picture"` // 海报图片地址, poster 操作使用
+}
+
+// FilmBatchError 批量操作中执行失败的影片
+type FilmBatchError struct {
+	Mid    int64  `json:"mid"`    // 影片ID
+	Reason string `json:"reason"` // 失败原因
+}
+
+// FilmBatchJob 批量操作任务信息
+type FilmBatchJob struct {
+	Id         string           `json:"id"`         // 任务ID
+	Action     string           `json:"action"`     // 操作类型
+	Params     FilmBatchVo      `json:"params"`     // 请求参数
+	Status     string           `json:"status"`     // 任务状态 running | finished
+	Total      int              `json:"total"`      // 需要处理的影片数量
+	Done       int              `json:"done"`       // 已处理的影片数量
+	Success    int              `json:"success"`    // 执行成功的数量
+	Failed     int              `json:"failed"`     // 执行失败的数量
+	Errors     []FilmBatchError `json:"errors"`     // 失败明细, 最多保留 FilmBatchErrorLimit 条
+	Operator   string           `json:"operator"`   // 操作人
+	CreateTime int64            `json:"createTime"` // 创建时间
+	EndTime    int64            `json:"endTime"`    // 完成时间
+}
+
+// Fail 记录执行失败的影片
+func (j *FilmBatchJob) Fail(mid int64, err error) {
+	j.Failed++
+	if len(j.Errors) < config.FilmBatchErrorLimit {
+		j.Errors = append(j.Errors, FilmBatchError{Mid: mid, Reason: err.Error()})
+	}
+}
+
+// Progress 任务执行进度(百分比)
+func (j *FilmBatchJob) Progress() int {
+	if j.Total == 0 {
+		return 100
+	}
+	return j.Done * 100 / j.Total
+}
+
+// MarshalJSON 序列化时附带任务执行进度
+func (j FilmBatchJob) MarshalJSON() ([]byte, error) {
+	type job FilmBatchJob
+	return json.Marshal(struct {
+		job
+		Progress int `json:"progress"`
+	}{job(j), j.Progress()})
+}
+
+// ------------------------------------------------------ Redis ------------------------------------------------------
+
+// SaveFilmBatchJob 保存批量操作任务信息
+func SaveFilmBatchJob(j FilmBatchJob) error {
+	data, _ := json.Marshal(j)
+	return db.Rdb.HSet(db.Cxt, config.FilmBatchJobKey, j.Id, data).Err()
+}
+
+// GetFilmBatchJob 获取批量操作任务信息
+func GetFilmBatchJob(id string) *FilmBatchJob {
+	data, err := db.Rdb.HGet(db.Cxt, config.FilmBatchJobKey, id).Result()
+	if err != nil {
+		return nil
+	}
+	j := FilmBatchJob{}
+	if err = json.Unmarshal([]byte(data), &j); err != nil {
+		return nil
+	}
+	return &j
+}
+
+// GetFilmBatchJobs 获取所有批量操作任务, 按创建时间倒序排列
+func GetFilmBatchJobs() []FilmBatchJob {
+	var jl []FilmBatchJob
+	for _, v := range db.Rdb.HGetAll(db.Cxt, config.FilmBatchJobKey).Val() {
+		j := FilmBatchJob{}
+		if err := json.Unmarshal([]byte(v), &j); err == nil {
+			jl = append(jl, j)
+		}
+	}
+	sort.Slice(jl, func(i, k int) bool { return jl[i].CreateTime > jl[k].CreateTime })
+	return jl
+}
+
+// PruneFilmBatchJobs 清理超出保留数量的已完成任务记录
+func PruneFilmBatchJobs() {
+	jl := GetFilmBatchJobs()
+	for i := config.FilmBatchJobRetain; i < len(jl); i++ {
+		if jl[i].Status == BatchFinished {
+			db.Rdb.HDel(db.Cxt, config.FilmBatchJobKey, jl[i].Id)
+		}
+	}
+}
+
+// NewFilmBatchJob 创建批量操作任务
+func NewFilmBatchJob(id string, params FilmBatchVo, total int, operator string) FilmBatchJob {
+	return FilmBatchJob{Id: id, Action: params.Action, Params: params, Status: BatchRunning, Total: total,
+		Operator: operator, CreateTime: time.Now().Unix()}
+}
@@ -0,0 +1,253 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"server/config"
+	"server/plugin/db"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+/*
+	影片屏蔽规则(下架名单)
+	采集时匹配屏蔽规则的影片不会写入 Redis 和 MySQL, 规则的添加与删除均记录操作人
+	规则类型:
+		source - 指定采集站的影片ID
+		dbid   - 豆瓣ID
+		name   - 片名匹配规则(正则表达式)
+*/
+
+const (
+	BlockSource = "source"
+	BlockDbId   = "dbid"
+	BlockName   = "name"
+)
+
+// FilmBlock 影片屏蔽规则
+type FilmBlock struct {
+	gorm.Model
+	Type     string `json:"type"`     // 规则类型 source | dbid | name
+	SourceId string `json:"sourceId"` // 采集站ID, source 类型使用
+	VodId    int64  `json:"vodId"`    // 采集站中的影片ID, source 类型使用
+	DbId     int64  `json:"dbId"`     // 豆瓣ID, dbid 类型使用
+	Pattern  string `json:"pattern"`  // 片名匹配规则, name 类型使用
+	Remark   string `json:"remark"`   // 屏蔽原因
+	Operator string `json:"operator"` // 添加规则的操作人
+}
+
+// TableName 屏蔽规则表名
+func (b FilmBlock) TableName() string {
+	return config.FilmBlockTableName
+}
+
+// String 屏蔽规则描述信息
+func (b FilmBlock) String() string {
+	switch b.Type {
+	case BlockSource:
+		return fmt.Sprintf("source:%s:%d", b.SourceId, b.VodId)
+	case BlockDbId:
+		return fmt.Sprintf("dbid:%d", b.DbId)
+	case BlockName:
+		return fmt.Sprintf("name:%s", b.Pattern)
+	}
+	return b.Type
+}
+
+// Valid 校验屏蔽规则参数
+func (b FilmBlock) Valid() error {
+	switch b.Type {
+	case BlockSource:
+		if b.SourceId == "" || b.VodId <= 0 {
+			return errors.New("source 类型的规则需指定采集站ID和影片ID")
+		}
+	case BlockDbId:
+		if b.DbId <= 0 {
+			return errors.New("dbid 类型的规则需指定豆瓣ID")
+		}
+	case BlockName:
+		if b.Pattern == "" {
+			return errors.New("name 类型的规则需指定片名匹配规则")
+		}
+		if _, err := regexp.Compile(b.Pattern); err != nil {
+			return fmt.Errorf("片名匹配规则格式异常: %w", err)
+		}
+	default:
+		return fmt.Errorf("屏蔽规则类型异常: %s, 可选值 %s | %s | %s", b.Type, BlockSource, BlockDbId, BlockName)
+	}
+	return nil
+}
+
+// FilmBlockAudit 屏蔽规则操作记录
+type FilmBlockAudit struct {
+	gorm.Model
+	BlockId  uint   `json:"blockId"`  // 屏蔽规则ID
+	Action   string `json:"action"`   // 操作类型 add | remove
+	Rule     string `json:"rule"`     // 规则描述
+	Operator string `json:"operator"` // 操作人
+}
+
+// TableName 屏蔽规则操作记录表名
+func (a FilmBlockAudit) TableName() string {
+	return config.FilmBlockAuditTableName
+}
+
+// BlockMatcher 屏蔽规则匹配器, 采集时一次加载全部规则
+type BlockMatcher struct {
+	sources  map[string]bool
+	dbIds    map[int64]bool
+	patterns []*regexp.Regexp
+}
+
+// NewBlockMatcher 通过屏蔽规则生成匹配器
+func NewBlockMatcher(rules []FilmBlock) *BlockMatcher {
+	m := &BlockMatcher{sources: make(map[string]bool), dbIds: make(map[int64]bool)}
+	for _, r := range rules {
+		switch r.Type {
+		case BlockSource:
+			m.sources[fmt.Sprintf("%s:%d", r.SourceId, r.VodId)] = true
+		case BlockDbId:
+			m.dbIds[r.DbId] = true
+		case BlockName:
+			if re, err := regexp.Compile(r.Pattern); err == nil {
+				m.patterns = append(m.patterns, re)
+			}
+		}
+	}
+	return m
+}
+
+// Empty 是否不存在任何屏蔽规则
+func (m *BlockMatcher) Empty() bool {
+	return len(m.sources) == 0 && len(m.dbIds) == 0 && len(m.patterns) == 0
+}
+
+// Match 影片是否匹配屏蔽规则, sourceId 为影片所属采集站
+func (m *BlockMatcher) Match(sourceId string, d MovieDetail) bool {
+	if m.sources[fmt.Sprintf("%s:%d", sourceId, d.Id)] {
+		return true
+	}
+	if d.DbId > 0 && m.dbIds[d.DbId] {
+		return true
+	}
+	for _, re := range m.patterns {
+		if re.MatchString(d.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// blockMatcher 屏蔽规则匹配器的本地缓存, 避免每次采集都重新加载规则并编译正则表达式
+var blockMatcher = struct {
+	sync.RWMutex
+	m       *BlockMatcher
+	expired time.Time
+}{}
+
+// ResetBlockMatcher 屏蔽规则变更后清除本地缓存
+func ResetBlockMatcher() {
+	blockMatcher.Lock()
+	blockMatcher.expired = time.Time{}
+	blockMatcher.Unlock()
+}
+
+// loadBlockMatcher 获取屏蔽规则匹配器, 缓存过期后重新加载
+func loadBlockMatcher() *BlockMatcher {
+	blockMatcher.RLock()
+	m, expired := blockMatcher.m, blockMatcher.expired
+	blockMatcher.RUnlock()
+	if m != nil && time.Now().Before(expired) {
+		return m
+	}
+	m = NewBlockMatcher(AllFilmBlocks())
+	blockMatcher.Lock()
+	blockMatcher.m, blockMatcher.expired = m, time.Now().Add(config.BlockMatcherExpired)
+	blockMatcher.Unlock()
+	return m
+}
+
+// ------------------------------------------------------ MySQL ------------------------------------------------------
+
+// AddFilmBlock 添加屏蔽规则并记录操作信息
+func AddFilmBlock(b *FilmBlock) error {
+	defer ResetBlockMatcher()
+	return db.Mdb.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(b).Error; err != nil {
+			return err
+		}
+		return tx.Create(&FilmBlockAudit{BlockId: b.ID, Action: "add", Rule: b.String(), Operator: b.Operator}).Error
+	})
+}
+
+// DelFilmBlock 删除屏蔽规则并记录操作信息
+func DelFilmBlock(id uint, operator string) error {
+	defer ResetBlockMatcher()
+	return db.Mdb.Transaction(func(tx *gorm.DB) error {
+		var b FilmBlock
+		if err := tx.First(&b, id).Error; err != nil {
+			return errors.New("屏蔽规则不存在")
+		}
+		if err := tx.Unscoped().Delete(&b).Error; err != nil {
+			return err
+		}
+		return tx.Create(&FilmBlockAudit{BlockId: b.ID, Action: "remove", Rule: b.String(), Operator: operator}).Error
+	})
+}
+
+// FilmBlockList 屏蔽规则分页数据
+func FilmBlockList(t string, page *Page) []FilmBlock {
+	qw := db.Mdb.Model(&FilmBlock{})
+	if t != "" {
+		qw = qw.Where("type = ?", t)
+	}
+	GetPage(qw, page)
+	var list []FilmBlock
+	if err := qw.Limit(page.PageSize).Offset((page.Current - 1) * page.PageSize).Order("id DESC").Find(&list).Error; err != nil {
+		log.Println(err)
+		return nil
+	}
+	return list
+}
+
+// AllFilmBlocks 获取全部屏蔽规则
+func AllFilmBlocks() []FilmBlock {
+	var list []FilmBlock
+	if err := db.Mdb.Find(&list).Error; err != nil {
+		log.Println("Get Film Blocks Error: ", err)
+	}
+	return list
+}
+
+// FilmBlockAuditList 屏蔽规则操作记录分页数据
+func FilmBlockAuditList(page *Page) []FilmBlockAudit {
+	qw := db.Mdb.Model(&FilmBlockAudit{})
+	GetPage(qw, page)
+	var list []FilmBlockAudit
+	if err := qw.Limit(page.PageSize).Offset((page.Current - 1) * page.PageSize).Order("id DESC").Find(&list).Error; err != nil {
+		log.Println(err)
+		return nil
+	}
+	return list
+}
+
+// FilterBlockedFilms 过滤掉匹配屏蔽规则的影片
+func FilterBlockedFilms(sourceId string, list []MovieDetail) []MovieDetail {
+	m := loadBlockMatcher()
+	if m.Empty() {
+		return list
+	}
+	res := list[:0:0]
+	for _, d := range list {
+		if m.Match(sourceId, d) {
+			log.Printf("[Block] 跳过已屏蔽的影片: %s %d %s\n", sourceId, d.Id, d.Name)
+			continue
+		}
+		res = append(res, d)
+	}
+	return res
+}
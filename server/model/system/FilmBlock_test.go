@@ -0,0 +1,107 @@
+package system
+
+import (
+	"testing"
+
+	"server/plugin/db"
+)
+
+func TestFilterBlockedFilmsCache(t *testing.T) {
+	list := []MovieDetail{{Id: 1, Name: "屏蔽影片"}, {Id: 2, Name: "正常影片"}}
+	names := func() []string {
+		var res []string
+		for _, d := range FilterBlockedFilms("s1", list) {
+			res = append(res, d.Name)
+		}
+		return res
+	}
+
+	b := FilmBlock{Type: BlockName, Pattern: "^屏蔽", Operator: "test"}
+	if err := AddFilmBlock(&b); err != nil {
+		t.Fatalf("AddFilmBlock error = %v", err)
+	}
+	if got := names(); len(got) != 1 || got[0] != "正常影片" {
+		t.Fatalf("FilterBlockedFilms after AddFilmBlock = %v, want [正常影片]", got)
+	}
+	// 绕过添加流程写入的规则在缓存过期前不生效
+	if err := db.Mdb.Create(&FilmBlock{Type: BlockName, Pattern: "^正常"}).Error; err != nil {
+		t.Fatalf("create block error = %v", err)
+	}
+	if got := names(); len(got) != 1 {
+		t.Errorf("FilterBlockedFilms with cached matcher = %v, want [正常影片]", got)
+	}
+	ResetBlockMatcher()
+	if got := names(); len(got) != 0 {
+		t.Errorf("FilterBlockedFilms after ResetBlockMatcher = %v, want []", got)
+	}
+	// 删除规则后立即生效
+	for _, r := range AllFilmBlocks() {
+		if err := DelFilmBlock(r.ID, "test"); err != nil {
+			t.Fatalf("DelFilmBlock error = %v", err)
+		}
+	}
+	if got := names(); len(got) != 2 {
+		t.Errorf("FilterBlockedFilms after DelFilmBlock = %v, want [屏蔽影片 正常影片]", got)
+	}
+}
+
+func TestFilmBlockValid(t *testing.T) {
+	tests := []struct {
+		name    string
+		block   FilmBlock
+		wantErr bool
+	}{
+		{"采集站影片", FilmBlock{Type: BlockSource, SourceId: "s1", VodId: 1}, false},
+		{"缺少采集站ID", FilmBlock{Type: BlockSource, VodId: 1}, true},
+		{"缺少影片ID", FilmBlock{Type: BlockSource, SourceId: "s1"}, true},
+		{"豆瓣ID", FilmBlock{Type: BlockDbId, DbId: 1292052}, false},
+		{"缺少豆瓣ID", FilmBlock{Type: BlockDbId}, true},
+		{"片名规则", FilmBlock{Type: BlockName, Pattern: "^测试.*"}, false},
+		{"缺少片名规则", FilmBlock{Type: BlockName}, true},
+		{"片名规则格式异常", FilmBlock{Type: BlockName, Pattern: "[测试"}, true},
+		{"规则类型异常", FilmBlock{Type: "actor", Pattern: "测试"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.block.Valid(); (err != nil) != tt.wantErr {
+				t.Errorf("Valid() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBlockMatcherMatch(t *testing.T) {
+	m := NewBlockMatcher([]FilmBlock{
+		{Type: BlockSource, SourceId: "s1", VodId: 10},
+		{Type: BlockDbId, DbId: 1292052},
+		{Type: BlockName, Pattern: "^下架"},
+		// 格式异常的片名规则被忽略
+		{Type: BlockName, Pattern: "[异常"},
+	})
+	tests := []struct {
+		name     string
+		sourceId string
+		film     MovieDetail
+		want     bool
+	}{
+		{"采集站影片ID", "s1", MovieDetail{Id: 10, Name: "正常影片"}, true},
+		{"其他采集站的相同ID", "s2", MovieDetail{Id: 10, Name: "正常影片"}, false},
+		{"豆瓣ID", "s2", MovieDetail{Id: 11, Name: "正常影片", MovieDescriptor: MovieDescriptor{DbId: 1292052}}, true},
+		{"片名规则", "s2", MovieDetail{Id: 12, Name: "下架影片"}, true},
+		{"片名规则未匹配", "s2", MovieDetail{Id: 13, Name: "影片下架"}, false},
+		{"未匹配任何规则", "s1", MovieDetail{Id: 14, Name: "[异常片名"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m.Match(tt.sourceId, tt.film); got != tt.want {
+				t.Errorf("Match(%q, %d %s) = %t, want %t", tt.sourceId, tt.film.Id, tt.film.Name, got, tt.want)
+			}
+		})
+	}
+	if m.Empty() {
+		t.Error("Empty() = true, want false")
+	}
+	if !NewBlockMatcher(nil).Empty() {
+		t.Error("NewBlockMatcher(nil).Empty() = false, want true")
+	}
+}
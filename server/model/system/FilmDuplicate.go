@@ -0,0 +1,430 @@
+package system
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"server/config"
+	"server/plugin/common/util"
+	"server/plugin/db"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+	重复影片检测与合并
+	采集站可能以不同的影片ID重复发布同一部影片(国语/粤语版本, 重新上传等)
+	检测任务依据 豆瓣ID 以及 规范化片名+年份 对检索信息进行分组, 管理员审核后将重复影片合并到保留的影片中
+	合并后的重复影片不再生成检索信息, 访问其ID时重定向到保留的影片, 其播放源在读取详情时追加到保留影片的播放列表中
+*/
+
+const (
+	DuplicateDbId = "dbid" // 豆瓣ID相同
+	DuplicateName = "name" // 规范化片名与年份相同
+)
+
+// DuplicateFilm 疑似重复的影片信息
+type DuplicateFilm struct {
+	Mid         int64  `json:"mid"`         // 影片ID
+	Cid         int64  `json:"cid"`         // 分类ID
+	Name        string `json:"name"`        // 片名
+	CName       string `json:"cName"`       // 分类名称
+	Year        int64  `json:"year"`        // 年份
+	DbId        int64  `json:"dbId"`        // 豆瓣ID
+	Remarks     string `json:"remarks"`     // 更新状态
+	Hits        int64  `json:"hits"`        // 热度
+	UpdateStamp int64  `json:"updateStamp"` // 更新时间
+	Sources     int    `json:"sources"`     // 播放源数量
+	Episodes    int    `json:"episodes"`    // 播放源中的最多集数
+}
+
+// FilmDuplicateGroup 疑似重复的影片分组
+type FilmDuplicateGroup struct {
+	Id      string          `json:"id"`      // 分组ID, 由组内影片ID生成, 组内影片变化后视为新的分组
+	Reasons []string        `json:"reasons"` // 判定依据 dbid | name
+	Keep    int64           `json:"keep"`    // 建议保留的影片ID
+	Films   []DuplicateFilm `json:"films"`   // 组内影片
+}
+
+// FilmDuplicateScan 重复影片检测任务信息
+type FilmDuplicateScan struct {
+	Status    string `json:"status"`    // 任务状态 running | finished
+	Scanned   int    `json:"scanned"`   // 已检测的影片数量
+	Groups    int    `json:"groups"`    // 检测到的疑似重复分组数量
+	Operator  string `json:"operator"`  // 操作人
+	StartTime int64  `json:"startTime"` // 开始时间
+	EndTime   int64  `json:"endTime"`   // 完成时间
+}
+
+// FilmMergeVo 合并重复影片请求参数
+type FilmMergeVo struct {
+	Id   string  `json:"id"`   // 疑似重复分组ID, 合并后从审核列表中移除
+	Keep int64   `json:"keep"` // 保留的影片ID
+	Mids []int64 `json:"mids"` // 需要合并到保留影片中的重复影片ID
+}
+
+// FilmRedirect 重复影片的合并信息
+type FilmRedirect struct {
+	Mid       int64  `json:"mid"`       // 重复影片ID
+	Cid       int64  `json:"cid"`       // 重复影片分类ID, 用于读取其播放源
+	Name      string `json:"name"`      // 重复影片片名
+	Target    int64  `json:"target"`    // 合并到的影片ID
+	Operator  string `json:"operator"`  // 操作人
+	MergeTime int64  `json:"mergeTime"` // 合并时间
+}
+
+// duplicateNameRules 片名规范化时移除的内容: 括号及其中的内容, 版本与清晰度标记, 空白与标点符号
+var duplicateNameRules = []*regexp.Regexp{
+	regexp.MustCompile(`[(（\[【][^)）\]】]*[)）\]】]`),
+	regexp.MustCompile(`(国语|粤语|台语|国配|粤配|台配|普通话|原声|中字|双语)版?`),
+	regexp.MustCompile(`(?i)\b(hd|tc|ts|bd|4k|1080p|720p)\b`),
+	regexp.MustCompile(`[\s\p{P}\p{S}]`),
+}
+
+// NormalizeFilmName 规范化片名, 用于判断不同版本的影片是否为同一部影片, 繁体字转换为简体字
+func NormalizeFilmName(name string) string {
+	n := util.ToSimplified(strings.ToLower(name))
+	for _, re := range duplicateNameRules {
+		n = re.ReplaceAllString(n, "")
+	}
+	return n
+}
+
+// FindDuplicateFilms 对检索信息中的疑似重复影片进行分组, 豆瓣ID或片名年份相同的影片均归入同一分组
+func FindDuplicateFilms(list []SearchInfo) []FilmDuplicateGroup {
+	// 并查集合并满足任一条件的影片
+	parent := make(map[int64]int64)
+	var find func(mid int64) int64
+	find = func(mid int64) int64 {
+		if p := parent[mid]; p != mid {
+			parent[mid] = find(p)
+		}
+		return parent[mid]
+	}
+	union := func(a, b int64) {
+		if ra, rb := find(a), find(b); ra != rb {
+			parent[max(ra, rb)] = min(ra, rb)
+		}
+	}
+	infos := make(map[int64]SearchInfo)
+	dbIds, names := make(map[int64]int64), make(map[string]int64)
+	for _, s := range list {
+		infos[s.Mid] = s
+		parent[s.Mid] = s.Mid
+		if s.DbId > 0 {
+			if m, ok := dbIds[s.DbId]; ok {
+				union(m, s.Mid)
+			} else {
+				dbIds[s.DbId] = s.Mid
+			}
+		}
+		if key := duplicateNameKey(s); key != "" {
+			if m, ok := names[key]; ok {
+				union(m, s.Mid)
+			} else {
+				names[key] = s.Mid
+			}
+		}
+	}
+	members := make(map[int64][]SearchInfo)
+	for mid := range parent {
+		root := find(mid)
+		members[root] = append(members[root], infos[mid])
+	}
+	var groups []FilmDuplicateGroup
+	for _, sl := range members {
+		if len(sl) > 1 {
+			groups = append(groups, newDuplicateGroup(sl))
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Films[0].Mid < groups[j].Films[0].Mid })
+	return groups
+}
+
+// duplicateNameKey 片名分组依据, 同一一级分类下规范化片名与年份相同的影片视为重复
+func duplicateNameKey(s SearchInfo) string {
+	name := NormalizeFilmName(s.Name)
+	if name == "" {
+		return ""
+	}
+	return fmt.Sprintf("%d:%s:%d", s.Pid, name, s.Year)
+}
+
+// newDuplicateGroup 生成疑似重复分组, 附带各影片的播放源信息并选出建议保留的影片
+func newDuplicateGroup(sl []SearchInfo) FilmDuplicateGroup {
+	sort.Slice(sl, func(i, j int) bool { return sl[i].Mid < sl[j].Mid })
+	g := FilmDuplicateGroup{}
+	dbIds, names := make(map[int64]int), make(map[string]int)
+	var ids []string
+	for _, s := range sl {
+		ids = append(ids, strconv.FormatInt(s.Mid, 10))
+		if s.DbId > 0 {
+			dbIds[s.DbId]++
+		}
+		names[duplicateNameKey(s)]++
+		f := DuplicateFilm{Mid: s.Mid, Cid: s.Cid, Name: s.Name, CName: s.CName, Year: s.Year, DbId: s.DbId,
+			Remarks: s.Remarks, Hits: s.Hits, UpdateStamp: s.UpdateStamp}
+		d := GetOriginDetailByKey(fmt.Sprintf(config.MovieDetailKey, s.Cid, s.Mid))
+		f.Sources = len(d.PlayList)
+		for _, pl := range d.PlayList {
+			f.Episodes = max(f.Episodes, len(pl))
+		}
+		g.Films = append(g.Films, f)
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(strings.Join(ids, ",")))
+	g.Id = strconv.FormatUint(h.Sum64(), 16)
+	for _, n := range dbIds {
+		if n > 1 {
+			g.Reasons = append(g.Reasons, DuplicateDbId)
+			break
+		}
+	}
+	for k, n := range names {
+		if k != "" && n > 1 {
+			g.Reasons = append(g.Reasons, DuplicateName)
+			break
+		}
+	}
+	// 建议保留集数最多的影片, 其次为播放源数量与热度
+	keep := g.Films[0]
+	for _, f := range g.Films[1:] {
+		if f.Episodes > keep.Episodes || (f.Episodes == keep.Episodes && (f.Sources > keep.Sources ||
+			(f.Sources == keep.Sources && f.Hits > keep.Hits))) {
+			keep = f
+		}
+	}
+	g.Keep = keep.Mid
+	return g
+}
+
+// FoldMergedFilms 将合并到当前影片中的重复影片的播放源追加到播放列表中, 与已有播放源相同的分组不重复添加
+func FoldMergedFilms(d *MovieDetail) {
+	merged := GetMergedFilms(d.Id)
+	if len(merged) == 0 {
+		return
+	}
+	links := make(map[string]bool)
+	for _, pl := range d.PlayList {
+		if len(pl) > 0 {
+			links[pl[0].Link] = true
+		}
+	}
+	for _, r := range merged {
+		o := GetOriginDetailByKey(fmt.Sprintf(config.MovieDetailKey, r.Cid, r.Mid))
+		for i, pl := range o.PlayList {
+			if len(pl) == 0 || links[pl[0].Link] {
+				continue
+			}
+			links[pl[0].Link] = true
+			from := ""
+			if i < len(o.PlayFrom) {
+				from = o.PlayFrom[i]
+			}
+			d.PlayFrom = append(d.PlayFrom, from)
+			d.PlayList = append(d.PlayList, pl)
+		}
+		d.DownloadList = append(d.DownloadList, o.DownloadList...)
+	}
+}
+
+// ------------------------------------------------------ Redis ------------------------------------------------------
+
+// SaveDuplicateGroups 保存检测到的疑似重复分组, 覆盖上一次的检测结果并排除已标记为非重复的分组
+func SaveDuplicateGroups(groups []FilmDuplicateGroup) (int, error) {
+	ignored := make(map[string]bool)
+	for _, id := range db.Rdb.SMembers(db.Cxt, config.FilmDuplicateIgnoreKey).Val() {
+		ignored[id] = true
+	}
+	values := make(map[string]any)
+	for _, g := range groups {
+		if !ignored[g.Id] {
+			data, _ := json.Marshal(g)
+			values[g.Id] = data
+		}
+	}
+	pipe := db.Rdb.TxPipeline()
+	pipe.Del(db.Cxt, config.FilmDuplicateKey)
+	if len(values) > 0 {
+		pipe.HSet(db.Cxt, config.FilmDuplicateKey, values)
+	}
+	_, err := pipe.Exec(db.Cxt)
+	return len(values), err
+}
+
+// GetDuplicateGroups 获取所有疑似重复分组, 按最近更新时间倒序排列
+func GetDuplicateGroups() []FilmDuplicateGroup {
+	var gl []FilmDuplicateGroup
+	for _, v := range db.Rdb.HGetAll(db.Cxt, config.FilmDuplicateKey).Val() {
+		g := FilmDuplicateGroup{}
+		if err := json.Unmarshal([]byte(v), &g); err == nil && len(g.Films) > 0 {
+			gl = append(gl, g)
+		}
+	}
+	latest := func(g FilmDuplicateGroup) int64 {
+		var t int64
+		for _, f := range g.Films {
+			t = max(t, f.UpdateStamp)
+		}
+		return t
+	}
+	sort.Slice(gl, func(i, j int) bool {
+		if ti, tj := latest(gl[i]), latest(gl[j]); ti != tj {
+			return ti > tj
+		}
+		return gl[i].Id < gl[j].Id
+	})
+	return gl
+}
+
+// GetDuplicateGroup 获取疑似重复分组
+func GetDuplicateGroup(id string) *FilmDuplicateGroup {
+	data, err := db.Rdb.HGet(db.Cxt, config.FilmDuplicateKey, id).Result()
+	if err != nil {
+		return nil
+	}
+	g := FilmDuplicateGroup{}
+	if err = json.Unmarshal([]byte(data), &g); err != nil {
+		return nil
+	}
+	return &g
+}
+
+// DelDuplicateGroup 从审核列表中移除疑似重复分组
+func DelDuplicateGroup(id string) {
+	db.Rdb.HDel(db.Cxt, config.FilmDuplicateKey, id)
+}
+
+// IgnoreDuplicateGroup 将分组标记为非重复, 后续检测时不再展示
+func IgnoreDuplicateGroup(id string) error {
+	if !db.Rdb.HExists(db.Cxt, config.FilmDuplicateKey, id).Val() {
+		return errors.New("疑似重复分组不存在")
+	}
+	if err := db.Rdb.SAdd(db.Cxt, config.FilmDuplicateIgnoreKey, id).Err(); err != nil {
+		return err
+	}
+	DelDuplicateGroup(id)
+	return nil
+}
+
+// SaveFilmDuplicateScan 保存重复影片检测任务信息
+func SaveFilmDuplicateScan(s FilmDuplicateScan) error {
+	data, _ := json.Marshal(s)
+	return db.Rdb.Set(db.Cxt, config.FilmDuplicateScanKey, data, 0).Err()
+}
+
+// GetFilmDuplicateScan 获取最近一次重复影片检测任务信息, 未执行过检测时返回 nil
+func GetFilmDuplicateScan() *FilmDuplicateScan {
+	data, err := db.Rdb.Get(db.Cxt, config.FilmDuplicateScanKey).Bytes()
+	if err != nil {
+		return nil
+	}
+	s := FilmDuplicateScan{}
+	if err = json.Unmarshal(data, &s); err != nil {
+		return nil
+	}
+	return &s
+}
+
+// GetFilmRedirect 获取影片的合并信息, 未被合并时返回 nil
+func GetFilmRedirect(mid int64) *FilmRedirect {
+	data, err := db.Rdb.HGet(db.Cxt, config.FilmRedirectKey, strconv.FormatInt(mid, 10)).Result()
+	if err != nil {
+		return nil
+	}
+	r := FilmRedirect{}
+	if err = json.Unmarshal([]byte(data), &r); err != nil {
+		return nil
+	}
+	return &r
+}
+
+// GetFilmRedirects 批量获取影片的合并信息 {mid: FilmRedirect}
+func GetFilmRedirects(mids ...int64) map[int64]*FilmRedirect {
+	res := make(map[int64]*FilmRedirect)
+	if len(mids) == 0 || db.Rdb.Exists(db.Cxt, config.FilmRedirectKey).Val() == 0 {
+		return res
+	}
+	var fields []string
+	for _, mid := range mids {
+		fields = append(fields, strconv.FormatInt(mid, 10))
+	}
+	for _, v := range db.Rdb.HMGet(db.Cxt, config.FilmRedirectKey, fields...).Val() {
+		data, ok := v.(string)
+		if !ok {
+			continue
+		}
+		r := FilmRedirect{}
+		if err := json.Unmarshal([]byte(data), &r); err == nil {
+			res[r.Mid] = &r
+		}
+	}
+	return res
+}
+
+// GetMergedFilms 获取合并到指定影片中的重复影片
+func GetMergedFilms(target int64) []FilmRedirect {
+	data, err := db.Rdb.HGet(db.Cxt, config.FilmMergedKey, strconv.FormatInt(target, 10)).Result()
+	if err != nil {
+		return nil
+	}
+	var rl []FilmRedirect
+	_ = json.Unmarshal([]byte(data), &rl)
+	return rl
+}
+
+// MergeFilm 将重复影片合并到目标影片, 已合并到重复影片中的影片一并转移到目标影片, 随后删除重复影片的检索信息
+func MergeFilm(r FilmRedirect) error {
+	if GetFilmRedirect(r.Target) != nil {
+		return errors.New("目标影片已被合并到其他影片中")
+	}
+	r.MergeTime = time.Now().Unix()
+	moved := GetMergedFilms(r.Mid)
+	for i := range moved {
+		moved[i].Target = r.Target
+	}
+	list := append(GetMergedFilms(r.Target), append(moved, r)...)
+	values := make(map[string]any)
+	for _, m := range append(moved, r) {
+		data, _ := json.Marshal(m)
+		values[strconv.FormatInt(m.Mid, 10)] = data
+	}
+	data, _ := json.Marshal(list)
+	pipe := db.Rdb.TxPipeline()
+	pipe.HSet(db.Cxt, config.FilmRedirectKey, values)
+	pipe.HSet(db.Cxt, config.FilmMergedKey, strconv.FormatInt(r.Target, 10), data)
+	pipe.HDel(db.Cxt, config.FilmMergedKey, strconv.FormatInt(r.Mid, 10))
+	if _, err := pipe.Exec(db.Cxt); err != nil {
+		return err
+	}
+	if err := db.Mdb.Unscoped().Where("mid = ?", r.Mid).Delete(&SearchInfo{}).Error; err != nil {
+		return err
+	}
+	if err := DelFilmPersons(r.Mid); err != nil {
+		return err
+	}
+	if err := DelFilmText(r.Mid); err != nil {
+		return err
+	}
+	return DelFilmSuggest(r.Mid)
+}
+
+// updateFilmRedirect 重复影片的分类发生变化时同步更新合并信息, 保证读取播放源时使用正确的 key
+func updateFilmRedirect(r FilmRedirect) {
+	list := GetMergedFilms(r.Target)
+	for i := range list {
+		if list[i].Mid == r.Mid {
+			list[i] = r
+		}
+	}
+	rd, _ := json.Marshal(r)
+	ld, _ := json.Marshal(list)
+	pipe := db.Rdb.TxPipeline()
+	pipe.HSet(db.Cxt, config.FilmRedirectKey, strconv.FormatInt(r.Mid, 10), rd)
+	pipe.HSet(db.Cxt, config.FilmMergedKey, strconv.FormatInt(r.Target, 10), ld)
+	_, _ = pipe.Exec(db.Cxt)
+}
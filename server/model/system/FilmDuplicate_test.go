@@ -0,0 +1,52 @@
+package system
+
+import "testing"
+
+func TestNormalizeFilmName(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"去除括号内容", "流浪地球(2019)", "流浪地球"},
+		{"去除全角括号与方括号", "流浪地球（导演剪辑版）【高清】", "流浪地球"},
+		{"去除语言版本", "流浪地球国语版", "流浪地球"},
+		{"去除清晰度标记", "流浪地球 HD", "流浪地球"},
+		{"去除空白与标点", "流浪地球 2：序章!", "流浪地球2序章"},
+		{"英文转换为小写", "Avatar", "avatar"},
+		{"繁体字转换为简体字", "無間道", "无间道"},
+		{"去除繁体语言版本", "流浪地球國語版", "流浪地球"},
+		{"保留英文单词中的字母", "Hdtv Show", "hdtvshow"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeFilmName(tt.in); got != tt.want {
+				t.Errorf("NormalizeFilmName(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDuplicateNameKey(t *testing.T) {
+	base := SearchInfo{Pid: 1, Name: "流浪地球", Year: 2019}
+	tests := []struct {
+		name string
+		s    SearchInfo
+		same bool
+	}{
+		{"不同版本的同名影片", SearchInfo{Pid: 1, Name: "流浪地球(国语版)", Year: 2019}, true},
+		{"年份不同", SearchInfo{Pid: 1, Name: "流浪地球", Year: 2023}, false},
+		{"一级分类不同", SearchInfo{Pid: 2, Name: "流浪地球", Year: 2019}, false},
+		{"片名不同", SearchInfo{Pid: 1, Name: "流浪地球2", Year: 2019}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := duplicateNameKey(tt.s) == duplicateNameKey(base); got != tt.same {
+				t.Errorf("duplicateNameKey(%q) equal = %t, want %t", tt.s.Name, got, tt.same)
+			}
+		})
+	}
+	if key := duplicateNameKey(SearchInfo{Pid: 1, Name: "（）!"}); key != "" {
+		t.Errorf("duplicateNameKey of an empty normalized name = %q, want empty", key)
+	}
+}
@@ -0,0 +1,42 @@
+package system
+
+import (
+	"server/config"
+	"server/plugin/db"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+/*
+	单独隐藏的影片
+	隐藏的影片不会出现在前台的影片列表, 搜索以及相关推荐中, 管理后台依旧可以检索和编辑
+	隐藏状态独立于检索表存储, 全量同步重建检索表后依旧生效
+*/
+
+// FilmHidden 隐藏的影片信息
+type FilmHidden struct {
+	gorm.Model
+	Mid      int64  `json:"mid"`      // 影片ID
+	Operator string `json:"operator"` // 操作人
+}
+
+// TableName 隐藏影片表名
+func (fh FilmHidden) TableName() string {
+	return config.FilmHiddenTableName
+}
+
+// HideFilm 隐藏影片, 已隐藏时更新操作人
+func HideFilm(mid int64, operator string) error {
+	defer ResetFilmVisibility()
+	return db.Mdb.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "mid"}},
+		DoUpdates: clause.AssignmentColumns([]string{"operator", "updated_at"}),
+	}).Create(&FilmHidden{Mid: mid, Operator: operator}).Error
+}
+
+// ShowFilm 取消影片的隐藏状态
+func ShowFilm(mid int64) error {
+	defer ResetFilmVisibility()
+	return db.Mdb.Unscoped().Where("mid = ?", mid).Delete(&FilmHidden{}).Error
+}
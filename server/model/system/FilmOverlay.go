@@ -0,0 +1,201 @@
+package system
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"server/config"
+	"server/plugin/db"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+	影片编辑信息(覆盖层)
+	管理员对影片的修改不直接写入采集的原始数据, 而是以 字段 => 修改值 的形式单独保存
+	读取影片详情, 基本信息以及生成检索信息时将覆盖层应用到原始数据上
+	重新采集到影片数据时, 未锁定字段的修改被丢弃(以采集站数据为准), 锁定字段继续保留修改后的值
+*/
+
+// FilmOverlay 影片编辑信息
+type FilmOverlay struct {
+	Mid        int64                      `json:"mid"`        // 影片ID
+	Fields     map[string]json.RawMessage `json:"fields"`     // 修改后的字段值 {json字段名: 值}
+	Locks      []string                   `json:"locks"`      // 锁定的字段, 重新采集时不会被覆盖
+	UpdateTime int64                      `json:"updateTime"` // 最近修改时间
+}
+
+// filmFixedFields 影片标识与分类字段参与 redis key 的生成, 不允许通过覆盖层修改
+var filmFixedFields = map[string]bool{"id": true, "cid": true, "pid": true}
+
+// Locked 字段是否已锁定
+func (o FilmOverlay) Locked(field string) bool {
+	for _, l := range o.Locks {
+		if l == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Empty 覆盖层中不存在任何修改和锁定信息
+func (o FilmOverlay) Empty() bool {
+	return len(o.Fields) == 0 && len(o.Locks) == 0
+}
+
+// FilmEditableFields 获取允许编辑的影片字段(MovieDetail 及 MovieDescriptor 的 json 字段名)
+func FilmEditableFields() []string {
+	var fields []string
+	for name := range overlayTargets(reflect.ValueOf(&MovieDetail{}).Elem()) {
+		if !filmFixedFields[name] {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// ValidFilmOverlay 校验覆盖层中的字段名称以及字段值类型
+func ValidFilmOverlay(o FilmOverlay) error {
+	targets := overlayTargets(reflect.ValueOf(&MovieDetail{}).Elem())
+	for name, raw := range o.Fields {
+		f, ok := targets[name]
+		if !ok || filmFixedFields[name] {
+			return fmt.Errorf("字段 %s 不允许编辑", name)
+		}
+		if err := json.Unmarshal(raw, f.Addr().Interface()); err != nil {
+			return fmt.Errorf("字段 %s 的值格式异常: %w", name, err)
+		}
+	}
+	for _, name := range o.Locks {
+		if _, ok := targets[name]; !ok || filmFixedFields[name] {
+			return fmt.Errorf("字段 %s 不允许锁定", name)
+		}
+	}
+	return nil
+}
+
+// ApplyFilmOverlay 将覆盖层中的修改应用到 MovieDetail 或 MovieBasicInfo 上, target 必须为结构体指针
+func ApplyFilmOverlay(target any, o *FilmOverlay) {
+	if o == nil || len(o.Fields) == 0 {
+		return
+	}
+	targets := overlayTargets(reflect.ValueOf(target).Elem())
+	for name, raw := range o.Fields {
+		if f, ok := targets[name]; ok && !filmFixedFields[name] {
+			// 先置为零值, 避免 slice 复用原有底层数组修改到原始数据
+			f.Set(reflect.Zero(f.Type()))
+			_ = json.Unmarshal(raw, f.Addr().Interface())
+		}
+	}
+}
+
+// FilmFieldValue 获取影片指定字段的 json 值
+func FilmFieldValue(detail MovieDetail, field string) (json.RawMessage, bool) {
+	f, ok := overlayTargets(reflect.ValueOf(&detail).Elem())[field]
+	if !ok {
+		return nil, false
+	}
+	data, _ := json.Marshal(f.Interface())
+	return data, true
+}
+
+// overlayTargets 获取结构体中所有可被覆盖的字段 {json字段名: 字段值}, 匿名嵌入的结构体字段平铺展开
+func overlayTargets(v reflect.Value) map[string]reflect.Value {
+	res := make(map[string]reflect.Value)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			for k, f := range overlayTargets(v.Field(i)) {
+				res[k] = f
+			}
+			continue
+		}
+		name := strings.Split(sf.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" || !sf.IsExported() {
+			continue
+		}
+		res[name] = v.Field(i)
+	}
+	return res
+}
+
+// ------------------------------------------------------ Redis ------------------------------------------------------
+
+// SaveFilmOverlay 保存影片编辑信息, 覆盖层为空时直接删除
+func SaveFilmOverlay(o FilmOverlay) error {
+	if o.Empty() {
+		DelFilmOverlay(o.Mid)
+		return nil
+	}
+	o.UpdateTime = time.Now().Unix()
+	data, _ := json.Marshal(o)
+	return db.Rdb.HSet(db.Cxt, config.MovieOverlayKey, o.Mid, data).Err()
+}
+
+// GetFilmOverlay 获取影片的编辑信息, 不存在则返回 nil
+func GetFilmOverlay(mid int64) *FilmOverlay {
+	data, err := db.Rdb.HGet(db.Cxt, config.MovieOverlayKey, strconv.FormatInt(mid, 10)).Result()
+	if err != nil {
+		return nil
+	}
+	o := FilmOverlay{}
+	if err = json.Unmarshal([]byte(data), &o); err != nil {
+		return nil
+	}
+	return &o
+}
+
+// GetFilmOverlays 批量获取影片的编辑信息 {mid: FilmOverlay}
+func GetFilmOverlays(mids ...int64) map[int64]*FilmOverlay {
+	res := make(map[int64]*FilmOverlay)
+	if len(mids) == 0 || db.Rdb.Exists(db.Cxt, config.MovieOverlayKey).Val() == 0 {
+		return res
+	}
+	var fields []string
+	for _, mid := range mids {
+		fields = append(fields, strconv.FormatInt(mid, 10))
+	}
+	for _, v := range db.Rdb.HMGet(db.Cxt, config.MovieOverlayKey, fields...).Val() {
+		data, ok := v.(string)
+		if !ok {
+			continue
+		}
+		o := FilmOverlay{}
+		if err := json.Unmarshal([]byte(data), &o); err == nil {
+			res[o.Mid] = &o
+		}
+	}
+	return res
+}
+
+// DelFilmOverlay 删除影片的编辑信息
+func DelFilmOverlay(mid int64) {
+	db.Rdb.HDel(db.Cxt, config.MovieOverlayKey, strconv.FormatInt(mid, 10))
+}
+
+// ReconcileFilmOverlay 采集到新数据时丢弃覆盖层中未锁定字段的修改, 返回处理后仍需应用的覆盖层
+func ReconcileFilmOverlay(list []MovieDetail) map[int64]*FilmOverlay {
+	var mids []int64
+	for _, d := range list {
+		mids = append(mids, d.Id)
+	}
+	overlays := GetFilmOverlays(mids...)
+	for mid, o := range overlays {
+		changed := false
+		for name := range o.Fields {
+			if !o.Locked(name) {
+				delete(o.Fields, name)
+				changed = true
+			}
+		}
+		if changed {
+			_ = SaveFilmOverlay(*o)
+		}
+		if len(o.Fields) == 0 {
+			delete(overlays, mid)
+		}
+	}
+	return overlays
+}
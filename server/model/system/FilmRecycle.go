@@ -0,0 +1,116 @@
+package system
+
+import (
+	"fmt"
+	"log"
+	"server/config"
+	"server/plugin/db"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+/*
+	影片回收站
+	删除影片时记录到回收站并对检索信息进行逻辑删除, 全量同步重建检索表后依据回收站记录重新屏蔽
+	回收站中的影片可以恢复或彻底删除
+*/
+
+// FilmRecycle 回收站影片信息
+type FilmRecycle struct {
+	gorm.Model
+	Mid      int64  `json:"mid"`      // 影片ID
+	Cid      int64  `json:"cid"`      // 分类ID
+	Pid      int64  `json:"pid"`      // 一级分类ID
+	Name     string `json:"name"`     // 片名
+	CName    string `json:"cName"`    // 分类名称
+	Operator string `json:"operator"` // 删除操作人
+}
+
+// TableName 回收站表名
+func (fr FilmRecycle) TableName() string {
+	return config.FilmRecycleTableName
+}
+
+// SaveFilmRecycle 将影片添加到回收站, 已存在时更新回收信息
+func SaveFilmRecycle(fr FilmRecycle) error {
+	return db.Mdb.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "mid"}},
+		DoUpdates: clause.AssignmentColumns([]string{"cid", "pid", "name", "c_name", "operator", "updated_at"}),
+	}).Create(&fr).Error
+}
+
+// FilmRecycleList 回收站影片分页数据
+func FilmRecycleList(name string, page *Page) []FilmRecycle {
+	qw := db.Mdb.Model(&FilmRecycle{})
+	if name != "" {
+		qw = qw.Where(db.Like("name"), fmt.Sprint(`%`, name, `%`))
+	}
+	GetPage(qw, page)
+	var list []FilmRecycle
+	if err := qw.Limit(page.PageSize).Offset((page.Current - 1) * page.PageSize).Order("updated_at DESC").Find(&list).Error; err != nil {
+		log.Println(err)
+		return nil
+	}
+	return list
+}
+
+// FindFilmRecycle 通过ID获取回收站影片信息
+func FindFilmRecycle(id uint) *FilmRecycle {
+	var fr FilmRecycle
+	if err := db.Mdb.First(&fr, id).Error; err != nil {
+		return nil
+	}
+	return &fr
+}
+
+// FindFilmRecycleByMid 通过影片ID获取回收站影片信息
+func FindFilmRecycleByMid(mid int64) *FilmRecycle {
+	var fr FilmRecycle
+	if err := db.Mdb.Where("mid = ?", mid).First(&fr).Error; err != nil {
+		return nil
+	}
+	return &fr
+}
+
+// DelFilmRecycle 删除回收站记录
+func DelFilmRecycle(id uint) error {
+	return db.Mdb.Unscoped().Delete(&FilmRecycle{}, id).Error
+}
+
+// ApplyFilmRecycle 重新屏蔽回收站中影片的检索信息, 检索表全量重建后执行
+func ApplyFilmRecycle() error {
+	sub := db.Mdb.Model(&FilmRecycle{}).Select("mid")
+	return db.Mdb.Model(&SearchInfo{}).Where("mid IN (?)", sub).Update("deleted_at", time.Now()).Error
+}
+
+// RestoreFilmSearch 恢复影片的检索信息
+func RestoreFilmSearch(mid int64) error {
+	return db.Mdb.Model(&SearchInfo{}).Unscoped().Where("mid = ?", mid).Update("deleted_at", nil).Error
+}
+
+// PurgeFilm 彻底删除影片的检索信息, 详情数据以及编辑信息
+func PurgeFilm(mid, cid int64) error {
+	if err := db.Mdb.Unscoped().Where("mid = ?", mid).Delete(&SearchInfo{}).Error; err != nil {
+		return err
+	}
+	DelFilmOverlay(mid)
+	if err := DelFilmPersons(mid); err != nil {
+		return err
+	}
+	if err := DelFilmText(mid); err != nil {
+		return err
+	}
+	if err := DelFilmSuggest(mid); err != nil {
+		return err
+	}
+	// 同时删除附属站点中匹配该影片的播放源, 本地影片未与附属站点的数据关联
+	key := fmt.Sprintf(config.MovieDetailKey, cid, mid)
+	if d := GetOriginDetailByKey(key); d.Id != 0 && d.Id < config.LocalFilmIdStart {
+		if err := DelMultiplePlay(d); err != nil {
+			return err
+		}
+	}
+	return db.Rdb.Del(db.Cxt, key, fmt.Sprintf(config.MovieBasicInfoKey, cid, mid)).Err()
+}
@@ -0,0 +1,50 @@
+package system
+
+import "testing"
+
+func TestPurgeFilmRemovesMultiplePlay(t *testing.T) {
+	film := MovieDetail{Id: 401, Cid: 6, Pid: 1, Name: "多源影片", PlayList: [][]MovieUrlInfo{{{Episode: "正片", Link: "http://a/1.m3u8"}}},
+		MovieDescriptor: MovieDescriptor{SubTitle: "多源别名/Multi", DbId: 4010}}
+	other := MovieDetail{Id: 402, Cid: 6, Pid: 1, Name: "其他影片",
+		PlayList: [][]MovieUrlInfo{{{Episode: "正片", Link: "http://a/2.m3u8"}}}}
+	if err := SaveDetails([]MovieDetail{film, other}); err != nil {
+		t.Fatalf("SaveDetails error = %v", err)
+	}
+	// 附属站点中的同名影片, 以及仅通过别名与豆瓣ID关联的影片
+	slave := []MovieDetail{
+		{Id: 1, Name: "多源影片", PlayList: [][]MovieUrlInfo{{{Episode: "正片", Link: "http://b/1.m3u8"}}}, MovieDescriptor: MovieDescriptor{DbId: 4010}},
+		{Id: 2, Name: "多源别名", PlayList: [][]MovieUrlInfo{{{Episode: "正片", Link: "http://b/2.m3u8"}}}},
+		{Id: 3, Name: "其他影片", PlayList: [][]MovieUrlInfo{{{Episode: "正片", Link: "http://b/3.m3u8"}}}},
+	}
+	for _, id := range []string{"slave1", "slave2"} {
+		if err := SaveSitePlayList(id, slave); err != nil {
+			t.Fatalf("SaveSitePlayList(%s) error = %v", id, err)
+		}
+	}
+	if err := PurgeFilm(film.Id, film.Cid); err != nil {
+		t.Fatalf("PurgeFilm error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		key  string
+		want bool
+	}{
+		{"豆瓣ID", GenerateHashKey(film.DbId), false},
+		{"片名", GenerateHashKey(film.Name), false},
+		{"别名", GenerateHashKey("多源别名"), false},
+		{"其他影片", GenerateHashKey(other.Name), true},
+	}
+	for _, tt := range tests {
+		for _, id := range []string{"slave1", "slave2"} {
+			t.Run(tt.name+"/"+id, func(t *testing.T) {
+				if got := len(GetMultiplePlay(id, tt.key)) > 0; got != tt.want {
+					t.Errorf("GetMultiplePlay(%s, %s) exists = %t, want %t", id, tt.key, got, tt.want)
+				}
+			})
+		}
+	}
+	if GetSearchInfoByMid(film.Id) != nil {
+		t.Errorf("search info of purged film %d still exists", film.Id)
+	}
+}
@@ -0,0 +1,134 @@
+package system
+
+import (
+	"log"
+	"regexp"
+	"server/config"
+	"server/plugin/db"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+/*
+	影片连载信息
+	采集站提供的 总集数 | 连载数 | 完结标识 优先使用, 缺失时通过更新状态(Remarks)推断, 例: 更新至12集 | 全40集 | 已完结 | HD
+	播出周期使用位掩码存储, 便于按星期筛选
+*/
+
+const (
+	SerialEnd     = "完结" // 已完结
+	SerialOngoing = "连载" // 连载中
+)
+
+var (
+	// remarksEndPattern 表示已完结的更新状态
+	remarksEndPattern = regexp.MustCompile(`完结|全集|大结局|全\s*[0-9]+\s*[集话期]|[0-9]+\s*[集话期]全`)
+	// remarksOngoingPattern 表示连载中的更新状态
+	remarksOngoingPattern = regexp.MustCompile(`更新|连载|[0-9]+\s*[集话期]|(?i)EP?\s*[0-9]+`)
+	// remarksNumPattern 更新状态中的集数
+	remarksNumPattern = regexp.MustCompile(`([0-9]+)\s*[集话期]|(?i)EP?\s*([0-9]+)`)
+	// weekdaySeparator 播出周期中的分隔符
+	weekdaySeparator = regexp.MustCompile(`[,，、/|\s]+`)
+)
+
+// weekdayNames 播出周期中星期的写法对应的星期序号 (周一为 1)
+var weekdayNames = map[string]int{
+	"一": 1, "二": 2, "三": 3, "四": 4, "五": 5, "六": 6, "日": 7, "天": 7, "七": 7,
+	"1": 1, "2": 2, "3": 3, "4": 4, "5": 5, "6": 6, "7": 7, "0": 7,
+}
+
+// ResolveSerial 补全缺失的连载信息, 采集站未提供完结标识时 总集数 与 已更新集数 均有效则通过二者判断, 否则通过更新状态推断
+func (md *MovieDescriptor) ResolveSerial() {
+	if md.Serial <= 0 {
+		md.Serial = remarksEpisode(md.Remarks)
+	}
+	if md.Total <= 0 && remarksEndPattern.MatchString(md.Remarks) && md.Serial > 0 {
+		md.Total = md.Serial
+	}
+	switch {
+	case md.IsEnd:
+	case md.Total > 0 && md.Serial > 0:
+		md.IsEnd = md.Serial >= md.Total
+	default:
+		md.IsEnd = remarksEnd(md.Remarks)
+	}
+}
+
+// remarksEpisode 获取更新状态中的集数
+func remarksEpisode(remarks string) int64 {
+	m := remarksNumPattern.FindStringSubmatch(remarks)
+	if m == nil {
+		return 0
+	}
+	n, _ := strconv.ParseInt(m[1]+m[2], 10, 64)
+	return n
+}
+
+// remarksEnd 通过更新状态判断是否完结, 不包含连载信息的更新状态(例: HD | 正片 | BD)视为已完结
+func remarksEnd(remarks string) bool {
+	if remarksEndPattern.MatchString(remarks) {
+		return true
+	}
+	return !remarksOngoingPattern.MatchString(remarks)
+}
+
+// ParseWeekdays 将播出周期转换为位掩码, 例: 一,三 | 周一/周三 | 1,3 -> 0b101
+func ParseWeekdays(s string) int {
+	var mask int
+	for _, w := range weekdaySeparator.Split(s, -1) {
+		w = strings.TrimPrefix(strings.TrimPrefix(strings.TrimPrefix(w, "星期"), "周"), "礼拜")
+		if d, ok := weekdayNames[w]; ok {
+			mask |= 1 << (d - 1)
+		}
+	}
+	return mask
+}
+
+// ------------------------------------------------------ MySQL ------------------------------------------------------
+
+// SerialStatus 完结状态筛选条件, status 为 完结 | 连载, 其他值不追加条件
+func SerialStatus(status string) func(tx *gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		switch status {
+		case SerialEnd:
+			return tx.Where("is_end = ?", true)
+		case SerialOngoing:
+			return tx.Where("is_end = ?", false)
+		}
+		return tx
+	}
+}
+
+// GetTodayFilms 获取今日更新的可展示影片, pid 为 0 时包含所有分类
+func GetTodayFilms(t Tenant, pid int64, page *Page) []SearchInfo {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	qw := db.Mdb.Model(&SearchInfo{}).Scopes(VisibleFilm(t)).Where("update_stamp >= ?", today.Unix())
+	if pid > 0 {
+		qw = qw.Where("pid = ?", pid)
+	}
+	GetPage(qw, page)
+	var sl []SearchInfo
+	if err := qw.Limit(page.PageSize).Offset((page.Current - 1) * page.PageSize).Order("update_stamp DESC").Find(&sl).Error; err != nil {
+		log.Println(err)
+		return nil
+	}
+	return sl
+}
+
+// GetWeekdayFilms 获取在指定星期(周一为 1)播出的连载中的可展示影片, 按更新时间倒序排列
+func GetWeekdayFilms(t Tenant, weekday int, pid int64) []SearchInfo {
+	qw := db.Mdb.Model(&SearchInfo{}).Scopes(VisibleFilm(t)).Where("is_end = ? AND weekdays & ? <> 0", false, 1<<(weekday-1))
+	if pid > 0 {
+		qw = qw.Where("pid = ?", pid)
+	}
+	var sl []SearchInfo
+	if err := qw.Order("update_stamp DESC").Limit(config.CalendarDayMaxFilms).Find(&sl).Error; err != nil {
+		log.Println(err)
+		return nil
+	}
+	return sl
+}
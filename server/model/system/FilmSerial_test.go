@@ -0,0 +1,68 @@
+package system
+
+import "testing"
+
+func TestResolveSerial(t *testing.T) {
+	tests := []struct {
+		name       string
+		in         MovieDescriptor
+		wantSerial int64
+		wantTotal  int64
+		wantEnd    bool
+	}{
+		{"更新至x集", MovieDescriptor{Remarks: "更新至12集"}, 12, 0, false},
+		{"更新至EPx", MovieDescriptor{Remarks: "更新至EP05"}, 5, 0, false},
+		{"第x集", MovieDescriptor{Remarks: "第5集"}, 5, 0, false},
+		{"全x集", MovieDescriptor{Remarks: "全40集"}, 40, 40, true},
+		{"x集全", MovieDescriptor{Remarks: "12集全"}, 12, 12, true},
+		{"已完结", MovieDescriptor{Remarks: "已完结"}, 0, 0, true},
+		{"中文数字无法解析集数", MovieDescriptor{Remarks: "更新至第十二集"}, 0, 0, false},
+		{"无连载信息视为完结", MovieDescriptor{Remarks: "HD"}, 0, 0, true},
+		{"更新状态为空", MovieDescriptor{}, 0, 0, true},
+		{"连载数优先于更新状态", MovieDescriptor{Serial: 8, Remarks: "更新至12集"}, 8, 0, false},
+		{"总集数与连载数判断连载中", MovieDescriptor{Serial: 10, Total: 24, Remarks: "HD"}, 10, 24, false},
+		{"总集数与连载数判断完结", MovieDescriptor{Serial: 24, Total: 24, Remarks: "更新至24集"}, 24, 24, true},
+		{"大结局补全总集数", MovieDescriptor{Serial: 30, Remarks: "大结局"}, 30, 30, true},
+		{"采集站完结标识优先", MovieDescriptor{Serial: 3, Total: 10, IsEnd: true}, 3, 10, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md := tt.in
+			md.ResolveSerial()
+			if md.Serial != tt.wantSerial || md.Total != tt.wantTotal || md.IsEnd != tt.wantEnd {
+				t.Errorf("ResolveSerial(%+v) = serial %d, total %d, end %t, want %d, %d, %t",
+					tt.in, md.Serial, md.Total, md.IsEnd, tt.wantSerial, tt.wantTotal, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestParseWeekdays(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want int
+	}{
+		{"中文数字", "一,三", 0b101},
+		{"周x", "周一/周三", 0b101},
+		{"阿拉伯数字", "1,3", 0b101},
+		{"星期x", "星期六、星期日", 0b1100000},
+		{"礼拜天", "礼拜天", 0b1000000},
+		{"周七与0均为周日", "周七,0", 0b1000000},
+		{"混合分隔符", "一，三|五 周日", 0b1010101},
+		{"分隔符前后空白", "1, 2 ,3", 0b111},
+		{"重复星期", "周一,周一", 0b1},
+		{"连续分隔符", "一,,、二", 0b11},
+		{"空字符串", "", 0},
+		{"仅包含分隔符", " , ", 0},
+		{"无效星期", "周八,abc,8", 0},
+		{"部分无效", "周八,周二", 0b10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseWeekdays(tt.in); got != tt.want {
+				t.Errorf("ParseWeekdays(%q) = %b, want %b", tt.in, got, tt.want)
+			}
+		})
+	}
+}
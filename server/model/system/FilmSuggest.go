@@ -0,0 +1,187 @@
+package system
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"server/config"
+	"server/plugin/common/util"
+	"server/plugin/db"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/redis/go-redis/v9"
+)
+
+/*
+	搜索建议
+	片名, 别名与英文名规范化后按前缀写入 Redis 有序集合, 包含汉字的标题同时写入全拼与拼音首字母的前缀
+	权重由更新时间(天)与热度共同决定, 每个前缀只保留权重最高的 SuggestPrefixMaxFilms 部影片
+	影片已写入的前缀单独记录, 影片信息更新时移除不再使用的前缀, 展示状态在查询时过滤
+*/
+
+// NormalizeSuggest 规范化搜索建议的文本, 只保留汉字, 英文字母与数字, 繁体字转换为简体字, 英文字母转换为小写
+func NormalizeSuggest(s string) string {
+	var b strings.Builder
+	for _, r := range util.ToSimplified(s) {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			b.WriteRune(r)
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+// suggestPrefixes 生成影片的搜索建议前缀
+func suggestPrefixes(d MovieDetail) []string {
+	var res []string
+	seen := make(map[string]bool)
+	add := func(s string) {
+		r := []rune(s)
+		for i := 1; i <= min(len(r), config.SuggestPrefixMaxLength); i++ {
+			if p := string(r[:i]); !seen[p] {
+				seen[p] = true
+				res = append(res, p)
+			}
+		}
+	}
+	for _, title := range append([]string{d.Name, d.EnName}, aliasSeparator.Split(d.SubTitle, -1)...) {
+		add(NormalizeSuggest(title))
+		if util.HasHan(title) {
+			add(util.Pinyin(title))
+			add(util.PinyinInitials(title))
+			continue
+		}
+		// 英文标题中的每个单词均可作为开头, 例: The Wandering Earth -> wanderingearth | earth
+		words := strings.Fields(title)
+		for i := 1; i < len(words); i++ {
+			add(NormalizeSuggest(strings.Join(words[i:], "")))
+		}
+	}
+	return res
+}
+
+// suggestWeight 搜索建议的权重, 更新时间越近, 热度越高的影片权重越高, 热度每增加十倍相当于更新时间提前 30 天
+func suggestWeight(d MovieDetail) float64 {
+	stamp, _ := time.ParseInLocation(time.DateTime, d.UpdateTime, time.Local)
+	return float64(max(stamp.Unix(), 0))/86400 + 30*math.Log10(float64(max(d.Hits, 0))+1)
+}
+
+// SaveFilmSuggests 更新影片的搜索建议前缀索引
+func SaveFilmSuggests(list ...MovieDetail) error {
+	if len(list) == 0 {
+		return nil
+	}
+	var fields []string
+	for _, d := range list {
+		fields = append(fields, strconv.FormatInt(d.Id, 10))
+	}
+	old, err := db.Rdb.HMGet(db.Cxt, config.SearchSuggestFilmKey, fields...).Result()
+	if err != nil {
+		return err
+	}
+	pipe := db.Rdb.Pipeline()
+	for i, d := range list {
+		mid := fields[i]
+		prefixes := suggestPrefixes(d)
+		current := make(map[string]bool, len(prefixes))
+		for _, p := range prefixes {
+			current[p] = true
+		}
+		// 移除影片信息变更后不再使用的前缀
+		if v, ok := old[i].(string); ok {
+			var ol []string
+			_ = json.Unmarshal([]byte(v), &ol)
+			for _, p := range ol {
+				if !current[p] {
+					pipe.ZRem(db.Cxt, fmt.Sprintf(config.SearchSuggestKey, p), mid)
+				}
+			}
+		}
+		weight := suggestWeight(d)
+		for _, p := range prefixes {
+			key := fmt.Sprintf(config.SearchSuggestKey, p)
+			pipe.ZAdd(db.Cxt, key, redis.Z{Score: weight, Member: mid})
+			pipe.ZRemRangeByRank(db.Cxt, key, 0, int64(-config.SuggestPrefixMaxFilms-1))
+		}
+		data, _ := json.Marshal(prefixes)
+		pipe.HSet(db.Cxt, config.SearchSuggestFilmKey, mid, data)
+	}
+	_, err = pipe.Exec(db.Cxt)
+	return err
+}
+
+// DelFilmSuggest 删除影片的搜索建议前缀索引
+func DelFilmSuggest(mid int64) error {
+	field := strconv.FormatInt(mid, 10)
+	v, err := db.Rdb.HGet(db.Cxt, config.SearchSuggestFilmKey, field).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil
+		}
+		return err
+	}
+	var prefixes []string
+	_ = json.Unmarshal([]byte(v), &prefixes)
+	pipe := db.Rdb.Pipeline()
+	for _, p := range prefixes {
+		pipe.ZRem(db.Cxt, fmt.Sprintf(config.SearchSuggestKey, p), field)
+	}
+	pipe.HDel(db.Cxt, config.SearchSuggestFilmKey, field)
+	_, err = pipe.Exec(db.Cxt)
+	return err
+}
+
+// GetFilmSuggests 获取前缀匹配关键字的可展示影片, 按权重倒序排列
+// 同时包含汉字与英文字母的关键字转换为拼音后匹配, 例: xi游 -> xiyou
+func GetFilmSuggests(t Tenant, keyword string, n int) []SearchInfo {
+	q := NormalizeSuggest(keyword)
+	if q == "" {
+		return nil
+	}
+	keys := []string{q}
+	if util.HasHan(q) && util.HasLetter(q) {
+		keys = []string{util.Pinyin(q), util.PinyinInitials(q)}
+	}
+	scores := make(map[string]float64)
+	for _, k := range keys {
+		// 超出索引长度的关键字使用最长的前缀匹配
+		if r := []rune(k); len(r) > config.SuggestPrefixMaxLength {
+			k = string(r[:config.SuggestPrefixMaxLength])
+		}
+		// 多取一部分影片, 用于补足展示状态过滤后的数量
+		zl, err := db.Rdb.ZRevRangeWithScores(db.Cxt, fmt.Sprintf(config.SearchSuggestKey, k), 0, int64(n*3-1)).Result()
+		if err != nil {
+			log.Println("Get Film Suggests Error: ", err)
+			continue
+		}
+		for _, z := range zl {
+			m := z.Member.(string)
+			scores[m] = max(scores[m], z.Score)
+		}
+	}
+	if len(scores) == 0 {
+		return nil
+	}
+	var mids []int64
+	for m := range scores {
+		if id, err := strconv.ParseInt(m, 10, 64); err == nil {
+			mids = append(mids, id)
+		}
+	}
+	var sl []SearchInfo
+	if err := db.Mdb.Model(&SearchInfo{}).Scopes(VisibleFilm(t)).Where("mid IN ?", mids).Find(&sl).Error; err != nil {
+		log.Println("Get Film Suggests Error: ", err)
+		return nil
+	}
+	sort.SliceStable(sl, func(i, j int) bool {
+		return scores[strconv.FormatInt(sl[i].Mid, 10)] > scores[strconv.FormatInt(sl[j].Mid, 10)]
+	})
+	return sl[:min(n, len(sl))]
+}
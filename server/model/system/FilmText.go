@@ -0,0 +1,549 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"regexp"
+	"server/config"
+	"server/plugin/common/util"
+	"server/plugin/db"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+/*
+	影片全文检索
+	检索范围包含 片名, 别名, 英文名, 主演, 导演 以及影片简介, 检索文本单独存储, 与影人信息在相同的时机重建
+	MySQL 使用 ngram 分词的 FULLTEXT 索引, SQLite 使用 trigram 分词的 FTS5 索引, 均通过索引召回候选影片
+	关键字过短无法使用索引以及 PostgreSQL 时使用模糊匹配召回, 召回的候选影片统一按照字段权重计算相关度并排序
+	片名与别名额外保存全拼与拼音首字母, 包含英文字母的关键字同时通过拼音召回, 例: xiyouji | xyj | xi游记 -> 西游记
+	检索文本与关键字中的繁体字统一转换为简体字, 高亮片段使用影片详情中的原始文本生成, 例: 西遊記 | 西游记 互相匹配
+*/
+
+// FilmText 影片全文检索信息
+type FilmText struct {
+	Mid      int64  `json:"mid" gorm:"primaryKey;autoIncrement:false"` // 影片ID
+	Name     string `json:"name"`                                      // 片名
+	Alias    string `json:"alias"`                                     // 别名
+	EnName   string `json:"enName"`                                    // 英文名
+	Actor    string `json:"actor"`                                     // 主演
+	Director string `json:"director"`                                  // 导演
+	Content  string `json:"content"`                                   // 简介, 去除 html 标签并截取前 FilmTextContentLimit 个字符
+	Pinyin   string `json:"pinyin"`                                    // 片名与别名的全拼, 多个使用空格分隔
+	Initials string `json:"initials"`                                  // 片名与别名的拼音首字母, 多个使用空格分隔
+}
+
+// TableName 影片全文检索信息表名
+func (FilmText) TableName() string {
+	return config.FilmTextTableName
+}
+
+// FilmTextHit 全文检索命中的影片以及相关度
+type FilmTextHit struct {
+	Mid        int64             `json:"mid"`
+	Cid        int64             `json:"cid"`
+	Score      float64           `json:"score"`                // 相关度
+	Highlights map[string]string `json:"highlights,omitempty"` // 命中字段的高亮片段, 关键字使用 <em> 标记
+}
+
+// filmTextField 参与相关度计算的字段以及 完全匹配 | 前缀匹配 | 包含 的权重, 同组字段只取得分最高的一项
+// Term 不为空时检索词需转换后再进行匹配, 转换结果为空时不参与匹配
+type filmTextField struct {
+	Name                   string
+	Group                  string
+	Exact, Prefix, Contain float64
+	Values                 func(t FilmText) []string
+	Term                   func(term string) string
+}
+
+var (
+	// htmlTagPattern 影片简介中的 html 标签
+	htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+	// aliasSeparator 别名之间的分隔符
+	aliasSeparator = regexp.MustCompile(`[,，/、|;；]+`)
+	// pinyinSlugPattern 采集站提供的拼音 (VodEn), 例: xiyouji | xi-you-ji
+	pinyinSlugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	// filmTextFields 字段权重, 片名 > 别名 > 英文名 > 影人 > 简介
+	filmTextFields = []filmTextField{
+		{"name", "title", 100, 60, 40, func(t FilmText) []string { return []string{t.Name} }, nil},
+		{"alias", "title", 80, 45, 30, func(t FilmText) []string { return aliasSeparator.Split(t.Alias, -1) }, nil},
+		{"enName", "title", 60, 35, 20, func(t FilmText) []string { return []string{t.EnName} }, nil},
+		// 拼音只匹配包含英文字母的检索词, 检索词中的汉字转换为拼音后匹配, 支持 xi游记 | xy记 形式的混合输入
+		{"pinyin", "title", 70, 40, 25, func(t FilmText) []string { return strings.Fields(t.Pinyin) }, pinyinTerm(util.Pinyin)},
+		// 拼音首字母较短, 只进行完全匹配与前缀匹配
+		{"initials", "title", 60, 35, 0, func(t FilmText) []string { return strings.Fields(t.Initials) }, pinyinTerm(util.PinyinInitials)},
+		{"actor", "person", 30, 20, 15, func(t FilmText) []string { return ParsePersonNames(t.Actor) }, nil},
+		{"director", "person", 30, 20, 15, func(t FilmText) []string { return ParsePersonNames(t.Director) }, nil},
+		{"content", "content", 5, 5, 5, func(t FilmText) []string { return []string{t.Content} }, nil},
+	}
+)
+
+// ConvertFilmText 提取影片详情中用于全文检索的文本, 繁体字转换为简体字
+func ConvertFilmText(d MovieDetail) FilmText {
+	t := originFilmText(d)
+	for _, v := range []*string{&t.Name, &t.Alias, &t.EnName, &t.Actor, &t.Director, &t.Content} {
+		*v = util.ToSimplified(*v)
+	}
+	t.Pinyin, t.Initials = filmTitlePinyin(t)
+	return t
+}
+
+// originFilmText 提取影片详情中保留原始字形的检索文本, 用于生成高亮片段
+func originFilmText(d MovieDetail) FilmText {
+	content := strings.Join(strings.Fields(html.UnescapeString(htmlTagPattern.ReplaceAllString(d.Content, " "))), " ")
+	if r := []rune(content); len(r) > config.FilmTextContentLimit {
+		content = string(r[:config.FilmTextContentLimit])
+	}
+	return FilmText{
+		Mid:      d.Id,
+		Name:     strings.TrimSpace(d.Name),
+		Alias:    strings.TrimSpace(d.SubTitle),
+		EnName:   strings.TrimSpace(d.EnName),
+		Actor:    d.Actor,
+		Director: d.Director,
+		Content:  content,
+	}
+}
+
+// filmTitlePinyin 生成包含汉字的片名与别名的全拼与拼音首字母, 采集站提供的拼音作为额外的全拼保留, 用于补充多音字的读音
+// 全拼与首字母分别最多保存 FilmTextPinyinMaxLength 个字符, 超出长度的标题不再追加, 片名的拼音过长时截断
+func filmTitlePinyin(t FilmText) (full, initials string) {
+	var fl, il []string
+	seen := make(map[string]bool)
+	add := func(l *[]string, v string) {
+		if v == "" || seen[v] {
+			return
+		}
+		// 拼音只包含英文字母与数字, 按字节计算长度
+		if len(*l) == 0 {
+			v = v[:min(len(v), config.FilmTextPinyinMaxLength)]
+		} else if len(strings.Join(*l, " "))+1+len(v) > config.FilmTextPinyinMaxLength {
+			return
+		}
+		seen[v] = true
+		*l = append(*l, v)
+	}
+	for _, title := range append([]string{t.Name}, aliasSeparator.Split(t.Alias, -1)...) {
+		if util.HasHan(title) {
+			add(&fl, util.Pinyin(title))
+			add(&il, util.PinyinInitials(title))
+		}
+	}
+	if en := strings.ToLower(t.EnName); len(fl) > 0 && pinyinSlugPattern.MatchString(en) {
+		add(&fl, strings.ReplaceAll(en, "-", ""))
+	}
+	return strings.Join(fl, " "), strings.Join(il, " ")
+}
+
+// pinyinTerm 检索词包含英文字母时转换为拼音, 否则不参与拼音匹配
+func pinyinTerm(convert func(s string) string) func(term string) string {
+	return func(term string) string {
+		if !util.HasLetter(term) {
+			return ""
+		}
+		return convert(term)
+	}
+}
+
+// SearchTerms 将关键字切分为检索词, 多个检索词需同时命中
+func SearchTerms(keyword string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, t := range strings.Fields(util.ToSimplified(strings.ToLower(keyword))) {
+		if !seen[t] {
+			seen[t] = true
+			terms = append(terms, t)
+		}
+	}
+	return terms
+}
+
+// scoreFilmText 计算检索词与影片的相关度, 完整关键字的匹配程度优先, 多个检索词时累加各检索词的匹配程度
+func scoreFilmText(t FilmText, terms []string) float64 {
+	// 完整关键字分别使用空格连接与直接连接两种形式匹配, 例: the wandering earth | 流浪 地球
+	score := max(fieldsScore(t, strings.Join(terms, " "), 1), fieldsScore(t, strings.Join(terms, ""), 1))
+	if len(terms) > 1 {
+		for _, term := range terms {
+			score += fieldsScore(t, term, 0.5)
+		}
+	}
+	return score
+}
+
+// fieldsScore 计算单个检索词在各组字段中的匹配得分之和
+func fieldsScore(t FilmText, term string, weight float64) float64 {
+	groups := make(map[string]float64)
+	for _, f := range filmTextFields {
+		ft := term
+		if f.Term != nil {
+			if ft = f.Term(term); ft == "" {
+				continue
+			}
+		}
+		var best float64
+		for _, v := range f.Values(t) {
+			v = strings.ToLower(strings.TrimSpace(v))
+			switch {
+			case v == "":
+			case v == ft:
+				best = max(best, f.Exact)
+			case strings.HasPrefix(v, ft):
+				best = max(best, f.Prefix)
+			case strings.Contains(v, ft):
+				best = max(best, f.Contain)
+			}
+		}
+		groups[f.Group] = max(groups[f.Group], best)
+	}
+	var score float64
+	for _, g := range groups {
+		score += g * weight
+	}
+	return score
+}
+
+// highlightFilmText 生成命中字段的高亮片段, 简介只保留关键字附近的内容
+func highlightFilmText(t FilmText, terms []string) map[string]string {
+	res := make(map[string]string)
+	for _, f := range filmTextFields {
+		// 拼音字段不生成高亮片段
+		if f.Term != nil {
+			continue
+		}
+		text := strings.Join(f.Values(t), " ")
+		if f.Name == "alias" {
+			text = t.Alias
+		}
+		if s, ok := highlightText(text, terms, f.Name == "content"); ok {
+			res[f.Name] = s
+		}
+	}
+	return res
+}
+
+// highlightText 使用 <em> 标记文本中的检索词, fragment 为 true 时截取第一个检索词前后的片段
+func highlightText(text string, terms []string, fragment bool) (string, bool) {
+	runes := []rune(text)
+	// 繁体字逐字转换为简体字后匹配, 转换不改变字符位置
+	lower := []rune(util.ToSimplified(strings.ToLower(text)))
+	// 大小写转换后长度发生变化的文本无法按位置标记
+	if text == "" || len(runes) != len(lower) {
+		return "", false
+	}
+	marks := make([]bool, len(runes))
+	first := -1
+	for _, term := range terms {
+		tr := []rune(term)
+		for i := 0; i+len(tr) <= len(lower); i++ {
+			if string(lower[i:i+len(tr)]) != term {
+				continue
+			}
+			for j := i; j < i+len(tr); j++ {
+				marks[j] = true
+			}
+			if first < 0 || i < first {
+				first = i
+			}
+		}
+	}
+	if first < 0 {
+		return "", false
+	}
+	start, end := 0, len(runes)
+	if fragment {
+		start, end = max(first-30, 0), min(first+60, len(runes))
+	}
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("...")
+	}
+	for i := start; i < end; i++ {
+		if marks[i] && (i == start || !marks[i-1]) {
+			b.WriteString("<em>")
+		}
+		b.WriteString(html.EscapeString(string(runes[i])))
+		if marks[i] && (i == end-1 || !marks[i+1]) {
+			b.WriteString("</em>")
+		}
+	}
+	if end < len(runes) {
+		b.WriteString("...")
+	}
+	return b.String(), true
+}
+
+// ------------------------------------------------------ MySQL ------------------------------------------------------
+
+// SaveFilmTexts 保存影片的全文检索信息, 影片已存在时覆盖
+func SaveFilmTexts(list ...MovieDetail) error {
+	index := make(map[int64]int)
+	var tl []FilmText
+	for _, d := range list {
+		t := ConvertFilmText(d)
+		if i, ok := index[t.Mid]; ok {
+			tl[i] = t
+			continue
+		}
+		index[t.Mid] = len(tl)
+		tl = append(tl, t)
+	}
+	upsert := func(v interface{}) error {
+		return db.Mdb.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "mid"}}, UpdateAll: true}).Create(v).Error
+	}
+	var errs []error
+	for i := 0; i < len(tl); i += config.MaxScanCount {
+		batch := tl[i:min(i+config.MaxScanCount, len(tl))]
+		err := upsert(&batch)
+		if err == nil {
+			continue
+		}
+		log.Printf("Save Film Texts Error: batch mid %d-%d, %v\n", batch[0].Mid, batch[len(batch)-1].Mid, err)
+		// 批量写入失败时逐条写入, 避免单条异常数据导致整批影片缺失检索信息
+		for _, t := range batch {
+			if err = upsert(&t); err != nil {
+				log.Printf("Save Film Text Error: mid %d, %v\n", t.Mid, err)
+				errs = append(errs, fmt.Errorf("mid %d: %w", t.Mid, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// DelFilmText 删除影片的全文检索信息
+func DelFilmText(mid int64) error {
+	return db.Mdb.Where("mid = ?", mid).Delete(&FilmText{}).Error
+}
+
+// TruncateFilmText 清空全文检索信息
+func TruncateFilmText() error {
+	return db.TruncateTable(config.FilmTextTableName)
+}
+
+// ExistFilmText 是否已生成影片的全文检索信息
+func ExistFilmText() bool {
+	var mids []int64
+	db.Mdb.Model(&FilmText{}).Limit(1).Pluck("mid", &mids)
+	return len(mids) > 0
+}
+
+// SearchFilmText 全文检索可展示的影片, 按相关度倒序排列, 相关度相同时热度与年份较高的影片靠前
+func SearchFilmText(t Tenant, keyword string, highlight bool, page *Page) []FilmTextHit {
+	terms := SearchTerms(keyword)
+	if len(terms) == 0 {
+		return nil
+	}
+	// 全文检索信息尚未生成时 (升级后后台补全期间) 使用片名模糊匹配, 避免已有影片无法被搜索到
+	if !ExistFilmText() {
+		return searchFilmTitle(t, keyword, terms, highlight, page)
+	}
+	mids, err := filmTextCandidates(t, terms)
+	if err != nil {
+		log.Println("Film Text Search Error: ", err)
+		return nil
+	}
+	if len(mids) == 0 {
+		return nil
+	}
+	var tl []FilmText
+	var sl []SearchInfo
+	if err = db.Mdb.Where("mid IN ?", mids).Find(&tl).Error; err == nil {
+		err = db.Mdb.Where("mid IN ?", mids).Find(&sl).Error
+	}
+	if err != nil {
+		log.Println("Film Text Search Error: ", err)
+		return nil
+	}
+	texts := make(map[int64]FilmText, len(tl))
+	for _, t := range tl {
+		texts[t.Mid] = t
+	}
+	type hit struct {
+		FilmTextHit
+		search SearchInfo
+		text   FilmText
+	}
+	var hl []hit
+	for _, s := range sl {
+		t, ok := texts[s.Mid]
+		if !ok {
+			continue
+		}
+		hl = append(hl, hit{FilmTextHit{Mid: s.Mid, Cid: s.Cid, Score: scoreFilmText(t, terms)}, s, t})
+	}
+	sort.SliceStable(hl, func(i, j int) bool {
+		switch {
+		case hl[i].Score != hl[j].Score:
+			return hl[i].Score > hl[j].Score
+		case hl[i].search.Hits != hl[j].search.Hits:
+			return hl[i].search.Hits > hl[j].search.Hits
+		default:
+			return hl[i].search.Year > hl[j].search.Year
+		}
+	})
+	page.Total = len(hl)
+	page.PageCount = (page.Total + page.PageSize - 1) / page.PageSize
+	var res []FilmTextHit
+	for _, h := range hl[min((page.Current-1)*page.PageSize, len(hl)):min(page.Current*page.PageSize, len(hl))] {
+		if highlight {
+			// 优先使用影片详情中的原始文本, 保留片名等信息的繁体字形
+			t := h.text
+			if d := GetDetailByKey(fmt.Sprintf(config.MovieDetailKey, h.Cid, h.Mid)); d.Id != 0 {
+				t = originFilmText(d)
+			}
+			h.Highlights = highlightFilmText(t, terms)
+		}
+		res = append(res, h.FilmTextHit)
+	}
+	return res
+}
+
+// searchFilmTitle 通过影片名称模糊匹配可展示的影片, 年份与更新时间较新的影片靠前
+func searchFilmTitle(t Tenant, keyword string, terms []string, highlight bool, page *Page) []FilmTextHit {
+	var sl []SearchInfo
+	// 名称条件需作为一个整体, 避免 OR 条件绕过分类展示状态的过滤
+	cond := db.Mdb.Where(db.Like("name"), fmt.Sprint(`%`, keyword, `%`)).Or(db.Like("sub_title"), fmt.Sprint(`%`, keyword, `%`))
+	var count int64
+	if err := db.Mdb.Model(&SearchInfo{}).Scopes(VisibleFilm(t)).Where(cond).Count(&count).Error; err != nil {
+		log.Println("Film Title Search Error: ", err)
+		return nil
+	}
+	page.Total = int(count)
+	page.PageCount = (page.Total + page.PageSize - 1) / page.PageSize
+	db.Mdb.Scopes(VisibleFilm(t)).Limit(page.PageSize).Offset((page.Current - 1) * page.PageSize).
+		Where(cond).Order("year DESC, update_stamp DESC").Find(&sl)
+	var res []FilmTextHit
+	for _, s := range sl {
+		h := FilmTextHit{Mid: s.Mid, Cid: s.Cid}
+		if highlight {
+			if d := GetDetailByKey(fmt.Sprintf(config.MovieDetailKey, s.Cid, s.Mid)); d.Id != 0 {
+				h.Highlights = highlightFilmText(originFilmText(d), terms)
+			}
+		}
+		res = append(res, h)
+	}
+	return res
+}
+
+// filmTextCandidates 召回可展示的候选影片ID, 检索词长度满足索引分词要求时使用全文索引, 否则使用模糊匹配
+func filmTextCandidates(t Tenant, terms []string) ([]int64, error) {
+	shortest := utf8.RuneCountInString(terms[0])
+	for _, t := range terms {
+		shortest = min(shortest, utf8.RuneCountInString(t))
+	}
+	var mids []int64
+	var err error
+	switch {
+	case db.Dialect() == db.DialectMysql && shortest >= 2:
+		// ngram 默认按照两个字符分词, 使用短语匹配保证检索词连续出现
+		var sl []string
+		for _, t := range terms {
+			sl = append(sl, fmt.Sprintf(`+"%s"`, strings.ReplaceAll(t, `"`, "")))
+		}
+		match := "MATCH(name, alias, en_name, actor, director, content) AGAINST(? IN BOOLEAN MODE)"
+		against := strings.Join(sl, " ")
+		err = db.Mdb.Model(&FilmText{}).Where(match, against).Where("mid IN (?)", visibleFilmMids(t)).
+			Clauses(clause.OrderBy{Expression: clause.Expr{SQL: match + " DESC", Vars: []interface{}{against}}}).
+			Limit(config.FilmTextMaxCandidates).Pluck("mid", &mids).Error
+	case db.Dialect() == db.DialectSqlite && shortest >= 3:
+		// trigram 分词要求检索词至少包含三个字符
+		var sl []string
+		for _, t := range terms {
+			sl = append(sl, fmt.Sprintf(`"%s"`, strings.ReplaceAll(t, `"`, `""`)))
+		}
+		err = db.Mdb.Table(config.FilmTextFtsTableName).
+			Where(fmt.Sprintf("%s MATCH ?", config.FilmTextFtsTableName), strings.Join(sl, " ")).
+			Where("rowid IN (?)", visibleFilmMids(t)).Order("rank").
+			Limit(config.FilmTextMaxCandidates).Pluck("rowid", &mids).Error
+	default:
+		mids, err = likeFilmTextCandidates(t, terms)
+	}
+	if err != nil {
+		// 全文索引不可用时退回模糊匹配
+		log.Println("Film Text Index Error: ", err)
+		if mids, err = likeFilmTextCandidates(t, terms); err != nil {
+			return nil, err
+		}
+	}
+	// 包含英文字母的关键字额外召回拼音匹配的影片
+	if kw := strings.Join(terms, ""); util.HasLetter(kw) {
+		pl, err := pinyinFilmTextCandidates(t, kw)
+		if err != nil {
+			return nil, err
+		}
+		seen := make(map[int64]bool, len(mids))
+		for _, m := range mids {
+			seen[m] = true
+		}
+		for _, m := range pl {
+			if !seen[m] {
+				mids = append(mids, m)
+			}
+		}
+	}
+	return mids, nil
+}
+
+// pinyinFilmTextCandidates 召回全拼包含关键字或拼音首字母以关键字开头的影片ID, 关键字中的汉字转换为拼音后匹配
+func pinyinFilmTextCandidates(t Tenant, keyword string) ([]int64, error) {
+	full, initials := util.Pinyin(keyword), util.PinyinInitials(keyword)
+	cond := db.Mdb.Where("initials LIKE ?", initials+"%").Or("initials LIKE ?", "% "+initials+"%")
+	// 单个字母的全拼匹配范围过大, 只匹配拼音首字母
+	if len(full) > 1 {
+		cond = cond.Or("pinyin LIKE ?", "%"+full+"%")
+	}
+	var mids []int64
+	err := db.Mdb.Model(&FilmText{}).Where("mid IN (?)", visibleFilmMids(t)).Where(cond).
+		Order("mid DESC").Limit(config.FilmTextMaxCandidates).Pluck("mid", &mids).Error
+	return mids, err
+}
+
+// likeFilmTextCandidates 使用模糊匹配召回候选影片ID, 每个检索词需命中任意字段
+func likeFilmTextCandidates(t Tenant, terms []string) ([]int64, error) {
+	qw := db.Mdb.Model(&FilmText{}).Where("mid IN (?)", visibleFilmMids(t))
+	for _, t := range terms {
+		like := fmt.Sprint("%", t, "%")
+		var cond *gorm.DB
+		for _, c := range []string{"name", "alias", "en_name", "actor", "director", "content"} {
+			if cond == nil {
+				cond = db.Mdb.Where(db.Like(c), like)
+				continue
+			}
+			cond = cond.Or(db.Like(c), like)
+		}
+		qw = qw.Where(cond)
+	}
+	var mids []int64
+	// 候选数量受限, 截取前优先保留片名完全匹配与前缀匹配的影片, 避免其被较新的影片挤出候选范围
+	err := qw.Clauses(clause.OrderBy{Expression: filmTitleRank(terms)}).Limit(config.FilmTextMaxCandidates).Pluck("mid", &mids).Error
+	return mids, err
+}
+
+// filmTitleRank 按照 片名完全匹配 | 片名前缀匹配 | 片名或别名包含 | 其他字段包含 的顺序排列候选影片, 相同匹配程度的影片按ID倒序
+func filmTitleRank(terms []string) clause.Expr {
+	var exact, prefix, contain []string
+	var ev, pv, cv []interface{}
+	seen := make(map[string]bool)
+	for _, kw := range []string{strings.Join(terms, " "), strings.Join(terms, "")} {
+		if seen[kw] {
+			continue
+		}
+		seen[kw] = true
+		exact = append(exact, "LOWER(name) = ?")
+		ev = append(ev, kw)
+		prefix = append(prefix, db.Like("name"))
+		pv = append(pv, fmt.Sprint(kw, "%"))
+		contain = append(contain, db.Like("name"), db.Like("alias"))
+		cv = append(cv, fmt.Sprint("%", kw, "%"), fmt.Sprint("%", kw, "%"))
+	}
+	return clause.Expr{
+		SQL: fmt.Sprintf("CASE WHEN %s THEN 0 WHEN %s THEN 1 WHEN %s THEN 2 ELSE 3 END, mid DESC",
+			strings.Join(exact, " OR "), strings.Join(prefix, " OR "), strings.Join(contain, " OR ")),
+		Vars: append(append(ev, pv...), cv...),
+	}
+}
@@ -0,0 +1,44 @@
+package system
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLikeFilmTextCandidates(t *testing.T) {
+	films := []MovieDetail{
+		{Id: 301, Name: "西游"},
+		{Id: 302, Name: "西游记"},
+		{Id: 303, Name: "大话西游"},
+		{Id: 304, Name: "齐天大圣", MovieDescriptor: MovieDescriptor{Content: "改编自西游记"}},
+		{Id: 305, Name: "女儿国", MovieDescriptor: MovieDescriptor{Content: "西游路上的故事"}},
+		{Id: 306, Name: "三打白骨精", MovieDescriptor: MovieDescriptor{Content: "西游记中的经典故事"}},
+	}
+	if err := SaveDetails(films); err != nil {
+		t.Fatalf("SaveDetails error = %v", err)
+	}
+	SyncSearchInfo(1)
+
+	// 候选影片数量受 FilmTextMaxCandidates 限制, 截取前需按片名匹配程度排列, 相同程度的影片按ID倒序
+	tests := []struct {
+		name  string
+		terms []string
+		want  []int64
+	}{
+		{"完全匹配 前缀匹配 片名包含 简介包含", []string{"西游"}, []int64{301, 302, 303, 306, 305, 304}},
+		{"多个检索词使用直接连接的形式匹配片名", []string{"西", "游"}, []int64{301, 302, 303, 306, 305, 304}},
+		{"仅简介包含", []string{"故事"}, []int64{306, 305}},
+		{"不存在", []string{"东游"}, []int64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := likeFilmTextCandidates(DefaultTenant(), tt.terms)
+			if err != nil {
+				t.Fatalf("likeFilmTextCandidates(%q) error = %v", tt.terms, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("likeFilmTextCandidates(%q) = %v, want %v", tt.terms, got, tt.want)
+			}
+		})
+	}
+}
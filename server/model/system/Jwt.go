@@ -3,6 +3,7 @@ package system
 import (
 	"errors"
 	"fmt"
+	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"log"
 	"server/config"
@@ -87,3 +88,13 @@ func GetUserTokenById(userId uint) string {
 func ClearUserToken(userId uint) error {
 	return db.Rdb.Del(db.Cxt, fmt.Sprintf(config.UserTokenKey, userId)).Err()
 }
+
+// CurrentOperator 获取当前登录用户的用户名, 用于记录管理操作的操作人
+func CurrentOperator(c *gin.Context) string {
+	if v, ok := c.Get(config.AuthUserClaims); ok {
+		if uc, ok := v.(*UserClaims); ok {
+			return uc.UserName
+		}
+	}
+	return ""
+}
@@ -46,26 +46,35 @@ func (bl Banners) Swap(i, j int) {
 
 // ------------------------------------------------------ Redis ------------------------------------------------------
 
-// SaveSiteBasic 保存网站基本配置信息
-func SaveSiteBasic(c BasicConfig) error {
+// SaveSiteBasic 保存站点的网站基本配置信息
+func SaveSiteBasic(tid string, c BasicConfig) error {
 	data, _ := json.Marshal(c)
-	return db.Rdb.Set(db.Cxt, config.SiteConfigBasic, data, config.ManageConfigExpired).Err()
+	err := db.Rdb.Set(db.Cxt, config.TenantKey(tid, config.SiteConfigBasic), data, config.ManageConfigExpired).Err()
+	// 站点域名可能发生变更, 清除域名映射缓存
+	resetTenantResolver()
+	return err
 }
 
-// GetSiteBasic 获取网站基本配置信息
-func GetSiteBasic() BasicConfig {
+// GetSiteBasic 获取站点的网站基本配置信息
+func GetSiteBasic(tid string) BasicConfig {
 	c := BasicConfig{}
-	data := db.Rdb.Get(db.Cxt, config.SiteConfigBasic).Val()
+	data := db.Rdb.Get(db.Cxt, config.TenantKey(tid, config.SiteConfigBasic)).Val()
+	if data == "" {
+		return c
+	}
 	if err := json.Unmarshal([]byte(data), &c); err != nil {
 		log.Println("GetSiteBasic Err", err)
 	}
 	return c
 }
 
-// GetBanners 获取轮播配置信息
-func GetBanners() Banners {
+// GetBanners 获取站点的轮播配置信息
+func GetBanners(tid string) Banners {
 	var bl Banners
-	data := db.Rdb.Get(db.Cxt, config.BannersKey).Val()
+	data := db.Rdb.Get(db.Cxt, config.TenantKey(tid, config.BannersKey)).Val()
+	if data == "" {
+		return bl
+	}
 	if err := json.Unmarshal([]byte(data), &bl); err != nil {
 		log.Println("Get Banners Error", err)
 	}
@@ -74,8 +83,8 @@ func GetBanners() Banners {
 	return bl
 }
 
-// SaveBanners 保存轮播配置信息
-func SaveBanners(bl Banners) error {
+// SaveBanners 保存站点的轮播配置信息
+func SaveBanners(tid string, bl Banners) error {
 	data, _ := json.Marshal(bl)
-	return db.Rdb.Set(db.Cxt, config.BannersKey, data, config.ManageConfigExpired).Err()
+	return db.Rdb.Set(db.Cxt, config.TenantKey(tid, config.BannersKey), data, config.ManageConfigExpired).Err()
 }
@@ -0,0 +1,101 @@
+package system
+
+import (
+	"regexp"
+	"strings"
+)
+
+/*
+	影片画质 | 版本 | 字幕标签
+	从采集站的画质版本(VodVersion), 更新状态, 片名以及播放组名称中提取, 统一转换为固定的标签值
+	画质只保留一个: 存在枪版标识时为枪版, 否则取最高的画质; 版本可能存在多个, 使用 , 分隔; 字幕只保留一个
+*/
+
+// mediaTag 标签值以及对应的匹配规则
+type mediaTag struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+var (
+	// qualityTags 画质标签, 按画质从高到低排列
+	qualityTags = []mediaTag{
+		{"4K", regexp.MustCompile(`(?i)\b(4K|2160P|UHD)\b|超高清`)},
+		{"蓝光", regexp.MustCompile(`(?i)蓝光|\b(BD|BluRay|Blu-ray|BDRip)\b`)},
+		{"1080P", regexp.MustCompile(`(?i)\b(1080[PI]|FHD)\b`)},
+		{"720P", regexp.MustCompile(`(?i)\b720P\b`)},
+		{"高清", regexp.MustCompile(`(?i)\b(HD|HDRip|WEB-?DL)\b|高清|超清`)},
+		{"标清", regexp.MustCompile(`(?i)\b(SD|DVD|DVDRip|480P)\b|标清`)},
+	}
+	// camQuality 枪版标签, 影院录制的版本无论其他标识如何均视为枪版
+	camQuality = mediaTag{"枪版", regexp.MustCompile(`(?i)\b(TC|TS|CAM|HDTC|HDTS|HDCAM)\b|枪版|抢先版`)}
+	// versionTags 版本标签
+	versionTags = []mediaTag{
+		{"国语版", regexp.MustCompile(`国语|普通话`)},
+		{"粤语版", regexp.MustCompile(`粤语`)},
+		{"原声版", regexp.MustCompile(`原声|原版`)},
+		{"导演剪辑版", regexp.MustCompile(`(?i)导演剪辑|director'?s\s*cut`)},
+		{"加长版", regexp.MustCompile(`(?i)加长|extended`)},
+		{"未删减版", regexp.MustCompile(`(?i)未删减|无删减|uncut`)},
+		{"3D", regexp.MustCompile(`(?i)\b3D\b`)},
+	}
+	// subtitleTags 字幕标签, 按优先级排列
+	subtitleTags = []mediaTag{
+		{"双语字幕", regexp.MustCompile(`中英双?字|双字|双语字幕|中英字幕`)},
+		{"中文字幕", regexp.MustCompile(`中字|中文字幕|简中|繁中|简繁|字幕`)},
+		{"无字幕", regexp.MustCompile(`生肉|无字`)},
+	}
+)
+
+// ParseMediaTags 从文本中提取 画质 | 版本 | 字幕 标签
+func ParseMediaTags(texts ...string) (quality, version, subtitle string) {
+	s := strings.Join(texts, " ")
+	if camQuality.Pattern.MatchString(s) {
+		quality = camQuality.Name
+	} else {
+		quality = firstMediaTag(qualityTags, s)
+	}
+	var vl []string
+	for _, t := range versionTags {
+		if t.Pattern.MatchString(s) {
+			vl = append(vl, t.Name)
+		}
+	}
+	return quality, strings.Join(vl, ","), firstMediaTag(subtitleTags, s)
+}
+
+// MediaTagNames 获取 Quality | Version | Subtitle 对应的全部标签值
+func MediaTagNames(title string) []string {
+	var tags []mediaTag
+	switch title {
+	case "Quality":
+		tags = append(qualityTags, camQuality)
+	case "Version":
+		tags = versionTags
+	case "Subtitle":
+		tags = subtitleTags
+	}
+	var names []string
+	for _, t := range tags {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+// firstMediaTag 获取第一个匹配的标签
+func firstMediaTag(tags []mediaTag, s string) string {
+	for _, t := range tags {
+		if t.Pattern.MatchString(s) {
+			return t.Name
+		}
+	}
+	return ""
+}
+
+// ResolveMediaTags 补全缺失的画质 | 版本 | 字幕标签, 旧版本采集的详情数据以及手动添加的影片通过更新状态, 片名与播放组名称提取
+func (d *MovieDetail) ResolveMediaTags() {
+	if d.Quality != "" || d.Version != "" || d.Subtitle != "" {
+		return
+	}
+	d.Quality, d.Version, d.Subtitle = ParseMediaTags(d.Remarks, d.Name, strings.Join(d.PlayFrom, " "))
+}
@@ -0,0 +1,62 @@
+package system
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMediaTags(t *testing.T) {
+	tests := []struct {
+		name         string
+		in           []string
+		wantQuality  string
+		wantVersion  string
+		wantSubtitle string
+	}{
+		{"高清国语", []string{"HD国语"}, "高清", "国语版", ""},
+		{"1080P双语字幕", []string{"1080P 中英双字"}, "1080P", "", "双语字幕"},
+		{"蓝光中字", []string{"BD中字"}, "蓝光", "", "中文字幕"},
+		{"取最高画质", []string{"4K 蓝光 1080P"}, "4K", "", ""},
+		{"枪版优先", []string{"1080P", "HDTC"}, "枪版", "", ""},
+		{"抢先版", []string{"抢先版"}, "枪版", "", ""},
+		{"多个版本", []string{"国语 粤语 导演剪辑 未删减"}, "", "国语版,粤语版,导演剪辑版,未删减版", ""},
+		{"英文版本标识忽略大小写", []string{"Director's Cut", "EXTENDED"}, "", "导演剪辑版,加长版", ""},
+		{"3D蓝光", []string{"BDRip 3D"}, "蓝光", "3D", ""},
+		{"WEB-DL", []string{"WEB-DL"}, "高清", "", ""},
+		{"生肉", []string{"生肉"}, "", "", "无字幕"},
+		{"多段文本合并", []string{"", "正片", "某电影 1080P", "蓝光线路"}, "蓝光", "", ""},
+		{"单词内的标识不匹配", []string{"LTS HDMI SDK"}, "", "", ""},
+		{"更新状态", []string{"更新至12集"}, "", "", ""},
+		{"空字符串", []string{""}, "", "", ""},
+		{"无参数", nil, "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, v, s := ParseMediaTags(tt.in...)
+			if q != tt.wantQuality || v != tt.wantVersion || s != tt.wantSubtitle {
+				t.Errorf("ParseMediaTags(%q) = %q, %q, %q, want %q, %q, %q",
+					tt.in, q, v, s, tt.wantQuality, tt.wantVersion, tt.wantSubtitle)
+			}
+		})
+	}
+}
+
+func TestMediaTagNames(t *testing.T) {
+	tests := []struct {
+		title string
+		want  []string
+	}{
+		{"Quality", []string{"4K", "蓝光", "1080P", "720P", "高清", "标清", "枪版"}},
+		{"Version", []string{"国语版", "粤语版", "原声版", "导演剪辑版", "加长版", "未删减版", "3D"}},
+		{"Subtitle", []string{"双语字幕", "中文字幕", "无字幕"}},
+		{"quality", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			if got := MediaTagNames(tt.title); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MediaTagNames(%q) = %v, want %v", tt.title, got, tt.want)
+			}
+		})
+	}
+}
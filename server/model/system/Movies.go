@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"github.com/redis/go-redis/v9"
 	"hash/fnv"
+	"log"
 	"regexp"
 	"server/config"
+	"server/plugin/common/util"
 	"server/plugin/db"
 	"strconv"
 	"strings"
@@ -48,6 +50,14 @@ type MovieDescriptor struct {
 	DbScore     string `json:"dbScore"`     // 豆瓣评分
 	Hits        int64  `json:"hits"`        //影片热度
 	Content     string `json:"content"`     //内容简介
+	Total       int64  `json:"total"`       // 总集数
+	Serial      int64  `json:"serial"`      // 已更新集数
+	IsEnd       bool   `json:"isEnd"`       // 是否完结
+	Weekday     string `json:"weekday"`     // 播出周期 例: 一,三
+	Tv          string `json:"tv"`          // 播出电视台 | 平台
+	Quality     string `json:"quality"`     // 画质 4K | 蓝光 | 1080P | 高清 | 枪版 ...
+	Version     string `json:"version"`     // 版本 国语版 | 导演剪辑版 ..., 多个版本使用 , 分隔
+	Subtitle    string `json:"subtitle"`    // 字幕 中文字幕 | 双语字幕 | 无字幕
 }
 
 // MovieBasicInfo 影片基本信息
@@ -86,6 +96,7 @@ type MovieDetail struct {
 	//PlaySeparator   string              `json:"playSeparator"` // 播放信息分隔符
 	PlayList        [][]MovieUrlInfo    `json:"playList"`     //播放地址url
 	DownloadList    [][]MovieUrlInfo    `json:"downloadList"` // 下载url地址
+	Plots           []EpisodePlot       `json:"plots"`        // 分集剧情, 按集数序号排列
 	MovieDescriptor `json:"descriptor"` //影片描述信息
 }
 
@@ -93,6 +104,17 @@ type MovieDetail struct {
 
 // SaveDetails 保存影片详情信息到redis中 格式: MovieDetail:Cid?:Id?
 func SaveDetails(list []MovieDetail) (err error) {
+	// 按照分类树修正影片的所属分类
+	ResolveFilmCategory(list)
+	// 新数据到达时丢弃未锁定字段的编辑信息, 检索信息使用应用编辑信息后的数据生成
+	overlays := ReconcileFilmOverlay(list)
+	// 已合并到其他影片的重复影片只保存详情数据, 不再生成检索信息
+	var mids []int64
+	for _, d := range list {
+		mids = append(mids, d.Id)
+	}
+	redirects := GetFilmRedirects(mids...)
+	var searchList []MovieDetail
 	// 遍历list中的信息
 	for _, detail := range list {
 		// 序列化影片详情信息
@@ -102,7 +124,16 @@ func SaveDetails(list []MovieDetail) (err error) {
 		err = db.Rdb.Set(db.Cxt, fmt.Sprintf(config.MovieDetailKey, detail.Cid, detail.Id), data, config.FilmExpired).Err()
 		// 2. 同步保存简略信息到redis中
 		SaveMovieBasicInfo(detail)
+		if r, ok := redirects[detail.Id]; ok {
+			if r.Cid != detail.Cid {
+				r.Cid = detail.Cid
+				updateFilmRedirect(*r)
+			}
+			continue
+		}
 		// 3. 保存 Search tag redis中
+		ApplyFilmOverlay(&detail, overlays[detail.Id])
+		searchList = append(searchList, detail)
 		if err == nil {
 			// 转换 detail信息
 			searchInfo := ConvertSearchInfo(detail)
@@ -112,12 +143,28 @@ func SaveDetails(list []MovieDetail) (err error) {
 
 	}
 	// 保存一份search信息到mysql, 批量存储
-	BatchSaveSearchInfo(list)
+	BatchSaveSearchInfo(searchList)
+	// 重建影片的影人信息
+	if e := SaveFilmPersons(searchList...); e != nil {
+		log.Println("SaveFilmPersons Error: ", e)
+	}
+	// 重建影片的全文检索信息
+	if e := SaveFilmTexts(searchList...); e != nil {
+		log.Println("SaveFilmTexts Error: ", e)
+	}
+	// 更新影片的搜索建议
+	if e := SaveFilmSuggests(searchList...); e != nil {
+		log.Println("SaveFilmSuggests Error: ", e)
+	}
 	return err
 }
 
 // SaveDetail 保存单部影片信息
 func SaveDetail(detail MovieDetail) (err error) {
+	// 按照分类树修正影片的所属分类
+	list := []MovieDetail{detail}
+	ResolveFilmCategory(list)
+	detail = list[0]
 	// 序列化影片详情信息
 	data, _ := json.Marshal(detail)
 	// 保存影片信息到Redis
@@ -127,14 +174,121 @@ func SaveDetail(detail MovieDetail) (err error) {
 	}
 	// 2. 同步保存简略信息到redis中
 	SaveMovieBasicInfo(detail)
-	// 转换 detail信息
+	// 已合并到其他影片的重复影片不再生成检索信息
+	if r := GetFilmRedirect(detail.Id); r != nil {
+		if r.Cid != detail.Cid {
+			r.Cid = detail.Cid
+			updateFilmRedirect(*r)
+		}
+		return nil
+	}
+	// 应用未被丢弃的编辑信息后转换 detail信息
+	ApplyFilmOverlay(&detail, ReconcileFilmOverlay([]MovieDetail{detail})[detail.Id])
 	searchInfo := ConvertSearchInfo(detail)
 	// 3. 保存 Search tag redis中
 	// 只存储用于检索对应影片的关键字信息
 	SaveSearchTag(searchInfo)
 	// 保存影片检索信息到searchTable
-	err = SaveSearchInfo(searchInfo)
-	return err
+	if err = SaveSearchInfo(searchInfo); err != nil {
+		return err
+	}
+	// 重建影片的影人信息, 全文检索信息与搜索建议
+	if err = SaveFilmPersons(detail); err != nil {
+		return err
+	}
+	if err = SaveFilmTexts(detail); err != nil {
+		return err
+	}
+	return SaveFilmSuggests(detail)
+}
+
+// NextLocalFilmId 生成本地添加与导入的影片ID, ID从 LocalFilmIdStart 开始递增
+func NextLocalFilmId() (int64, error) {
+	if err := db.Rdb.SetNX(db.Cxt, config.LocalFilmIdKey, config.LocalFilmIdStart-1, 0).Err(); err != nil {
+		return 0, err
+	}
+	return db.Rdb.Incr(db.Cxt, config.LocalFilmIdKey).Result()
+}
+
+// IsLocalFilmKey 判断影片详情或基本信息的 key 是否属于本地添加与导入的影片
+func IsLocalFilmKey(key string) bool {
+	i := strings.LastIndex(key, ":Id")
+	if i < 0 {
+		return false
+	}
+	id, err := strconv.ParseInt(key[i+len(":Id"):], 10, 64)
+	return err == nil && id >= config.LocalFilmIdStart
+}
+
+// GetLocalFilmDetails 获取所有本地添加与导入的影片详情
+func GetLocalFilmDetails() ([]MovieDetail, error) {
+	keys, err := db.ScanKeys(config.KeyPattern(config.MovieDetailKey))
+	if err != nil {
+		return nil, err
+	}
+	var local []string
+	for _, k := range keys {
+		if IsLocalFilmKey(k) {
+			local = append(local, k)
+		}
+	}
+	var list []MovieDetail
+	for i := 0; i < len(local); i += config.MaxScanCount {
+		vals, err := db.Rdb.MGet(db.Cxt, local[i:min(i+config.MaxScanCount, len(local))]...).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range vals {
+			data, ok := v.(string)
+			if !ok {
+				continue
+			}
+			d := MovieDetail{}
+			if err = json.Unmarshal([]byte(data), &d); err == nil {
+				list = append(list, d)
+			}
+		}
+	}
+	return list, nil
+}
+
+// SyncLocalFilms 重新生成本地影片的检索信息, 检索表全量重建后执行
+// 本地影片不会出现在采集站的数据中, 需要从影片详情中重新生成检索信息, 全文检索信息与搜索建议
+func SyncLocalFilms() error {
+	list, err := GetLocalFilmDetails()
+	if err != nil || len(list) == 0 {
+		return err
+	}
+	var mids []int64
+	for _, d := range list {
+		mids = append(mids, d.Id)
+	}
+	redirects := GetFilmRedirects(mids...)
+	overlays := GetFilmOverlays(mids...)
+	var details []MovieDetail
+	var infos []SearchInfo
+	for _, d := range list {
+		// 已合并到其他影片的重复影片不再生成检索信息
+		if _, ok := redirects[d.Id]; ok {
+			continue
+		}
+		ApplyFilmOverlay(&d, overlays[d.Id])
+		info := ConvertSearchInfo(d)
+		SaveSearchTag(info)
+		details = append(details, d)
+		infos = append(infos, info)
+	}
+	if len(infos) == 0 {
+		return nil
+	}
+	BatchSaveOrUpdate(infos)
+	if err = SaveFilmPersons(details...); err != nil {
+		return err
+	}
+	if err = SaveFilmTexts(details...); err != nil {
+		return err
+	}
+	return SaveFilmSuggests(details...)
 }
 
 // SaveMovieBasicInfo 摘取影片的详情部分信息转存为影视基本信息
@@ -200,6 +354,14 @@ func BatchSaveSearchInfo(list []MovieDetail) {
 
 // ConvertSearchInfo 将detail信息处理成 searchInfo
 func ConvertSearchInfo(detail MovieDetail) SearchInfo {
+	// 旧版本采集的详情数据以及手动添加的影片缺少连载信息与画质标签, 通过更新状态补全
+	detail.ResolveSerial()
+	detail.ResolveMediaTags()
+	// 采集站未提供首字母时使用片名的拼音首字母
+	initial := detail.Initial
+	if p := util.PinyinInitials(detail.Name); initial == "" && p != "" {
+		initial = strings.ToUpper(p[:1])
+	}
 	score, _ := strconv.ParseFloat(detail.DbScore, 64)
 	stamp, _ := time.ParseInLocation(time.DateTime, detail.UpdateTime, time.Local)
 	// detail中的年份信息并不准确, 因此采用 ReleaseDate中的年份
@@ -209,6 +371,7 @@ func ConvertSearchInfo(detail MovieDetail) SearchInfo {
 	}
 	return SearchInfo{
 		Mid:         detail.Id,
+		DbId:        detail.DbId,
 		Cid:         detail.Cid,
 		Pid:         detail.Pid,
 		Name:        detail.Name,
@@ -218,7 +381,7 @@ func ConvertSearchInfo(detail MovieDetail) SearchInfo {
 		Area:        detail.Area,
 		Language:    detail.Language,
 		Year:        year,
-		Initial:     detail.Initial,
+		Initial:     initial,
 		Score:       score,
 		Hits:        detail.Hits,
 		UpdateStamp: stamp.Unix(),
@@ -226,6 +389,14 @@ func ConvertSearchInfo(detail MovieDetail) SearchInfo {
 		Remarks:     detail.Remarks,
 		// ReleaseDate 部分影片缺失该参数, 所以使用添加时间作为上映时间排序
 		ReleaseStamp: detail.AddTime,
+		Total:        detail.Total,
+		Serial:       detail.Serial,
+		IsEnd:        detail.IsEnd,
+		Weekdays:     ParseWeekdays(detail.Weekday),
+		Tv:           detail.Tv,
+		Quality:      detail.Quality,
+		Version:      detail.Version,
+		Subtitle:     detail.Subtitle,
 	}
 }
 
@@ -237,6 +408,8 @@ func GetBasicInfoByKey(key string) MovieBasicInfo {
 	_ = json.Unmarshal(data, &basic)
 	// 执行本地图片匹配
 	ReplaceBasicDetailPic(&basic)
+	// 应用管理员的编辑信息
+	ApplyFilmOverlay(&basic, GetFilmOverlay(basic.Id))
 	return basic
 }
 
@@ -247,14 +420,31 @@ func GetDetailByKey(key string) MovieDetail {
 	detail := MovieDetail{}
 	_ = json.Unmarshal(data, &detail)
 
+	// 追加已合并的重复影片的播放源
+	FoldMergedFilms(&detail)
 	// 执行本地图片匹配
 	ReplaceDetailPic(&detail)
+	// 应用管理员的编辑信息
+	ApplyFilmOverlay(&detail, GetFilmOverlay(detail.Id))
+	return detail
+}
+
+// GetOriginDetailByKey 获取未应用编辑信息的原始影片详情
+func GetOriginDetailByKey(key string) MovieDetail {
+	data := []byte(db.Rdb.Get(db.Cxt, key).Val())
+	detail := MovieDetail{}
+	_ = json.Unmarshal(data, &detail)
 	return detail
 }
 
 // GetBasicInfoBySearchInfos 通过searchInfo 获取影片的基本信息
 func GetBasicInfoBySearchInfos(infos ...SearchInfo) []MovieBasicInfo {
 	var list []MovieBasicInfo
+	var mids []int64
+	for _, s := range infos {
+		mids = append(mids, s.Mid)
+	}
+	overlays := GetFilmOverlays(mids...)
 	for _, s := range infos {
 		data := []byte(db.Rdb.Get(db.Cxt, fmt.Sprintf(config.MovieBasicInfoKey, s.Cid, s.Mid)).Val())
 		basic := MovieBasicInfo{}
@@ -262,6 +452,7 @@ func GetBasicInfoBySearchInfos(infos ...SearchInfo) []MovieBasicInfo {
 
 		// 执行本地图片匹配
 		ReplaceBasicDetailPic(&basic)
+		ApplyFilmOverlay(&basic, overlays[s.Mid])
 		list = append(list, basic)
 	}
 	return list
@@ -276,7 +467,8 @@ func GetBasicInfoBySearchInfos(infos ...SearchInfo) []MovieBasicInfo {
 */
 // GenerateHashKey 存储播放源信息时对影片名称进行处理, 提高各站点间同一影片的匹配度
 func GenerateHashKey[K string | ~int | int64](key K) string {
-	mName := fmt.Sprint(key)
+	// 繁体字转换为简体字, 使繁简不同的同名影片生成相同的key
+	mName := util.ToSimplified(fmt.Sprint(key))
 	//1. 去除name中的所有空格
 	mName = regexp.MustCompile(`\s`).ReplaceAllString(mName, "")
 	//2. 去除name中含有的别名～.*～
@@ -311,7 +503,11 @@ func SaveMoves(list []Movie) (err error) {
 
 // AllMovieInfoKey 获取redis中所有的影视列表信息key MovieList:Cid
 func AllMovieInfoKey() []string {
-	return db.Rdb.Keys(db.Cxt, fmt.Sprint("MovieList:Cid*")).Val()
+	keys, err := db.ScanKeys(config.KeyPattern(config.MovieListInfoKey))
+	if err != nil {
+		log.Println("Scan MovieList Keys Error: ", err)
+	}
+	return keys
 }
 
 // GetMovieListByKey 获取指定分类的影片列表数据
@@ -0,0 +1,52 @@
+package system
+
+import (
+	"fmt"
+	"hash/fnv"
+	"testing"
+
+	"server/config"
+	"server/plugin/db"
+	"server/plugin/migrate"
+)
+
+func TestRekeyMultipleSource(t *testing.T) {
+	// 版本 19 前繁体片名直接计算 hash 值
+	legacyKey := func(name string) string {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(name))
+		return fmt.Sprint(h.Sum32())
+	}
+	tests := []struct {
+		name string
+		mid  int64
+	}{
+		{"西遊記", 501},
+		{"紅樓夢", 502},
+		{"三国演义", 503},
+	}
+	site := fmt.Sprintf(config.MultipleSiteDetail, "rekey")
+	if err := migrate.Rollback(0, 18); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	for _, tt := range tests {
+		if err := db.Mdb.Create(&SearchInfo{Mid: tt.mid, Cid: 6, Pid: 1, Name: tt.name}).Error; err != nil {
+			t.Fatalf("create SearchInfo %d error = %v", tt.mid, err)
+		}
+		play := fmt.Sprintf(`[{"episode":"正片","link":"http://rekey/%d.m3u8"}]`, tt.mid)
+		if err := db.Rdb.HSet(db.Cxt, site, legacyKey(tt.name), play).Err(); err != nil {
+			t.Fatalf("HSet() error = %v", err)
+		}
+	}
+	if err := migrate.Up(0); err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pl := GetMultiplePlay("rekey", GenerateHashKey(tt.name))
+			if want := fmt.Sprintf("http://rekey/%d.m3u8", tt.mid); len(pl) != 1 || pl[0].Link != want {
+				t.Errorf("GetMultiplePlay(%s) = %v, want link %s", tt.name, pl, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package system
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"server/config"
+	"server/plugin/db"
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+/*
+	影人信息
+	影片入库时将 主演/导演/编剧 字段拆分为独立的影人, 并记录影人在影片中担任的角色
+	影人以规范化后的姓名作为唯一标识, 影片每次入库或编辑后重建其关联信息
+	影人相关的查询只统计当前可展示的影片
+*/
+
+const (
+	RoleActor    = "actor"    // 主演
+	RoleDirector = "director" // 导演
+	RoleWriter   = "writer"   // 编剧
+)
+
+// Person 影人信息
+type Person struct {
+	gorm.Model
+	Name string `json:"name"` // 姓名
+}
+
+// TableName 影人表名
+func (Person) TableName() string {
+	return config.PersonTableName
+}
+
+// FilmPerson 影片与影人的关联信息
+type FilmPerson struct {
+	ID       uint   `json:"id" gorm:"primarykey"`
+	Mid      int64  `json:"mid"`      // 影片ID
+	PersonId uint   `json:"personId"` // 影人ID
+	Role     string `json:"role"`     // 角色 actor | director | writer
+	Sort     int    `json:"sort"`     // 在影片同一角色中的排列顺序
+}
+
+// TableName 影片影人关联表名
+func (FilmPerson) TableName() string {
+	return config.FilmPersonTableName
+}
+
+// FilmPersonVo 影片中的影人信息
+type FilmPersonVo struct {
+	Id   uint   `json:"id"`   // 影人ID
+	Name string `json:"name"` // 姓名
+	Role string `json:"role"` // 角色
+	Pic  string `json:"pic"`  // 演员资料中的照片
+}
+
+// PersonVo 影人信息以及参与的影片数量
+type PersonVo struct {
+	Id      uint             `json:"id"`      // 影人ID
+	Name    string           `json:"name"`    // 姓名
+	Films   int64            `json:"films"`   // 参与的影片数量
+	Roles   map[string]int64 `json:"roles"`   // 各角色参与的影片数量
+	Profile *Actor           `json:"profile"` // 采集的演员资料, 不存在时为 null
+}
+
+var (
+	// personSeparator 影人字段中的姓名分隔符
+	personSeparator = regexp.MustCompile(`[,，/／、|｜;；]+`)
+	// personSpace 姓名中连续的空白字符
+	personSpace = regexp.MustCompile(`\s+`)
+	// personPlaceholder 采集站用于占位的无效姓名
+	personPlaceholder = map[string]bool{"内详": true, "未知": true, "不详": true, "暂无": true, "佚名": true, "其他": true, "其它": true, "无": true}
+)
+
+// personRoles 影片中各角色对应的影人字段
+func personRoles(d MovieDetail) [][2]string {
+	return [][2]string{{RoleActor, d.Actor}, {RoleDirector, d.Director}, {RoleWriter, d.Writer}}
+}
+
+// ParsePersonNames 拆分影人字段中的姓名, 去除多余的空白以及无效的占位内容
+func ParsePersonNames(s string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, n := range personSeparator.Split(s, -1) {
+		n = personSpace.ReplaceAllString(strings.TrimSpace(n), " ")
+		if n == "" || personPlaceholder[n] || seen[n] || utf8.RuneCountInString(n) > config.PersonNameMaxLength {
+			continue
+		}
+		seen[n] = true
+		names = append(names, n)
+	}
+	return names
+}
+
+// visibleFilmMids 可展示影片的ID子查询
+func visibleFilmMids(t Tenant) *gorm.DB {
+	return db.Mdb.Model(&SearchInfo{}).Scopes(VisibleFilm(t)).Select("mid")
+}
+
+// ------------------------------------------------------ MySQL ------------------------------------------------------
+
+// ExistFilmPerson 是否已生成影片与影人的关联信息
+func ExistFilmPerson() bool {
+	var ids []uint
+	db.Mdb.Model(&FilmPerson{}).Limit(1).Pluck("id", &ids)
+	return len(ids) > 0
+}
+
+// SaveFilmPersons 重建影片与影人的关联信息
+func SaveFilmPersons(list ...MovieDetail) error {
+	if len(list) == 0 {
+		return nil
+	}
+	var mids []int64
+	var names []string
+	var links []FilmPerson
+	linkNames := make(map[int]string)
+	seen := make(map[string]bool)
+	for _, d := range list {
+		mids = append(mids, d.Id)
+		for _, r := range personRoles(d) {
+			for i, n := range ParsePersonNames(r[1]) {
+				linkNames[len(links)] = n
+				links = append(links, FilmPerson{Mid: d.Id, Role: r[0], Sort: i})
+				if !seen[n] {
+					seen[n] = true
+					names = append(names, n)
+				}
+			}
+		}
+	}
+	return db.Mdb.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("mid IN ?", mids).Delete(&FilmPerson{}).Error; err != nil {
+			return err
+		}
+		if len(links) == 0 {
+			return nil
+		}
+		var pl []Person
+		for _, n := range names {
+			pl = append(pl, Person{Name: n})
+		}
+		if err := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "name"}}, DoNothing: true}).
+			CreateInBatches(&pl, config.MaxScanCount).Error; err != nil {
+			return err
+		}
+		ids := make(map[string]uint)
+		for i := 0; i < len(names); i += config.MaxScanCount {
+			var found []Person
+			if err := tx.Where("name IN ?", names[i:min(i+config.MaxScanCount, len(names))]).Find(&found).Error; err != nil {
+				return err
+			}
+			for _, p := range found {
+				ids[p.Name] = p.ID
+			}
+		}
+		var fl []FilmPerson
+		for i, l := range links {
+			if id, ok := ids[linkNames[i]]; ok {
+				l.PersonId = id
+				fl = append(fl, l)
+			}
+		}
+		return tx.CreateInBatches(&fl, config.MaxScanCount).Error
+	})
+}
+
+// DelFilmPersons 删除影片的影人关联信息
+func DelFilmPersons(mid int64) error {
+	return db.Mdb.Where("mid = ?", mid).Delete(&FilmPerson{}).Error
+}
+
+// GetFilmPersons 获取影片中的影人信息, 按照 主演 导演 编剧 的顺序排列
+func GetFilmPersons(mid int64) []FilmPersonVo {
+	var list []FilmPersonVo
+	err := db.Mdb.Model(&FilmPerson{}).
+		Select(fmt.Sprintf("%s.id, %s.name, %s.role, COALESCE(%s.pic, '') AS pic", config.PersonTableName, config.PersonTableName, config.FilmPersonTableName, config.ActorTableName)).
+		Joins(fmt.Sprintf("JOIN %s ON %s.id = %s.person_id", config.PersonTableName, config.PersonTableName, config.FilmPersonTableName)).
+		Joins(fmt.Sprintf("LEFT JOIN %s ON %s.name = %s.name", config.ActorTableName, config.ActorTableName, config.PersonTableName)).
+		Where(fmt.Sprintf("%s.mid = ?", config.FilmPersonTableName), mid).
+		Order(fmt.Sprintf("%s.role, %s.sort", config.FilmPersonTableName, config.FilmPersonTableName)).
+		Scan(&list).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return list
+}
+
+// GetPersonById 获取影人信息, 各角色参与的可展示影片数量以及采集的演员资料
+func GetPersonById(t Tenant, id uint) *PersonVo {
+	var p Person
+	if err := db.Mdb.First(&p, id).Error; err != nil {
+		return nil
+	}
+	res := personVos(t, []Person{p})
+	res[0].Profile = GetActorByName(p.Name)
+	return &res[0]
+}
+
+// SearchPersons 通过姓名检索参与过可展示影片的影人, 姓名完全匹配的影人优先
+func SearchPersons(t Tenant, keyword string, page *Page) []PersonVo {
+	qw := db.Mdb.Model(&Person{}).Where(db.Like("name"), fmt.Sprint(`%`, keyword, `%`)).
+		Where("id IN (?)", db.Mdb.Model(&FilmPerson{}).Select("person_id").Where("mid IN (?)", visibleFilmMids(t)))
+	GetPage(qw, page)
+	var pl []Person
+	err := qw.Limit(page.PageSize).Offset((page.Current - 1) * page.PageSize).
+		Clauses(clause.OrderBy{Expression: clause.Expr{SQL: "CASE WHEN name = ? THEN 0 ELSE 1 END, id", Vars: []any{keyword}}}).
+		Find(&pl).Error
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	return personVos(t, pl)
+}
+
+// personVos 统计影人在各角色中参与的可展示影片数量
+func personVos(t Tenant, pl []Person) []PersonVo {
+	var ids []uint
+	for _, p := range pl {
+		ids = append(ids, p.ID)
+	}
+	var counts []struct {
+		PersonId uint
+		Role     string
+		Count    int64
+	}
+	db.Mdb.Model(&FilmPerson{}).Select("person_id, role, COUNT(*) AS count").
+		Where("person_id IN ? AND mid IN (?)", ids, visibleFilmMids(t)).Group("person_id, role").Scan(&counts)
+	// 影人参与的影片总数需按影片去重, 同一影片中担任多个角色只计一次
+	var totals []struct {
+		PersonId uint
+		Count    int64
+	}
+	db.Mdb.Model(&FilmPerson{}).Select("person_id, COUNT(DISTINCT mid) AS count").
+		Where("person_id IN ? AND mid IN (?)", ids, visibleFilmMids(t)).Group("person_id").Scan(&totals)
+	films := make(map[uint]int64, len(totals))
+	for _, c := range totals {
+		films[c.PersonId] = c.Count
+	}
+	var res []PersonVo
+	for _, p := range pl {
+		vo := PersonVo{Id: p.ID, Name: p.Name, Films: films[p.ID], Roles: make(map[string]int64)}
+		for _, c := range counts {
+			if c.PersonId == p.ID {
+				vo.Roles[c.Role] = c.Count
+			}
+		}
+		res = append(res, vo)
+	}
+	return res
+}
+
+// GetPersonFilms 获取影人参与的可展示影片, role 为空时包含所有角色, 按年份与更新时间倒序排列
+func GetPersonFilms(t Tenant, id uint, role string, page *Page) []SearchInfo {
+	sub := db.Mdb.Model(&FilmPerson{}).Select("mid").Where("person_id = ?", id)
+	if role != "" {
+		sub = sub.Where("role = ?", role)
+	}
+	qw := db.Mdb.Model(&SearchInfo{}).Scopes(VisibleFilm(t)).Where("mid IN (?)", sub)
+	GetPage(qw, page)
+	var sl []SearchInfo
+	if err := qw.Limit(page.PageSize).Offset((page.Current - 1) * page.PageSize).Order("year DESC, update_stamp DESC").Find(&sl).Error; err != nil {
+		log.Println(err)
+		return nil
+	}
+	return sl
+}
+
+// personFilmMids 指定姓名的影人以指定角色参与的影片ID子查询
+func personFilmMids(name, role string) *gorm.DB {
+	return db.Mdb.Model(&FilmPerson{}).Select(fmt.Sprintf("%s.mid", config.FilmPersonTableName)).
+		Joins(fmt.Sprintf("JOIN %s ON %s.id = %s.person_id", config.PersonTableName, config.PersonTableName, config.FilmPersonTableName)).
+		Where(fmt.Sprintf("%s.name = ? AND %s.role = ?", config.PersonTableName, config.FilmPersonTableName), name, role)
+}
+
+// PrunePersons 删除未关联任何影片的影人
+func PrunePersons() error {
+	return db.Mdb.Unscoped().Where("id NOT IN (?)", db.Mdb.Model(&FilmPerson{}).Select("person_id")).Delete(&Person{}).Error
+}
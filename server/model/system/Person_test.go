@@ -0,0 +1,48 @@
+package system
+
+import (
+	"testing"
+
+	"server/plugin/db"
+)
+
+func TestSearchPersons(t *testing.T) {
+	films := []MovieDetail{
+		{Id: 201, Cid: 6, Pid: 1, Name: "影人测试一", MovieDescriptor: MovieDescriptor{Actor: "张三丰, 张三", Director: "张三"}},
+		{Id: 202, Cid: 6, Pid: 1, Name: "影人测试二", MovieDescriptor: MovieDescriptor{Actor: "张三丰"}},
+	}
+	for _, d := range films {
+		if err := db.Mdb.Create(&SearchInfo{Mid: d.Id, Cid: d.Cid, Pid: d.Pid, Name: d.Name}).Error; err != nil {
+			t.Fatalf("create SearchInfo %d error = %v", d.Id, err)
+		}
+	}
+	if err := SaveFilmPersons(films...); err != nil {
+		t.Fatalf("SaveFilmPersons() error = %v", err)
+	}
+
+	page := &Page{PageSize: 10, Current: 1}
+	res := SearchPersons(DefaultTenant(), "张三", page)
+	want := []struct {
+		name  string
+		films int64
+		roles map[string]int64
+	}{
+		// 姓名完全匹配的影人优先, 同一影片中担任多个角色只计一次
+		{"张三", 1, map[string]int64{"actor": 1, "director": 1}},
+		{"张三丰", 2, map[string]int64{"actor": 2}},
+	}
+	if len(res) != len(want) {
+		t.Fatalf("SearchPersons() returned %d persons, want %d", len(res), len(want))
+	}
+	for i, w := range want {
+		got := res[i]
+		if got.Name != w.name || got.Films != w.films {
+			t.Errorf("SearchPersons()[%d] = {%s, %d}, want {%s, %d}", i, got.Name, got.Films, w.name, w.films)
+		}
+		for role, n := range w.roles {
+			if got.Roles[role] != n {
+				t.Errorf("SearchPersons()[%d].Roles[%s] = %d, want %d", i, role, got.Roles[role], n)
+			}
+		}
+	}
+}
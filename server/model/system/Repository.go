@@ -0,0 +1,533 @@
+package system
+
+/*
+	数据存储仓库
+	按业务领域划分存储接口, 上层 (logic | spider | SystemInit) 统一通过 Repo 访问数据
+	默认实现基于 db.Rdb (Redis | 进程内 KV) 与 db.Mdb (MySQL | SQLite), 存储后端由 config.StorageMode 决定
+*/
+
+// FilmRepository 影片详情 & 分类树存储
+type FilmRepository interface {
+	// SaveDetails 批量保存主站点影片详情
+	SaveDetails(list []MovieDetail) error
+	// SaveDetail 保存单部影片详情并同步检索信息
+	SaveDetail(detail MovieDetail) error
+	// SaveSitePlayList 保存附属站点的播放列表
+	SaveSitePlayList(id string, list []MovieDetail) error
+	// GetDetailByKey 获取影片详情(已应用编辑信息)
+	GetDetailByKey(key string) MovieDetail
+	// GetOriginDetailByKey 获取采集的原始影片详情
+	GetOriginDetailByKey(key string) MovieDetail
+	// GetBasicInfoByKey 获取影片基本信息
+	GetBasicInfoByKey(key string) MovieBasicInfo
+	// GetBasicInfoBySearchInfos 批量获取检索信息对应的影片基本信息
+	GetBasicInfoBySearchInfos(infos ...SearchInfo) []MovieBasicInfo
+	// GetMultiplePlay 获取附属站点的播放列表
+	GetMultiplePlay(siteId, key string) []MovieUrlInfo
+	// DelMultiplePlay 删除影片在附属站点中的播放列表, siteIds 为空时删除所有站点中的数据
+	DelMultiplePlay(d MovieDetail, siteIds ...string) error
+	// GetOverlay 获取影片编辑信息
+	GetOverlay(mid int64) *FilmOverlay
+	// SaveOverlay 保存影片编辑信息
+	SaveOverlay(o FilmOverlay) error
+	// DelOverlay 删除影片编辑信息
+	DelOverlay(mid int64)
+	// Zero 删除所有已采集的影片数据
+	Zero()
+	// SaveCategoryTree 保存分类树
+	SaveCategoryTree(tree *CategoryTree) error
+	// GetCategoryTree 获取分类树
+	GetCategoryTree() CategoryTree
+	// ExistsCategoryTree 分类树是否存在
+	ExistsCategoryTree() bool
+	// GetCategoryMapping 获取分类合并关系
+	GetCategoryMapping() map[int64]int64
+	// SaveCategoryMapping 记录分类合并关系
+	SaveCategoryMapping(from, to int64) error
+	// ReassignCategory 将分类下的影片转移到目标分类
+	ReassignCategory(from, to, pid int64, cName string) (int, error)
+	// MoveCategory 将单部影片转移到目标分类
+	MoveCategory(mid, from, to, pid int64, cName string) error
+	// SaveFilmCategory 记录单部影片调整后的分类
+	SaveFilmCategory(mid, cid int64) error
+	// Hide 隐藏影片
+	Hide(mid int64, operator string) error
+	// Show 取消影片的隐藏状态
+	Show(mid int64) error
+	// GetRedirect 获取重复影片的合并信息
+	GetRedirect(mid int64) *FilmRedirect
+	// Merge 将重复影片合并到目标影片
+	Merge(r FilmRedirect) error
+	// NextLocalId 生成本地影片ID
+	NextLocalId() (int64, error)
+}
+
+// SearchRepository 影片检索信息存储
+type SearchRepository interface {
+	// Sync 同步暂存的检索信息 model 0-清空并保存 | 1-更新
+	Sync(model int)
+	FindById(id int64) *SearchInfo
+	FindByMid(mid int64) *SearchInfo
+	Update(s SearchInfo) error
+	// Scan 分批遍历所有检索信息
+	Scan(fn func(list []SearchInfo)) error
+	// CountByCid 统计分类下的影片数量
+	CountByCid(cid int64) int64
+	// Exist 是否已存在影片检索信息
+	Exist() bool
+	// FindMids 获取满足检索条件的全部影片ID
+	FindMids(s SearchVo, deleted bool) ([]int64, error)
+	Page(s SearchVo) []SearchInfo
+	// Keyword 全文检索影片, highlight 为 true 时返回命中字段的高亮片段
+	Keyword(t Tenant, keyword string, highlight bool, page *Page) []FilmTextHit
+	// Index 重建影片的全文检索信息与搜索建议
+	Index(list ...MovieDetail) error
+	// Indexed 是否已生成影片的全文检索信息
+	Indexed() bool
+	// Suggest 获取前缀匹配关键字的影片
+	Suggest(t Tenant, keyword string, n int) []SearchInfo
+	// Record 记录搜索关键字以及搜索结果数量
+	Record(keyword string, results int)
+	// HotKeywords 最近 days 天的热门搜索关键字
+	HotKeywords(days, n int) []KeywordStat
+	// ZeroKeywords 最近 days 天无搜索结果的关键字
+	ZeroKeywords(days, n int) []KeywordStat
+	// StartLookup 创建关键字的采集站搜索任务, 已存在时返回当前任务状态
+	StartLookup(keyword string) (*SearchLookup, bool)
+	// SaveLookup 更新采集站搜索任务状态
+	SaveLookup(l SearchLookup)
+	// Lookup 获取关键字的采集站搜索任务状态
+	Lookup(keyword string) *SearchLookup
+	ByTags(t Tenant, st SearchTagsVO, page *Page) []SearchInfo
+	// Facets 统计当前筛选条件下各维度选项的影片数量
+	Facets(t Tenant, st SearchTagsVO) map[string]map[string]int64
+	MovieListByPid(t Tenant, pid int64, page *Page) []MovieBasicInfo
+	MovieListByCid(t Tenant, cid int64, page *Page) []MovieBasicInfo
+	MovieListBySort(t Tenant, sortType int, pid int64, page *Page) []MovieBasicInfo
+	HotMovieByPid(t Tenant, pid int64, page *Page) []SearchInfo
+	HotMovieByCid(t Tenant, cid int64, page *Page) []SearchInfo
+	RelateMovie(t Tenant, search SearchInfo, page *Page) []MovieBasicInfo
+	// Today 今日更新的影片
+	Today(t Tenant, pid int64, page *Page) []SearchInfo
+	// Weekday 在指定星期播出的连载中的影片
+	Weekday(t Tenant, weekday int, pid int64) []SearchInfo
+	Tags(pid int64) map[string]interface{}
+	Options(pid int64) map[string]interface{}
+	Delete(id int64) error
+}
+
+// SourceRepository 采集站点信息存储
+type SourceRepository interface {
+	SaveList(list []FilmSource) error
+	List() []FilmSource
+	ListByGrade(grade SourceGrade) []FilmSource
+	FindById(id string) *FilmSource
+	Add(s FilmSource) error
+	Update(s FilmSource) error
+	Delete(id string)
+	Exist() bool
+}
+
+// CronTaskRepository 定时任务存储
+type CronTaskRepository interface {
+	Save(t FilmCollectTask)
+	All() []FilmCollectTask
+	FindById(id string) (FilmCollectTask, error)
+	Update(t FilmCollectTask)
+	Delete(id string)
+	Exist() bool
+}
+
+// RecordRepository 采集失败记录存储
+type RecordRepository interface {
+	Save(fr FailureRecord)
+	List(vo RecordRequestVo) []FailureRecord
+	FindById(id uint) *FailureRecord
+	Pending() []FailureRecord
+	Change(fr *FailureRecord, status int)
+	DelDone()
+	Truncate()
+}
+
+// FileRepository 图片文件信息存储
+type FileRepository interface {
+	Save(f FileInfo)
+	FindById(id uint) FileInfo
+	Page(tl []string, page *Page) []FileInfo
+	Delete(id uint)
+	// SaveVirtualPic 保存待同步的图片信息
+	SaveVirtualPic(pl []VirtualPicture) error
+	// SyncPicture 同步待同步的图片到本地
+	SyncPicture()
+}
+
+// UserRepository 用户信息 & 登录凭证存储
+type UserRepository interface {
+	InitAdmin()
+	FindByNameOrEmail(userName string) *User
+	FindById(id uint) User
+	Update(u User)
+	SaveToken(token string, userId uint) error
+	GetToken(userId uint) string
+	ClearToken(userId uint) error
+}
+
+// RecycleRepository 影片回收站存储
+type RecycleRepository interface {
+	Save(fr FilmRecycle) error
+	List(name string, page *Page) []FilmRecycle
+	FindById(id uint) *FilmRecycle
+	FindByMid(mid int64) *FilmRecycle
+	Delete(id uint) error
+	// Restore 恢复影片检索信息
+	Restore(mid int64) error
+	// Purge 彻底删除影片数据
+	Purge(mid, cid int64) error
+}
+
+// BlockRepository 影片屏蔽规则存储
+type BlockRepository interface {
+	Add(b *FilmBlock) error
+	Delete(id uint, operator string) error
+	List(t string, page *Page) []FilmBlock
+	All() []FilmBlock
+	Audits(page *Page) []FilmBlockAudit
+	// Filter 过滤掉匹配屏蔽规则的影片
+	Filter(sourceId string, list []MovieDetail) []MovieDetail
+}
+
+// BatchRepository 影片批量操作任务存储
+type BatchRepository interface {
+	Save(j FilmBatchJob) error
+	FindById(id string) *FilmBatchJob
+	List() []FilmBatchJob
+	// Prune 清理超出保留数量的任务记录
+	Prune()
+}
+
+// DuplicateRepository 疑似重复影片分组存储
+type DuplicateRepository interface {
+	// SaveGroups 覆盖保存检测结果, 返回保存的分组数量
+	SaveGroups(groups []FilmDuplicateGroup) (int, error)
+	List() []FilmDuplicateGroup
+	FindById(id string) *FilmDuplicateGroup
+	Delete(id string)
+	// Ignore 将分组标记为非重复
+	Ignore(id string) error
+	SaveScan(s FilmDuplicateScan) error
+	GetScan() *FilmDuplicateScan
+}
+
+// PersonRepository 影人信息存储
+type PersonRepository interface {
+	// Link 重建影片与影人的关联信息
+	Link(list ...MovieDetail) error
+	// Prune 删除未关联任何影片的影人
+	Prune() error
+	// Linked 是否已生成影片与影人的关联信息
+	Linked() bool
+	FindById(t Tenant, id uint) *PersonVo
+	Search(t Tenant, keyword string, page *Page) []PersonVo
+	// Films 影人参与的影片, role 为空时包含所有角色
+	Films(t Tenant, id uint, role string, page *Page) []SearchInfo
+	// ByFilm 影片中的影人信息
+	ByFilm(mid int64) []FilmPersonVo
+	// SaveActors 保存采集的演员资料
+	SaveActors(list []Actor) error
+	// SaveRoles 保存采集的影片角色
+	SaveRoles(list []FilmRole) error
+	// Roles 影片中的角色信息
+	Roles(mid int64) []FilmRoleVo
+}
+
+// ArticleRepository 文章资讯存储
+type ArticleRepository interface {
+	Save(list []Article) error
+	List(keyword, typeName string, page *Page) []Article
+	Types() []string
+	FindById(id uint) *Article
+	// Films 文章关联的可展示影片
+	Films(t Tenant, a Article) []SearchInfo
+}
+
+// CacheRepository API 数据缓存
+type CacheRepository interface {
+	Set(key string, data map[string]interface{})
+	Get(key string) map[string]interface{}
+	Remove(key string)
+}
+
+// Repositories 各领域存储仓库集合
+type Repositories struct {
+	Film      FilmRepository
+	Search    SearchRepository
+	Source    SourceRepository
+	CronTask  CronTaskRepository
+	Record    RecordRepository
+	File      FileRepository
+	User      UserRepository
+	Recycle   RecycleRepository
+	Block     BlockRepository
+	Batch     BatchRepository
+	Duplicate DuplicateRepository
+	Person    PersonRepository
+	Article   ArticleRepository
+	Cache     CacheRepository
+}
+
+// Repo 当前使用的存储仓库
+var Repo = Repositories{
+	Film:      filmStore{},
+	Search:    searchStore{},
+	Source:    sourceStore{},
+	CronTask:  cronTaskStore{},
+	Record:    recordStore{},
+	File:      fileStore{},
+	User:      userStore{},
+	Recycle:   recycleStore{},
+	Block:     blockStore{},
+	Batch:     batchStore{},
+	Duplicate: duplicateStore{},
+	Person:    personStore{},
+	Article:   articleStore{},
+	Cache:     cacheStore{},
+}
+
+// ------------------------------------------------ 默认实现 (Redis & GORM) ------------------------------------------------
+
+type filmStore struct{}
+
+func (filmStore) SaveDetails(list []MovieDetail) error { return SaveDetails(list) }
+func (filmStore) SaveDetail(detail MovieDetail) error  { return SaveDetail(detail) }
+func (filmStore) SaveSitePlayList(id string, list []MovieDetail) error {
+	return SaveSitePlayList(id, list)
+}
+func (filmStore) GetDetailByKey(key string) MovieDetail       { return GetDetailByKey(key) }
+func (filmStore) GetOriginDetailByKey(key string) MovieDetail { return GetOriginDetailByKey(key) }
+func (filmStore) GetBasicInfoByKey(key string) MovieBasicInfo { return GetBasicInfoByKey(key) }
+func (filmStore) GetBasicInfoBySearchInfos(infos ...SearchInfo) []MovieBasicInfo {
+	return GetBasicInfoBySearchInfos(infos...)
+}
+func (filmStore) GetMultiplePlay(siteId, key string) []MovieUrlInfo {
+	return GetMultiplePlay(siteId, key)
+}
+func (filmStore) DelMultiplePlay(d MovieDetail, siteIds ...string) error {
+	return DelMultiplePlay(d, siteIds...)
+}
+func (filmStore) GetOverlay(mid int64) *FilmOverlay         { return GetFilmOverlay(mid) }
+func (filmStore) SaveOverlay(o FilmOverlay) error           { return SaveFilmOverlay(o) }
+func (filmStore) DelOverlay(mid int64)                      { DelFilmOverlay(mid) }
+func (filmStore) Zero()                                     { FilmZero() }
+func (filmStore) SaveCategoryTree(tree *CategoryTree) error { return SaveCategoryTree(tree) }
+func (filmStore) GetCategoryTree() CategoryTree             { return GetCategoryTree() }
+func (filmStore) GetCategoryMapping() map[int64]int64       { return GetCategoryMapping() }
+func (filmStore) SaveCategoryMapping(from, to int64) error  { return SaveCategoryMapping(from, to) }
+func (filmStore) ReassignCategory(from, to, pid int64, cName string) (int, error) {
+	return ReassignFilmCategory(from, to, pid, cName)
+}
+func (filmStore) MoveCategory(mid, from, to, pid int64, cName string) error {
+	return MoveFilmCategory(mid, from, to, pid, cName)
+}
+func (filmStore) SaveFilmCategory(mid, cid int64) error { return SaveFilmCategory(mid, cid) }
+func (filmStore) Hide(mid int64, operator string) error { return HideFilm(mid, operator) }
+func (filmStore) Show(mid int64) error                  { return ShowFilm(mid) }
+func (filmStore) ExistsCategoryTree() bool              { return ExistsCategoryTree() }
+func (filmStore) GetRedirect(mid int64) *FilmRedirect   { return GetFilmRedirect(mid) }
+func (filmStore) NextLocalId() (int64, error)           { return NextLocalFilmId() }
+func (filmStore) Merge(r FilmRedirect) error            { return MergeFilm(r) }
+
+type searchStore struct{}
+
+func (searchStore) Sync(model int)                  { SyncSearchInfo(model) }
+func (searchStore) FindById(id int64) *SearchInfo   { return GetSearchInfoById(id) }
+func (searchStore) FindByMid(mid int64) *SearchInfo { return GetSearchInfoByMid(mid) }
+func (searchStore) Update(s SearchInfo) error       { return UpdateSearchInfo(s) }
+func (searchStore) Scan(fn func(list []SearchInfo)) error {
+	return ScanSearchInfo(fn)
+}
+func (searchStore) CountByCid(cid int64) int64 { return CountSearchInfoByCid(cid) }
+func (searchStore) Exist() bool                { return ExistAnySearchInfo() }
+func (searchStore) Indexed() bool              { return ExistFilmText() }
+func (searchStore) FindMids(s SearchVo, deleted bool) ([]int64, error) {
+	return FindSearchMids(s, deleted)
+}
+func (searchStore) Page(s SearchVo) []SearchInfo { return GetSearchPage(s) }
+func (searchStore) Keyword(t Tenant, k string, highlight bool, p *Page) []FilmTextHit {
+	return SearchFilmText(t, k, highlight, p)
+}
+func (searchStore) Index(list ...MovieDetail) error {
+	if err := SaveFilmTexts(list...); err != nil {
+		return err
+	}
+	return SaveFilmSuggests(list...)
+}
+func (searchStore) Suggest(t Tenant, k string, n int) []SearchInfo { return GetFilmSuggests(t, k, n) }
+func (searchStore) Record(k string, results int)                   { RecordSearchKeyword(k, results) }
+func (searchStore) HotKeywords(days, n int) []KeywordStat          { return GetHotKeywords(days, n) }
+func (searchStore) ZeroKeywords(days, n int) []KeywordStat         { return GetZeroKeywords(days, n) }
+func (searchStore) StartLookup(k string) (*SearchLookup, bool)     { return StartSearchLookup(k) }
+func (searchStore) SaveLookup(l SearchLookup)                      { SaveSearchLookup(l) }
+func (searchStore) Lookup(k string) *SearchLookup                  { return GetSearchLookup(k) }
+func (searchStore) ByTags(t Tenant, st SearchTagsVO, p *Page) []SearchInfo {
+	return GetSearchInfosByTags(t, st, p)
+}
+func (searchStore) Facets(t Tenant, st SearchTagsVO) map[string]map[string]int64 {
+	return GetSearchFacets(t, st)
+}
+func (searchStore) MovieListByPid(t Tenant, pid int64, p *Page) []MovieBasicInfo {
+	return GetMovieListByPid(t, pid, p)
+}
+func (searchStore) MovieListByCid(t Tenant, cid int64, p *Page) []MovieBasicInfo {
+	return GetMovieListByCid(t, cid, p)
+}
+func (searchStore) MovieListBySort(t Tenant, sortType int, pid int64, p *Page) []MovieBasicInfo {
+	return GetMovieListBySort(t, sortType, pid, p)
+}
+func (searchStore) HotMovieByPid(t Tenant, pid int64, p *Page) []SearchInfo {
+	return GetHotMovieByPid(t, pid, p)
+}
+func (searchStore) HotMovieByCid(t Tenant, cid int64, p *Page) []SearchInfo {
+	return GetHotMovieByCid(t, cid, p)
+}
+func (searchStore) RelateMovie(t Tenant, s SearchInfo, p *Page) []MovieBasicInfo {
+	return GetRelateMovieBasicInfo(t, s, p)
+}
+func (searchStore) Today(t Tenant, pid int64, p *Page) []SearchInfo {
+	return GetTodayFilms(t, pid, p)
+}
+func (searchStore) Weekday(t Tenant, weekday int, pid int64) []SearchInfo {
+	return GetWeekdayFilms(t, weekday, pid)
+}
+func (searchStore) Tags(pid int64) map[string]interface{}    { return GetSearchTag(pid) }
+func (searchStore) Options(pid int64) map[string]interface{} { return GetSearchOptions(pid) }
+func (searchStore) Delete(id int64) error                    { return DelFilmSearch(id) }
+
+type sourceStore struct{}
+
+func (sourceStore) SaveList(list []FilmSource) error { return SaveCollectSourceList(list) }
+func (sourceStore) List() []FilmSource               { return GetCollectSourceList() }
+func (sourceStore) ListByGrade(grade SourceGrade) []FilmSource {
+	return GetCollectSourceListByGrade(grade)
+}
+func (sourceStore) FindById(id string) *FilmSource { return FindCollectSourceById(id) }
+func (sourceStore) Add(s FilmSource) error         { return AddCollectSource(s) }
+func (sourceStore) Update(s FilmSource) error      { return UpdateCollectSource(s) }
+func (sourceStore) Delete(id string)               { DelCollectResource(id) }
+func (sourceStore) Exist() bool                    { return ExistCollectSourceList() }
+
+type cronTaskStore struct{}
+
+func (cronTaskStore) Save(t FilmCollectTask)   { SaveFilmTask(t) }
+func (cronTaskStore) All() []FilmCollectTask   { return GetAllFilmTask() }
+func (cronTaskStore) Update(t FilmCollectTask) { UpdateFilmTask(t) }
+func (cronTaskStore) Delete(id string)         { DelFilmTask(id) }
+func (cronTaskStore) Exist() bool              { return ExistTask() }
+func (cronTaskStore) FindById(id string) (FilmCollectTask, error) {
+	return GetFilmTaskById(id)
+}
+
+type recordStore struct{}
+
+func (recordStore) Save(fr FailureRecord)                   { SaveFailureRecord(fr) }
+func (recordStore) List(vo RecordRequestVo) []FailureRecord { return FailureRecordList(vo) }
+func (recordStore) FindById(id uint) *FailureRecord         { return FindRecordById(id) }
+func (recordStore) Pending() []FailureRecord                { return PendingRecord() }
+func (recordStore) Change(fr *FailureRecord, status int)    { ChangeRecord(fr, status) }
+func (recordStore) DelDone()                                { DelDoneRecord() }
+func (recordStore) Truncate()                               { TruncateRecordTable() }
+
+type fileStore struct{}
+
+func (fileStore) Save(f FileInfo)                          { SaveGallery(f) }
+func (fileStore) FindById(id uint) FileInfo                { return GetFileInfoById(id) }
+func (fileStore) Page(tl []string, page *Page) []FileInfo  { return GetFileInfoPage(tl, page) }
+func (fileStore) Delete(id uint)                           { DelFileInfo(id) }
+func (fileStore) SaveVirtualPic(pl []VirtualPicture) error { return SaveVirtualPic(pl) }
+func (fileStore) SyncPicture()                             { SyncFilmPicture() }
+
+type userStore struct{}
+
+func (userStore) InitAdmin()                                { InitAdminAccount() }
+func (userStore) FindByNameOrEmail(userName string) *User   { return GetUserByNameOrEmail(userName) }
+func (userStore) FindById(id uint) User                     { return GetUserById(id) }
+func (userStore) Update(u User)                             { UpdateUserInfo(u) }
+func (userStore) SaveToken(token string, userId uint) error { return SaveUserToken(token, userId) }
+func (userStore) GetToken(userId uint) string               { return GetUserTokenById(userId) }
+func (userStore) ClearToken(userId uint) error              { return ClearUserToken(userId) }
+
+type recycleStore struct{}
+
+func (recycleStore) Save(fr FilmRecycle) error               { return SaveFilmRecycle(fr) }
+func (recycleStore) List(name string, p *Page) []FilmRecycle { return FilmRecycleList(name, p) }
+func (recycleStore) FindById(id uint) *FilmRecycle           { return FindFilmRecycle(id) }
+func (recycleStore) FindByMid(mid int64) *FilmRecycle        { return FindFilmRecycleByMid(mid) }
+func (recycleStore) Delete(id uint) error                    { return DelFilmRecycle(id) }
+func (recycleStore) Restore(mid int64) error                 { return RestoreFilmSearch(mid) }
+func (recycleStore) Purge(mid, cid int64) error              { return PurgeFilm(mid, cid) }
+
+type blockStore struct{}
+
+func (blockStore) Add(b *FilmBlock) error                { return AddFilmBlock(b) }
+func (blockStore) Delete(id uint, operator string) error { return DelFilmBlock(id, operator) }
+func (blockStore) List(t string, p *Page) []FilmBlock    { return FilmBlockList(t, p) }
+func (blockStore) All() []FilmBlock                      { return AllFilmBlocks() }
+func (blockStore) Audits(p *Page) []FilmBlockAudit       { return FilmBlockAuditList(p) }
+func (blockStore) Filter(id string, list []MovieDetail) []MovieDetail {
+	return FilterBlockedFilms(id, list)
+}
+
+type batchStore struct{}
+
+func (batchStore) Save(j FilmBatchJob) error        { return SaveFilmBatchJob(j) }
+func (batchStore) FindById(id string) *FilmBatchJob { return GetFilmBatchJob(id) }
+func (batchStore) List() []FilmBatchJob             { return GetFilmBatchJobs() }
+func (batchStore) Prune()                           { PruneFilmBatchJobs() }
+
+type duplicateStore struct{}
+
+func (duplicateStore) SaveGroups(groups []FilmDuplicateGroup) (int, error) {
+	return SaveDuplicateGroups(groups)
+}
+func (duplicateStore) List() []FilmDuplicateGroup             { return GetDuplicateGroups() }
+func (duplicateStore) FindById(id string) *FilmDuplicateGroup { return GetDuplicateGroup(id) }
+func (duplicateStore) Delete(id string)                       { DelDuplicateGroup(id) }
+func (duplicateStore) Ignore(id string) error                 { return IgnoreDuplicateGroup(id) }
+func (duplicateStore) SaveScan(s FilmDuplicateScan) error     { return SaveFilmDuplicateScan(s) }
+func (duplicateStore) GetScan() *FilmDuplicateScan            { return GetFilmDuplicateScan() }
+
+type personStore struct{}
+
+func (personStore) Link(list ...MovieDetail) error { return SaveFilmPersons(list...) }
+func (personStore) Prune() error                   { return PrunePersons() }
+func (personStore) Linked() bool                   { return ExistFilmPerson() }
+func (personStore) FindById(t Tenant, id uint) *PersonVo {
+	return GetPersonById(t, id)
+}
+func (personStore) Search(t Tenant, keyword string, page *Page) []PersonVo {
+	return SearchPersons(t, keyword, page)
+}
+func (personStore) Films(t Tenant, id uint, role string, page *Page) []SearchInfo {
+	return GetPersonFilms(t, id, role, page)
+}
+func (personStore) ByFilm(mid int64) []FilmPersonVo { return GetFilmPersons(mid) }
+func (personStore) SaveActors(list []Actor) error   { return SaveActors(list) }
+func (personStore) SaveRoles(list []FilmRole) error { return SaveFilmRoles(list) }
+func (personStore) Roles(mid int64) []FilmRoleVo    { return GetFilmRoles(mid) }
+
+type articleStore struct{}
+
+func (articleStore) Save(list []Article) error { return SaveArticles(list) }
+func (articleStore) List(keyword, typeName string, page *Page) []Article {
+	return GetArticleList(keyword, typeName, page)
+}
+func (articleStore) Types() []string           { return GetArticleTypes() }
+func (articleStore) FindById(id uint) *Article { return GetArticleById(id) }
+func (articleStore) Films(t Tenant, a Article) []SearchInfo {
+	return GetArticleFilms(t, a)
+}
+
+type cacheStore struct{}
+
+func (cacheStore) Set(key string, data map[string]interface{}) { DataCache(key, data) }
+func (cacheStore) Get(key string) map[string]interface{}       { return GetCacheData(key) }
+func (cacheStore) Remove(key string)                           { RemoveCache(key) }
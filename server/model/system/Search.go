@@ -7,13 +7,12 @@ import (
 	"gorm.io/gorm"
 	"log"
 	"math"
-	"reflect"
 	"regexp"
 	"server/config"
-	"server/plugin/common/param"
 	"server/plugin/db"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -21,6 +20,7 @@ import (
 type SearchInfo struct {
 	gorm.Model
 	Mid          int64   `json:"mid"`          //影片ID gorm:"uniqueIndex:idx_mid"
+	DbId         int64   `json:"dbId"`         //豆瓣ID
 	Cid          int64   `json:"cid"`          //分类ID
 	Pid          int64   `json:"pid"`          //上级分类ID
 	Name         string  `json:"name"`         // 片名
@@ -37,6 +37,14 @@ type SearchInfo struct {
 	State        string  `json:"state"`        //状态 正片|预告
 	Remarks      string  `json:"remarks"`      // 完结 | 更新至x集
 	ReleaseStamp int64   `json:"releaseStamp"` //上映时间 时间戳
+	Total        int64   `json:"total"`        // 总集数
+	Serial       int64   `json:"serial"`       // 已更新集数
+	IsEnd        bool    `json:"isEnd"`        // 是否完结
+	Weekdays     int     `json:"weekdays"`     // 播出周期, 周一至周日依次对应第 0 - 6 位
+	Tv           string  `json:"tv"`           // 播出电视台 | 平台
+	Quality      string  `json:"quality"`      // 画质
+	Version      string  `json:"version"`      // 版本, 多个版本使用 , 分隔
+	Subtitle     string  `json:"subtitle"`     // 字幕
 }
 
 // Tag 影片分类标签结构体
@@ -67,27 +75,60 @@ func RdbSaveSearchInfo(list []SearchInfo) {
 func FilmZero() {
 	// 删除redis中当前库存储的所有数据
 	//db.Rdb.FlushDB(db.Cxt)
-	db.Rdb.Del(db.Cxt, db.Rdb.Keys(db.Cxt, "MovieBasicInfoKey*").Val()...)
-	db.Rdb.Del(db.Cxt, db.Rdb.Keys(db.Cxt, "MovieDetail*").Val()...)
-	db.Rdb.Del(db.Cxt, db.Rdb.Keys(db.Cxt, "MultipleSource*").Val()...)
-	db.Rdb.Del(db.Cxt, db.Rdb.Keys(db.Cxt, "OriginalResource*").Val()...)
-	db.Rdb.Del(db.Cxt, db.Rdb.Keys(db.Cxt, "Search*").Val()...)
+	// 使用 SCAN + UNLINK 分批删除, 避免 KEYS 阻塞 redis, 且只删除带有当前 key 前缀的数据
+	// 本地添加与导入的影片不属于采集数据, 保留其详情信息
+	for _, p := range []string{config.KeyPattern(config.MovieBasicInfoKey), config.KeyPattern(config.MovieDetailKey)} {
+		n, err := db.UnlinkKeysExcept(p, IsLocalFilmKey, func(deleted int) {
+			log.Printf("[FilmZero] 清除 %s, 已删除: %d\n", p, deleted)
+		})
+		if err != nil {
+			log.Printf("[FilmZero] 清除 %s 失败: %v\n", p, err)
+			continue
+		}
+		log.Printf("[FilmZero] 清除 %s 完成, 共删除: %d\n", p, n)
+	}
+	for _, p := range []string{
+		config.KeyPattern(config.MultipleSiteDetail),
+		config.KeyPattern(config.OriginalFilmDetailKey),
+		config.FilmClassKey,
+		config.KeyPattern(config.SearchTitle),
+		config.SearchInfoTemp,
+		config.KeyPattern(config.SearchSuggestKey),
+		config.SearchSuggestFilmKey,
+	} {
+		n, err := db.UnlinkKeys(p, func(deleted int) {
+			log.Printf("[FilmZero] 清除 %s, 已删除: %d\n", p, deleted)
+		})
+		if err != nil {
+			log.Printf("[FilmZero] 清除 %s 失败: %v\n", p, err)
+			continue
+		}
+		log.Printf("[FilmZero] 清除 %s 完成, 共删除: %d\n", p, n)
+	}
 	// 删除mysql中留存的检索表
 	var s SearchInfo
 	//db.Mdb.Exec(fmt.Sprintf(`drop table if exists %s`, s.TableName()))
 	// 截断数据表 truncate table users
 	if ExistSearchTable() {
-		db.Mdb.Exec(fmt.Sprintf("TRUNCATE table %s", s.TableName()))
+		if err := db.TruncateTable(s.TableName()); err != nil {
+			log.Println("TRUNCATE TABLE Error: ", err)
+		}
+	}
+	if err := TruncateFilmText(); err != nil {
+		log.Println("TRUNCATE TABLE Error: ", err)
+	}
+	// 重新生成本地影片的检索信息, 回收站中的本地影片保持删除状态
+	if err := SyncLocalFilms(); err != nil {
+		log.Println("Sync Local Films Error: ", err)
+	}
+	if err := ApplyFilmRecycle(); err != nil {
+		log.Println("Apply Film Recycle Error: ", err)
 	}
 }
 
-// ResetSearchTable 重置Search表
+// ResetSearchTable 重置Search表, 表结构及索引由数据库迁移维护, 此处仅清空数据
 func ResetSearchTable() {
-	// 删除 Search 表
-	var s SearchInfo
-	db.Mdb.Exec(fmt.Sprintf("drop table if exists %s", s.TableName()))
-	// 重新创建 Search 表
-	CreateSearchTable()
+	TunCateSearchTable()
 }
 
 // DelMtPlay 清空附加播放源信息
@@ -97,7 +138,7 @@ func DelMtPlay(keys []string) {
 
 /*
 SearchKeyword 设置search关键字集合(影片分类检索类型数据)
-	类型, 剧情 , 地区, 语言, 年份, 首字母, 排序
+	类型, 剧情 , 地区, 语言, 年份, 首字母, 排序, 画质, 版本, 字幕
 	1. 在影片详情缓存到redis时将影片的相关数据进行记录, 存在相同类型则分值加一
 	2. 通过分值对类型进行排序类型展示到页面
 */
@@ -112,17 +153,18 @@ func SaveSearchTag(search SearchInfo) {
 	// 获取redis中的searchMap
 	key := fmt.Sprintf(config.SearchTitle, search.Pid)
 	searchMap := db.Rdb.HGetAll(db.Cxt, key).Val()
-	// 是否存在对应分类的map, 如果不存在则缓存一份
-	if len(searchMap) == 0 {
-		searchMap = make(map[string]string)
-		searchMap["Category"] = "类型"
-		searchMap["Plot"] = "剧情"
-		searchMap["Area"] = "地区"
-		searchMap["Language"] = "语言"
-		searchMap["Year"] = "年份"
-		searchMap["Initial"] = "首字母"
-		searchMap["Sort"] = "排序"
-		db.Rdb.HMSet(db.Cxt, key, searchMap)
+	// 是否存在对应分类的map, 如果不存在则缓存一份, 已存在时补充后续新增的分类标题
+	titles := map[string]string{"Category": "类型", "Plot": "剧情", "Area": "地区", "Language": "语言", "Year": "年份",
+		"Initial": "首字母", "Sort": "排序", "Quality": "画质", "Version": "版本", "Subtitle": "字幕"}
+	missing := make(map[string]string)
+	for k, v := range titles {
+		if _, ok := searchMap[k]; !ok {
+			missing[k] = v
+			searchMap[k] = v
+		}
+	}
+	if len(missing) > 0 {
+		db.Rdb.HMSet(db.Cxt, key, missing)
 	}
 	// 对searchMap中的各个类型进行处理
 	for k, _ := range searchMap {
@@ -164,6 +206,16 @@ func SaveSearchTag(search SearchInfo) {
 				}
 				db.Rdb.ZAdd(db.Cxt, fmt.Sprintf(config.SearchTag, search.Pid, k), tags...)
 			}
+		case "Quality", "Version", "Subtitle":
+			// 画质 版本 字幕为固定的标签值, 按标签定义的顺序缓存一份
+			if tagCount == 0 {
+				names := MediaTagNames(k)
+				var tags []redis.Z
+				for i, n := range names {
+					tags = append(tags, redis.Z{Score: float64(len(names) - i), Member: fmt.Sprintf("%v:%v", n, n)})
+				}
+				db.Rdb.ZAdd(db.Cxt, tagKey, tags...)
+			}
 		case "Plot":
 			HandleSearchTags(search.ClassTag, tagKey)
 		case "Area":
@@ -219,37 +271,12 @@ func BatchHandleSearchTag(infos ...SearchInfo) {
 
 // ================================= Spider 数据处理(mysql) =================================
 
-// CreateSearchTable 创建存储检索信息的数据表
-func CreateSearchTable() {
-	// 如果不存在则创建表
-	if !ExistSearchTable() {
-		err := db.Mdb.AutoMigrate(&SearchInfo{})
-		if err != nil {
-			log.Println("Create Table SearchInfo Failed: ", err)
-		}
-	}
-}
-
 // ExistSearchTable 是否存在Search Table
 func ExistSearchTable() bool {
 	// 1. 判断表中是否存在当前表
 	return db.Mdb.Migrator().HasTable(&SearchInfo{})
 }
 
-// AddSearchIndex search表中数据保存完毕后 将常用字段添加索引提高查询效率
-func AddSearchIndex() {
-	var s SearchInfo
-	tableName := s.TableName()
-	// 添加索引
-	db.Mdb.Exec(fmt.Sprintf("CREATE UNIQUE INDEX idx_mid ON %s (mid)", tableName))
-	db.Mdb.Exec(fmt.Sprintf("CREATE INDEX idx_time ON %s (update_stamp DESC)", tableName))
-	db.Mdb.Exec(fmt.Sprintf("CREATE INDEX idx_hits ON %s (hits DESC)", tableName))
-	db.Mdb.Exec(fmt.Sprintf("CREATE INDEX idx_score ON %s (score DESC)", tableName))
-	db.Mdb.Exec(fmt.Sprintf("CREATE INDEX idx_release ON %s (release_stamp DESC)", tableName))
-	db.Mdb.Exec(fmt.Sprintf("CREATE INDEX idx_year ON %s (year DESC)", tableName))
-
-}
-
 // BatchSave 批量保存影片search信息
 func BatchSave(list []SearchInfo) {
 	tx := db.Mdb.Begin()
@@ -268,18 +295,21 @@ func BatchSave(list []SearchInfo) {
 	tx.Commit()
 }
 
+// searchRefreshColumns 影片重新采集时更新的检索字段, 完结状态等字段存在零值, 因此需要显式指定
+var searchRefreshColumns = []string{"update_stamp", "hits", "state", "remarks", "score", "release_stamp", "db_id",
+	"total", "serial", "is_end", "weekdays", "tv", "quality", "version", "subtitle"}
+
 // BatchSaveOrUpdate 判断数据库中是否存在对应mid的数据, 如果存在则更新, 否则插入
 func BatchSaveOrUpdate(list []SearchInfo) {
 	tx := db.Mdb.Begin()
 	for _, info := range list {
 		var count int64
-		// 通过当前影片id 对应的记录数
-		tx.Model(&SearchInfo{}).Where("mid", info.Mid).Count(&count)
+		// 通过当前影片id 对应的记录数 (包含已逻辑删除的记录, 避免重复插入)
+		tx.Model(&SearchInfo{}).Unscoped().Where("mid", info.Mid).Count(&count)
 		// 如果存在对应数据则进行更新, 否则保存相应数据
 		if count > 0 {
 			// 记录已经存在则执行更新部分内容
-			err := tx.Model(&SearchInfo{}).Where("mid", info.Mid).Updates(SearchInfo{UpdateStamp: info.UpdateStamp, Hits: info.Hits, State: info.State,
-				Remarks: info.Remarks, Score: info.Score, ReleaseStamp: info.ReleaseStamp}).Error
+			err := tx.Model(&SearchInfo{}).Where("mid", info.Mid).Select(searchRefreshColumns).Updates(&info).Error
 			if err != nil {
 				tx.Rollback()
 			}
@@ -311,8 +341,7 @@ func SaveSearchInfo(s SearchInfo) error {
 		BatchHandleSearchTag(s)
 	} else {
 		// 如果已经存在当前记录则将当前记录进行更新
-		err := tx.Model(&SearchInfo{}).Where("mid", s.Mid).Updates(SearchInfo{UpdateStamp: s.UpdateStamp, Hits: s.Hits, State: s.State,
-			Remarks: s.Remarks, Score: s.Score, ReleaseStamp: s.ReleaseStamp}).Error
+		err := tx.Model(&SearchInfo{}).Where("mid", s.Mid).Select(searchRefreshColumns).Updates(&s).Error
 		if err != nil {
 			tx.Rollback()
 			return err
@@ -323,17 +352,49 @@ func SaveSearchInfo(s SearchInfo) error {
 	return nil
 }
 
+// UpdateSearchInfo 使用影片最新的检索信息覆盖对应记录的全部检索字段(影片编辑后同步检索信息)
+func UpdateSearchInfo(s SearchInfo) error {
+	return db.Mdb.Model(&SearchInfo{}).Where("mid", s.Mid).
+		Select("name", "sub_title", "c_name", "class_tag", "area", "language", "year", "initial",
+			"score", "update_stamp", "hits", "state", "remarks", "release_stamp", "db_id", "total", "serial", "is_end", "weekdays", "tv",
+			"quality", "version", "subtitle").
+		Updates(&s).Error
+}
+
+// ScanSearchInfo 分批遍历所有检索信息(包含已逻辑删除的记录)
+func ScanSearchInfo(fn func(list []SearchInfo)) error {
+	var batch []SearchInfo
+	return db.Mdb.Unscoped().Model(&SearchInfo{}).FindInBatches(&batch, config.MaxScanCount, func(tx *gorm.DB, _ int) error {
+		fn(batch)
+		return nil
+	}).Error
+}
+
+// CountSearchInfoByCid 统计分类下的影片数量(包含已逻辑删除的记录)
+func CountSearchInfoByCid(cid int64) int64 {
+	var count int64
+	db.Mdb.Unscoped().Model(&SearchInfo{}).Where("cid = ?", cid).Count(&count)
+	return count
+}
+
+// ExistAnySearchInfo 是否已存在影片检索信息
+func ExistAnySearchInfo() bool {
+	var mids []int64
+	db.Mdb.Model(&SearchInfo{}).Limit(1).Pluck("mid", &mids)
+	return len(mids) > 0
+}
+
 // ExistSearchInfo 通过Mid查询是否存在影片的检索信息
 func ExistSearchInfo(mid int64) bool {
 	var count int64
-	db.Mdb.Model(&SearchInfo{}).Where("mid", mid).Count(&count)
+	db.Mdb.Model(&SearchInfo{}).Unscoped().Where("mid", mid).Count(&count)
 	return count > 0
 }
 
 // TunCateSearchTable 截断SearchInfo数据表
 func TunCateSearchTable() {
 	var searchInfo SearchInfo
-	err := db.Mdb.Exec(fmt.Sprintf("TRUNCATE TABLE %s", searchInfo.TableName())).Error
+	err := db.TruncateTable(searchInfo.TableName())
 	if err != nil {
 		log.Println("TRUNCATE TABLE Error: ", err)
 	}
@@ -343,16 +404,22 @@ func TunCateSearchTable() {
 func SyncSearchInfo(model int) {
 	switch model {
 	case 0:
-		// 重置Search表, (恢复为初始状态, 未添加索引)
+		// 重置Search表, (清空所有检索数据)
 		ResetSearchTable()
 		// 批量添加 SearchInfo
 		SearchInfoToMdb(model)
-		// 保存完所有 SearchInfo 后添加字段索引
-		AddSearchIndex()
+		// 本地影片不在采集数据中, 从影片详情重新生成检索信息
+		if err := SyncLocalFilms(); err != nil {
+			log.Println("Sync Local Films Error: ", err)
+		}
 	case 1:
 		// 批量更新或添加
 		SearchInfoToMdb(model)
 	}
+	// 回收站中的影片保持删除状态
+	if err := ApplyFilmRecycle(); err != nil {
+		log.Println("Apply Film Recycle Error: ", err)
+	}
 }
 
 // SearchInfoToMdb 扫描redis中的检索信息, 并批量存入mysql (model 执行模式 0-清空并保存 || 1-更新)
@@ -391,16 +458,80 @@ func SearchInfoToMdb(model int) {
 
 // ================================= API 数据接口信息处理 =================================
 
+// filmVisibility 影片展示状态的本地缓存, 避免每次查询都读取并解析分类树
+var filmVisibility = struct {
+	sync.RWMutex
+	tree      CategoryTree       // 全局分类树, 只读
+	hiddenIds map[string][]int64 // 站点Id => 隐藏分类的ID, 未单独设置分类展示状态的站点使用空字符串
+	hasHidden bool               // 是否存在单独隐藏的影片
+	expired   time.Time
+}{}
+
+// ResetFilmVisibility 分类, 站点或影片的展示状态变更后清除本地缓存
+func ResetFilmVisibility() {
+	filmVisibility.Lock()
+	filmVisibility.expired = time.Time{}
+	filmVisibility.Unlock()
+}
+
+// loadFilmVisibility 获取站点中隐藏分类的ID以及是否存在单独隐藏的影片, 缓存过期后重新加载
+func loadFilmVisibility(t Tenant) ([]int64, bool) {
+	key := t.Id
+	if len(t.Categories) == 0 {
+		key = ""
+	}
+	filmVisibility.RLock()
+	tree, hasHidden, expired := filmVisibility.tree, filmVisibility.hasHidden, filmVisibility.expired
+	ids, ok := filmVisibility.hiddenIds[key]
+	filmVisibility.RUnlock()
+	if time.Now().Before(expired) && ok {
+		return ids, hasHidden
+	}
+	if time.Now().After(expired) {
+		tree = GetCategoryTree()
+		var mids []int64
+		db.Mdb.Model(&FilmHidden{}).Limit(1).Pluck("mid", &mids)
+		hasHidden = len(mids) > 0
+	}
+	tt := t.CategoryTree(tree)
+	ids = tt.HiddenIds()
+	filmVisibility.Lock()
+	if time.Now().After(filmVisibility.expired) {
+		filmVisibility.tree, filmVisibility.hasHidden = tree, hasHidden
+		filmVisibility.hiddenIds = make(map[string][]int64)
+		filmVisibility.expired = time.Now().Add(config.FilmVisibilityExpired)
+	}
+	filmVisibility.hiddenIds[key] = ids
+	filmVisibility.Unlock()
+	return ids, hasHidden
+}
+
+// VisibleFilm 查询条件中排除站点中隐藏分类下的影片以及隐藏的影片, 展示状态在查询时生效, 不受检索表重建的影响
+// 站点单独设置的分类展示状态覆盖全局分类树, 不区分站点的查询使用 DefaultTenant
+func VisibleFilm(t Tenant) func(tx *gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		ids, hasHidden := loadFilmVisibility(t)
+		if len(ids) > 0 {
+			tx = tx.Where("cid NOT IN ? AND pid NOT IN ?", ids, ids)
+		}
+		// 排除单独设置为隐藏的影片, 不存在隐藏影片时省略子查询
+		if hasHidden {
+			tx = tx.Where("mid NOT IN (?)", db.Mdb.Model(&FilmHidden{}).Select("mid"))
+		}
+		return tx
+	}
+}
+
 // GetMovieListByPid  通过Pid 分类ID 获取对应影片的数据信息
-func GetMovieListByPid(pid int64, page *Page) []MovieBasicInfo {
+func GetMovieListByPid(t Tenant, pid int64, page *Page) []MovieBasicInfo {
 	// 返回分页参数
 	var count int64
-	db.Mdb.Model(&SearchInfo{}).Where("pid", pid).Count(&count)
+	db.Mdb.Model(&SearchInfo{}).Scopes(VisibleFilm(t)).Where("pid", pid).Count(&count)
 	page.Total = int(count)
 	page.PageCount = int((page.Total + page.PageSize - 1) / page.PageSize)
 	// 进行具体的信息查询
 	var s []SearchInfo
-	if err := db.Mdb.Limit(page.PageSize).Offset((page.Current-1)*page.PageSize).Where("pid", pid).Order("update_stamp DESC").Find(&s).Error; err != nil {
+	if err := db.Mdb.Scopes(VisibleFilm(t)).Limit(page.PageSize).Offset((page.Current-1)*page.PageSize).Where("pid", pid).Order("update_stamp DESC").Find(&s).Error; err != nil {
 		log.Println(err)
 		return nil
 	}
@@ -413,16 +544,17 @@ func GetMovieListByPid(pid int64, page *Page) []MovieBasicInfo {
 	return list
 }
 
-// GetMovieListByCid 通过Cid查找对应的影片分页数据, 不适合GetMovieListByPid 糅合
-func GetMovieListByCid(cid int64, page *Page) []MovieBasicInfo {
+// GetMovieListByCid 通过Cid查找对应的影片分页数据(包含子孙分类), 不适合GetMovieListByPid 糅合
+func GetMovieListByCid(t Tenant, cid int64, page *Page) []MovieBasicInfo {
+	cids := CategoryIds(cid)
 	// 返回分页参数
 	var count int64
-	db.Mdb.Model(&SearchInfo{}).Where("cid", cid).Count(&count)
+	db.Mdb.Model(&SearchInfo{}).Scopes(VisibleFilm(t)).Where("cid IN ?", cids).Count(&count)
 	page.Total = int(count)
 	page.PageCount = int((page.Total + page.PageSize - 1) / page.PageSize)
 	// 进行具体的信息查询
 	var s []SearchInfo
-	if err := db.Mdb.Limit(page.PageSize).Offset((page.Current-1)*page.PageSize).Where("cid", cid).Order("update_stamp DESC").Find(&s).Error; err != nil {
+	if err := db.Mdb.Scopes(VisibleFilm(t)).Limit(page.PageSize).Offset((page.Current-1)*page.PageSize).Where("cid IN ?", cids).Order("update_stamp DESC").Find(&s).Error; err != nil {
 		log.Println(err)
 		return nil
 	}
@@ -436,7 +568,7 @@ func GetMovieListByCid(cid int64, page *Page) []MovieBasicInfo {
 }
 
 // GetHotMovieByPid  获取Pid指定类别的热门影片
-func GetHotMovieByPid(pid int64, page *Page) []SearchInfo {
+func GetHotMovieByPid(t Tenant, pid int64, page *Page) []SearchInfo {
 	// 返回分页参数
 	//var count int64
 	//db.Mdb.Model(&SearchInfo{}).Where("pid", pid).Count(&count)
@@ -445,8 +577,8 @@ func GetHotMovieByPid(pid int64, page *Page) []SearchInfo {
 	// 进行具体的信息查询
 	var s []SearchInfo
 	// 当前时间偏移一个月
-	t := time.Now().AddDate(0, -1, 0).Unix()
-	if err := db.Mdb.Limit(page.PageSize).Offset((page.Current-1)*page.PageSize).Where("pid=? AND update_stamp > ?", pid, t).Order(" year DESC, hits DESC").Find(&s).Error; err != nil {
+	stamp := time.Now().AddDate(0, -1, 0).Unix()
+	if err := db.Mdb.Scopes(VisibleFilm(t)).Limit(page.PageSize).Offset((page.Current-1)*page.PageSize).Where("pid=? AND update_stamp > ?", pid, stamp).Order(" year DESC, hits DESC").Find(&s).Error; err != nil {
 		log.Println(err)
 		return nil
 	}
@@ -454,7 +586,7 @@ func GetHotMovieByPid(pid int64, page *Page) []SearchInfo {
 }
 
 // GetHotMovieByCid 获取当前分类下的热门影片
-func GetHotMovieByCid(cid int64, page *Page) []SearchInfo {
+func GetHotMovieByCid(t Tenant, cid int64, page *Page) []SearchInfo {
 	// 返回分页参数
 	//var count int64
 	//db.Mdb.Model(&SearchInfo{}).Where("pid", pid).Count(&count)
@@ -463,30 +595,16 @@ func GetHotMovieByCid(cid int64, page *Page) []SearchInfo {
 	// 进行具体的信息查询
 	var s []SearchInfo
 	// 当前时间偏移一个月
-	t := time.Now().AddDate(0, -1, 0).Unix()
-	if err := db.Mdb.Limit(page.PageSize).Offset((page.Current-1)*page.PageSize).Where("cid=? AND update_stamp > ?", cid, t).Order(" year DESC, hits DESC").Find(&s).Error; err != nil {
+	stamp := time.Now().AddDate(0, -1, 0).Unix()
+	if err := db.Mdb.Scopes(VisibleFilm(t)).Limit(page.PageSize).Offset((page.Current-1)*page.PageSize).Where("cid IN ? AND update_stamp > ?", CategoryIds(cid), stamp).Order(" year DESC, hits DESC").Find(&s).Error; err != nil {
 		log.Println(err)
 		return nil
 	}
 	return s
 }
 
-// SearchFilmKeyword 通过关键字搜索库存中满足条件的影片名
-func SearchFilmKeyword(keyword string, page *Page) []SearchInfo {
-	var searchList []SearchInfo
-	// 1. 先统计搜索满足条件的数据量
-	var count int64
-	db.Mdb.Model(&SearchInfo{}).Where("name LIKE ?", fmt.Sprint(`%`, keyword, `%`)).Or("sub_title LIKE ?", fmt.Sprint(`%`, keyword, `%`)).Count(&count)
-	page.Total = int(count)
-	page.PageCount = int((page.Total + page.PageSize - 1) / page.PageSize)
-	// 2. 获取满足条件的数据
-	db.Mdb.Limit(page.PageSize).Offset((page.Current-1)*page.PageSize).
-		Where("name LIKE ?", fmt.Sprintf(`%%%s%%`, keyword)).Or("sub_title LIKE ?", fmt.Sprintf(`%%%s%%`, keyword)).Order("year DESC, update_stamp DESC").Find(&searchList)
-	return searchList
-}
-
 // GetRelateMovieBasicInfo GetRelateMovie 根据SearchInfo获取相关影片
-func GetRelateMovieBasicInfo(search SearchInfo, page *Page) []MovieBasicInfo {
+func GetRelateMovieBasicInfo(t Tenant, search SearchInfo, page *Page) []MovieBasicInfo {
 	/*
 		根据当前影片信息匹配相关的影片
 		1. 分类Cid,
@@ -495,9 +613,6 @@ func GetRelateMovieBasicInfo(search SearchInfo, page *Page) []MovieBasicInfo {
 		4. area 地区
 		5. 语言 Language
 	*/
-	// sql 拼接查询条件
-	sql := ""
-
 	// 优先进行名称相似匹配
 	//search.Name = regexp.MustCompile("第.{1,3}季").ReplaceAllString(search.Name, "")
 	name := regexp.MustCompile(`(第.{1,3}季.*)|([0-9]{1,3})|(剧场版)|(\s\S*$)|(之.*)|([\p{P}\p{S}].*)`).ReplaceAllString(search.Name, "")
@@ -506,39 +621,43 @@ func GetRelateMovieBasicInfo(search SearchInfo, page *Page) []MovieBasicInfo {
 		// 中文字符需截取3的倍数,否则可能乱码
 		name = name[:int(math.Ceil(float64(len(name))/5)*3)]
 	}
-	sql = fmt.Sprintf(`select * from %s where (name LIKE "%%%s%%" or sub_title LIKE "%%%[2]s%%") AND cid=%d AND search.deleted_at IS NULL union`, search.TableName(), name, search.Cid)
-	// 执行后续匹配内容, 匹配结果过少,减少过滤条件
-	//sql = fmt.Sprintf(`%s select * from %s where cid=%d AND area="%s" AND language="%s" AND`, sql, search.TableName(), search.Cid, search.Area, search.Language)
+	var list []SearchInfo
+	db.Mdb.Model(&SearchInfo{}).Scopes(VisibleFilm(t)).Where("cid = ?", search.Cid).
+		Where(db.Mdb.Where(db.Like("name"), fmt.Sprintf("%%%s%%", name)).Or(db.Like("sub_title"), fmt.Sprintf("%%%s%%", name))).
+		Offset(page.Current).Limit(page.PageSize).Find(&list)
 
-	// 添加其他相似匹配规则
-	sql = fmt.Sprintf(`%s (select * from %s where cid=%d AND `, sql, search.TableName(), search.Cid)
-	// 根据剧情标签查找相似影片, classTag 使用的分隔符为 , | /
+	// 添加其他相似匹配规则, 根据剧情标签查找相似影片, classTag 使用的分隔符为 , | /
 	// 首先去除 classTag 中包含的所有空格
 	search.ClassTag = strings.ReplaceAll(search.ClassTag, " ", "")
+	tagQuery := db.Mdb.Where(db.Like("class_tag"), fmt.Sprintf("%%%s%%", search.ClassTag))
 	// 如果 classTag 中包含分割符则进行拆分匹配
-	if strings.Contains(search.ClassTag, ",") {
-		s := "("
-		for _, t := range strings.Split(search.ClassTag, ",") {
-			s = fmt.Sprintf(`%s class_tag like "%%%s%%" OR`, s, t)
+	for _, sep := range []string{",", "/"} {
+		if strings.Contains(search.ClassTag, sep) {
+			tagQuery = db.Mdb.Where("1 = 0")
+			for _, t := range strings.Split(search.ClassTag, sep) {
+				tagQuery = tagQuery.Or(db.Like("class_tag"), fmt.Sprintf("%%%s%%", t))
+			}
+			break
 		}
-		sql = fmt.Sprintf("%s %s)", sql, strings.TrimSuffix(s, "OR"))
-	} else if strings.Contains(search.ClassTag, "/") {
-		s := "("
-		for _, t := range strings.Split(search.ClassTag, "/") {
-			s = fmt.Sprintf(`%s class_tag like "%%%s%%" OR`, s, t)
+	}
+	// 除名称外的相似影片 (已通过名称匹配的影片不再重复返回)
+	var tagList []SearchInfo
+	db.Mdb.Model(&SearchInfo{}).Scopes(VisibleFilm(t)).Where("cid = ?", search.Cid).Where(tagQuery).
+		Offset(page.Current).Limit(page.PageSize).Find(&tagList)
+	exist := make(map[int64]bool)
+	for _, s := range list {
+		exist[s.Mid] = true
+	}
+	for _, s := range tagList {
+		if !exist[s.Mid] {
+			list = append(list, s)
+			exist[s.Mid] = true
 		}
-		sql = fmt.Sprintf("%s %s)", sql, strings.TrimSuffix(s, "OR"))
-	} else {
-		sql = fmt.Sprintf(`%s class_tag like "%%%s%%"`, sql, search.ClassTag)
 	}
-	// 除名称外的相似影片使用随机排序
-	//sql = fmt.Sprintf("%s ORDER BY RAND() limit %d,%d)", sql, page.Current, page.PageSize)
-	sql = fmt.Sprintf("%s AND search.deleted_at IS NULL limit %d,%d)", sql, page.Current, page.PageSize)
 	// 条件拼接完成后加上limit参数
-	sql = fmt.Sprintf("(%s)  limit %d,%d", sql, page.Current, page.PageSize)
-	// 执行sql
-	var list []SearchInfo
-	db.Mdb.Raw(sql).Scan(&list)
+	if len(list) > page.PageSize {
+		list = list[:page.PageSize]
+	}
 	// 根据list 获取对应的BasicInfo
 	var basicList []MovieBasicInfo
 	for _, s := range list {
@@ -557,6 +676,55 @@ func GetMultiplePlay(siteId, key string) []MovieUrlInfo {
 	return playList
 }
 
+// firstSeasonPattern 片名末尾的第一季
+var firstSeasonPattern = regexp.MustCompile(`第一季$`)
+
+// MultiplePlayKeys 影片在附属站点中匹配播放源的 key, 依次为 豆瓣ID, 片名, 去除第一季的片名以及别名
+func MultiplePlayKeys(d MovieDetail) []string {
+	var keys []string
+	seen := make(map[string]bool)
+	add := func(k string) {
+		if !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	if d.DbId > 0 {
+		add(GenerateHashKey(d.DbId))
+	}
+	add(GenerateHashKey(d.Name))
+	add(GenerateHashKey(firstSeasonPattern.ReplaceAllString(d.Name, "")))
+	for _, sep := range []string{",", "/"} {
+		if strings.Contains(d.SubTitle, sep) {
+			for _, v := range strings.Split(d.SubTitle, sep) {
+				add(GenerateHashKey(v))
+			}
+		}
+	}
+	return keys
+}
+
+// DelMultiplePlay 删除影片在附属站点中的播放源, siteIds 为空时删除所有站点中的播放源
+func DelMultiplePlay(d MovieDetail, siteIds ...string) error {
+	var hashes []string
+	if len(siteIds) == 0 {
+		var err error
+		if hashes, err = db.ScanKeys(config.KeyPattern(config.MultipleSiteDetail)); err != nil {
+			return err
+		}
+	}
+	for _, id := range siteIds {
+		hashes = append(hashes, fmt.Sprintf(config.MultipleSiteDetail, id))
+	}
+	keys := MultiplePlayKeys(d)
+	pipe := db.Rdb.Pipeline()
+	for _, h := range hashes {
+		pipe.HDel(db.Cxt, h, keys...)
+	}
+	_, err := pipe.Exec(db.Cxt)
+	return err
+}
+
 // GetSearchTag 通过影片分类 Pid 返回对应分类的tag信息
 func GetSearchTag(pid int64) map[string]interface{} {
 	// 整合searchTag相关内容
@@ -570,7 +738,7 @@ func GetSearchTag(pid int64) map[string]interface{} {
 	}
 	res["tags"] = tagMap
 	// 分类列表展示的顺序
-	res["sortList"] = []string{"Category", "Plot", "Area", "Language", "Year", "Sort"}
+	res["sortList"] = []string{"Category", "Plot", "Area", "Language", "Year", "Quality", "Version", "Subtitle", "Sort"}
 	return res
 }
 
@@ -594,7 +762,7 @@ func GetTagsByTitle(pid int64, t string) []string {
 		tags = db.Rdb.ZRevRange(db.Cxt, fmt.Sprintf(config.SearchTag, pid, t), 0, 11).Val()
 	case "Language":
 		tags = db.Rdb.ZRevRange(db.Cxt, fmt.Sprintf(config.SearchTag, pid, t), 0, 6).Val()
-	case "Year", "Initial", "Sort":
+	case "Year", "Initial", "Sort", "Quality", "Version", "Subtitle":
 		tags = db.Rdb.ZRevRange(db.Cxt, fmt.Sprintf(config.SearchTag, pid, t), 0, -1).Val()
 	default:
 		break
@@ -629,51 +797,17 @@ func HandleTagStr(title string, tags ...string) []map[string]string {
 }
 
 // GetSearchInfosByTags 查询满足searchTag条件的影片分页数据
-func GetSearchInfosByTags(st SearchTagsVO, page *Page) []SearchInfo {
-	// 准备查询语句的条件
-	qw := db.Mdb.Model(&SearchInfo{})
-	// 通过searchTags的非空属性值, 拼接对应的查询条件
-	t := reflect.TypeOf(st)
-	v := reflect.ValueOf(st)
-	for i := 0; i < t.NumField(); i++ {
-		// 如果字段值不为空
-		value := v.Field(i).Interface()
-		if !param.IsEmpty(value) {
-			// 如果value是 其它 则进行特殊处理
-			var ts []string
-			if v, flag := value.(string); flag && strings.EqualFold(v, "其它") {
-				for _, s := range GetTagsByTitle(st.Pid, t.Field(i).Name) {
-					ts = append(ts, strings.Split(s, ":")[1])
-				}
-			}
-			k := strings.ToLower(t.Field(i).Name)
-			switch k {
-			case "pid", "cid", "year":
-				qw = qw.Where(fmt.Sprintf("%s = ?", k), value)
-			case "area", "language":
-				if strings.EqualFold(value.(string), "其它") {
-					qw = qw.Where(fmt.Sprintf("%s NOT IN ?", k), ts)
-					break
-				}
-				qw = qw.Where(fmt.Sprintf("%s = ?", k), value)
-			case "plot":
-				if strings.EqualFold(value.(string), "其它") {
-					for _, t := range ts {
-						qw = qw.Where("class_tag NOT LIKE ?", fmt.Sprintf("%%%v%%", t))
-					}
-					break
-				}
-				qw = qw.Where("class_tag LIKE ?", fmt.Sprintf("%%%v%%", value))
-			case "sort":
-				if strings.EqualFold(value.(string), "release_stamp") {
-					qw.Order(fmt.Sprintf("year DESC ,%v DESC", value))
-					break
-				}
-				qw.Order(fmt.Sprintf("%v DESC", value))
-			default:
-				break
-			}
-		}
+func GetSearchInfosByTags(t Tenant, st SearchTagsVO, page *Page) []SearchInfo {
+	// 通过searchTags中各维度的筛选条件生成查询语句
+	qw := searchTagsQuery(t, newTagScope(st.Pid), st, "")
+	// 排序字段只允许使用白名单中的字段, 其余情况按更新时间排序
+	switch strings.ToLower(st.Sort) {
+	case "release_stamp":
+		qw.Order("year DESC, release_stamp DESC")
+	case "hits", "score":
+		qw.Order(strings.ToLower(st.Sort) + " DESC")
+	default:
+		qw.Order("update_stamp DESC")
 	}
 
 	// 返回分页参数
@@ -689,11 +823,11 @@ func GetSearchInfosByTags(st SearchTagsVO, page *Page) []SearchInfo {
 }
 
 // GetMovieListBySort 通过排序类型返回对应的影片基本信息
-func GetMovieListBySort(t int, pid int64, page *Page) []MovieBasicInfo {
+func GetMovieListBySort(t Tenant, sortType int, pid int64, page *Page) []MovieBasicInfo {
 	var sl []SearchInfo
-	qw := db.Mdb.Model(&SearchInfo{}).Where("pid", pid).Limit(page.PageSize).Offset((page.Current) - 10*page.PageSize)
+	qw := db.Mdb.Model(&SearchInfo{}).Scopes(VisibleFilm(t)).Where("pid", pid).Limit(page.PageSize).Offset((page.Current) - 10*page.PageSize)
 	// 针对不同排序类型返回对应的分页数据
-	switch t {
+	switch sortType {
 	case 0:
 		// 最新上映 (上映时间)
 		qw.Order("release_stamp DESC")
@@ -716,20 +850,46 @@ func GetMovieListBySort(t int, pid int64, page *Page) []MovieBasicInfo {
 
 // GetSearchPage 获取影片检索分页数据
 func GetSearchPage(s SearchVo) []SearchInfo {
+	query := searchPageQuery(s)
+	// 返回分页参数
+	GetPage(query, s.Paging)
+	// 查询具体的数据
+	var sl []SearchInfo
+	if err := query.Limit(s.Paging.PageSize).Offset((s.Paging.Current - 1) * s.Paging.PageSize).Find(&sl).Error; err != nil {
+		log.Println(err)
+		return nil
+	}
+	return sl
+
+}
+
+// FindSearchMids 获取满足检索条件的全部影片ID, deleted 为 true 时查询回收站中的影片
+func FindSearchMids(s SearchVo, deleted bool) ([]int64, error) {
+	query := searchPageQuery(s)
+	if deleted {
+		query = query.Unscoped().Where("deleted_at IS NOT NULL")
+	}
+	var mids []int64
+	err := query.Order("id").Pluck("mid", &mids).Error
+	return mids, err
+}
+
+// searchPageQuery 通过检索参数构建影片检索信息的查询条件
+func searchPageQuery(s SearchVo) *gorm.DB {
 	// 构建 query查询条件
 	query := db.Mdb.Model(&SearchInfo{})
 	// 如果参数不为空则追加对应查询条件
 	if s.Name != "" {
-		query = query.Where("name LIKE ?", fmt.Sprintf("%%%s%%", s.Name))
+		query = query.Where(db.Like("name"), fmt.Sprintf("%%%s%%", s.Name))
 	}
 	// 分类ID为负数则默认不追加该条件
 	if s.Cid > 0 {
-		query = query.Where("cid = ?", s.Cid)
+		query = query.Where("cid IN ?", CategoryIds(s.Cid))
 	} else if s.Pid > 0 {
 		query = query.Where("pid = ?", s.Pid)
 	}
 	if s.Plot != "" {
-		query = query.Where("class_tag LIKE ?", fmt.Sprintf("%%%s%%", s.Plot))
+		query = query.Where(db.Like("class_tag"), fmt.Sprintf("%%%s%%", s.Plot))
 	}
 	if s.Area != "" {
 		query = query.Where("area = ?", s.Area)
@@ -740,12 +900,13 @@ func GetSearchPage(s SearchVo) []SearchInfo {
 	if int(s.Year) > time.Now().Year()-12 {
 		query = query.Where("year = ?", s.Year)
 	}
+	// 完结状态 完结 | 未完结
 	switch s.Remarks {
-	case "完结":
-		query = query.Where("remarks IN ?", []string{"完结", "HD"})
+	case SerialEnd:
+		query = query.Scopes(SerialStatus(SerialEnd))
 	case "":
 	default:
-		query = query.Not(map[string]interface{}{"remarks": []string{"完结", "HD"}})
+		query = query.Scopes(SerialStatus(SerialOngoing))
 	}
 	if s.BeginTime > 0 {
 		query = query.Where("update_stamp >= ? ", s.BeginTime)
@@ -753,17 +914,7 @@ func GetSearchPage(s SearchVo) []SearchInfo {
 	if s.EndTime > 0 {
 		query = query.Where("update_stamp <= ? ", s.EndTime)
 	}
-
-	// 返回分页参数
-	GetPage(query, s.Paging)
-	// 查询具体的数据
-	var sl []SearchInfo
-	if err := query.Limit(s.Paging.PageSize).Offset((s.Paging.Current - 1) * s.Paging.PageSize).Find(&sl).Error; err != nil {
-		log.Println(err)
-		return nil
-	}
-	return sl
-
+	return query
 }
 
 // GetSearchOptions 获取全部影片的检索标签信息
@@ -775,7 +926,7 @@ func GetSearchOptions(pid int64) map[string]interface{} {
 	for t, _ := range titles {
 		switch t {
 		// 只获取对应几个类型的标签
-		case "Plot", "Area", "Language", "Year":
+		case "Plot", "Area", "Language", "Year", "Quality", "Version", "Subtitle":
 			tagMap[t] = HandleTagStr(t, GetTagsByTitle(pid, t)...)
 		default:
 		}
@@ -793,30 +944,20 @@ func GetSearchInfoById(id int64) *SearchInfo {
 	return &s
 }
 
-// DelFilmSearch 删除影片检索信息, (不影响后续更新, 逻辑删除)
-func DelFilmSearch(id int64) error {
-	// 通过检索id对影片检索信息进行删除
-	if err := db.Mdb.Delete(&SearchInfo{}, id).Error; err != nil {
-		log.Println(err)
-		return err
-	}
-	return nil
-}
-
-// ShieldFilmSearch 删除所属分类下的所有影片检索信息
-func ShieldFilmSearch(cid int64) error {
-	// 通过检索id对影片检索信息进行删除
-	if err := db.Mdb.Where("cid = ?", cid).Delete(&SearchInfo{}).Error; err != nil {
+// GetSearchInfoByMid 查询影片ID对应的检索信息
+func GetSearchInfoByMid(mid int64) *SearchInfo {
+	s := SearchInfo{}
+	if err := db.Mdb.Where("mid", mid).First(&s).Error; err != nil {
 		log.Println(err)
-		return err
+		return nil
 	}
-	return nil
+	return &s
 }
 
-// RecoverFilmSearch 恢复所属分类下的影片检索信息状态
-func RecoverFilmSearch(cid int64) error {
+// DelFilmSearch 删除影片检索信息, (不影响后续更新, 逻辑删除)
+func DelFilmSearch(id int64) error {
 	// 通过检索id对影片检索信息进行删除
-	if err := db.Mdb.Model(&SearchInfo{}).Unscoped().Where("cid = ?", cid).Update("deleted_at", nil).Error; err != nil {
+	if err := db.Mdb.Delete(&SearchInfo{}, id).Error; err != nil {
 		log.Println(err)
 		return err
 	}
@@ -851,7 +992,7 @@ func RemoveCache(key string) {
 
 func FindFilmIds(params map[string]string, page *Page) ([]int64, error) {
 	var ids []int64
-	query := db.Mdb.Model(&SearchInfo{}).Select("mid")
+	query := db.Mdb.Model(&SearchInfo{}).Scopes(VisibleFilm(DefaultTenant())).Select("mid")
 	for k, v := range params {
 		// 如果 v 为空则直接 continue
 		if len(v) <= 0 {
@@ -860,10 +1001,10 @@ func FindFilmIds(params map[string]string, page *Page) ([]int64, error) {
 		switch k {
 		case "t":
 			if cid, err := strconv.ParseInt(v, 10, 64); err == nil {
-				query = query.Where("cid = ?", cid)
+				query = query.Where("cid IN ?", CategoryIds(cid))
 			}
 		case "wd":
-			query = query.Where("name like ?", fmt.Sprintf("%%%s%%", v))
+			query = query.Where(db.Like("name"), fmt.Sprintf("%%%s%%", v))
 		case "h":
 			if h, err := strconv.ParseInt(v, 10, 64); err == nil {
 				query = query.Where("update_stamp >= ?", time.Now().Unix()-h*3600)
@@ -0,0 +1,297 @@
+package system
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"server/config"
+	"server/plugin/db"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+/*
+	标签检索条件与分面统计
+	每个维度可同时选择多个值, 影片满足任意一个即可, 以 ! 开头的值为排除条件, 例: Area=日本,韩国 | Area=!美国
+	年份与评分支持范围条件, 例: Year=2010-2019 | Score=7- (不低于7分)
+	分面统计在当前筛选条件下计算各维度选项的影片数量, 统计某一维度时忽略该维度自身的条件, 使多选时同维度的其它选项仍有数量
+*/
+
+// TagFilter 单个维度的筛选条件, 影片需满足 Values 中的任意一项, 且不满足 Excludes 中的任意一项
+type TagFilter struct {
+	Values   []string `json:"values"`
+	Excludes []string `json:"excludes"`
+}
+
+// ParseTagFilter 解析使用 , (兼容全角 ，) 分隔的筛选条件, 以 ! (兼容全角 ！) 开头的值为排除条件
+func ParseTagFilter(s string) TagFilter {
+	var f TagFilter
+	for _, v := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '，' }) {
+		v = strings.TrimSpace(v)
+		if e, ok := cutExclude(v); ok {
+			if e = strings.TrimSpace(e); e != "" {
+				f.Excludes = append(f.Excludes, e)
+			}
+		} else if v != "" {
+			f.Values = append(f.Values, v)
+		}
+	}
+	return f
+}
+
+// cutExclude 去除排除条件的 ! 前缀, 不是排除条件时返回 false
+func cutExclude(v string) (string, bool) {
+	if e, ok := strings.CutPrefix(v, "!"); ok {
+		return e, true
+	}
+	return strings.CutPrefix(v, "！")
+}
+
+// 标签维度的匹配方式
+const (
+	tagMatchExact    = iota // 字段值等于标签值
+	tagMatchLike            // 字段包含多个标签, 模糊匹配
+	tagMatchRange           // 数值字段, 支持 min-max 范围
+	tagMatchCategory        // 分类及其子孙分类
+	tagMatchSerial          // 完结 | 连载
+)
+
+// tagScope 单次标签检索的一级分类以及分类树, 同一请求中的多次查询只读取一次分类树
+type tagScope struct {
+	pid  int64
+	tree CategoryTree
+}
+
+// newTagScope 读取分类树生成标签检索范围
+func newTagScope(pid int64) tagScope {
+	return tagScope{pid: pid, tree: GetCategoryTree()}
+}
+
+// categoryIds 获取分类及其所有子孙分类的ID
+func (sc tagScope) categoryIds(id int64) []int64 {
+	if n := sc.tree.Find(id); n != nil {
+		return n.Ids()
+	}
+	return []int64{id}
+}
+
+// tagDimension 标签检索维度
+type tagDimension struct {
+	Title  string
+	Column string
+	Match  int
+	Filter func(st SearchTagsVO) TagFilter
+}
+
+var (
+	// tagDimensions 参与筛选与分面统计的维度
+	tagDimensions = []tagDimension{
+		{"Category", "cid", tagMatchCategory, func(st SearchTagsVO) TagFilter { return st.Category }},
+		{"Plot", "class_tag", tagMatchLike, func(st SearchTagsVO) TagFilter { return st.Plot }},
+		{"Area", "area", tagMatchExact, func(st SearchTagsVO) TagFilter { return st.Area }},
+		{"Language", "language", tagMatchExact, func(st SearchTagsVO) TagFilter { return st.Language }},
+		{"Year", "year", tagMatchRange, func(st SearchTagsVO) TagFilter { return st.Year }},
+		{"Score", "score", tagMatchRange, func(st SearchTagsVO) TagFilter { return st.Score }},
+		{"Status", "is_end", tagMatchSerial, func(st SearchTagsVO) TagFilter { return st.Status }},
+		{"Quality", "quality", tagMatchExact, func(st SearchTagsVO) TagFilter { return st.Quality }},
+		{"Version", "version", tagMatchLike, func(st SearchTagsVO) TagFilter { return st.Version }},
+		{"Subtitle", "subtitle", tagMatchLike, func(st SearchTagsVO) TagFilter { return st.Subtitle }},
+	}
+	// scoreTagOptions 评分维度分面统计的选项
+	scoreTagOptions = []string{"9-", "8-", "7-", "6-"}
+)
+
+// options 维度在分面统计中的选项值
+func (d tagDimension) options(sc tagScope) []string {
+	var res []string
+	switch d.Title {
+	case "Category":
+		for _, t := range sc.tree.Children {
+			if t.Id != sc.pid {
+				continue
+			}
+			for _, c := range t.Children {
+				res = append(res, fmt.Sprint(c.Id))
+			}
+		}
+	case "Score":
+		res = scoreTagOptions
+	case "Status":
+		res = []string{SerialEnd, SerialOngoing}
+	default:
+		res = d.tags(sc.pid)
+		if d.Title != "Year" {
+			res = append(res, "其它")
+		}
+	}
+	return res
+}
+
+// tags 维度已记录的标签值
+func (d tagDimension) tags(pid int64) []string {
+	var res []string
+	for _, t := range GetTagsByTitle(pid, d.Title) {
+		if sl := strings.Split(t, ":"); len(sl) > 1 {
+			res = append(res, sl[1])
+		}
+	}
+	return res
+}
+
+// rangeSeparators 范围条件中 min 与 max 之间的分隔符, 依次为 连字符, 短破折号, 波浪号
+var rangeSeparators = []string{"-", "–", "~"}
+
+// cutRange 使用首个存在的分隔符拆分范围条件, 不包含分隔符时 ok 为 false
+func cutRange(value string) (lo, hi string, ok bool) {
+	for _, sep := range rangeSeparators {
+		if lo, hi, ok = strings.Cut(value, sep); ok {
+			return
+		}
+	}
+	return value, "", false
+}
+
+// valueExpr 生成单个标签值对应的查询条件
+func (d tagDimension) valueExpr(sc tagScope, value string) clause.Expr {
+	col := d.Column
+	switch d.Match {
+	case tagMatchCategory:
+		id, _ := strconv.ParseInt(value, 10, 64)
+		return clause.Expr{SQL: "cid IN ?", Vars: []interface{}{sc.categoryIds(id)}}
+	case tagMatchSerial:
+		return clause.Expr{SQL: "is_end = ?", Vars: []interface{}{value == SerialEnd}}
+	case tagMatchRange:
+		// min-max 任意一侧为空时不限, 例: 2010-2019 | 7- | -2000 | 2010~2019
+		if lo, hi, ok := cutRange(value); ok {
+			var sl []string
+			var vars []interface{}
+			for _, b := range []struct{ v, op string }{{lo, ">="}, {hi, "<="}} {
+				if n, err := strconv.ParseFloat(strings.TrimSpace(b.v), 64); err == nil {
+					sl = append(sl, fmt.Sprintf("%s %s ?", col, b.op))
+					vars = append(vars, n)
+				}
+			}
+			if len(sl) == 0 {
+				return clause.Expr{SQL: "1 = 1"}
+			}
+			return clause.Expr{SQL: strings.Join(sl, " AND "), Vars: vars}
+		}
+		n, _ := strconv.ParseFloat(value, 64)
+		return clause.Expr{SQL: col + " = ?", Vars: []interface{}{n}}
+	}
+	// 其它 为不属于任何已记录标签的影片
+	if strings.EqualFold(value, "其它") {
+		ts := d.tags(sc.pid)
+		if len(ts) == 0 {
+			return clause.Expr{SQL: "1 = 1"}
+		}
+		if d.Match == tagMatchExact {
+			return clause.Expr{SQL: col + " NOT IN ?", Vars: []interface{}{ts}}
+		}
+		var sl []string
+		var vars []interface{}
+		for _, t := range ts {
+			sl = append(sl, db.NotLike(col))
+			vars = append(vars, fmt.Sprintf("%%%v%%", t))
+		}
+		return clause.Expr{SQL: strings.Join(sl, " AND "), Vars: vars}
+	}
+	if d.Match == tagMatchLike {
+		return clause.Expr{SQL: db.Like(col), Vars: []interface{}{fmt.Sprintf("%%%v%%", value)}}
+	}
+	return clause.Expr{SQL: col + " = ?", Vars: []interface{}{value}}
+}
+
+// filterExpr 生成维度筛选条件对应的查询条件, 条件为空时返回 false
+func (d tagDimension) filterExpr(sc tagScope, f TagFilter) (clause.Expr, bool) {
+	var sl []string
+	var vars []interface{}
+	join := func(values []string) string {
+		var el []string
+		for _, v := range values {
+			e := d.valueExpr(sc, v)
+			el = append(el, "("+e.SQL+")")
+			vars = append(vars, e.Vars...)
+		}
+		return strings.Join(el, " OR ")
+	}
+	if len(f.Values) > 0 {
+		sl = append(sl, "("+join(f.Values)+")")
+	}
+	if len(f.Excludes) > 0 {
+		sl = append(sl, "NOT ("+join(f.Excludes)+")")
+	}
+	if len(sl) == 0 {
+		return clause.Expr{}, false
+	}
+	return clause.Expr{SQL: strings.Join(sl, " AND "), Vars: vars}, true
+}
+
+// searchTagsQuery 生成满足标签筛选条件的查询, skip 为需要忽略筛选条件的维度
+func searchTagsQuery(t Tenant, sc tagScope, st SearchTagsVO, skip string) *gorm.DB {
+	qw := db.Mdb.Model(&SearchInfo{}).Scopes(VisibleFilm(t))
+	if st.Pid > 0 {
+		qw = qw.Where("pid = ?", st.Pid)
+	}
+	for _, d := range tagDimensions {
+		if d.Title == skip {
+			continue
+		}
+		if e, ok := d.filterExpr(sc, d.Filter(st)); ok {
+			qw = qw.Where(e.SQL, e.Vars...)
+		}
+	}
+	if st.Actor != "" {
+		qw = qw.Where("mid IN (?)", personFilmMids(st.Actor, RoleActor))
+	}
+	return qw
+}
+
+// GetSearchFacets 统计当前筛选条件下各维度选项的影片数量, 返回 {维度: {选项值: 数量}}, 选项值为空表示该维度不做限制时的数量
+// 统计结果按站点与筛选条件缓存 SearchFacetsExpired
+func GetSearchFacets(t Tenant, st SearchTagsVO) map[string]map[string]int64 {
+	// 排序方式不影响统计结果
+	st.Sort = ""
+	data, _ := json.Marshal(st)
+	h := fnv.New64a()
+	_, _ = h.Write(data)
+	key := t.Key(fmt.Sprintf(config.SearchFacetsKey, strconv.FormatUint(h.Sum64(), 16)))
+	res := make(map[string]map[string]int64)
+	if cache, err := db.Rdb.Get(db.Cxt, key).Bytes(); err == nil && json.Unmarshal(cache, &res) == nil {
+		return res
+	}
+	sc := newTagScope(st.Pid)
+	for _, d := range tagDimensions {
+		options := d.options(sc)
+		// 每个选项使用 COUNT(CASE WHEN ...) 统计, 单次查询获取维度所有选项的数量
+		sl := []string{"COUNT(*)"}
+		var vars []interface{}
+		for _, o := range options {
+			e := d.valueExpr(sc, o)
+			sl = append(sl, fmt.Sprintf("COUNT(CASE WHEN %s THEN 1 END)", e.SQL))
+			vars = append(vars, e.Vars...)
+		}
+		counts := make([]int64, len(sl))
+		dest := make([]interface{}, len(sl))
+		for i := range counts {
+			dest[i] = &counts[i]
+		}
+		if err := searchTagsQuery(t, sc, st, d.Title).Select(strings.Join(sl, ", "), vars...).Row().Scan(dest...); err != nil {
+			log.Println("Search Facets Error: ", err)
+			continue
+		}
+		m := map[string]int64{"": counts[0]}
+		for i, o := range options {
+			m[o] = counts[i+1]
+		}
+		res[d.Title] = m
+	}
+	if data, err := json.Marshal(res); err == nil {
+		db.Rdb.Set(db.Cxt, key, data, config.SearchFacetsExpired)
+	}
+	return res
+}
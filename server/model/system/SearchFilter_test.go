@@ -0,0 +1,69 @@
+package system
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTagFilter(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want TagFilter
+	}{
+		{"多个值", "日本,韩国", TagFilter{Values: []string{"日本", "韩国"}}},
+		{"排除条件", "!美国", TagFilter{Excludes: []string{"美国"}}},
+		{"筛选与排除", "日本,!美国", TagFilter{Values: []string{"日本"}, Excludes: []string{"美国"}}},
+		{"去除空白", " 日本 , ! 美国 ", TagFilter{Values: []string{"日本"}, Excludes: []string{"美国"}}},
+		{"全角分隔符与感叹号", "日本，韩国,！美国", TagFilter{Values: []string{"日本", "韩国"}, Excludes: []string{"美国"}}},
+		{"连续分隔符", ",,日本,,", TagFilter{Values: []string{"日本"}}},
+		{"范围条件", "2010-2019,7-", TagFilter{Values: []string{"2010-2019", "7-"}}},
+		{"仅保留第一个感叹号", "!!美国", TagFilter{Excludes: []string{"!美国"}}},
+		{"空排除条件", "!, ！ ,!", TagFilter{}},
+		{"仅包含分隔符", " , ，", TagFilter{}},
+		{"空字符串", "", TagFilter{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseTagFilter(tt.in); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseTagFilter(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTagDimensionRangeExpr(t *testing.T) {
+	var year tagDimension
+	for _, d := range tagDimensions {
+		if d.Title == "Year" {
+			year = d
+		}
+	}
+	tests := []struct {
+		name     string
+		in       string
+		wantSQL  string
+		wantVars []interface{}
+	}{
+		{"闭区间", "2010-2019", "year >= ? AND year <= ?", []interface{}{2010.0, 2019.0}},
+		{"下限", "2010-", "year >= ?", []interface{}{2010.0}},
+		{"上限", "-2000", "year <= ?", []interface{}{2000.0}},
+		{"范围两侧空白", " 2010 - 2019 ", "year >= ? AND year <= ?", []interface{}{2010.0, 2019.0}},
+		{"短破折号", "2010–2019", "year >= ? AND year <= ?", []interface{}{2010.0, 2019.0}},
+		{"波浪号", "2010~2019", "year >= ? AND year <= ?", []interface{}{2010.0, 2019.0}},
+		{"波浪号下限", "2010~", "year >= ?", []interface{}{2010.0}},
+		{"短破折号上限", "–2000", "year <= ?", []interface{}{2000.0}},
+		{"单个值", "2020", "year = ?", []interface{}{2020.0}},
+		{"两侧均为空", "-", "1 = 1", nil},
+		{"两侧均无效", "abc-xyz", "1 = 1", nil},
+		{"一侧无效", "abc-2019", "year <= ?", []interface{}{2019.0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := year.valueExpr(tagScope{}, tt.in)
+			if e.SQL != tt.wantSQL || !reflect.DeepEqual(e.Vars, tt.wantVars) {
+				t.Errorf("valueExpr(%q) = %q %v, want %q %v", tt.in, e.SQL, e.Vars, tt.wantSQL, tt.wantVars)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package system
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"server/config"
+	"server/plugin/db"
+	"time"
+	"unicode/utf8"
+)
+
+/*
+	采集站搜索
+	本地影片库中没有搜索结果时, 在后台通过 wd 参数向已启用的采集站搜索影片, 主站点的结果按正常流程入库, 附属站点的结果仅保存播放列表
+	同一关键字在 SearchLookupExpired 内只搜索一次, 所有关键字每分钟最多触发 SearchLookupRateLimit 次, 前端通过关键字轮询搜索状态
+*/
+
+const (
+	LookupPending = "pending" // 等待执行
+	LookupRunning = "running" // 搜索中
+	LookupDone    = "done"    // 搜索完成
+	LookupFailed  = "failed"  // 所有采集站均请求失败
+)
+
+// SearchLookup 采集站搜索任务状态
+type SearchLookup struct {
+	Keyword    string `json:"keyword"`    // 规范化后的关键字
+	Status     string `json:"status"`     // 任务状态 pending | running | done | failed
+	Found      int    `json:"found"`      // 主站点中获取并保存的影片数量
+	UpdateTime int64  `json:"updateTime"` // 状态更新时间
+}
+
+// StartSearchLookup 尝试创建关键字的采集站搜索任务, started 为 false 时返回已存在的任务状态
+// 关键字无效或超出每分钟的触发次数时返回 nil
+func StartSearchLookup(keyword string) (l *SearchLookup, started bool) {
+	k := NormalizeKeyword(keyword)
+	if utf8.RuneCountInString(k) < 2 || utf8.RuneCountInString(k) > config.SearchKeywordMaxLength {
+		return nil, false
+	}
+	l = &SearchLookup{Keyword: k, Status: LookupPending, UpdateTime: time.Now().Unix()}
+	data, _ := json.Marshal(l)
+	key := fmt.Sprintf(config.SearchLookupKey, k)
+	ok, err := db.Rdb.SetNX(db.Cxt, key, data, config.SearchLookupExpired).Result()
+	if err != nil {
+		log.Println("Start Search Lookup Error: ", err)
+		return nil, false
+	}
+	if !ok {
+		return GetSearchLookup(k), false
+	}
+	// 超出每分钟的触发次数时撤销任务, 以便之后的搜索重新触发
+	rateKey := fmt.Sprintf(config.SearchLookupRateKey, time.Now().Format("200601021504"))
+	pipe := db.Rdb.TxPipeline()
+	count := pipe.Incr(db.Cxt, rateKey)
+	pipe.Expire(db.Cxt, rateKey, time.Minute*2)
+	if _, err = pipe.Exec(db.Cxt); err != nil || count.Val() > config.SearchLookupRateLimit {
+		db.Rdb.Del(db.Cxt, key)
+		return nil, false
+	}
+	return l, true
+}
+
+// SaveSearchLookup 更新采集站搜索任务状态
+func SaveSearchLookup(l SearchLookup) {
+	l.UpdateTime = time.Now().Unix()
+	data, _ := json.Marshal(l)
+	if err := db.Rdb.Set(db.Cxt, fmt.Sprintf(config.SearchLookupKey, l.Keyword), data, config.SearchLookupExpired).Err(); err != nil {
+		log.Println("Save Search Lookup Error: ", err)
+	}
+}
+
+// GetSearchLookup 获取关键字对应的采集站搜索任务状态, 不存在时返回 nil
+func GetSearchLookup(keyword string) *SearchLookup {
+	data, err := db.Rdb.Get(db.Cxt, fmt.Sprintf(config.SearchLookupKey, NormalizeKeyword(keyword))).Bytes()
+	if err != nil {
+		return nil
+	}
+	l := &SearchLookup{}
+	if err = json.Unmarshal(data, l); err != nil {
+		return nil
+	}
+	return l
+}
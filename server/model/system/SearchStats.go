@@ -0,0 +1,118 @@
+package system
+
+import (
+	"fmt"
+	"log"
+	"server/config"
+	"server/plugin/common/util"
+	"server/plugin/db"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/redis/go-redis/v9"
+)
+
+/*
+	搜索统计
+	每日的搜索关键字与无结果的搜索关键字分别使用有序集合记录次数, 关键字当日最近一次的结果数量使用哈希表记录, 均保留 SearchStatsExpired
+	多日的统计通过 ZUNIONSTORE 合并后短暂缓存, 结果数量取最近一次搜索当天的记录, 用于热门搜索过滤与无结果报表展示
+*/
+
+// KeywordStat 搜索关键字统计信息
+type KeywordStat struct {
+	Keyword string `json:"keyword"` // 规范化后的关键字
+	Count   int64  `json:"count"`   // 统计周期内的搜索次数
+	Results int    `json:"results"` // 最近一次搜索的结果数量
+}
+
+// NormalizeKeyword 规范化搜索关键字, 繁体字转换为简体字, 英文字母转换为小写, 连续的空白符合并为一个空格
+func NormalizeKeyword(keyword string) string {
+	return strings.Join(strings.Fields(util.ToSimplified(strings.ToLower(keyword))), " ")
+}
+
+// RecordSearchKeyword 记录搜索关键字以及搜索结果数量
+func RecordSearchKeyword(keyword string, results int) {
+	k := NormalizeKeyword(keyword)
+	if k == "" || utf8.RuneCountInString(k) > config.SearchKeywordMaxLength {
+		return
+	}
+	day := time.Now().Format("20060102")
+	pipe := db.Rdb.Pipeline()
+	keys := []string{fmt.Sprintf(config.SearchKeywordKey, day)}
+	if results == 0 {
+		keys = append(keys, fmt.Sprintf(config.SearchZeroKey, day))
+	}
+	for _, key := range keys {
+		pipe.ZIncrBy(db.Cxt, key, 1, k)
+		pipe.Expire(db.Cxt, key, config.SearchStatsExpired)
+	}
+	resultKey := fmt.Sprintf(config.SearchResultKey, day)
+	pipe.HSet(db.Cxt, resultKey, k, results)
+	pipe.Expire(db.Cxt, resultKey, config.SearchStatsExpired)
+	if _, err := pipe.Exec(db.Cxt); err != nil {
+		log.Println("Record Search Keyword Error: ", err)
+	}
+}
+
+// GetHotKeywords 获取最近 days 天搜索次数最多且存在搜索结果的关键字
+func GetHotKeywords(days, n int) []KeywordStat {
+	var res []KeywordStat
+	// 多取一部分关键字, 用于补足过滤无结果关键字后的数量
+	for _, s := range keywordStats(config.SearchKeywordKey, "Keyword", days, n*2) {
+		if s.Results > 0 {
+			res = append(res, s)
+		}
+	}
+	return res[:min(n, len(res))]
+}
+
+// GetZeroKeywords 获取最近 days 天无搜索结果的关键字, 按无结果的搜索次数倒序排列
+func GetZeroKeywords(days, n int) []KeywordStat {
+	return keywordStats(config.SearchZeroKey, "Zero", days, n)
+}
+
+// keywordStats 合并最近 days 天的统计数据并获取次数最多的 n 个关键字
+func keywordStats(format, name string, days, n int) []KeywordStat {
+	days = min(max(days, 1), int(config.SearchStatsExpired/(time.Hour*24)))
+	key := fmt.Sprintf(format, time.Now().Format("20060102"))
+	if days > 1 {
+		key = fmt.Sprintf(config.SearchStatsUnionKey, fmt.Sprintf("%s:%d", name, days))
+		if db.Rdb.Exists(db.Cxt, key).Val() == 0 {
+			var keys []string
+			for i := 0; i < days; i++ {
+				keys = append(keys, fmt.Sprintf(format, time.Now().AddDate(0, 0, -i).Format("20060102")))
+			}
+			pipe := db.Rdb.TxPipeline()
+			pipe.ZUnionStore(db.Cxt, key, &redis.ZStore{Keys: keys})
+			pipe.Expire(db.Cxt, key, config.SearchStatsUnionExpired)
+			if _, err := pipe.Exec(db.Cxt); err != nil {
+				log.Println("Search Stats Union Error: ", err)
+				return nil
+			}
+		}
+	}
+	zl, err := db.Rdb.ZRevRangeWithScores(db.Cxt, key, 0, int64(n-1)).Result()
+	if err != nil || len(zl) == 0 {
+		return nil
+	}
+	var res []KeywordStat
+	var fields []string
+	for _, z := range zl {
+		res = append(res, KeywordStat{Keyword: z.Member.(string), Count: int64(z.Score)})
+		fields = append(fields, z.Member.(string))
+	}
+	// 由近到远查找关键字最近一次的结果数量
+	found := make([]bool, len(res))
+	for i := 0; i < days && len(fields) > 0; i++ {
+		vl := db.Rdb.HMGet(db.Cxt, fmt.Sprintf(config.SearchResultKey, time.Now().AddDate(0, 0, -i).Format("20060102")), fields...).Val()
+		for j, v := range vl {
+			if s, ok := v.(string); ok && !found[j] {
+				found[j] = true
+				res[j].Results, _ = strconv.Atoi(s)
+			}
+		}
+	}
+	return res
+}
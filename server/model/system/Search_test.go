@@ -0,0 +1,66 @@
+package system
+
+import (
+	"fmt"
+	"testing"
+
+	"server/config"
+)
+
+// searchable 影片是否存在检索信息且能够通过片名搜索到
+func searchable(t *testing.T, mid int64, name string) bool {
+	t.Helper()
+	if GetSearchInfoByMid(mid) == nil {
+		return false
+	}
+	page := &Page{PageSize: 10, Current: 1}
+	for _, h := range SearchFilmText(DefaultTenant(), name, false, page) {
+		if h.Mid == mid {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLocalFilmSurvivesFullSync(t *testing.T) {
+	local := MovieDetail{Id: config.LocalFilmIdStart + 1, Cid: 6, Pid: 1, Name: "本地导入影片",
+		PlayFrom: []string{"local"}, PlayList: [][]MovieUrlInfo{{{Episode: "正片", Link: "http://a/1.m3u8"}}}}
+	collected := MovieDetail{Id: 101, Cid: 6, Pid: 1, Name: "采集站影片",
+		PlayList: [][]MovieUrlInfo{{{Episode: "正片", Link: "http://b/1.m3u8"}}}}
+
+	// 与导入影片相同的保存流程
+	if err := SaveDetails([]MovieDetail{local}); err != nil {
+		t.Fatalf("SaveDetails(local) error = %v", err)
+	}
+	SyncSearchInfo(1)
+	if !searchable(t, local.Id, local.Name) {
+		t.Fatalf("imported film %d is not searchable", local.Id)
+	}
+
+	tests := []struct {
+		name string
+		run  func()
+	}{
+		{"全量同步", func() {
+			if err := SaveDetails([]MovieDetail{collected}); err != nil {
+				t.Fatalf("SaveDetails(collected) error = %v", err)
+			}
+			SyncSearchInfo(0)
+		}},
+		{"清空采集数据", FilmZero},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run()
+			if !searchable(t, local.Id, local.Name) {
+				t.Errorf("imported film %d is not searchable after %s", local.Id, tt.name)
+			}
+			if d := GetDetailByKey(fmt.Sprintf(config.MovieDetailKey, local.Cid, local.Id)); d.Id != local.Id {
+				t.Errorf("imported film %d detail was removed after %s", local.Id, tt.name)
+			}
+		})
+	}
+	if searchable(t, collected.Id, collected.Name) {
+		t.Errorf("collected film %d is still searchable after FilmZero", collected.Id)
+	}
+}
@@ -0,0 +1,214 @@
+package system
+
+import (
+	"encoding/json"
+	"errors"
+	"server/config"
+	"server/plugin/common/util"
+	"server/plugin/db"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+/*
+	多站点(租户)信息
+	同一服务进程为多个站点提供服务, 每个站点拥有独立的网站配置, 轮播, 首页缓存, 分类展示状态以及播放源范围
+	已采集的影片数据由所有站点共享, 站点通过 Sources 限定可展示的采集站播放源
+*/
+
+// Tenant 站点信息
+type Tenant struct {
+	Id           string         `json:"id"`           // 站点唯一标识, 默认站点为 default
+	Name         string         `json:"name"`         // 站点名称
+	Sources      []string       `json:"sources"`      // 站点可用的采集站Id, ShareLibrary 为 false 时生效
+	ShareLibrary bool           `json:"shareLibrary"` // 是否共享全部采集站的播放源
+	Categories   map[int64]bool `json:"categories"`   // 分类展示状态, 覆盖全局分类树中的 show 属性
+	Remark       string         `json:"remark"`       // 备注信息
+}
+
+// DefaultTenant 默认站点, 未匹配到其他站点的请求均归属于默认站点
+func DefaultTenant() Tenant {
+	return Tenant{Id: config.DefaultTenantId, Name: "默认站点", ShareLibrary: true}
+}
+
+// Key 获取当前站点对应的 redis key
+func (t Tenant) Key(key string) string {
+	return config.TenantKey(t.Id, key)
+}
+
+// UseSource 当前站点是否展示指定采集站的播放源
+func (t Tenant) UseSource(id string) bool {
+	if t.ShareLibrary {
+		return true
+	}
+	for _, s := range t.Sources {
+		if s == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Visible 分类在当前站点中是否展示, 未单独设置时沿用全局分类的展示状态
+func (t Tenant) Visible(c *Category) bool {
+	if show, ok := t.Categories[c.Id]; ok {
+		return show
+	}
+	return c.Show
+}
+
+// CategoryTree 获取应用当前站点展示状态后的分类树
+func (t Tenant) CategoryTree(tree CategoryTree) CategoryTree {
+	if len(t.Categories) == 0 || tree.Category == nil {
+		return tree
+	}
+	return *t.applyVisible(&tree)
+}
+
+// applyVisible 复制分类树节点并覆盖展示状态, 避免修改原始分类树
+func (t Tenant) applyVisible(node *CategoryTree) *CategoryTree {
+	c := *node.Category
+	c.Show = t.Visible(node.Category)
+	res := &CategoryTree{Category: &c}
+	for _, child := range node.Children {
+		res.Children = append(res.Children, t.applyVisible(child))
+	}
+	return res
+}
+
+// ------------------------------------------------------ Redis ------------------------------------------------------
+
+// SaveTenant 保存站点信息 {Id: Tenant}
+func SaveTenant(t Tenant) error {
+	data, _ := json.Marshal(t)
+	err := db.Rdb.HSet(db.Cxt, config.TenantListKey, t.Id, data).Err()
+	resetTenantResolver()
+	ResetFilmVisibility()
+	return err
+}
+
+// GetTenants 获取所有站点信息, 默认站点始终位于首位
+func GetTenants() []Tenant {
+	var tl = []Tenant{GetTenantById(config.DefaultTenantId)}
+	tMap := db.Rdb.HGetAll(db.Cxt, config.TenantListKey).Val()
+	for id, v := range tMap {
+		if id == config.DefaultTenantId {
+			continue
+		}
+		var t = Tenant{}
+		if err := json.Unmarshal([]byte(v), &t); err == nil {
+			tl = append(tl, t)
+		}
+	}
+	return tl
+}
+
+// GetTenantById 通过Id获取站点信息, 默认站点未保存时返回默认配置
+func GetTenantById(id string) Tenant {
+	data, err := db.Rdb.HGet(db.Cxt, config.TenantListKey, id).Result()
+	if err != nil {
+		if id == config.DefaultTenantId {
+			return DefaultTenant()
+		}
+		return Tenant{}
+	}
+	var t = Tenant{}
+	_ = json.Unmarshal([]byte(data), &t)
+	return t
+}
+
+// ExistTenant 站点信息是否存在
+func ExistTenant(id string) bool {
+	return id == config.DefaultTenantId || db.Rdb.HExists(db.Cxt, config.TenantListKey, id).Val()
+}
+
+// DelTenant 删除站点信息以及站点独立的配置数据
+func DelTenant(id string) error {
+	if id == config.DefaultTenantId {
+		return errors.New("默认站点不允许删除")
+	}
+	db.Rdb.HDel(db.Cxt, config.TenantListKey, id)
+	_, err := db.UnlinkKeys(config.TenantKey(id, config.KeyPrefix)+"*", nil)
+	resetTenantResolver()
+	ResetFilmVisibility()
+	return err
+}
+
+// ------------------------------------------------------ 站点解析 ------------------------------------------------------
+
+// tenantResolver 站点信息以及 域名 => 站点Id 映射的本地缓存, 避免每次请求都读取站点配置
+var tenantResolver = struct {
+	sync.RWMutex
+	tenants map[string]Tenant
+	hosts   map[string]string
+	expired time.Time
+}{}
+
+// resetTenantResolver 站点信息或站点域名变更后清除本地缓存
+func resetTenantResolver() {
+	tenantResolver.Lock()
+	tenantResolver.tenants, tenantResolver.hosts = nil, nil
+	tenantResolver.Unlock()
+}
+
+// loadTenantResolver 获取站点信息与域名映射, 缓存过期后一次性读取全部站点配置
+func loadTenantResolver() (map[string]Tenant, map[string]string) {
+	tenantResolver.RLock()
+	tenants, hosts, expired := tenantResolver.tenants, tenantResolver.hosts, tenantResolver.expired
+	tenantResolver.RUnlock()
+	if tenants != nil && time.Now().Before(expired) {
+		return tenants, hosts
+	}
+	tl := GetTenants()
+	tenants = make(map[string]Tenant, len(tl))
+	for _, t := range tl {
+		tenants[t.Id] = t
+	}
+	hosts = tenantHosts(tl)
+	tenantResolver.Lock()
+	tenantResolver.tenants, tenantResolver.hosts = tenants, hosts
+	tenantResolver.expired = time.Now().Add(config.TenantResolveExpired)
+	tenantResolver.Unlock()
+	return tenants, hosts
+}
+
+// ResolveTenant 解析请求所属的站点, 优先使用请求头指定的站点Id, 其次通过请求域名匹配站点配置中的 Domain
+// 站点Id不存在或域名未绑定站点时归属于默认站点
+func ResolveTenant(host, id string) Tenant {
+	tenants, hosts := loadTenantResolver()
+	if t, ok := tenants[id]; ok {
+		return t
+	}
+	if t, ok := tenants[hosts[util.HostName(host)]]; ok {
+		return t
+	}
+	return tenants[config.DefaultTenantId]
+}
+
+// FindTenantByHost 查找绑定了指定域名的站点Id, 未绑定时返回空字符串
+func FindTenantByHost(domain string) string {
+	return tenantHosts(GetTenants())[util.HostName(domain)]
+}
+
+// tenantHosts 获取站点配置中的域名信息
+func tenantHosts(tl []Tenant) map[string]string {
+	hosts := make(map[string]string)
+	for _, t := range tl {
+		if h := util.HostName(GetSiteBasic(t.Id).Domain); h != "" {
+			hosts[h] = t.Id
+		}
+	}
+	return hosts
+}
+
+// CurrentTenant 获取当前请求所属的站点信息
+func CurrentTenant(c *gin.Context) Tenant {
+	if v, ok := c.Get(config.TenantCtxKey); ok {
+		if t, ok := v.(Tenant); ok {
+			return t
+		}
+	}
+	return GetTenantById(config.DefaultTenantId)
+}
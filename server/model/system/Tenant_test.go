@@ -0,0 +1,120 @@
+package system
+
+import (
+	"testing"
+
+	"server/config"
+	"server/plugin/db"
+)
+
+func TestResolveTenant(t *testing.T) {
+	if err := SaveTenant(Tenant{Id: "t1", Name: "站点一"}); err != nil {
+		t.Fatalf("SaveTenant error = %v", err)
+	}
+	if err := SaveSiteBasic("t1", BasicConfig{Domain: "https://T1.example.com"}); err != nil {
+		t.Fatalf("SaveSiteBasic error = %v", err)
+	}
+	defer func() { _ = DelTenant("t1") }()
+
+	tests := []struct {
+		name string
+		host string
+		id   string
+		want string
+	}{
+		{"请求头指定站点", "other.example.com", "t1", "t1"},
+		{"请求头指定默认站点", "t1.example.com", config.DefaultTenantId, config.DefaultTenantId},
+		{"请求头站点不存在时匹配域名", "t1.example.com", "missing", "t1"},
+		{"域名忽略大小写与端口", "T1.Example.com:8080", "", "t1"},
+		{"域名未绑定站点", "other.example.com", "", config.DefaultTenantId},
+		{"请求头站点不存在且域名未绑定", "", "missing", config.DefaultTenantId},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ResolveTenant(tt.host, tt.id); got.Id != tt.want {
+				t.Errorf("ResolveTenant(%q, %q) = %q, want %q", tt.host, tt.id, got.Id, tt.want)
+			}
+		})
+	}
+
+	// 站点信息在缓存有效期内不再读取 redis, 通过站点管理接口修改时立即失效
+	db.Rdb.HDel(db.Cxt, config.TenantListKey, "t1")
+	if got := ResolveTenant("", "t1"); got.Id != "t1" {
+		t.Errorf("ResolveTenant with cached tenants = %q, want t1", got.Id)
+	}
+	resetTenantResolver()
+	if got := ResolveTenant("", "t1"); got.Id != config.DefaultTenantId {
+		t.Errorf("ResolveTenant after reset = %q, want %q", got.Id, config.DefaultTenantId)
+	}
+}
+
+func TestTenantUseSource(t *testing.T) {
+	tests := []struct {
+		name   string
+		tenant Tenant
+		id     string
+		want   bool
+	}{
+		{"共享全部播放源", Tenant{ShareLibrary: true}, "s1", true},
+		{"共享时忽略播放源范围", Tenant{ShareLibrary: true, Sources: []string{"s2"}}, "s1", true},
+		{"播放源在范围内", Tenant{Sources: []string{"s1", "s2"}}, "s2", true},
+		{"播放源不在范围内", Tenant{Sources: []string{"s2"}}, "s1", false},
+		{"未设置播放源范围", Tenant{}, "s1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.tenant.UseSource(tt.id); got != tt.want {
+				t.Errorf("UseSource(%q) = %t, want %t", tt.id, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTenantCategoryTree(t *testing.T) {
+	tree := CategoryTree{Category: &Category{Id: 0, Name: "分类信息", Show: true}, Children: []*CategoryTree{
+		{Category: &Category{Id: 1, Name: "电影", Show: true}, Children: []*CategoryTree{
+			{Category: &Category{Id: 6, Pid: 1, Name: "动作片", Show: true}},
+			{Category: &Category{Id: 7, Pid: 1, Name: "喜剧片", Show: false}},
+		}},
+		{Category: &Category{Id: 2, Name: "电视剧", Show: true}},
+	}}
+	show := func(tree CategoryTree) map[int64]bool {
+		res := make(map[int64]bool)
+		var walk func(n *CategoryTree)
+		walk = func(n *CategoryTree) {
+			res[n.Id] = n.Show
+			for _, c := range n.Children {
+				walk(c)
+			}
+		}
+		walk(&tree)
+		return res
+	}
+	global := show(tree)
+
+	tests := []struct {
+		name       string
+		categories map[int64]bool
+		want       map[int64]bool
+	}{
+		{"未单独设置时沿用全局状态", nil, map[int64]bool{0: true, 1: true, 6: true, 7: false, 2: true}},
+		{"隐藏分类", map[int64]bool{6: false, 2: false}, map[int64]bool{0: true, 1: true, 6: false, 7: false, 2: false}},
+		{"展示全局隐藏的分类", map[int64]bool{7: true}, map[int64]bool{0: true, 1: true, 6: true, 7: true, 2: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := show(Tenant{Id: "t1", Categories: tt.categories}.CategoryTree(tree))
+			for id, want := range tt.want {
+				if got[id] != want {
+					t.Errorf("category %d show = %t, want %t", id, got[id], want)
+				}
+			}
+			// 不修改全局分类树
+			for id, want := range global {
+				if s := show(tree)[id]; s != want {
+					t.Errorf("global category %d show changed to %t", id, s)
+				}
+			}
+		})
+	}
+}
@@ -1,7 +1,6 @@
 package system
 
 import (
-	"fmt"
 	"log"
 	"server/config"
 	"server/plugin/common/util"
@@ -31,24 +30,6 @@ func (u *User) TableName() string {
 	return config.UserTableName
 }
 
-// CreateUserTable 创建存储检索信息的数据表
-func CreateUserTable() {
-	var u = &User{}
-	// 如果不存在则创建表 并设置自增ID初始值为10000
-	if !ExistUserTable() {
-		err := db.Mdb.AutoMigrate(u)
-		db.Mdb.Exec(fmt.Sprintf("alter table %s auto_Increment = %d", u.TableName(), config.UserIdInitialVal))
-		if err != nil {
-			log.Println("Create Table SearchInfo Failed: ", err)
-		}
-	}
-}
-
-// ExistUserTable 判断表中是否存在User表
-func ExistUserTable() bool {
-	return db.Mdb.Migrator().HasTable(&User{})
-}
-
 // InitAdminAccount 初始化admin用户密码
 func InitAdminAccount() {
 	// 先查询是否已经存在admin用户信息, 存在则直接退出
@@ -1,16 +1,25 @@
 package system
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // SearchTagsVO 搜索标签请求参数
 type SearchTagsVO struct {
-	Pid      int64  `json:"pid"`
-	Cid      int64  `json:"cid"`
-	Plot     string `json:"plot"`
-	Area     string `json:"area"`
-	Language string `json:"language"`
-	Year     int64  `json:"year"`
-	Sort     string `json:"sort"`
+	Pid      int64     `json:"pid"`
+	Category TagFilter `json:"category"` // 子分类ID
+	Plot     TagFilter `json:"plot"`
+	Area     TagFilter `json:"area"`
+	Language TagFilter `json:"language"`
+	Year     TagFilter `json:"year"`  // 年份, 支持范围 2010-2019
+	Score    TagFilter `json:"score"` // 评分范围, 例: 7- 表示不低于7分
+	Actor    string    `json:"actor"`
+	Status   TagFilter `json:"status"`   // 完结 | 连载
+	Quality  TagFilter `json:"quality"`  // 画质
+	Version  TagFilter `json:"version"`  // 版本
+	Subtitle TagFilter `json:"subtitle"` // 字幕
+	Sort     string    `json:"sort"`
 }
 
 // FilmCronVo 影视更新任务请求参数
@@ -99,6 +108,30 @@ type FilmDetailVo struct {
 	Content      string   `json:"content"`      //内容简介
 }
 
+// FilmImportItem 导入文件中的单部影片的解析结果
+type FilmImportItem struct {
+	Index  int         `json:"index"`  // 影片在导入文件中的序号, 从 1 开始
+	Detail MovieDetail `json:"detail"` // 解析得到的影片详情
+	Errors []string    `json:"errors"` // 校验错误, 存在错误的影片不会被保存
+}
+
+// FilmImportResult 影片导入结果
+type FilmImportResult struct {
+	Format  string           `json:"format"`  // 导入格式 m3u | text | csv | json
+	Preview bool             `json:"preview"` // 是否仅预览, 预览时不保存影片
+	Total   int              `json:"total"`   // 解析出的影片数量
+	Valid   int              `json:"valid"`   // 校验通过的影片数量
+	Saved   int              `json:"saved"`   // 已保存的影片数量
+	Items   []FilmImportItem `json:"items"`   // 各影片的解析结果
+}
+
+// FilmEditVo 影片编辑请求参数
+type FilmEditVo struct {
+	Id     int64                      `json:"id"`     // 影片id
+	Fields map[string]json.RawMessage `json:"fields"` // 修改的字段 {json字段名: 值}, 值为 null 时撤销该字段的修改
+	Locks  []string                   `json:"locks"`  // 编辑后锁定的全部字段, 不传则在原有基础上锁定本次修改的字段
+}
+
 // UserInfoVo 用户信息返回对象
 type UserInfoVo struct {
 	Id       uint   `json:"id"`
@@ -120,7 +153,37 @@ type PlayLinkVo struct {
 // MovieDetailVo 影片详情数据, 播放源合并版
 type MovieDetailVo struct {
 	MovieDetail
-	List []PlayLinkVo `json:"list"`
+	List    []PlayLinkVo   `json:"list"`
+	Persons []FilmPersonVo `json:"persons"` // 影片中的影人信息
+	Roles   []FilmRoleVo   `json:"roles"`   // 采集的影片角色信息
+}
+
+// CalendarDay 放送表中一天的播出信息
+type CalendarDay struct {
+	Weekday  int               `json:"weekday"`  // 星期 周一为 1
+	Channels []CalendarChannel `json:"channels"` // 按播出电视台 | 平台分组的影片
+}
+
+// CalendarChannel 放送表中同一电视台 | 平台播出的影片, 未知平台的影片 Tv 为空
+type CalendarChannel struct {
+	Tv   string           `json:"tv"`
+	List []MovieBasicInfo `json:"list"`
+}
+
+// FilmSuggestVo 搜索建议
+type FilmSuggestVo struct {
+	Id      int64  `json:"id"`      // 影片ID
+	Name    string `json:"name"`    // 片名
+	Year    string `json:"year"`    // 年份
+	CName   string `json:"cName"`   // 分类名称
+	Picture string `json:"picture"` // 海报
+}
+
+// FilmSearchVo 影片全文检索结果
+type FilmSearchVo struct {
+	MovieBasicInfo
+	Score      float64           `json:"score"`                // 相关度
+	Highlights map[string]string `json:"highlights,omitempty"` // 命中字段的高亮片段
 }
 
 type RecordRequestVo struct {
@@ -132,3 +195,16 @@ type RecordRequestVo struct {
 	EndTime     time.Time `json:"endTime"`     // 结束时间
 	Paging      *Page     `json:"paging"`      // 分页参数
 }
+
+// BackupVo 数据备份 & 恢复请求参数
+type BackupVo struct {
+	Name  string `json:"name"`  // 备份文件名称
+	Scope string `json:"scope"` // 数据范围 all | config | library
+}
+
+// TenantVo 站点信息 & 站点绑定的域名
+type TenantVo struct {
+	Tenant
+	SiteName string `json:"siteName"` // 网站名称
+	Domain   string `json:"domain"`   // 网站域名
+}
@@ -0,0 +1,39 @@
+package system
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"server/config"
+	"server/plugin/db"
+	"server/plugin/migrate"
+)
+
+// TestMain 使用临时目录中的嵌入式存储执行需要数据库的测试用例
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "gofilm-system-")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	config.StorageMode = config.StorageEmbedded
+	config.SqlitePath = filepath.Join(dir, "gofilm.db")
+	config.KVSnapshotPath = filepath.Join(dir, "kv.snapshot")
+	config.KVAppendPath = filepath.Join(dir, "kv.aof")
+	config.MigrateLockPath = filepath.Join(dir, "gofilm.db.migrate.lock")
+	if err = db.InitEmbeddedKV(); err != nil {
+		log.Fatalln(err)
+	}
+	if err = db.InitSqlite(); err != nil {
+		log.Fatalln(err)
+	}
+	if err = migrate.Up(0); err != nil {
+		log.Fatalln(err)
+	}
+	code := m.Run()
+	_ = db.CloseDatabase()
+	db.CloseKV()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
@@ -1,17 +1,17 @@
 package SystemInit
 
-import "server/model/system"
+import (
+	"server/model/system"
+	"server/plugin/migrate"
+)
 
-// TableInIt 初始化 mysql 数据库相关数据
-func TableInIt() {
-	// 创建 User Table
-	system.CreateUserTable()
+// TableInIt 初始化数据库表结构及相关数据
+func TableInIt() error {
+	// 执行所有未应用的数据库版本迁移 (users | search | files | failure_records)
+	if err := migrate.Up(0); err != nil {
+		return err
+	}
 	// 初始化管理员账户
-	system.InitAdminAccount()
-	// 创建 Search Table
-	system.CreateSearchTable()
-	// 创建图片信息管理表
-	system.CreateFileTable()
-	// 创建采集失效记录表
-	system.CreateFailureRecordTable()
+	system.Repo.User.InitAdmin()
+	return nil
 }
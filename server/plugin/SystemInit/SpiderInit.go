@@ -17,7 +17,7 @@ func SpiderInit() {
 // FilmSourceInit  初始化预存站点信息 提供一些预存采集连Api链接
 func FilmSourceInit() {
 	// 首先获取filmSourceList 数据, 如果存在则直接返回
-	if system.ExistCollectSourceList() {
+	if system.Repo.Source.Exist() {
 		return
 	}
 	var l []system.FilmSource = []system.FilmSource{
@@ -37,7 +37,7 @@ func FilmSourceInit() {
 		//{Id: util.GenerateSalt(), Name: "HD(fs)", Uri: `https://www.feisuzyapi.com/api.php/provide/vod/`, ResultModel: system.JsonResult, Grade: system.SlaveCollect, SyncPictures: false, CollectType: system.CollectVideo, State: false},
 		//{Id: util.GenerateSalt(), Name: "HD(bfBk)", Uri: `http://app.bfzyapi.com/api.php/provide/vod/`, ResultModel: system.JsonResult, Grade: system.SlaveCollect, SyncPictures: false, CollectType: system.CollectVideo, State: false},
 	}
-	err := system.SaveCollectSourceList(l)
+	err := system.Repo.Source.SaveList(l)
 	if err != nil {
 		log.Println("SaveSourceApiList Error: ", err)
 	}
@@ -46,9 +46,9 @@ func FilmSourceInit() {
 // CollectCrontabInit 初始化系统预定义的定时任务
 func CollectCrontabInit() {
 	// 如果系统已经存在Task定时任务信息,则将redis中的定时任务信息重新添加到执行队列
-	if system.ExistTask() {
+	if system.Repo.CronTask.Exist() {
 		// 将系统中的定时任务重新设置到 CollectCron中
-		for _, task := range system.GetAllFilmTask() {
+		for _, task := range system.Repo.CronTask.All() {
 			switch task.Model {
 			case 0:
 				cid, err := spider.AddAutoUpdateCron(task.Id, task.Spec)
@@ -78,7 +78,7 @@ func CollectCrontabInit() {
 				// 将定时任务Id记录到Task中
 				task.Cid = cid
 			}
-			system.UpdateFilmTask(task)
+			system.Repo.CronTask.Update(task)
 		}
 	} else {
 		/*
@@ -99,7 +99,7 @@ func CollectCrontabInit() {
 		// 将定时任务Id记录到Task中
 		task.Cid = cid
 		// 如果没有异常则将当前定时任务信息记录到redis中
-		system.SaveFilmTask(task)
+		system.Repo.CronTask.Save(task)
 
 		// 添加一条定时任务-定期处理失败请求
 		recoverTask := system.FilmCollectTask{Id: util.GenerateSalt(), Time: 0, Spec: config.EveryWeekSpec,
@@ -114,7 +114,7 @@ func CollectCrontabInit() {
 		// 将定时任务Id记录到Task中
 		recoverTask.Cid = cid
 		// 如果没有异常则将当前定时任务信息记录到redis中
-		system.SaveFilmTask(recoverTask)
+		system.Repo.CronTask.Save(recoverTask)
 	}
 
 	// 完成初始化后启动 Cron
@@ -20,7 +20,12 @@ func BasicConfigInit() {
 		return
 	}
 
-	var bc = system.BasicConfig{
+	_ = system.SaveSiteBasic(config.DefaultTenantId, DefaultBasicConfig())
+}
+
+// DefaultBasicConfig 网站基本配置的初始值
+func DefaultBasicConfig() system.BasicConfig {
+	return system.BasicConfig{
 		SiteName: "Bracket",
 		Domain:   "http://127.0.0.1:3600",
 		Logo:     "https://s2.loli.net/2023/12/05/O2SEiUcMx5aWlv4.jpg",
@@ -29,7 +34,6 @@ func BasicConfigInit() {
 		State:    true,
 		Hint:     "网站升级中, 暂时无法访问 !!!",
 	}
-	_ = system.SaveSiteBasic(bc)
 }
 
 func BannersInit() {
@@ -45,5 +49,5 @@ func BannersInit() {
 		system.Banner{Id: util.GenerateSalt(), Name: "五等分的花嫁", Year: 2020, CName: "日韩动漫", Poster: "https://s2.loli.net/2024/02/21/wXJr59Zuv4tcKNp.jpg", Picture: "https://img.bfzypic.com/upload/vod/20230424-43/06e79232a4650aea00f7476356a49847.jpg", Remark: "已完结"},
 		system.Banner{Id: util.GenerateSalt(), Name: "我的青春恋爱物语果然有问题", Year: 2020, CName: "日韩动漫", Poster: "https://s2.loli.net/2024/02/21/oMAGzSliK2YbhRu.jpg", Picture: "https://img.bfzypic.com/upload/vod/20230424-43/06e79232a4650aea00f7476356a49847.jpg", Remark: "已完结"},
 	}
-	_ = system.SaveBanners(bl)
+	_ = system.SaveBanners(config.DefaultTenantId, bl)
 }
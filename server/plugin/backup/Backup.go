@@ -0,0 +1,326 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"server/config"
+	"server/plugin/db"
+	"server/plugin/migrate"
+)
+
+/*
+	数据备份与恢复
+	备份文件为 tar.gz 归档, 包含以下内容:
+		manifest.json              备份信息 (格式版本 | 数据库版本 | 备份范围 | 数据量)
+		redis/<scope>.jsonl        GoFilm 使用的 redis key, 按范围拆分
+		tables/<table>.jsonl       数据表的全部记录 (包含软删除的记录)
+	备份范围:
+		config  采集站 | 定时任务 | 网站配置 | 轮播 | 用户
+		library 分类树 | 影片详情 | 多站点播放源 | 检索信息 | 图片 | 采集失败记录
+*/
+
+const (
+	ScopeAll     = "all"
+	ScopeConfig  = "config"
+	ScopeLibrary = "library"
+
+	manifestName = "manifest.json"
+)
+
+// Manifest 备份文件的描述信息
+type Manifest struct {
+	FormatVersion int            `json:"formatVersion"` // 备份文件格式版本
+	SchemaVersion int64          `json:"schemaVersion"` // 备份时的数据库版本
+	Scope         string         `json:"scope"`         // 备份范围
+	Driver        string         `json:"driver"`        // 备份时使用的数据库
+	KeyPrefix     string         `json:"keyPrefix"`     // 备份时使用的 redis key 前缀
+	CreatedAt     time.Time      `json:"createdAt"`     // 备份时间
+	Redis         map[string]int `json:"redis"`         // 各范围导出的 key 数量
+	Tables        map[string]int `json:"tables"`        // 各数据表导出的记录数
+}
+
+// FileInfo 备份文件信息
+type FileInfo struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// redisPatterns 各备份范围对应的 redis key 匹配规则
+var redisPatterns = map[string][]string{
+	ScopeConfig: {
+		config.FilmSourceListKey,
+		config.FilmCrontabKey,
+		config.SiteConfigBasic,
+		config.BannersKey,
+		config.TenantListKey,
+		config.TenantKey("*", config.SiteConfigBasic),
+		config.TenantKey("*", config.BannersKey),
+	},
+	ScopeLibrary: {
+		config.CategoryTreeKey,
+		config.CategoryMappingKey,
+		config.FilmCategoryKey,
+		config.KeyPattern(config.MovieListInfoKey),
+		config.KeyPattern(config.MovieDetailKey),
+		config.KeyPattern(config.MovieBasicInfoKey),
+		config.MovieOverlayKey,
+		config.LocalFilmIdKey,
+		config.KeyPattern(config.MultipleSiteDetail),
+		config.KeyPattern(config.OriginalFilmDetailKey),
+		config.FilmClassKey,
+		config.KeyPattern(config.SearchTitle),
+		config.SearchInfoTemp,
+		config.KeyPattern(config.SearchSuggestKey),
+		config.SearchSuggestFilmKey,
+		config.VirtualPictureKey,
+		config.FilmRedirectKey,
+		config.FilmMergedKey,
+		config.FilmDuplicateKey,
+		config.FilmDuplicateIgnoreKey,
+	},
+}
+
+// ValidScope 校验备份范围
+func ValidScope(scope string) error {
+	switch scope {
+	case ScopeAll, ScopeConfig, ScopeLibrary:
+		return nil
+	default:
+		return fmt.Errorf("备份范围异常: %s, 可选值 %s | %s | %s", scope, ScopeAll, ScopeConfig, ScopeLibrary)
+	}
+}
+
+// scopes 展开备份范围
+func scopes(scope string) []string {
+	if scope == ScopeAll {
+		return []string{ScopeConfig, ScopeLibrary}
+	}
+	return []string{scope}
+}
+
+// Create 备份 scope 范围内的数据, 保存到 config.BackupDir 目录并返回备份文件信息
+func Create(scope string) (FileInfo, error) {
+	if err := ValidScope(scope); err != nil {
+		return FileInfo{}, err
+	}
+	if err := os.MkdirAll(config.BackupDir, os.ModePerm); err != nil {
+		return FileInfo{}, err
+	}
+	name := fmt.Sprintf("gofilm-%s-%s%s", scope, time.Now().Format("20060102-150405"), config.BackupFileSuffix)
+	path := filepath.Join(config.BackupDir, name)
+	// 先写入临时文件, 完成后重命名, 避免生成不完整的备份文件
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	if err = Export(f, scope); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return FileInfo{}, err
+	}
+	if err = f.Close(); err != nil {
+		return FileInfo{}, err
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		return FileInfo{}, err
+	}
+	return stat(name)
+}
+
+// Export 将 scope 范围内的数据以备份文件格式写入 w
+func Export(w io.Writer, scope string) error {
+	// 各部分数据先导出到临时目录, tar 需要预先知道每个文件的大小
+	dir, err := os.MkdirTemp("", "gofilm-backup-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	m := Manifest{
+		FormatVersion: config.BackupFormatVersion,
+		SchemaVersion: migrate.Latest(),
+		Scope:         scope,
+		Driver:        db.Dialect(),
+		KeyPrefix:     config.KeyPrefix,
+		CreatedAt:     time.Now(),
+		Redis:         make(map[string]int),
+		Tables:        make(map[string]int),
+	}
+	var entries []string
+	for _, s := range scopes(scope) {
+		name := fmt.Sprintf("redis/%s.jsonl", s)
+		n, err := exportFile(dir, name, func(w io.Writer) (int, error) { return db.ExportKeys(w, redisPatterns[s]...) })
+		if err != nil {
+			return fmt.Errorf("export redis %s failed: %w", s, err)
+		}
+		m.Redis[s] = n
+		entries = append(entries, name)
+		for _, t := range tables {
+			if t.scope != s {
+				continue
+			}
+			name = fmt.Sprintf("tables/%s.jsonl", t.name)
+			if n, err = exportFile(dir, name, t.dump); err != nil {
+				return fmt.Errorf("export table %s failed: %w", t.name, err)
+			}
+			m.Tables[t.name] = n
+			entries = append(entries, name)
+		}
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(filepath.Join(dir, manifestName), data, 0o644); err != nil {
+		return err
+	}
+
+	// 打包, manifest 放在首位便于恢复时优先校验
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	for _, name := range append([]string{manifestName}, entries...) {
+		if err = addFile(tw, dir, name); err != nil {
+			return err
+		}
+	}
+	if err = tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// exportFile 调用 fn 将数据写入临时目录下的 name 文件
+func exportFile(dir, name string, fn func(w io.Writer) (int, error)) (int, error) {
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return 0, err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return fn(f)
+}
+
+// addFile 将临时目录下的 name 文件写入归档
+func addFile(tw *tar.Writer, dir, name string) error {
+	f, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err = tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: info.Size(), ModTime: info.ModTime()}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// List 返回备份目录中的所有备份文件, 按创建时间倒序排列
+func List() ([]FileInfo, error) {
+	des, err := os.ReadDir(config.BackupDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var list []FileInfo
+	for _, d := range des {
+		if d.IsDir() || !strings.HasSuffix(d.Name(), config.BackupFileSuffix) {
+			continue
+		}
+		if fi, err := stat(d.Name()); err == nil {
+			list = append(list, fi)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list, nil
+}
+
+// Path 返回备份文件的完整路径, 仅允许访问备份目录中的文件
+func Path(name string) (string, error) {
+	if name == "" || name != filepath.Base(name) || !strings.HasSuffix(name, config.BackupFileSuffix) {
+		return "", fmt.Errorf("备份文件名称异常: %s", name)
+	}
+	path := filepath.Join(config.BackupDir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("备份文件不存在: %s", name)
+	}
+	return path, nil
+}
+
+// Save 保存上传的备份文件, 已存在同名的备份文件时返回错误, 不覆盖已有的备份
+func Save(name string, src io.Reader) error {
+	if name == "" || name != filepath.Base(name) || !strings.HasSuffix(name, config.BackupFileSuffix) {
+		return fmt.Errorf("备份文件格式异常, 仅支持 %s 文件", config.BackupFileSuffix)
+	}
+	if err := os.MkdirAll(config.BackupDir, os.ModePerm); err != nil {
+		return err
+	}
+	path := filepath.Join(config.BackupDir, name)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("已存在同名的备份文件: %s, 请重命名后上传", name)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(f, src); err != nil {
+		_ = f.Close()
+		_ = os.Remove(path)
+		return err
+	}
+	if err = f.Close(); err != nil {
+		_ = os.Remove(path)
+	}
+	return err
+}
+
+// Remove 删除备份文件
+func Remove(name string) error {
+	path, err := Path(name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// Prune 仅保留最近的 keep 个备份文件
+func Prune(keep int) {
+	list, err := List()
+	if err != nil {
+		log.Println("Prune Backup Failed: ", err)
+		return
+	}
+	for i := keep; i < len(list); i++ {
+		if err = Remove(list[i].Name); err != nil {
+			log.Println("Prune Backup Failed: ", err)
+		}
+	}
+}
+
+func stat(name string) (FileInfo, error) {
+	info, err := os.Stat(filepath.Join(config.BackupDir, name))
+	if err != nil {
+		return FileInfo{}, err
+	}
+	return FileInfo{Name: name, Size: info.Size(), CreatedAt: info.ModTime()}, nil
+}
@@ -0,0 +1,174 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"server/config"
+	"server/plugin/migrate"
+)
+
+func TestValidScope(t *testing.T) {
+	tests := []struct {
+		scope   string
+		wantErr bool
+	}{
+		{ScopeAll, false},
+		{ScopeConfig, false},
+		{ScopeLibrary, false},
+		{"", true},
+		{"All", true},
+		{"users", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.scope, func(t *testing.T) {
+			if err := ValidScope(tt.scope); (err != nil) != tt.wantErr {
+				t.Errorf("ValidScope(%q) error = %v, wantErr %t", tt.scope, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestScopes(t *testing.T) {
+	tests := []struct {
+		scope string
+		want  []string
+	}{
+		{ScopeAll, []string{ScopeConfig, ScopeLibrary}},
+		{ScopeConfig, []string{ScopeConfig}},
+		{ScopeLibrary, []string{ScopeLibrary}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.scope, func(t *testing.T) {
+			got := scopes(tt.scope)
+			if len(got) != len(tt.want) {
+				t.Fatalf("scopes(%q) = %v, want %v", tt.scope, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("scopes(%q) = %v, want %v", tt.scope, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestPath(t *testing.T) {
+	dir := t.TempDir()
+	saved := config.BackupDir
+	config.BackupDir = dir
+	defer func() { config.BackupDir = saved }()
+	name := "gofilm-all-20240101-000000" + config.BackupFileSuffix
+	if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		wantErr bool
+	}{
+		{"备份文件", name, false},
+		{"文件不存在", "gofilm-all-missing" + config.BackupFileSuffix, true},
+		{"空文件名", "", true},
+		{"后缀异常", "gofilm-all.zip", true},
+		{"上级目录", "../" + name, true},
+		{"子目录", "sub/" + name, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Path(tt.file)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Path(%q) error = %v, wantErr %t", tt.file, err, tt.wantErr)
+			}
+			if !tt.wantErr && p != filepath.Join(dir, tt.file) {
+				t.Errorf("Path(%q) = %q, want %q", tt.file, p, filepath.Join(dir, tt.file))
+			}
+		})
+	}
+}
+
+// archive 生成只包含指定文件的备份归档
+func archive(t *testing.T, files map[string]any) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for name, v := range files {
+		data, _ := json.Marshal(v)
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = tw.Close()
+	_ = gw.Close()
+	return &buf
+}
+
+func TestRestoreRejectsInvalidArchive(t *testing.T) {
+	tests := []struct {
+		name  string
+		scope string
+		data  func(t *testing.T) *bytes.Buffer
+	}{
+		{"备份范围异常", "users", func(t *testing.T) *bytes.Buffer {
+			return archive(t, map[string]any{manifestName: Manifest{FormatVersion: config.BackupFormatVersion}})
+		}},
+		{"非 gzip 文件", ScopeAll, func(t *testing.T) *bytes.Buffer { return bytes.NewBufferString("not a backup") }},
+		{"缺少 manifest", ScopeAll, func(t *testing.T) *bytes.Buffer {
+			return archive(t, map[string]any{"redis/config.jsonl": nil})
+		}},
+		{"格式版本过高", ScopeAll, func(t *testing.T) *bytes.Buffer {
+			return archive(t, map[string]any{manifestName: Manifest{FormatVersion: config.BackupFormatVersion + 1}})
+		}},
+		{"数据库版本过高", ScopeAll, func(t *testing.T) *bytes.Buffer {
+			return archive(t, map[string]any{manifestName: Manifest{FormatVersion: config.BackupFormatVersion, SchemaVersion: migrate.Latest() + 1}})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Restore(tt.data(t), tt.scope); err == nil {
+				t.Errorf("Restore() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestSave(t *testing.T) {
+	dir := t.TempDir()
+	saved := config.BackupDir
+	config.BackupDir = dir
+	defer func() { config.BackupDir = saved }()
+	name := "gofilm-upload" + config.BackupFileSuffix
+
+	tests := []struct {
+		name    string
+		file    string
+		data    string
+		wantErr bool
+	}{
+		{"上传备份文件", name, "first", false},
+		{"同名文件不覆盖", name, "second", true},
+		{"后缀异常", "gofilm-upload.zip", "data", true},
+		{"上级目录", "../" + name, "data", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Save(tt.file, strings.NewReader(tt.data)); (err != nil) != tt.wantErr {
+				t.Errorf("Save(%q) error = %v, wantErr %t", tt.file, err, tt.wantErr)
+			}
+		})
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, name)); string(data) != "first" {
+		t.Errorf("backup content = %q, want %q", data, "first")
+	}
+}
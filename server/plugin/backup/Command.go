@@ -0,0 +1,69 @@
+package backup
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+/*
+	数据备份命令行
+	server backup list                                   查看备份目录中的备份文件
+	server backup create [-scope all|config|library]     创建备份
+	server backup restore -f file [-scope all|config|library]  从备份文件恢复数据
+*/
+
+// RunCommand 执行数据备份子命令
+func RunCommand(args []string) error {
+	if len(args) == 0 {
+		return usage()
+	}
+	fs := flag.NewFlagSet(fmt.Sprint("backup ", args[0]), flag.ContinueOnError)
+	scope := fs.String("scope", ScopeAll, "数据范围 all | config | library")
+	file := fs.String("f", "", "备份文件路径")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	switch args[0] {
+	case "list":
+		list, err := List()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "NAME\tSIZE\tCREATED AT")
+		for _, fi := range list {
+			_, _ = fmt.Fprintf(w, "%s\t%d\t%s\n", fi.Name, fi.Size, fi.CreatedAt.Format("2006-01-02 15:04:05"))
+		}
+		return w.Flush()
+	case "create":
+		fi, err := Create(*scope)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("备份完成: %s (%d bytes)\n", fi.Name, fi.Size)
+		return nil
+	case "restore":
+		if *file == "" {
+			return usage()
+		}
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		res, err := Restore(f, *scope)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("恢复完成: redis %v, tables %v\n", res.Redis, res.Tables)
+		return nil
+	default:
+		return usage()
+	}
+}
+
+func usage() error {
+	return fmt.Errorf("usage: backup list | create [-scope all|config|library] | restore -f file [-scope all|config|library]")
+}
@@ -0,0 +1,145 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strings"
+
+	"server/config"
+	"server/plugin/db"
+	"server/plugin/migrate"
+)
+
+// Result 恢复结果
+type Result struct {
+	Manifest Manifest       `json:"manifest"` // 备份文件信息
+	Redis    map[string]int `json:"redis"`    // 各范围恢复的 key 数量
+	Tables   map[string]int `json:"tables"`   // 各数据表恢复的记录数
+}
+
+// RestoreFile 从备份目录中的 name 文件恢复 scope 范围内的数据
+func RestoreFile(name, scope string) (Result, error) {
+	p, err := Path(name)
+	if err != nil {
+		return Result{}, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return Result{}, err
+	}
+	defer f.Close()
+	return Restore(f, scope)
+}
+
+// Restore 读取备份文件并恢复 scope 范围内的数据, 备份中不包含的范围将被忽略
+// 恢复 redis 数据前会先清除当前范围内已有的 key, 数据表会被清空后重新写入
+func Restore(r io.Reader, scope string) (Result, error) {
+	res := Result{Redis: make(map[string]int), Tables: make(map[string]int)}
+	if err := ValidScope(scope); err != nil {
+		return res, err
+	}
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return res, fmt.Errorf("备份文件格式异常: %w", err)
+	}
+	defer gr.Close()
+	tr := tar.NewReader(gr)
+
+	// 首个文件必须为 manifest, 校验备份文件版本
+	h, err := tr.Next()
+	if err != nil || h.Name != manifestName {
+		return res, errors.New("备份文件格式异常: 缺少 manifest.json")
+	}
+	if err = json.NewDecoder(tr).Decode(&res.Manifest); err != nil {
+		return res, fmt.Errorf("备份文件格式异常: %w", err)
+	}
+	if res.Manifest.FormatVersion > config.BackupFormatVersion {
+		return res, fmt.Errorf("不支持的备份文件格式版本: %d", res.Manifest.FormatVersion)
+	}
+	if res.Manifest.SchemaVersion > migrate.Latest() {
+		return res, fmt.Errorf("备份文件的数据库版本 %d 高于当前程序支持的版本 %d, 请先升级程序", res.Manifest.SchemaVersion, migrate.Latest())
+	}
+	// 确保数据表结构为最新版本
+	if err = migrate.Up(0); err != nil {
+		return res, err
+	}
+
+	allow := make(map[string]bool)
+	for _, s := range scopes(scope) {
+		allow[s] = true
+	}
+	for {
+		h, err = tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return res, err
+		}
+		dir, file := path.Split(h.Name)
+		name := strings.TrimSuffix(file, ".jsonl")
+		switch dir {
+		case "redis/":
+			if !allow[name] {
+				continue
+			}
+			if err = clearKeys(redisPatterns[name]...); err != nil {
+				return res, err
+			}
+			n, err := db.ImportKeys(tr, renameKey(res.Manifest.KeyPrefix))
+			if err != nil {
+				return res, fmt.Errorf("restore redis %s failed: %w", name, err)
+			}
+			res.Redis[name] = n
+		case "tables/":
+			t := findTable(name)
+			if t == nil || !allow[t.scope] {
+				continue
+			}
+			n, err := t.load(tr)
+			if err != nil {
+				return res, fmt.Errorf("restore table %s failed: %w", name, err)
+			}
+			res.Tables[name] = n
+		}
+	}
+	// 数据恢复后清除所有站点的首页缓存
+	_ = clearKeys(config.IndexCacheKey, config.TenantKey("*", config.IndexCacheKey))
+	log.Printf("[Backup] 数据恢复完成, redis: %v, tables: %v\n", res.Redis, res.Tables)
+	return res, nil
+}
+
+// clearKeys 删除所有匹配 patterns 的 key
+func clearKeys(patterns ...string) error {
+	for _, p := range patterns {
+		if _, err := db.UnlinkKeys(p, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renameKey 将备份时的 key 前缀替换为当前配置的前缀
+func renameKey(prefix string) func(key string) string {
+	if prefix == config.KeyPrefix {
+		return nil
+	}
+	return func(key string) string {
+		return config.KeyPrefix + strings.TrimPrefix(key, prefix)
+	}
+}
+
+func findTable(name string) *table {
+	for i := range tables {
+		if tables[i].name == name {
+			return &tables[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package backup
+
+import (
+	"context"
+	"log"
+
+	"server/config"
+
+	"github.com/robfig/cron/v3"
+)
+
+// backupCron 定时备份任务
+var backupCron = cron.New(cron.WithSeconds())
+
+// InitSchedule 根据 config.BackupSpec 开启定时备份, 未配置时不执行任何操作
+func InitSchedule() error {
+	if config.BackupSpec == "" {
+		return nil
+	}
+	if err := ValidScope(config.BackupScope); err != nil {
+		return err
+	}
+	_, err := backupCron.AddFunc(config.BackupSpec, func() {
+		fi, err := Create(config.BackupScope)
+		if err != nil {
+			log.Println("Scheduled Backup Failed: ", err)
+			return
+		}
+		// 清理超出保留数量的旧备份
+		Prune(config.BackupKeep)
+		log.Printf("执行一次定时备份任务: %s\n", fi.Name)
+	})
+	if err != nil {
+		return err
+	}
+	backupCron.Start()
+	log.Printf("[Backup] 定时备份已开启: %s, 备份范围: %s\n", config.BackupSpec, config.BackupScope)
+	return nil
+}
+
+// StopSchedule 停止定时备份, 返回的 context 在执行中的备份任务结束后完成
+func StopSchedule() context.Context {
+	return backupCron.Stop()
+}
@@ -0,0 +1,116 @@
+package backup
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+
+	"server/config"
+	"server/model/system"
+	"server/plugin/db"
+
+	"gorm.io/gorm"
+)
+
+// batchSize 数据表分批读写的记录数
+const batchSize = 500
+
+// table 参与备份的数据表
+type table struct {
+	name  string
+	scope string
+	dump  func(w io.Writer) (int, error)
+	load  func(r io.Reader) (int, error)
+}
+
+// tables 参与备份的所有数据表
+var tables = []table{
+	tableOf[system.User](config.UserTableName, ScopeConfig, config.UserIdInitialVal),
+	tableOf[system.SearchInfo](config.SearchTableName, ScopeLibrary, 1),
+	tableOf[system.FileInfo](config.FileTableName, ScopeLibrary, 1),
+	tableOf[system.FailureRecord](config.FailureRecordTableName, ScopeLibrary, 1),
+	tableOf[system.FilmRecycle](config.FilmRecycleTableName, ScopeLibrary, 1),
+	tableOf[system.FilmHidden](config.FilmHiddenTableName, ScopeLibrary, 1),
+	tableOf[system.Person](config.PersonTableName, ScopeLibrary, 1),
+	tableOf[system.FilmPerson](config.FilmPersonTableName, ScopeLibrary, 1),
+	tableOf[system.Actor](config.ActorTableName, ScopeLibrary, 1),
+	tableOf[system.FilmRole](config.FilmRoleTableName, ScopeLibrary, 1),
+	tableOf[system.Article](config.ArticleTableName, ScopeLibrary, 1),
+	tableOf[system.FilmText](config.FilmTextTableName, ScopeLibrary, 0),
+	tableOf[system.FilmBlock](config.FilmBlockTableName, ScopeConfig, 1),
+	tableOf[system.FilmBlockAudit](config.FilmBlockAuditTableName, ScopeConfig, 1),
+}
+
+// tableOf 生成模型 T 对应数据表的导出与导入方法, minId 为表中自增ID的最小起始值, 为 0 时表示数据表不使用自增ID
+func tableOf[T any](name, scope string, minId int) table {
+	return table{
+		name:  name,
+		scope: scope,
+		dump: func(w io.Writer) (int, error) {
+			enc := json.NewEncoder(w)
+			count := 0
+			var batch []T
+			// Unscoped 同时导出软删除的记录 (例如回收站中影片的检索信息)
+			err := db.Mdb.Unscoped().FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+				for _, r := range batch {
+					if err := enc.Encode(r); err != nil {
+						return err
+					}
+				}
+				count += len(batch)
+				return nil
+			}).Error
+			return count, err
+		},
+		load: func(r io.Reader) (int, error) {
+			if err := db.TruncateTable(name); err != nil {
+				return 0, err
+			}
+			scanner := bufio.NewScanner(r)
+			scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+			count := 0
+			var batch []T
+			flush := func() error {
+				if len(batch) == 0 {
+					return nil
+				}
+				if err := db.Mdb.CreateInBatches(batch, batchSize).Error; err != nil {
+					return err
+				}
+				count += len(batch)
+				batch = batch[:0]
+				return nil
+			}
+			for scanner.Scan() {
+				if len(scanner.Bytes()) == 0 {
+					continue
+				}
+				var row T
+				if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+					return count, err
+				}
+				if batch = append(batch, row); len(batch) >= batchSize {
+					if err := flush(); err != nil {
+						return count, err
+					}
+				}
+			}
+			if err := scanner.Err(); err != nil {
+				return count, err
+			}
+			if err := flush(); err != nil {
+				return count, err
+			}
+			if minId == 0 {
+				return count, nil
+			}
+			// 记录携带原有ID写入, 需要将自增ID重置到最大ID之后
+			var maxId int
+			db.Mdb.Table(name).Select("COALESCE(MAX(id), 0)").Scan(&maxId)
+			if maxId+1 > minId {
+				minId = maxId + 1
+			}
+			return count, db.SetAutoIncrement(db.Mdb, name, minId)
+		},
+	}
+}
@@ -3,9 +3,12 @@ package conver
 import (
 	"encoding/xml"
 	"log"
+	"regexp"
 	"server/config"
 	"server/model/collect"
 	"server/model/system"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -14,6 +17,9 @@ import (
 	统一转化为内部结构体
 */
 
+// serialNumPattern 连载数中的数字部分
+var serialNumPattern = regexp.MustCompile(`[0-9]+`)
+
 // GenCategoryTree 解析处理 filmListPage数据 生成分类树形数据
 func GenCategoryTree(list []collect.FilmClass) *system.CategoryTree {
 	// 遍历所有分类进行树形结构组装
@@ -34,26 +40,33 @@ func GenCategoryTree(list []collect.FilmClass) *system.CategoryTree {
 		// 根据 pid获取父节点信息
 		parent, ok := temp[category.Pid]
 		if !ok {
-			// 如果不存在父节点存在, 则将父节点存放到temp中
+			// 如果父节点还未出现, 则先创建占位节点存放到temp中, 等待父节点信息到达后补全
+			parent = &system.CategoryTree{}
 			temp[c.TypePid] = parent
 		}
 		// 将当前节点存放到父节点的Children中
 		parent.Children = append(parent.Children, category)
 	}
-
+	// 父节点始终未出现的分类直接挂载到根节点下
+	for _, node := range temp {
+		if node.Category == nil {
+			for _, c := range node.Children {
+				c.Pid = tree.Id
+			}
+			tree.Children = append(tree.Children, node.Children...)
+		}
+	}
 	return tree
 }
 
-// ConvertCategoryList 将分类树形数据转化为list类型
+// ConvertCategoryList 将分类树形数据转化为list类型, 支持任意层级
 func ConvertCategoryList(tree system.CategoryTree) []system.Category {
-	var cl = []system.Category{system.Category{Id: tree.Id, Pid: tree.Pid, Name: tree.Name, Show: tree.Show}}
+	if tree.Category == nil {
+		return nil
+	}
+	var cl = []system.Category{*tree.Category}
 	for _, c := range tree.Children {
-		cl = append(cl, system.Category{Id: c.Id, Pid: c.Pid, Name: c.Name, Show: c.Show})
-		if c.Children != nil && len(c.Children) > 0 {
-			for _, subC := range c.Children {
-				cl = append(cl, system.Category{Id: subC.Id, Pid: subC.Pid, Name: subC.Name, Show: subC.Show})
-			}
-		}
+		cl = append(cl, ConvertCategoryList(*c)...)
 	}
 	return cl
 }
@@ -99,17 +112,62 @@ func ConvertFilmDetail(detail collect.FilmDetail) system.MovieDetail {
 			DbScore:     detail.VodDouBanScore,
 			Hits:        detail.VodHits,
 			Content:     detail.VodContent,
+			Total:       detail.VodTotal,
+			IsEnd:       detail.VodIsEnd == 1,
+			Weekday:     detail.VodWeekday,
+			Tv:          detail.VodTv,
 		},
 	}
+	// 连载数可能为 12 | 更新至12集 等格式, 取其中的数字部分
+	md.Serial, _ = strconv.ParseInt(serialNumPattern.FindString(detail.VodSerial), 10, 64)
+	md.ResolveSerial()
+	// 画质 版本 字幕标签
+	md.Quality, md.Version, md.Subtitle = system.ParseMediaTags(detail.VodVersion, detail.VodRemarks, detail.VodName, detail.VodPlayFrom)
 	// 通过分割符切分播放源信息  PlaySeparator $$$
 	md.PlayFrom = strings.Split(detail.VodPlayFrom, detail.VodPlayNote)
 	// v2 只保留m3u8播放源
 	md.PlayList = GenFilmPlayList(detail.VodPlayURL, detail.VodPlayNote)
 	md.DownloadList = GenFilmPlayList(detail.VodDownURL, detail.VodPlayNote)
+	// 分集剧情
+	md.Plots = ParseEpisodePlots(detail.VodPlotName, detail.VodPlotDetail)
 
 	return md
 }
 
+// ParseEpisodePlots 解析分集剧情, 名称与剧情按位置一一对应, 使用 $$$ 分隔, 不包含 $$$ 时使用 $ 分隔
+// 按照名称中解析出的集数序号排列, 无法解析序号的分集使用其所在位置, 序号重复时保留先出现的分集
+func ParseEpisodePlots(names, details string) []system.EpisodePlot {
+	if strings.TrimSpace(details) == "" {
+		return nil
+	}
+	split := func(s string) []string {
+		if strings.Contains(s, "$$$") {
+			return strings.Split(s, "$$$")
+		}
+		return strings.Split(s, "$")
+	}
+	nl, dl := split(names), split(details)
+	seen := make(map[int]bool)
+	var res []system.EpisodePlot
+	for i, d := range dl {
+		var name string
+		if i < len(nl) {
+			name = strings.TrimSpace(nl[i])
+		}
+		n := system.EpisodeNumber(name)
+		if n == 0 {
+			n = i + 1
+		}
+		if d = strings.TrimSpace(d); d == "" || seen[n] {
+			continue
+		}
+		seen[n] = true
+		res = append(res, system.EpisodePlot{Episode: n, Name: name, Content: d})
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Episode < res[j].Episode })
+	return res
+}
+
 // GenFilmPlayList 处理影片播放地址数据, 只保留m3u8与mp4格式的链接,生成playList
 func GenFilmPlayList(playUrl, separator string) [][]system.MovieUrlInfo {
 	var res [][]system.MovieUrlInfo
@@ -0,0 +1,95 @@
+package conver
+
+import (
+	"reflect"
+	"testing"
+
+	"server/model/system"
+)
+
+func TestParseEpisodePlots(t *testing.T) {
+	tests := []struct {
+		name    string
+		names   string
+		details string
+		want    []system.EpisodePlot
+	}{
+		{
+			name:    "$ 分隔",
+			names:   "第1集$第2集",
+			details: "剧情一$剧情二",
+			want:    []system.EpisodePlot{{Episode: 1, Name: "第1集", Content: "剧情一"}, {Episode: 2, Name: "第2集", Content: "剧情二"}},
+		},
+		{
+			name:    "$$$ 分隔",
+			names:   "第1集$$$第2集",
+			details: "剧情一$$$剧情二",
+			want:    []system.EpisodePlot{{Episode: 1, Name: "第1集", Content: "剧情一"}, {Episode: 2, Name: "第2集", Content: "剧情二"}},
+		},
+		{
+			name:    "名称与剧情分隔符不一致",
+			names:   "第1集$$$第2集",
+			details: "剧情一$剧情二",
+			want:    []system.EpisodePlot{{Episode: 1, Name: "第1集", Content: "剧情一"}, {Episode: 2, Name: "第2集", Content: "剧情二"}},
+		},
+		{
+			name:    "中文数字并按集数排序",
+			names:   "第三集$第一集$第二集",
+			details: "c$a$b",
+			want: []system.EpisodePlot{
+				{Episode: 1, Name: "第一集", Content: "a"},
+				{Episode: 2, Name: "第二集", Content: "b"},
+				{Episode: 3, Name: "第三集", Content: "c"},
+			},
+		},
+		{
+			name:    "缺少名称时使用位置",
+			names:   "",
+			details: "a$b",
+			want:    []system.EpisodePlot{{Episode: 1, Content: "a"}, {Episode: 2, Content: "b"}},
+		},
+		{
+			name:    "无法解析序号的名称使用位置",
+			names:   "花絮$第2集",
+			details: "x$y",
+			want:    []system.EpisodePlot{{Episode: 1, Name: "花絮", Content: "x"}, {Episode: 2, Name: "第2集", Content: "y"}},
+		},
+		{
+			name:    "序号重复保留先出现的分集",
+			names:   "第1集$第一集",
+			details: "a$b",
+			want:    []system.EpisodePlot{{Episode: 1, Name: "第1集", Content: "a"}},
+		},
+		{
+			name:    "跳过空剧情并去除空白",
+			names:   " 第1集 $第2集$第3集",
+			details: " a $  $c",
+			want:    []system.EpisodePlot{{Episode: 1, Name: "第1集", Content: "a"}, {Episode: 3, Name: "第3集", Content: "c"}},
+		},
+		{
+			name:    "剧情为空",
+			names:   "第1集",
+			details: "",
+			want:    nil,
+		},
+		{
+			name:    "剧情仅包含空白",
+			names:   "第1集",
+			details: "  ",
+			want:    nil,
+		},
+		{
+			name:    "剧情仅包含分隔符",
+			names:   "第1集$第2集",
+			details: "$",
+			want:    nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseEpisodePlots(tt.names, tt.details); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseEpisodePlots(%q, %q) = %+v, want %+v", tt.names, tt.details, got, tt.want)
+			}
+		})
+	}
+}
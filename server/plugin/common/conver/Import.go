@@ -0,0 +1,264 @@
+package conver
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"server/model/system"
+	"strconv"
+	"strings"
+)
+
+/*
+	影片导入文件解析
+	m3u  - M3U/M3U8 播放列表, #EXTINF 中的标题作为集数名称, 整个文件生成一部影片
+	text - 文本剧集列表, 每行一集: 集数$地址 | 集数,地址 | 集数 地址 | 地址, 空行或 $$$ 分隔多个播放源
+	csv  - 多部影片, 首行为表头, 列名与 FilmDetailVo 的 json 字段名一致
+	json - 多部影片, FilmDetailVo 数组或单个对象
+	解析结果统一转换为 FilmDetailVo, 播放列表使用 MacCMS 的 集数$地址#集数$地址$$$... 格式
+*/
+
+const (
+	ImportM3U  = "m3u"
+	ImportText = "text"
+	ImportCSV  = "csv"
+	ImportJSON = "json"
+)
+
+// ImportFilm 导入文件中解析出的影片信息以及解析过程中的错误
+type ImportFilm struct {
+	Film   system.FilmDetailVo
+	Errors []string
+}
+
+// ImportFormat 通过文件名后缀推断导入格式, 无法识别时按文本剧集列表处理
+func ImportFormat(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".m3u", ".m3u8":
+		return ImportM3U
+	case ".csv":
+		return ImportCSV
+	case ".json":
+		return ImportJSON
+	}
+	return ImportText
+}
+
+// ParseImportFile 解析导入文件, base 中的影片信息作为每部影片未填写字段的默认值
+func ParseImportFile(format string, data []byte, base system.FilmDetailVo) ([]ImportFilm, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	switch format {
+	case ImportM3U:
+		groups, err := parseM3U(string(data))
+		if err != nil {
+			return nil, err
+		}
+		return []ImportFilm{playListFilm(base, groups)}, nil
+	case ImportText:
+		return []ImportFilm{playListFilm(base, parseEpisodeText(string(data)))}, nil
+	case ImportCSV, ImportJSON:
+		// 多部影片的导入不沿用默认信息中的影片ID与播放列表
+		base.Id, base.PlayLink = 0, ""
+		if format == ImportCSV {
+			return parseFilmCSV(data, base)
+		}
+		return parseFilmJSON(data, base)
+	}
+	return nil, fmt.Errorf("导入格式异常: %s, 可选值 %s | %s | %s | %s", format, ImportM3U, ImportText, ImportCSV, ImportJSON)
+}
+
+// playListFilm 使用解析出的播放列表生成影片信息
+func playListFilm(base system.FilmDetailVo, groups [][]system.MovieUrlInfo) ImportFilm {
+	f := ImportFilm{Film: base}
+	var gl []string
+	for _, g := range groups {
+		var el []string
+		for _, e := range g {
+			el = append(el, fmt.Sprint(e.Episode, "$", e.Link))
+		}
+		gl = append(gl, strings.Join(el, "#"))
+	}
+	f.Film.PlayLink = strings.Join(gl, "$$$")
+	if len(gl) == 0 {
+		f.Errors = append(f.Errors, "未解析到任何剧集信息")
+	}
+	return f
+}
+
+// parseM3U 解析 M3U 播放列表, 每个条目作为一集
+func parseM3U(text string) ([][]system.MovieUrlInfo, error) {
+	var list []system.MovieUrlInfo
+	var title string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXT-X-TARGETDURATION"), strings.HasPrefix(line, "#EXT-X-STREAM-INF"):
+			// HLS 播放列表本身即为一集的播放地址
+			return nil, errors.New("文件为 HLS 媒体播放列表, 请直接使用该播放列表的地址作为剧集地址")
+		case strings.HasPrefix(line, "#EXTINF:"):
+			title = m3uTitle(line)
+		case strings.HasPrefix(line, "#"):
+		default:
+			list = append(list, episode(title, line, len(list)))
+			title = ""
+		}
+	}
+	if len(list) == 0 {
+		return nil, nil
+	}
+	return [][]system.MovieUrlInfo{list}, nil
+}
+
+// m3uTitle 获取 #EXTINF 中的标题, 标题位于属性信息之后第一个不在引号中的逗号后
+func m3uTitle(line string) string {
+	quoted := false
+	for i, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ',' && !quoted:
+			return strings.TrimSpace(line[i+1:])
+		}
+	}
+	return ""
+}
+
+// parseEpisodeText 解析文本剧集列表
+func parseEpisodeText(text string) [][]system.MovieUrlInfo {
+	var groups [][]system.MovieUrlInfo
+	var list []system.MovieUrlInfo
+	flush := func() {
+		if len(list) > 0 {
+			groups = append(groups, list)
+			list = nil
+		}
+	}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line == "$$$" {
+			flush()
+			continue
+		}
+		var name, link string
+		if i := strings.Index(line, "http"); i >= 0 {
+			name, link = strings.TrimRight(line[:i], "$,， \t"), line[i:]
+		} else if i = strings.Index(line, "$"); i >= 0 {
+			name, link = line[:i], line[i+1:]
+		} else {
+			link = line
+		}
+		list = append(list, episode(name, link, len(list)))
+	}
+	flush()
+	return groups
+}
+
+// episode 生成剧集信息, 移除名称和地址中与播放列表分隔符冲突的字符
+func episode(name, link string, index int) system.MovieUrlInfo {
+	name = strings.TrimSpace(strings.NewReplacer("$", "", "#", "").Replace(name))
+	if name == "" {
+		name = fmt.Sprintf("第%d集", index+1)
+	}
+	// 地址中的 # 之后为锚点, 不影响播放请求
+	link, _, _ = strings.Cut(strings.TrimSpace(link), "#")
+	return system.MovieUrlInfo{Episode: name, Link: strings.ReplaceAll(link, "$", "%24")}
+}
+
+// parseFilmCSV 解析 CSV 格式的影片信息
+func parseFilmCSV(data []byte, base system.FilmDetailVo) ([]ImportFilm, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("CSV 文件格式异常: %w", err)
+	}
+	if len(rows) < 2 {
+		return nil, errors.New("CSV 文件中不存在影片数据")
+	}
+	fields := filmVoFields(reflect.ValueOf(&system.FilmDetailVo{}).Elem())
+	header := rows[0]
+	for i, h := range header {
+		header[i] = strings.TrimSpace(h)
+		if _, ok := fields[header[i]]; !ok {
+			return nil, fmt.Errorf("CSV 表头中存在未知的字段: %s", h)
+		}
+	}
+	var res []ImportFilm
+	for _, row := range rows[1:] {
+		f := ImportFilm{}
+		v := reflect.ValueOf(&f.Film).Elem()
+		target := filmVoFields(v)
+		for i, value := range row {
+			if i >= len(header) || strings.TrimSpace(value) == "" {
+				continue
+			}
+			if err = setFilmVoField(target[header[i]], strings.TrimSpace(value)); err != nil {
+				f.Errors = append(f.Errors, fmt.Sprintf("字段 %s 的值格式异常: %s", header[i], value))
+			}
+		}
+		mergeFilmDetailVo(&f.Film, base)
+		res = append(res, f)
+	}
+	return res, nil
+}
+
+// parseFilmJSON 解析 JSON 格式的影片信息
+func parseFilmJSON(data []byte, base system.FilmDetailVo) ([]ImportFilm, error) {
+	var list []system.FilmDetailVo
+	if err := json.Unmarshal(data, &list); err != nil {
+		var fd system.FilmDetailVo
+		if json.Unmarshal(data, &fd) != nil {
+			return nil, fmt.Errorf("JSON 文件格式异常: %w", err)
+		}
+		list = append(list, fd)
+	}
+	var res []ImportFilm
+	for _, fd := range list {
+		mergeFilmDetailVo(&fd, base)
+		res = append(res, ImportFilm{Film: fd})
+	}
+	return res, nil
+}
+
+// filmVoFields 获取 FilmDetailVo 的字段 {json字段名: 字段值}
+func filmVoFields(v reflect.Value) map[string]reflect.Value {
+	res := make(map[string]reflect.Value)
+	for i := 0; i < v.NumField(); i++ {
+		if name := strings.Split(v.Type().Field(i).Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+			res[name] = v.Field(i)
+		}
+	}
+	return res
+}
+
+// setFilmVoField 将 CSV 中的文本值写入字段, 播放来源使用 $$$ 分隔
+func setFilmVoField(f reflect.Value, value string) error {
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(value)
+	case reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		f.SetInt(n)
+	case reflect.Slice:
+		f.Set(reflect.ValueOf(strings.Split(value, "$$$")))
+	}
+	return nil
+}
+
+// mergeFilmDetailVo 未填写的字段使用默认影片信息中的值
+func mergeFilmDetailVo(fd *system.FilmDetailVo, base system.FilmDetailVo) {
+	dst, src := reflect.ValueOf(fd).Elem(), reflect.ValueOf(base)
+	for i := 0; i < dst.NumField(); i++ {
+		if dst.Field(i).IsZero() && !src.Field(i).IsZero() {
+			dst.Field(i).Set(src.Field(i))
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package conver
+
+import (
+	"reflect"
+	"testing"
+
+	"server/model/system"
+)
+
+func TestImportFormat(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		{"list.m3u", ImportM3U},
+		{"LIST.M3U8", ImportM3U},
+		{"films.csv", ImportCSV},
+		{"films.JSON", ImportJSON},
+		{"episodes.txt", ImportText},
+		{"episodes", ImportText},
+		{"", ImportText},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			if got := ImportFormat(tt.filename); got != tt.want {
+				t.Errorf("ImportFormat(%q) = %q, want %q", tt.filename, got, tt.want)
+			}
+		})
+	}
+}
+
+// importTest 导入文件解析的测试用例
+type importTest struct {
+	name    string
+	data    string
+	want    []ImportFilm
+	wantErr bool
+}
+
+// runImportTests 使用相同的默认影片信息执行导入文件解析的测试用例
+func runImportTests(t *testing.T, format string, base system.FilmDetailVo, tests []importTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseImportFile(format, []byte(tt.data), base)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseImportFile(%s, %q) error = %v, wantErr %t", format, tt.data, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseImportFile(%s, %q) = %+v, want %+v", format, tt.data, got, tt.want)
+			}
+		})
+	}
+}
+
+// playLinkFilm 使用默认影片信息以及播放列表生成期望的解析结果
+func playLinkFilm(base system.FilmDetailVo, playLink string, errs ...string) []ImportFilm {
+	base.PlayLink = playLink
+	return []ImportFilm{{Film: base, Errors: errs}}
+}
+
+func TestParseImportFileM3U(t *testing.T) {
+	base := system.FilmDetailVo{Id: 5, Name: "测试影片"}
+	runImportTests(t, ImportM3U, base, []importTest{
+		{
+			name: "EXTINF 标题",
+			data: "#EXTM3U\n#EXTINF:-1 tvg-name=\"a,b\" group-title=\"剧集\",第1集\nhttp://a/1.m3u8\n#EXTINF:-1,第2集\nhttp://a/2.m3u8\n",
+			want: playLinkFilm(base, "第1集$http://a/1.m3u8#第2集$http://a/2.m3u8"),
+		},
+		{
+			name: "BOM 与 CRLF",
+			data: "\xef\xbb\xbf#EXTM3U\r\n#EXTINF:10,EP01\r\nhttp://a/1.mp4\r\n",
+			want: playLinkFilm(base, "EP01$http://a/1.mp4"),
+		},
+		{
+			name: "缺少 EXTINF 时按位置命名",
+			data: "http://a/1.m3u8\n\n  http://a/2.m3u8  ",
+			want: playLinkFilm(base, "第1集$http://a/1.m3u8#第2集$http://a/2.m3u8"),
+		},
+		{
+			name: "EXTINF 缺少标题",
+			data: "#EXTINF:-1\nhttp://a/1.m3u8",
+			want: playLinkFilm(base, "第1集$http://a/1.m3u8"),
+		},
+		{
+			name: "移除与分隔符冲突的字符",
+			data: "#EXTINF:-1,第1集$预告#1\nhttp://a/$1.m3u8#t=10",
+			want: playLinkFilm(base, "第1集预告1$http://a/%241.m3u8"),
+		},
+		{
+			name:    "HLS 媒体播放列表",
+			data:    "#EXTM3U\n#EXT-X-TARGETDURATION:10\n#EXTINF:10,\nseg1.ts\n",
+			wantErr: true,
+		},
+		{
+			name:    "HLS 主播放列表",
+			data:    "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=800000\n720p.m3u8\n",
+			wantErr: true,
+		},
+		{
+			name: "仅包含注释",
+			data: "#EXTM3U\n#EXTINF:-1,第1集\n",
+			want: playLinkFilm(base, "", "未解析到任何剧集信息"),
+		},
+		{
+			name: "空文件",
+			data: "",
+			want: playLinkFilm(base, "", "未解析到任何剧集信息"),
+		},
+	})
+}
+
+func TestParseImportFileText(t *testing.T) {
+	base := system.FilmDetailVo{Name: "测试影片"}
+	runImportTests(t, ImportText, base, []importTest{
+		{
+			name: "$ 分隔",
+			data: "第1集$http://a/1.m3u8\n第2集$http://a/2.m3u8",
+			want: playLinkFilm(base, "第1集$http://a/1.m3u8#第2集$http://a/2.m3u8"),
+		},
+		{
+			name: "混合分隔符",
+			data: "第1集,http://a/1\r\n第2集，http://a/2\r\n第3集 http://a/3\r\n第4集\thttp://a/4",
+			want: playLinkFilm(base, "第1集$http://a/1#第2集$http://a/2#第3集$http://a/3#第4集$http://a/4"),
+		},
+		{
+			name: "中文数字集数",
+			data: "第十二集 http://a/12\n第十三集$http://a/13",
+			want: playLinkFilm(base, "第十二集$http://a/12#第十三集$http://a/13"),
+		},
+		{
+			name: "仅包含地址",
+			data: "http://a/1\nhttps://a/2",
+			want: playLinkFilm(base, "第1集$http://a/1#第2集$https://a/2"),
+		},
+		{
+			name: "非 http 地址",
+			data: "第1集$ftp://a/1\nmagnet:?xt=abc",
+			want: playLinkFilm(base, "第1集$ftp://a/1#第2集$magnet:?xt=abc"),
+		},
+		{
+			name: "空行与 $$$ 分隔播放源",
+			data: "第1集$http://a/1\n\n\n第1集$http://b/1\n$$$\n第1集$http://c/1\n",
+			want: playLinkFilm(base, "第1集$http://a/1$$$第1集$http://b/1$$$第1集$http://c/1"),
+		},
+		{
+			name: "仅包含空行",
+			data: "\n \n\t\n",
+			want: playLinkFilm(base, "", "未解析到任何剧集信息"),
+		},
+		{
+			name: "空文件",
+			data: "",
+			want: playLinkFilm(base, "", "未解析到任何剧集信息"),
+		},
+	})
+}
+
+func TestParseImportFileCSV(t *testing.T) {
+	base := system.FilmDetailVo{Id: 9, Name: "默认片名", Area: "日本", PlayLink: "第1集$http://base/1"}
+	runImportTests(t, ImportCSV, base, []importTest{
+		{
+			name: "多部影片",
+			data: "name,year,hits,playFrom,playLink\n片一,2020,10,a$$$b,第1集$http://a/1\n片二,,,,\n",
+			want: []ImportFilm{
+				{Film: system.FilmDetailVo{Name: "片一", Year: "2020", Hits: 10, PlayFrom: []string{"a", "b"}, PlayLink: "第1集$http://a/1", Area: "日本"}},
+				{Film: system.FilmDetailVo{Name: "片二", Area: "日本"}},
+			},
+		},
+		{
+			name: "表头与字段值去除空白",
+			data: "\xef\xbb\xbf name , area \r\n 片一 , 韩国 \r\n",
+			want: []ImportFilm{{Film: system.FilmDetailVo{Name: "片一", Area: "韩国"}}},
+		},
+		{
+			name: "缺少字段时使用默认值",
+			data: "year\n2021\n",
+			want: []ImportFilm{{Film: system.FilmDetailVo{Name: "默认片名", Year: "2021", Area: "日本"}}},
+		},
+		{
+			name: "数值字段格式异常",
+			data: "name,hits\n片一,很多\n",
+			want: []ImportFilm{{Film: system.FilmDetailVo{Name: "片一", Area: "日本"}, Errors: []string{"字段 hits 的值格式异常: 很多"}}},
+		},
+		{
+			name: "忽略多余的列",
+			data: "name\n片一,多余\n",
+			want: []ImportFilm{{Film: system.FilmDetailVo{Name: "片一", Area: "日本"}}},
+		},
+		{
+			name:    "未知字段",
+			data:    "name,score\n片一,9\n",
+			wantErr: true,
+		},
+		{
+			name:    "引号未闭合",
+			data:    "name\n\"片一\n",
+			wantErr: true,
+		},
+		{
+			name:    "仅包含表头",
+			data:    "name,year\n",
+			wantErr: true,
+		},
+		{
+			name:    "空文件",
+			data:    "",
+			wantErr: true,
+		},
+	})
+}
+
+func TestParseImportFileJSON(t *testing.T) {
+	base := system.FilmDetailVo{Id: 9, Area: "日本", PlayLink: "第1集$http://base/1"}
+	runImportTests(t, ImportJSON, base, []importTest{
+		{
+			name: "影片数组",
+			data: `[{"name":"片一","year":"2020","playFrom":["a"]},{"name":"片二","area":"韩国"}]`,
+			want: []ImportFilm{
+				{Film: system.FilmDetailVo{Name: "片一", Year: "2020", PlayFrom: []string{"a"}, Area: "日本"}},
+				{Film: system.FilmDetailVo{Name: "片二", Area: "韩国"}},
+			},
+		},
+		{
+			name: "单个影片对象",
+			data: "\xef\xbb\xbf{\"name\":\"片一\",\"hits\":3}",
+			want: []ImportFilm{{Film: system.FilmDetailVo{Name: "片一", Hits: 3, Area: "日本"}}},
+		},
+		{
+			name: "空数组",
+			data: `[]`,
+			want: nil,
+		},
+		{
+			name:    "字段类型错误",
+			data:    `[{"name":"片一","hits":"很多"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "格式不完整",
+			data:    `{"name":`,
+			wantErr: true,
+		},
+		{
+			name:    "空文件",
+			data:    "",
+			wantErr: true,
+		},
+	})
+}
+
+func TestParseImportFileUnknownFormat(t *testing.T) {
+	if _, err := ParseImportFile("xml", []byte("<film/>"), system.FilmDetailVo{}); err == nil {
+		t.Error("ParseImportFile(xml) error = nil, want error")
+	}
+}
@@ -0,0 +1,113 @@
+package conver
+
+import (
+	"server/model/collect"
+	"server/model/system"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+	演员 角色 文章 采集数据转换
+*/
+
+// ConvertActors 将采集的演员详情转换为演员资料, 忽略姓名为空的数据
+func ConvertActors(sid string, list []collect.ActorDetail) []system.Actor {
+	var res []system.Actor
+	for _, d := range list {
+		name := strings.TrimSpace(d.ActorName)
+		if name == "" {
+			continue
+		}
+		res = append(res, system.Actor{
+			Sid:         sid,
+			OriginId:    d.ActorID,
+			Name:        name,
+			En:          d.ActorEn,
+			Alias:       d.ActorAlias,
+			Sex:         d.ActorSex,
+			Area:        d.ActorArea,
+			Height:      d.ActorHeight,
+			Weight:      d.ActorWeight,
+			Birthday:    d.ActorBirthday,
+			BirthArea:   d.ActorBirthArea,
+			Blood:       d.ActorBlood,
+			StarSign:    d.ActorStarSign,
+			School:      d.ActorSchool,
+			Works:       d.ActorWorks,
+			Tag:         d.ActorTag,
+			Pic:         d.ActorPic,
+			Blurb:       d.ActorBlurb,
+			Content:     d.ActorContent,
+			UpdateStamp: resourceStamp(d.ActorTime),
+		})
+	}
+	return res
+}
+
+// ConvertRoles 将采集的角色详情转换为影片角色, 忽略未关联影片的数据
+func ConvertRoles(sid string, list []collect.RoleDetail) []system.FilmRole {
+	var res []system.FilmRole
+	for _, d := range list {
+		if d.RoleRid <= 0 || strings.TrimSpace(d.RoleName) == "" {
+			continue
+		}
+		res = append(res, system.FilmRole{
+			Sid:         sid,
+			OriginId:    d.RoleID,
+			Mid:         d.RoleRid,
+			Name:        strings.TrimSpace(d.RoleName),
+			Actor:       strings.TrimSpace(d.RoleActor),
+			Pic:         d.RolePic,
+			Remarks:     d.RoleRemarks,
+			Content:     d.RoleContent,
+			Sort:        d.RoleSort,
+			UpdateStamp: resourceStamp(d.RoleTime),
+		})
+	}
+	return res
+}
+
+// ConvertArticles 将采集的文章详情转换为文章资讯, 忽略标题为空的数据
+func ConvertArticles(sid string, list []collect.ArticleDetail) []system.Article {
+	var res []system.Article
+	for _, d := range list {
+		if strings.TrimSpace(d.ArtName) == "" {
+			continue
+		}
+		res = append(res, system.Article{
+			Sid:         sid,
+			OriginId:    d.ArtID,
+			TypeName:    d.TypeName,
+			Name:        strings.TrimSpace(d.ArtName),
+			Sub:         d.ArtSub,
+			From:        d.ArtFrom,
+			Author:      d.ArtAuthor,
+			Tag:         d.ArtTag,
+			Pic:         d.ArtPic,
+			Blurb:       d.ArtBlurb,
+			Remarks:     d.ArtRemarks,
+			RelVod:      d.ArtRelVod,
+			Content:     d.ArtContent,
+			UpdateStamp: resourceStamp(d.ArtTime),
+		})
+	}
+	return res
+}
+
+// resourceStamp 采集站返回的更新时间可能为时间戳或 yyyy-MM-dd HH:mm:ss 格式的文本, 统一转换为时间戳
+func resourceStamp(v any) int64 {
+	switch t := v.(type) {
+	case float64:
+		return int64(t)
+	case string:
+		if n, err := strconv.ParseInt(t, 10, 64); err == nil {
+			return n
+		}
+		if tm, err := time.ParseInLocation(time.DateTime, t, time.Local); err == nil {
+			return tm.Unix()
+		}
+	}
+	return 0
+}
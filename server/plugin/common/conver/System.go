@@ -18,6 +18,7 @@ func CovertFilmDetailVo(fd system.FilmDetailVo) (system.MovieDetail, error) {
 		Pid:      fd.Pid,
 		Name:     fd.Name,
 		Picture:  fd.Picture,
+		PlayFrom: fd.PlayFrom,
 		DownFrom: fd.DownFrom,
 		MovieDescriptor: system.MovieDescriptor{
 			SubTitle:    fd.SubTitle,
@@ -0,0 +1,37 @@
+package util
+
+import (
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+var (
+	// simplifiedMap 繁体字到简体字的映射, 首次使用时由对照表生成
+	simplifiedMap  map[rune]rune
+	simplifiedOnce sync.Once
+)
+
+// ToSimplified 将文本中的繁体字逐字转换为简体字, 其余字符保持不变, 转换前后的字符数量一致, 例: 西遊記 -> 西游记
+func ToSimplified(s string) string {
+	// 不包含繁体字时直接返回原文本
+	if strings.IndexFunc(s, func(r rune) bool { return SimplifiedRune(r) != r }) < 0 {
+		return s
+	}
+	return strings.Map(SimplifiedRune, s)
+}
+
+// SimplifiedRune 将单个繁体字转换为简体字, 非繁体字原样返回
+func SimplifiedRune(r rune) rune {
+	simplifiedOnce.Do(func() {
+		simplifiedMap = make(map[rune]rune, utf8.RuneCountInString(tradChars))
+		simp := []rune(simpChars)
+		for i, t := range []rune(tradChars) {
+			simplifiedMap[t] = simp[i]
+		}
+	})
+	if v, ok := simplifiedMap[r]; ok {
+		return v
+	}
+	return r
+}
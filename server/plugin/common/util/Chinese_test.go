@@ -0,0 +1,57 @@
+package util
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestToSimplified(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"繁体片名", "西遊記", "西游记"},
+		{"繁简混合并保留数字", "鬥羅大陸2", "斗罗大陆2"},
+		{"保留全角标点", "《無間道》", "《无间道》"},
+		{"英文与数字不变", "Harry Potter 7", "Harry Potter 7"},
+		{"简体不变", "西游记", "西游记"},
+		{"空字符串", "", ""},
+		// 以下字符在简体中同样常用, 对照表中未收录, 保持不变
+		{"乾", "乾隆", "乾隆"},
+		{"著", "著名", "著名"},
+		{"吒", "哪吒", "哪吒"},
+		{"阪", "大阪", "大阪"},
+		{"跤", "摔跤", "摔跤"},
+		{"俱", "俱乐部", "俱乐部"},
+		{"瞭", "瞭望", "瞭望"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ToSimplified(tt.in)
+			if got != tt.want {
+				t.Errorf("ToSimplified(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			if utf8.RuneCountInString(got) != utf8.RuneCountInString(tt.in) {
+				t.Errorf("ToSimplified(%q) changed the rune count: %q", tt.in, got)
+			}
+		})
+	}
+}
+
+func TestChineseTable(t *testing.T) {
+	trad, simp := []rune(tradChars), []rune(simpChars)
+	if len(trad) != len(simp) {
+		t.Fatalf("tradChars has %d runes, simpChars has %d", len(trad), len(simp))
+	}
+	seen := make(map[rune]bool, len(trad))
+	for i, r := range trad {
+		if seen[r] {
+			t.Errorf("duplicate traditional character %q at %d", r, i)
+		}
+		seen[r] = true
+		if r == simp[i] {
+			t.Errorf("traditional character %q at %d maps to itself", r, i)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package util
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/mozillazg/go-pinyin"
+)
+
+// pinyinArgs 不带声调的拼音, 多音字使用最常用的读音
+var pinyinArgs = pinyin.NewArgs()
+
+// HasHan 文本中是否包含汉字
+func HasHan(s string) bool {
+	for _, r := range s {
+		if unicode.Is(unicode.Han, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasLetter 文本中是否包含英文字母
+func HasLetter(s string) bool {
+	for _, r := range s {
+		if r < unicode.MaxASCII && unicode.IsLetter(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// Pinyin 将文本转换为全拼, 汉字转换为拼音, 英文字母与数字转换为小写后保留, 其余字符忽略, 例: 西游记2 -> xiyouji2
+func Pinyin(s string) string {
+	return convertPinyin(s, func(p string) string { return p })
+}
+
+// PinyinInitials 将文本转换为拼音首字母, 英文字母与数字转换为小写后保留, 其余字符忽略, 例: 西游记2 -> xyj2
+func PinyinInitials(s string) string {
+	return convertPinyin(s, func(p string) string { return p[:1] })
+}
+
+// convertPinyin 按字符转换文本, fn 处理汉字对应的拼音
+func convertPinyin(s string, fn func(p string) string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			// 繁体字转换为简体字后获取拼音, 避免繁体字的读音缺失
+			if p := pinyin.SinglePinyin(SimplifiedRune(r), pinyinArgs); len(p) > 0 && p[0] != "" {
+				b.WriteString(fn(p[0]))
+			}
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,57 @@
+package util
+
+import "testing"
+
+func TestPinyin(t *testing.T) {
+	tests := []struct {
+		name         string
+		in           string
+		want         string
+		wantInitials string
+	}{
+		{"汉字与数字", "西游记2", "xiyouji2", "xyj2"},
+		{"繁体字", "西遊記", "xiyouji", "xyj"},
+		{"英文转小写", "Hello World", "helloworld", "helloworld"},
+		{"中英混合", "X战警", "xzhanjing", "xzj"},
+		{"忽略标点", "007：大破天幕杀机", "007dapotianmushaji", "007dptmsj"},
+		{"混合分隔符", "速度与激情·8 (2017)", "suduyujiqing82017", "sdyjq82017"},
+		{"忽略全角字母与数字", "西游记２ＡＢ", "xiyouji", "xyj"},
+		{"仅包含符号", "·、（）！", "", ""},
+		{"空字符串", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Pinyin(tt.in); got != tt.want {
+				t.Errorf("Pinyin(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			if got := PinyinInitials(tt.in); got != tt.wantInitials {
+				t.Errorf("PinyinInitials(%q) = %q, want %q", tt.in, got, tt.wantInitials)
+			}
+		})
+	}
+}
+
+func TestHasHanAndLetter(t *testing.T) {
+	tests := []struct {
+		in         string
+		wantHan    bool
+		wantLetter bool
+	}{
+		{"西游记", true, false},
+		{"xyj", false, true},
+		{"X战警", true, true},
+		{"2049", false, false},
+		{"ＡＢＣ", false, false},
+		{"", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := HasHan(tt.in); got != tt.wantHan {
+				t.Errorf("HasHan(%q) = %t, want %t", tt.in, got, tt.wantHan)
+			}
+			if got := HasLetter(tt.in); got != tt.wantLetter {
+				t.Errorf("HasLetter(%q) = %t, want %t", tt.in, got, tt.wantLetter)
+			}
+		})
+	}
+}
@@ -10,8 +10,10 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/url"
 	"regexp"
+	"strings"
 )
 
 // GenerateUUID 生成UUID
@@ -96,6 +98,29 @@ func ValidIPHost(s string) bool {
 	return regexp.MustCompile(`^(http|https)://(\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3})(:[0-9]{1,5})?$`).MatchString(s)
 }
 
+// HostName 提取域名或请求 Host 中的主机名部分, 例: http://Example.com:3600 => example.com
+func HostName(domain string) string {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if domain == "" {
+		return ""
+	}
+	if !strings.Contains(domain, "://") {
+		domain = "//" + domain
+	}
+	if u, err := url.Parse(domain); err == nil && u.Host != "" {
+		return u.Hostname()
+	}
+	if h, _, err := net.SplitHostPort(domain); err == nil {
+		return h
+	}
+	return domain
+}
+
+// ValidTenantId 校验站点标识, 仅允许字母 数字 _ -
+func ValidTenantId(s string) bool {
+	return regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`).MatchString(s)
+}
+
 // ValidURL 校验http链接是否是符合规范的URL
 func ValidURL(s string) bool {
 	_, err := url.ParseRequestURI(s)
@@ -0,0 +1,48 @@
+package util
+
+import "testing"
+
+func TestHostName(t *testing.T) {
+	tests := []struct {
+		name   string
+		domain string
+		want   string
+	}{
+		{"完整链接", "http://Example.com:3600", "example.com"},
+		{"https 链接与路径", "https://www.example.com/index", "www.example.com"},
+		{"请求 Host 带端口", "example.com:8080", "example.com"},
+		{"仅主机名", "Example.COM", "example.com"},
+		{"首尾空白", "  example.com  ", "example.com"},
+		{"IP 地址", "127.0.0.1:3600", "127.0.0.1"},
+		{"空字符串", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HostName(tt.domain); got != tt.want {
+				t.Errorf("HostName(%q) = %q, want %q", tt.domain, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidTenantId(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"site_1", true},
+		{"Site-2", true},
+		{"", false},
+		{"site.1", false},
+		{"站点", false},
+		{"a234567890123456789012345678901b", true},
+		{"a234567890123456789012345678901bc", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			if got := ValidTenantId(tt.id); got != tt.want {
+				t.Errorf("ValidTenantId(%q) = %t, want %t", tt.id, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package db
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+/*
+ 不同数据库方言的差异化 SQL 处理
+*/
+
+const (
+	DialectMysql    = "mysql"
+	DialectSqlite   = "sqlite"
+	DialectPostgres = "postgres"
+)
+
+// Dialect 返回当前关系型数据库的方言名称
+func Dialect() string {
+	return Mdb.Dialector.Name()
+}
+
+// TruncateTable 清空数据表并重置自增ID
+func TruncateTable(table string) error {
+	switch Dialect() {
+	case DialectSqlite:
+		// SQLite 不支持 TRUNCATE, 删除全部数据后重置 sqlite_sequence 中的自增记录
+		if err := Mdb.Exec(fmt.Sprintf("DELETE FROM %s", table)).Error; err != nil {
+			return err
+		}
+		return Mdb.Exec("DELETE FROM sqlite_sequence WHERE name = ?", table).Error
+	case DialectPostgres:
+		// PostgreSQL 的自增序列不会随 TRUNCATE 重置, 需要显式指定 RESTART IDENTITY
+		return Mdb.Exec(fmt.Sprintf("TRUNCATE TABLE %s RESTART IDENTITY", table)).Error
+	default:
+		return Mdb.Exec(fmt.Sprintf("TRUNCATE TABLE %s", table)).Error
+	}
+}
+
+// SetAutoIncrement 设置数据表自增ID的起始值
+func SetAutoIncrement(tx *gorm.DB, table string, start int) error {
+	switch Dialect() {
+	case DialectSqlite:
+		// sqlite_sequence 中记录的是已使用的最大ID, 下一个ID为 seq+1
+		res := tx.Exec("UPDATE sqlite_sequence SET seq = ? WHERE name = ?", start-1, table)
+		if res.Error != nil || res.RowsAffected > 0 {
+			return res.Error
+		}
+		return tx.Exec("INSERT INTO sqlite_sequence (name, seq) VALUES (?, ?)", table, start-1).Error
+	case DialectPostgres:
+		// 自增列由 serial 序列维护, is_called=false 表示下一次 nextval 直接返回 start
+		return tx.Exec("SELECT setval(pg_get_serial_sequence(?, 'id'), ?, false)", table, start).Error
+	default:
+		return tx.Exec(fmt.Sprintf("ALTER TABLE %s AUTO_INCREMENT = %d", table, start)).Error
+	}
+}
+
+// IndexName 返回索引在当前数据库中的实际名称
+// PostgreSQL 中索引名在 schema 内全局唯一, 使用 表名_索引名 避免与其他表冲突
+func IndexName(table, name string) string {
+	if Dialect() == DialectPostgres {
+		return fmt.Sprintf("%s_%s", table, name)
+	}
+	return name
+}
+
+// CreateIndex 为数据表创建索引, columns 为索引列定义, 例如 "update_stamp DESC", 索引已存在时直接跳过
+func CreateIndex(tx *gorm.DB, table, name, columns string, unique bool) error {
+	name = IndexName(table, name)
+	if tx.Migrator().HasIndex(table, name) {
+		return nil
+	}
+	var u string
+	if unique {
+		u = "UNIQUE "
+	}
+	return tx.Exec(fmt.Sprintf("CREATE %sINDEX %s ON %s (%s)", u, name, table, columns)).Error
+}
+
+// DropIndex 删除数据表的索引, 索引不存在时直接跳过
+func DropIndex(tx *gorm.DB, table, name string) error {
+	name = IndexName(table, name)
+	if !tx.Migrator().HasIndex(table, name) {
+		return nil
+	}
+	return tx.Migrator().DropIndex(table, name)
+}
+
+// Like 返回字段模糊匹配的查询条件, PostgreSQL 的 LIKE 区分大小写, 使用 ILIKE 与 MySQL 的默认行为保持一致
+func Like(column string) string {
+	if Dialect() == DialectPostgres {
+		return fmt.Sprintf("%s ILIKE ?", column)
+	}
+	return fmt.Sprintf("%s LIKE ?", column)
+}
+
+// NotLike 返回字段模糊排除的查询条件
+func NotLike(column string) string {
+	if Dialect() == DialectPostgres {
+		return fmt.Sprintf("%s NOT ILIKE ?", column)
+	}
+	return fmt.Sprintf("%s NOT LIKE ?", column)
+}
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"server/config"
+)
+
+/*
+ 基于文件的进程间排他锁
+ 嵌入式模式下 SQLite 数据库, KV 快照以及追加日志只允许一个进程使用, 进程启动时锁定数据文件, 退出时释放
+ 服务运行期间执行命令行子命令 (migrate | backup) 会因无法获取锁而直接退出, 避免两个进程各自的 KV 数据互相覆盖
+*/
+
+// ErrFileLocked 文件锁已被其他进程持有
+var ErrFileLocked = errors.New("file is locked by another process")
+
+// FileLock 文件锁
+type FileLock struct {
+	f *os.File
+}
+
+// TryLockFile 尝试获取 path 的排他锁, 锁已被其他进程持有时立即返回 ErrFileLocked
+func TryLockFile(path string) (*FileLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err = tryLock(f); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return &FileLock{f: f}, nil
+}
+
+// Unlock 释放文件锁
+func (l *FileLock) Unlock() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	err := errors.Join(unlock(l.f), l.f.Close())
+	l.f = nil
+	return err
+}
+
+// dataLock 当前进程持有的数据文件锁
+var dataLock *FileLock
+
+// LockDataDir 锁定嵌入式模式的数据文件, 已被其他进程 (运行中的服务或命令行) 锁定时返回错误
+func LockDataDir() error {
+	l, err := TryLockFile(config.DataLockPath)
+	if errors.Is(err, ErrFileLocked) {
+		return fmt.Errorf("数据文件正在被其他进程使用 (%s), 请先停止运行中的服务或命令后重试", config.DataLockPath)
+	} else if err != nil {
+		return err
+	}
+	// 记录持有锁的进程号, 便于排查
+	if err = l.f.Truncate(0); err == nil {
+		_, _ = l.f.WriteAt([]byte(fmt.Sprint(os.Getpid())), 0)
+	}
+	dataLock = l
+	return nil
+}
+
+// UnlockDataDir 释放数据文件锁
+func UnlockDataDir() error {
+	err := dataLock.Unlock()
+	dataLock = nil
+	return err
+}
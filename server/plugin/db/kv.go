@@ -0,0 +1,252 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"server/config"
+	"sync"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+/*
+ 嵌入式部署使用的进程内 KV 服务
+ 兼容 Redis 协议, Rdb 通过内存管道直接连接该服务, 不对外监听任何端口, 上层调用方式与 Redis 保持一致
+ 数据常驻内存, 写命令实时追加到日志 (见 kvlog.go), 定期以及程序退出时合并为快照, 启动时加载快照并重放日志
+*/
+
+var (
+	kvServer *miniredis.Miniredis
+	kvMutex  sync.Mutex
+	kvStop   chan struct{}
+	kvWait   sync.WaitGroup
+)
+
+// InitEmbeddedKV 启动进程内 KV 服务并从快照文件以及追加日志中恢复数据
+func InitEmbeddedKV() error {
+	kvServer = miniredis.NewMiniRedis()
+	// miniredis 启动时必定监听本地端口, 先设置随机密码再启动, 启动后立即关闭监听, 之后仅通过内存管道访问
+	password, err := randomPassword()
+	if err != nil {
+		return err
+	}
+	kvServer.RequireAuth(password)
+	if err = kvServer.Start(); err != nil {
+		return err
+	}
+	kvServer.Server().Close()
+	Rdb = redis.NewClient(&redis.Options{
+		Dialer:      dialKV,
+		Password:    password,
+		PoolSize:    10,
+		DialTimeout: time.Second * 10,
+	})
+	if err = loadKVSnapshot(); err != nil {
+		return err
+	}
+	count, err := replayKVLog()
+	if err != nil {
+		return err
+	}
+	log.Printf("[KV] 从追加日志重放 %d 条写命令\n", count)
+	if err = kvLog.open(); err != nil {
+		return err
+	}
+	Rdb.AddHook(kvLogHook{})
+	// 日志中的数据合并到快照, 同时清理日志末尾可能存在的不完整命令
+	if fi, e := kvLog.file.Stat(); e == nil && fi.Size() > 0 {
+		if err = SaveKVSnapshot(); err != nil {
+			return err
+		}
+	}
+	kvStop = make(chan struct{})
+	kvWait.Add(1)
+	go kvMaintain()
+	return nil
+}
+
+// dialKV 创建连接到进程内 KV 服务的内存管道
+func dialKV(_ context.Context, _, _ string) (net.Conn, error) {
+	client, server := net.Pipe()
+	kvServer.Server().ServeConn(newKVConn(server))
+	return client, nil
+}
+
+// kvConn 进程内 KV 服务端的管道连接, 响应数据先写入内存缓冲再异步写入管道
+// net.Pipe 没有缓冲区, 批量命令 (Pipeline) 在全部命令发送完成前不会读取响应, 直接写入会导致双方互相阻塞
+type kvConn struct {
+	net.Conn
+	mu     sync.Mutex
+	cond   *sync.Cond
+	buf    []byte
+	closed bool
+}
+
+func newKVConn(c net.Conn) *kvConn {
+	kc := &kvConn{Conn: c}
+	kc.cond = sync.NewCond(&kc.mu)
+	go kc.flush()
+	return kc
+}
+
+// Write 将响应数据追加到缓冲区, 不会阻塞
+func (kc *kvConn) Write(p []byte) (int, error) {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+	if kc.closed {
+		return 0, net.ErrClosed
+	}
+	kc.buf = append(kc.buf, p...)
+	kc.cond.Signal()
+	return len(p), nil
+}
+
+// Close 关闭连接, 未写出的响应数据直接丢弃
+func (kc *kvConn) Close() error {
+	kc.mu.Lock()
+	kc.closed = true
+	kc.cond.Signal()
+	kc.mu.Unlock()
+	return kc.Conn.Close()
+}
+
+// flush 将缓冲区中的数据持续写入管道
+func (kc *kvConn) flush() {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+	for {
+		for len(kc.buf) == 0 && !kc.closed {
+			kc.cond.Wait()
+		}
+		if kc.closed {
+			return
+		}
+		data := kc.buf
+		kc.buf = nil
+		kc.mu.Unlock()
+		_, err := kc.Conn.Write(data)
+		kc.mu.Lock()
+		if err != nil {
+			kc.closed = true
+			return
+		}
+	}
+}
+
+// randomPassword 生成进程内 KV 服务的随机访问密码
+func randomPassword() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// SaveKVSnapshot 将进程内 KV 的全部数据保存到快照文件, 保存完成后清空追加日志
+func SaveKVSnapshot() error {
+	if kvServer == nil {
+		return nil
+	}
+	kvMutex.Lock()
+	defer kvMutex.Unlock()
+	// 保存期间暂停写命令, 确保快照之后的写操作全部记录在清空后的日志中
+	kvLog.gate.Lock()
+	defer kvLog.gate.Unlock()
+	if err := os.MkdirAll(filepath.Dir(config.KVSnapshotPath), os.ModePerm); err != nil {
+		return err
+	}
+	// 先写入临时文件再替换, 避免保存中途退出导致快照损坏
+	tmp := config.KVSnapshotPath + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err = ExportKeys(f, "*"); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, config.KVSnapshotPath); err != nil {
+		return err
+	}
+	return kvLog.truncate()
+}
+
+// CloseKV 保存快照并关闭进程内 KV 服务
+func CloseKV() {
+	if kvServer == nil {
+		return
+	}
+	if kvStop != nil {
+		close(kvStop)
+		kvWait.Wait()
+		kvStop = nil
+	}
+	if err := SaveKVSnapshot(); err != nil {
+		log.Println("Save KV Snapshot Error: ", err)
+	}
+	if err := kvLog.close(); err != nil {
+		log.Println("Close KV Append Log Error: ", err)
+	}
+	_ = Rdb.Close()
+	kvServer.Close()
+	kvServer = nil
+}
+
+// loadKVSnapshot 从快照文件中恢复数据, 快照文件不存在时直接跳过
+func loadKVSnapshot() error {
+	f, err := os.Open(config.KVSnapshotPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	count, err := ImportKeys(f, nil)
+	log.Printf("[KV] 从快照恢复 %d 条数据\n", count)
+	return err
+}
+
+// kvMaintain 推进进程内 KV 的时钟使过期 key 失效, 定期将追加日志刷盘并保存数据快照
+func kvMaintain() {
+	defer kvWait.Done()
+	clock := time.NewTicker(time.Second)
+	defer clock.Stop()
+	flush := time.NewTicker(config.KVAppendSyncInterval)
+	defer flush.Stop()
+	snapshot := time.NewTicker(config.KVSnapshotInterval)
+	defer snapshot.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-kvStop:
+			return
+		case now := <-clock.C:
+			// 进程内 KV 不会自动流逝时间, 需按实际经过的时间推进才能清理过期数据
+			kvServer.FastForward(now.Sub(last))
+			last = now
+		case <-flush.C:
+			if err := kvLog.sync(); err != nil {
+				log.Println("Sync KV Append Log Error: ", err)
+			}
+		case <-snapshot.C:
+			if err := SaveKVSnapshot(); err != nil {
+				log.Println("Save KV Snapshot Error: ", err)
+			}
+		}
+	}
+}
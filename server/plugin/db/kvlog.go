@@ -0,0 +1,352 @@
+package db
+
+import (
+	"bufio"
+	"context"
+	"encoding"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"server/config"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+/*
+ 进程内 KV 的写命令追加日志 (AOF)
+ 所有成功执行的写命令以 RESP 格式追加到日志文件, 按 config.KVAppendSyncInterval 周期刷盘
+ 相对有效期 (EXPIRE | SET EX 等) 在写入日志时转换为绝对时间 PEXPIREAT, 重放时不会延长 key 的有效期
+ 启动时先加载快照再重放日志, 每次保存快照后清空日志
+*/
+
+// kvWriteCommands 需要记录到日志中的写命令
+var kvWriteCommands = map[string]bool{
+	"set": true, "setnx": true, "setex": true, "psetex": true, "getset": true, "getdel": true, "append": true,
+	"incr": true, "incrby": true, "incrbyfloat": true, "decr": true, "decrby": true, "mset": true, "msetnx": true, "setrange": true,
+	"del": true, "unlink": true, "expire": true, "pexpire": true, "expireat": true, "pexpireat": true, "persist": true,
+	"rename": true, "renamenx": true, "copy": true, "flushdb": true, "flushall": true,
+	"hset": true, "hsetnx": true, "hmset": true, "hdel": true, "hincrby": true, "hincrbyfloat": true,
+	"lpush": true, "rpush": true, "lpushx": true, "rpushx": true, "lpop": true, "rpop": true, "lrem": true,
+	"lset": true, "ltrim": true, "linsert": true, "rpoplpush": true, "lmove": true,
+	"sadd": true, "srem": true, "smove": true, "sinterstore": true, "sunionstore": true, "sdiffstore": true,
+	"zadd": true, "zincrby": true, "zrem": true, "zremrangebyrank": true, "zremrangebyscore": true, "zremrangebylex": true,
+	"zpopmin": true, "zpopmax": true, "zunionstore": true, "zinterstore": true, "zdiffstore": true, "zrangestore": true,
+	"eval": true, "evalsha": true,
+}
+
+// appendLog 写命令追加日志
+type appendLog struct {
+	gate sync.RWMutex // 写命令执行期间持有读锁, 保存快照时持有写锁, 保证快照与日志之间不遗漏也不重复
+	mu   sync.Mutex   // 保护日志文件的写入
+	file *os.File
+	w    *bufio.Writer
+}
+
+var kvLog = &appendLog{}
+
+// open 以追加模式打开日志文件
+func (l *appendLog) open() error {
+	if err := os.MkdirAll(filepath.Dir(config.KVAppendPath), os.ModePerm); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(config.KVAppendPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	l.file, l.w = f, bufio.NewWriter(f)
+	l.mu.Unlock()
+	return nil
+}
+
+// append 记录执行成功的写命令
+func (l *appendLog) append(cmds ...redis.Cmder) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.w == nil {
+		return
+	}
+	for _, cmd := range cmds {
+		if !kvWriteCommands[cmd.Name()] || !appliedCmd(cmd) {
+			continue
+		}
+		for _, args := range rewriteCmdArgs(cmd.Args(), time.Now()) {
+			if err := writeRESP(l.w, args); err != nil {
+				log.Println("KV Append Log Error: ", err)
+				return
+			}
+		}
+	}
+}
+
+// sync 将缓冲区数据写入日志文件并刷盘
+func (l *appendLog) sync() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.w == nil {
+		return nil
+	}
+	if err := l.w.Flush(); err != nil {
+		return err
+	}
+	return l.file.Sync()
+}
+
+// truncate 清空日志, 需在持有 gate 写锁且快照已保存后调用
+func (l *appendLog) truncate() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.w == nil {
+		return nil
+	}
+	l.w.Reset(l.file)
+	if err := l.file.Truncate(0); err != nil {
+		return err
+	}
+	return l.file.Sync()
+}
+
+// close 刷盘并关闭日志文件
+func (l *appendLog) close() error {
+	err := l.sync()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil {
+		err = errors.Join(err, l.file.Close())
+		l.file, l.w = nil, nil
+	}
+	return err
+}
+
+// appliedCmd 命令是否实际修改了数据, SET NX | XX 条件未满足时不需要记录
+func appliedCmd(cmd redis.Cmder) bool {
+	err := cmd.Err()
+	if cmd.Name() != "set" {
+		return err == nil || errors.Is(err, redis.Nil)
+	}
+	if b, ok := cmd.(*redis.BoolCmd); ok {
+		return err == nil && b.Val()
+	}
+	return err == nil
+}
+
+// rewriteCmdArgs 将命令中的相对有效期转换为绝对时间, 返回需要写入日志的命令
+func rewriteCmdArgs(args []any, now time.Time) [][]string {
+	cmd := make([]string, len(args))
+	for i, a := range args {
+		cmd[i] = argString(a)
+	}
+	name := strings.ToLower(cmd[0])
+	// expireAt 计算 base + n * unit 对应的 unix 毫秒时间
+	expireAt := func(n string, unit time.Duration, base time.Time) string {
+		v, _ := strconv.ParseInt(n, 10, 64)
+		return strconv.FormatInt(base.Add(time.Duration(v)*unit).UnixMilli(), 10)
+	}
+	switch {
+	case name == "expire" && len(cmd) >= 3:
+		return [][]string{append([]string{"pexpireat", cmd[1], expireAt(cmd[2], time.Second, now)}, cmd[3:]...)}
+	case name == "pexpire" && len(cmd) >= 3:
+		return [][]string{append([]string{"pexpireat", cmd[1], expireAt(cmd[2], time.Millisecond, now)}, cmd[3:]...)}
+	case name == "expireat" && len(cmd) >= 3:
+		return [][]string{append([]string{"pexpireat", cmd[1], expireAt(cmd[2], time.Second, time.UnixMilli(0))}, cmd[3:]...)}
+	case (name == "setex" || name == "psetex") && len(cmd) == 4:
+		unit := time.Second
+		if name == "psetex" {
+			unit = time.Millisecond
+		}
+		return [][]string{{"set", cmd[1], cmd[3]}, {"pexpireat", cmd[1], expireAt(cmd[2], unit, now)}}
+	case name == "set" && len(cmd) >= 3:
+		set, at := cmd[:3:3], ""
+		for i := 3; i < len(cmd); i++ {
+			switch opt := strings.ToLower(cmd[i]); {
+			case opt == "ex" && i+1 < len(cmd):
+				at, i = expireAt(cmd[i+1], time.Second, now), i+1
+			case opt == "px" && i+1 < len(cmd):
+				at, i = expireAt(cmd[i+1], time.Millisecond, now), i+1
+			case opt == "exat" && i+1 < len(cmd):
+				at, i = expireAt(cmd[i+1], time.Second, time.UnixMilli(0)), i+1
+			case opt == "pxat" && i+1 < len(cmd):
+				at, i = cmd[i+1], i+1
+			case opt == "nx" || opt == "xx" || opt == "get":
+				// 条件已在执行时判断, 重放时无需再次判断
+			default:
+				set = append(set, cmd[i])
+			}
+		}
+		if at == "" {
+			return [][]string{set}
+		}
+		return [][]string{set, {"pexpireat", cmd[1], at}}
+	}
+	return [][]string{cmd}
+}
+
+// argString 按 go-redis 的参数编码规则将命令参数转换为字符串
+func argString(a any) string {
+	switch v := a.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case int:
+		return strconv.Itoa(v)
+	case int8, int16, int32, int64:
+		return fmt.Sprint(v)
+	case uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case time.Duration:
+		return strconv.FormatInt(v.Nanoseconds(), 10)
+	case encoding.BinaryMarshaler:
+		if b, err := v.MarshalBinary(); err == nil {
+			return string(b)
+		}
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(a)
+}
+
+// writeRESP 以 RESP 数组格式写入一条命令
+func writeRESP(w io.Writer, args []string) error {
+	if _, err := fmt.Fprintf(w, "*%d\r\n", len(args)); err != nil {
+		return err
+	}
+	for _, a := range args {
+		if _, err := fmt.Fprintf(w, "$%d\r\n%s\r\n", len(a), a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readRESP 读取一条 RESP 数组格式的命令
+func readRESP(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if errors.Is(err, io.EOF) && line != "" {
+		return nil, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") || !strings.HasSuffix(line, "\r\n") {
+		return nil, fmt.Errorf("invalid array header %q", line)
+	}
+	n, err := strconv.Atoi(line[1 : len(line)-2])
+	if err != nil || n <= 0 {
+		return nil, fmt.Errorf("invalid array length %q", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		if line, err = r.ReadString('\n'); errors.Is(err, io.EOF) {
+			return nil, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") || !strings.HasSuffix(line, "\r\n") {
+			return nil, fmt.Errorf("invalid bulk header %q", line)
+		}
+		size, err := strconv.Atoi(line[1 : len(line)-2])
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("invalid bulk length %q", line)
+		}
+		buf := make([]byte, size+2)
+		if _, err = io.ReadFull(r, buf); err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if string(buf[size:]) != "\r\n" {
+			return nil, errors.New("invalid bulk terminator")
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+// replayKVLog 重放日志中的写命令, 日志末尾不完整的命令 (写入中途进程退出) 会被丢弃, 返回重放的命令数量
+func replayKVLog() (int, error) {
+	f, err := os.Open(config.KVAppendPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	r := bufio.NewReaderSize(f, 1024*1024)
+	count := 0
+	for {
+		args, err := readRESP(r)
+		if errors.Is(err, io.EOF) {
+			return count, nil
+		} else if err != nil {
+			log.Printf("[KV] 追加日志第 %d 条命令不完整, 已忽略之后的内容: %v\n", count+1, err)
+			return count, nil
+		}
+		cmd := make([]any, len(args))
+		for i, a := range args {
+			cmd[i] = a
+		}
+		if err = Rdb.Do(Cxt, cmd...).Err(); err != nil && !errors.Is(err, redis.Nil) {
+			return count, fmt.Errorf("replay %s failed: %w", args[0], err)
+		}
+		count++
+	}
+}
+
+// hasWriteCmd 批量命令中是否包含写命令
+func hasWriteCmd(cmds []redis.Cmder) bool {
+	for _, cmd := range cmds {
+		if kvWriteCommands[cmd.Name()] {
+			return true
+		}
+	}
+	return false
+}
+
+// kvLogHook 将写命令记录到追加日志的 go-redis hook
+type kvLogHook struct{}
+
+func (kvLogHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (kvLogHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if !kvWriteCommands[cmd.Name()] {
+			return next(ctx, cmd)
+		}
+		kvLog.gate.RLock()
+		defer kvLog.gate.RUnlock()
+		err := next(ctx, cmd)
+		kvLog.append(cmd)
+		return err
+	}
+}
+
+func (kvLogHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if !hasWriteCmd(cmds) {
+			return next(ctx, cmds)
+		}
+		kvLog.gate.RLock()
+		defer kvLog.gate.RUnlock()
+		err := next(ctx, cmds)
+		kvLog.append(cmds...)
+		return err
+	}
+}
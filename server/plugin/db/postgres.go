@@ -0,0 +1,34 @@
+package db
+
+import (
+	"server/config"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// InitPostgres 初始化 PostgreSQL 数据库连接
+func InitPostgres() (err error) {
+	Mdb, err = gorm.Open(postgres.New(postgres.Config{DSN: config.PostgresDsn}), gormConfig())
+	return
+}
+
+// InitDatabase 按照 config.DBDriver 初始化对应的关系型数据库连接
+func InitDatabase() error {
+	if config.DBDriver == config.DriverPostgres {
+		return InitPostgres()
+	}
+	return InitMysql()
+}
+
+// CloseDatabase 关闭关系型数据库连接, 会等待已开始执行的 SQL 完成
+func CloseDatabase() error {
+	if Mdb == nil {
+		return nil
+	}
+	sqlDB, err := Mdb.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
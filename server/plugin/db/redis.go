@@ -35,3 +35,55 @@ func InitRedisConn() error {
 func CloseRedis() error {
 	return Rdb.Close()
 }
+
+// ScanKeys 通过 SCAN 分批扫描并返回所有匹配 pattern 的 key, 避免 KEYS 命令阻塞 redis
+func ScanKeys(pattern string) ([]string, error) {
+	var keys []string
+	iter := Rdb.Scan(Cxt, 0, pattern, config.MaxScanCount).Iterator()
+	for iter.Next(Cxt) {
+		keys = append(keys, iter.Val())
+	}
+	return keys, iter.Err()
+}
+
+// UnlinkKeys 通过 SCAN 分批扫描匹配 pattern 的 key 并使用 UNLINK 异步删除, 返回删除的 key 数量
+// progress 不为空时每删除一批 key 回调一次当前已删除的总数
+func UnlinkKeys(pattern string, progress func(deleted int)) (int, error) {
+	return UnlinkKeysExcept(pattern, nil, progress)
+}
+
+// UnlinkKeysExcept 与 UnlinkKeys 相同, keep 不为空时保留 keep 返回 true 的 key
+func UnlinkKeysExcept(pattern string, keep func(key string) bool, progress func(deleted int)) (int, error) {
+	deleted := 0
+	batch := make([]string, 0, config.MaxScanCount)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		n, err := Rdb.Unlink(Cxt, batch...).Result()
+		if err != nil {
+			return err
+		}
+		deleted += int(n)
+		batch = batch[:0]
+		if progress != nil {
+			progress(deleted)
+		}
+		return nil
+	}
+	iter := Rdb.Scan(Cxt, 0, pattern, config.MaxScanCount).Iterator()
+	for iter.Next(Cxt) {
+		if keep != nil && keep(iter.Val()) {
+			continue
+		}
+		if batch = append(batch, iter.Val()); len(batch) >= config.MaxScanCount {
+			if err := flush(); err != nil {
+				return deleted, err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return deleted, err
+	}
+	return deleted, flush()
+}
@@ -0,0 +1,173 @@
+package db
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+/*
+ Redis 键空间的导出与导入
+ 导出格式为 JSON Lines, 每行记录一个 key 的类型、剩余有效期以及完整数据
+*/
+
+// KeyEntry 单个 key 的导出数据
+type KeyEntry struct {
+	Key   string          `json:"key"`   // key名称
+	Type  string          `json:"type"`  // 数据类型 string | hash | zset | set | list
+	TTL   int64           `json:"ttl"`   // 剩余有效期, 单位 ms, 0 表示永久有效
+	Value json.RawMessage `json:"value"` // 数据内容
+}
+
+// scanBatchSize Scan 操作每次扫描的数据量
+const scanBatchSize = 300
+
+// ExportKeys 通过 SCAN 扫描匹配 patterns 的 key, 并将数据逐行写入 w, 返回导出的 key 数量
+func ExportKeys(w io.Writer, patterns ...string) (int, error) {
+	enc := json.NewEncoder(w)
+	count := 0
+	for _, p := range patterns {
+		iter := Rdb.Scan(Cxt, 0, p, scanBatchSize).Iterator()
+		for iter.Next(Cxt) {
+			entry, err := dumpKey(iter.Val())
+			if err != nil {
+				return count, err
+			}
+			// key 在扫描期间过期或被删除, 直接跳过
+			if entry == nil {
+				continue
+			}
+			if err = enc.Encode(entry); err != nil {
+				return count, err
+			}
+			count++
+		}
+		if err := iter.Err(); err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// ImportKeys 读取 ExportKeys 导出的数据并写回, 已存在的同名 key 会被覆盖, rename 不为空时使用其返回值作为写入的 key
+func ImportKeys(r io.Reader, rename func(key string) string) (int, error) {
+	scanner := bufio.NewScanner(r)
+	// 单个 key 的数据可能较大, 扩大单行缓冲区
+	scanner.Buffer(make([]byte, 1024*1024), 512*1024*1024)
+	count := 0
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry KeyEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return count, err
+		}
+		if rename != nil {
+			entry.Key = rename(entry.Key)
+		}
+		if err := restoreKey(entry); err != nil {
+			return count, fmt.Errorf("restore key %s failed: %w", entry.Key, err)
+		}
+		count++
+	}
+	return count, scanner.Err()
+}
+
+// dumpKey 读取单个 key 的完整数据
+func dumpKey(key string) (*KeyEntry, error) {
+	t, err := Rdb.Type(Cxt, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	switch t {
+	case "string":
+		value, err = Rdb.Get(Cxt, key).Result()
+	case "hash":
+		value, err = Rdb.HGetAll(Cxt, key).Result()
+	case "zset":
+		value, err = Rdb.ZRangeWithScores(Cxt, key, 0, -1).Result()
+	case "set":
+		value, err = Rdb.SMembers(Cxt, key).Result()
+	case "list":
+		value, err = Rdb.LRange(Cxt, key, 0, -1).Result()
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s of key %s", t, key)
+	}
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	entry := &KeyEntry{Key: key, Type: t, Value: data}
+	// PTTL 返回 -1 (永久有效) | -2 (不存在) 时均按永久有效处理
+	if ttl, e := Rdb.PTTL(Cxt, key).Result(); e == nil && ttl > 0 {
+		entry.TTL = ttl.Milliseconds()
+	}
+	return entry, nil
+}
+
+// restoreKey 将单个 key 的数据写回
+func restoreKey(entry KeyEntry) error {
+	pipe := Rdb.TxPipeline()
+	pipe.Del(Cxt, entry.Key)
+	switch entry.Type {
+	case "string":
+		var v string
+		if err := json.Unmarshal(entry.Value, &v); err != nil {
+			return err
+		}
+		pipe.Set(Cxt, entry.Key, v, 0)
+	case "hash":
+		var v map[string]string
+		if err := json.Unmarshal(entry.Value, &v); err != nil {
+			return err
+		}
+		if len(v) > 0 {
+			pipe.HSet(Cxt, entry.Key, v)
+		}
+	case "zset":
+		var v []redis.Z
+		if err := json.Unmarshal(entry.Value, &v); err != nil {
+			return err
+		}
+		if len(v) > 0 {
+			pipe.ZAdd(Cxt, entry.Key, v...)
+		}
+	case "set", "list":
+		var v []string
+		if err := json.Unmarshal(entry.Value, &v); err != nil {
+			return err
+		}
+		if len(v) > 0 {
+			members := make([]any, 0, len(v))
+			for _, m := range v {
+				members = append(members, m)
+			}
+			if entry.Type == "set" {
+				pipe.SAdd(Cxt, entry.Key, members...)
+			} else {
+				pipe.RPush(Cxt, entry.Key, members...)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported type %s", entry.Type)
+	}
+	if entry.TTL > 0 {
+		pipe.PExpire(Cxt, entry.Key, time.Duration(entry.TTL)*time.Millisecond)
+	}
+	_, err := pipe.Exec(Cxt)
+	return err
+}
@@ -0,0 +1,27 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"server/config"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+/*
+ 嵌入式部署使用的 SQLite 数据库 (纯 Go 实现, 无需 CGO)
+*/
+
+// InitSqlite 初始化 SQLite 数据库连接
+func InitSqlite() (err error) {
+	// 确保数据库文件所在目录存在
+	if err = os.MkdirAll(filepath.Dir(config.SqlitePath), os.ModePerm); err != nil {
+		return
+	}
+	// 开启 WAL 模式提高读写并发, busy_timeout 避免并发写入时直接返回 database is locked
+	dsn := fmt.Sprintf("%s?_pragma=journal_mode(WAL)&_pragma=busy_timeout(10000)&_pragma=foreign_keys(1)", config.SqlitePath)
+	Mdb, err = gorm.Open(sqlite.Open(dsn), gormConfig())
+	return
+}
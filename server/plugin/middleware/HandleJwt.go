@@ -27,7 +27,7 @@ func AuthToken() gin.HandlerFunc {
 		// 解析token中的信息
 		uc, err := system.ParseToken(authToken)
 		// 从Redis中获取对应的token是否存在, 如果存在则刷新token
-		t := system.GetUserTokenById(uc.UserID)
+		t := system.Repo.User.GetToken(uc.UserID)
 		// 如果 redis中获取的token为空则登录已过期需重新登录
 		if len(t) <= 0 {
 			system.CustomResult(http.StatusUnauthorized, system.SUCCESS, nil, "身份验证信息已失效,请重新登录!!!", c)
@@ -45,7 +45,7 @@ func AuthToken() gin.HandlerFunc {
 			// 生成新token
 			newToken, _ := system.GenToken(uc.UserID, uc.UserName)
 			// 将新token同步到redis中
-			_ = system.SaveUserToken(newToken, uc.UserID)
+			_ = system.Repo.User.SaveToken(newToken, uc.UserID)
 			// 解析出新的 UserClaims
 			uc, _ = system.ParseToken(newToken)
 			c.Header("new-token", newToken)
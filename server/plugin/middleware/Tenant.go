@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"server/config"
+	"server/model/system"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Tenant 解析当前请求所属的站点, 优先使用 X-Tenant-Id 请求头, 其次匹配请求域名
+func Tenant() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 经由前端服务代理的请求使用 X-Forwarded-Host 保留原始域名
+		host := c.Request.Header.Get("X-Forwarded-Host")
+		if host == "" {
+			host = c.Request.Host
+		}
+		c.Set(config.TenantCtxKey, system.ResolveTenant(host, c.Request.Header.Get(config.TenantHeader)))
+		c.Next()
+	}
+}
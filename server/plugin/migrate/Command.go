@@ -0,0 +1,66 @@
+package migrate
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+/*
+	数据库迁移命令行
+	server migrate list                      查看所有版本的应用状态
+	server migrate apply [-to version]       应用未执行的版本 (默认全部)
+	server migrate rollback [-steps n | -to version]  回滚已应用的版本 (默认回滚最近 1 个版本)
+*/
+
+// RunCommand 执行数据库迁移子命令
+func RunCommand(args []string) error {
+	if len(args) == 0 {
+		return usage()
+	}
+	fs := flag.NewFlagSet(fmt.Sprint("migrate ", args[0]), flag.ContinueOnError)
+	to := fs.Int64("to", 0, "目标版本号")
+	steps := fs.Int("steps", 1, "回滚的版本数量")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	switch args[0] {
+	case "list":
+		return printList()
+	case "apply":
+		if err := Up(*to); err != nil {
+			return err
+		}
+		return printList()
+	case "rollback":
+		if err := Rollback(*steps, *to); err != nil {
+			return err
+		}
+		return printList()
+	default:
+		return usage()
+	}
+}
+
+// printList 以表格形式输出所有版本的应用状态
+func printList() error {
+	list, err := List()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, s := range list {
+		status, at := "pending", "-"
+		if s.Applied {
+			status, at = "applied", s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Version, s.Name, status, at)
+	}
+	return w.Flush()
+}
+
+func usage() error {
+	return fmt.Errorf("usage: migrate list | apply [-to version] | rollback [-steps n | -to version]")
+}
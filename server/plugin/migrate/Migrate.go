@@ -0,0 +1,233 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"server/config"
+	"server/plugin/common/util"
+	"server/plugin/db"
+
+	"gorm.io/gorm"
+)
+
+/*
+	数据库版本迁移
+	每个 Migration 拥有唯一递增的版本号以及 Up/Down 两个步骤, 已执行的版本记录在 schema_migrations 表中
+	程序启动时自动执行所有未应用的版本, 多实例同时启动时通过 redis 锁保证同一时刻只有一个实例执行迁移
+*/
+
+// Migration 单个版本的数据库变更
+type Migration struct {
+	Version int64                   // 版本号, 递增且唯一
+	Name    string                  // 变更描述
+	Up      func(tx *gorm.DB) error // 应用变更
+	Down    func(tx *gorm.DB) error // 回滚变更
+}
+
+// SchemaMigration 已应用的版本记录
+type SchemaMigration struct {
+	Version   int64     `json:"version" gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `json:"name"`
+	AppliedAt time.Time `json:"appliedAt"`
+}
+
+// TableName 版本记录表表名
+func (SchemaMigration) TableName() string {
+	return config.SchemaMigrationTableName
+}
+
+// Status 版本迁移状态
+type Status struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"appliedAt"`
+}
+
+// migrations 已注册的所有版本, 按版本号升序排列
+var migrations []Migration
+
+// register 注册数据库版本变更
+func register(m ...Migration) {
+	migrations = append(migrations, m...)
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+}
+
+// Up 执行所有未应用的版本, target > 0 时只执行到 target 版本为止
+func Up(target int64) error {
+	return withLock(func() error {
+		applied, err := appliedVersions()
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			if target > 0 && m.Version > target {
+				break
+			}
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			if err = apply(m); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Rollback 回滚最近应用的 steps 个版本, target > 0 时回滚所有大于 target 的版本
+func Rollback(steps int, target int64) error {
+	return withLock(func() error {
+		applied, err := appliedVersions()
+		if err != nil {
+			return err
+		}
+		for i := len(migrations) - 1; i >= 0; i-- {
+			m := migrations[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			if target > 0 {
+				if m.Version <= target {
+					break
+				}
+			} else if steps <= 0 {
+				break
+			}
+			if err = revert(m); err != nil {
+				return err
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+// List 返回所有版本的应用状态
+func List() ([]Status, error) {
+	applied, err := appliedVersions()
+	if err != nil {
+		return nil, err
+	}
+	var list []Status
+	for _, m := range migrations {
+		s := Status{Version: m.Version, Name: m.Name}
+		if r, ok := applied[m.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = &r.AppliedAt
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+// Latest 返回已注册的最新版本号
+func Latest() int64 {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+// apply 在事务中执行版本变更并记录版本号
+func apply(m Migration) error {
+	start := time.Now()
+	err := db.Mdb.Transaction(func(tx *gorm.DB) error {
+		if err := m.Up(tx); err != nil {
+			return err
+		}
+		return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migrate up %d_%s failed: %w", m.Version, m.Name, err)
+	}
+	log.Printf("[Migrate] up %d_%s, 耗时: %s\n", m.Version, m.Name, time.Since(start))
+	return nil
+}
+
+// revert 在事务中回滚版本变更并删除版本记录
+func revert(m Migration) error {
+	if m.Down == nil {
+		return fmt.Errorf("migrate down %d_%s failed: 当前版本不支持回滚", m.Version, m.Name)
+	}
+	err := db.Mdb.Transaction(func(tx *gorm.DB) error {
+		if err := m.Down(tx); err != nil {
+			return err
+		}
+		return tx.Delete(&SchemaMigration{}, "version = ?", m.Version).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migrate down %d_%s failed: %w", m.Version, m.Name, err)
+	}
+	log.Printf("[Migrate] down %d_%s\n", m.Version, m.Name)
+	return nil
+}
+
+// appliedVersions 获取已应用的版本记录, 版本记录表不存在时自动创建
+func appliedVersions() (map[int64]SchemaMigration, error) {
+	if !db.Mdb.Migrator().HasTable(&SchemaMigration{}) {
+		if err := db.Mdb.Migrator().CreateTable(&SchemaMigration{}); err != nil {
+			return nil, err
+		}
+	}
+	var list []SchemaMigration
+	if err := db.Mdb.Find(&list).Error; err != nil {
+		return nil, err
+	}
+	res := make(map[int64]SchemaMigration)
+	for _, r := range list {
+		res[r.Version] = r
+	}
+	return res, nil
+}
+
+// unlockScript 仅释放当前实例持有的锁
+const unlockScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) else return 0 end`
+
+// withLock 获取迁移锁后执行 fn, 锁被其他实例持有时等待其释放
+func withLock(fn func() error) error {
+	// 嵌入式模式下 KV 为进程私有, 无法在进程间互斥, 改用数据文件旁的文件锁
+	if config.StorageMode == config.StorageEmbedded {
+		return withFileLock(fn)
+	}
+	token := util.GenerateUUID()
+	deadline := time.Now().Add(config.MigrateLockWait)
+	for {
+		ok, err := db.Rdb.SetNX(db.Cxt, config.MigrateLockKey, token, config.MigrateLockExpired).Result()
+		if err != nil {
+			return err
+		}
+		if ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			return errors.New("获取数据库迁移锁超时, 其他实例正在执行迁移")
+		}
+		time.Sleep(time.Second)
+	}
+	defer db.Rdb.Eval(db.Cxt, unlockScript, []string{config.MigrateLockKey}, token)
+	return fn()
+}
+
+// withFileLock 获取迁移文件锁后执行 fn, 进程退出时系统自动释放文件锁
+func withFileLock(fn func() error) error {
+	deadline := time.Now().Add(config.MigrateLockWait)
+	for {
+		l, err := db.TryLockFile(config.MigrateLockPath)
+		if err == nil {
+			defer l.Unlock()
+			return fn()
+		}
+		if !errors.Is(err, db.ErrFileLocked) {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return errors.New("获取数据库迁移锁超时, 其他实例正在执行迁移")
+		}
+		time.Sleep(time.Second)
+	}
+}
@@ -0,0 +1,59 @@
+package migrate
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestRegisteredMigrations(t *testing.T) {
+	if len(migrations) == 0 {
+		t.Fatal("no migrations registered")
+	}
+	names := make(map[string]bool)
+	for i, m := range migrations {
+		// 版本号从 1 开始连续递增
+		if want := int64(i + 1); m.Version != want {
+			t.Errorf("migrations[%d].Version = %d, want %d", i, m.Version, want)
+		}
+		if m.Name == "" || names[m.Name] {
+			t.Errorf("migration %d has an empty or duplicate name %q", m.Version, m.Name)
+		}
+		names[m.Name] = true
+		if m.Up == nil || m.Down == nil {
+			t.Errorf("migration %d (%s) is missing Up or Down", m.Version, m.Name)
+		}
+	}
+}
+
+func TestRegisterSortsByVersion(t *testing.T) {
+	noop := func(tx *gorm.DB) error { return nil }
+	tests := []struct {
+		name     string
+		versions []int64
+		want     []int64
+	}{
+		{"已排序", []int64{1, 2, 3}, []int64{1, 2, 3}},
+		{"倒序", []int64{3, 2, 1}, []int64{1, 2, 3}},
+		{"乱序", []int64{2, 5, 1, 4, 3}, []int64{1, 2, 3, 4, 5}},
+		{"单个版本", []int64{7}, []int64{7}},
+	}
+	saved := migrations
+	defer func() { migrations = saved }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations = nil
+			for _, v := range tt.versions {
+				register(Migration{Version: v, Name: "test", Up: noop, Down: noop})
+			}
+			if len(migrations) != len(tt.want) {
+				t.Fatalf("registered %d migrations, want %d", len(migrations), len(tt.want))
+			}
+			for i, m := range migrations {
+				if m.Version != tt.want[i] {
+					t.Errorf("migrations[%d].Version = %d, want %d", i, m.Version, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,768 @@
+package migrate
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"server/config"
+	"server/plugin/common/util"
+	"server/plugin/db"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+/*
+	数据库版本变更记录
+	每个版本使用当时的表结构快照建表, 后续修改 model 中的结构体时需新增版本, 不要修改已发布的版本
+	初始版本兼容已部署的旧数据库: 数据表或索引已存在时直接跳过
+*/
+
+// userV1 初始版本的用户表结构
+type userV1 struct {
+	gorm.Model
+	UserName string
+	Password string
+	Salt     string
+	Email    string
+	Gender   int
+	NickName string
+	Avatar   string
+	Status   int
+	Reserve1 string
+	Reserve2 string
+	Reserve3 string
+}
+
+func (userV1) TableName() string { return config.UserTableName }
+
+// searchV1 初始版本的影片检索信息表结构
+type searchV1 struct {
+	gorm.Model
+	Mid          int64
+	Cid          int64
+	Pid          int64
+	Name         string
+	SubTitle     string
+	CName        string
+	ClassTag     string
+	Area         string
+	Language     string
+	Year         int64
+	Initial      string
+	Score        float64
+	UpdateStamp  int64
+	Hits         int64
+	State        string
+	Remarks      string
+	ReleaseStamp int64
+}
+
+func (searchV1) TableName() string { return config.SearchTableName }
+
+// searchV2 检索信息中增加豆瓣ID, 用于重复影片检测
+type searchV2 struct {
+	searchV1
+	DbId int64
+}
+
+// searchV3 检索信息中增加连载信息, 用于完结状态筛选与放送表
+type searchV3 struct {
+	searchV2
+	Total    int64  `gorm:"default:0"`
+	Serial   int64  `gorm:"default:0"`
+	IsEnd    bool   `gorm:"default:false"`
+	Weekdays int    `gorm:"default:0"`
+	Tv       string `gorm:"default:''"`
+}
+
+// searchV3Columns searchV3 中新增的字段
+var searchV3Columns = []string{"Total", "Serial", "IsEnd", "Weekdays", "Tv"}
+
+// searchV4 检索信息中增加画质 版本 字幕标签
+type searchV4 struct {
+	searchV3
+	Quality  string `gorm:"default:''"`
+	Version  string `gorm:"default:''"`
+	Subtitle string `gorm:"default:''"`
+}
+
+// searchV4Columns searchV4 中新增的字段
+var searchV4Columns = []string{"Quality", "Version", "Subtitle"}
+
+// fileV1 初始版本的图片信息表结构
+type fileV1 struct {
+	gorm.Model
+	Link        string
+	Uid         int
+	RelevanceId int64
+	Type        int
+	Fid         string
+	FileType    string
+}
+
+func (fileV1) TableName() string { return config.FileTableName }
+
+// failureRecordV1 初始版本的采集失败记录表结构
+type failureRecordV1 struct {
+	gorm.Model
+	OriginId    string
+	OriginName  string
+	Uri         string
+	CollectType int
+	PageNumber  int
+	Hour        int
+	Cause       string
+	Status      int
+}
+
+func (failureRecordV1) TableName() string { return config.FailureRecordTableName }
+
+// filmRecycleV1 初始版本的影片回收站表结构
+type filmRecycleV1 struct {
+	gorm.Model
+	Mid      int64 `gorm:"uniqueIndex"`
+	Cid      int64
+	Pid      int64
+	Name     string
+	CName    string
+	Operator string
+}
+
+func (filmRecycleV1) TableName() string { return config.FilmRecycleTableName }
+
+// filmBlockV1 初始版本的影片屏蔽规则表结构
+type filmBlockV1 struct {
+	gorm.Model
+	Type     string
+	SourceId string
+	VodId    int64
+	DbId     int64
+	Pattern  string
+	Remark   string
+	Operator string
+}
+
+func (filmBlockV1) TableName() string { return config.FilmBlockTableName }
+
+// filmBlockAuditV1 初始版本的屏蔽规则操作记录表结构
+type filmBlockAuditV1 struct {
+	gorm.Model
+	BlockId  uint
+	Action   string
+	Rule     string
+	Operator string
+}
+
+func (filmBlockAuditV1) TableName() string { return config.FilmBlockAuditTableName }
+
+// filmHiddenV1 初始版本的隐藏影片表结构
+type filmHiddenV1 struct {
+	gorm.Model
+	Mid      int64 `gorm:"uniqueIndex"`
+	Operator string
+}
+
+func (filmHiddenV1) TableName() string { return config.FilmHiddenTableName }
+
+// personV1 初始版本的影人表结构
+type personV1 struct {
+	gorm.Model
+	Name string `gorm:"size:128;uniqueIndex"`
+}
+
+func (personV1) TableName() string { return config.PersonTableName }
+
+// filmPersonV1 初始版本的影片影人关联表结构
+type filmPersonV1 struct {
+	ID       uint   `gorm:"primarykey"`
+	Mid      int64  `gorm:"index"`
+	PersonId uint   `gorm:"index:idx_film_persons_person"`
+	Role     string `gorm:"size:16;index:idx_film_persons_person"`
+	Sort     int
+}
+
+func (filmPersonV1) TableName() string { return config.FilmPersonTableName }
+
+// actorV1 初始版本的演员资料表结构
+type actorV1 struct {
+	ID          uint `gorm:"primarykey"`
+	Sid         string
+	OriginId    int64
+	Name        string `gorm:"size:128;uniqueIndex"`
+	En          string
+	Alias       string
+	Sex         string
+	Area        string
+	Height      string
+	Weight      string
+	Birthday    string
+	BirthArea   string
+	Blood       string
+	StarSign    string
+	School      string
+	Works       string
+	Tag         string
+	Pic         string
+	Blurb       string
+	Content     string
+	UpdateStamp int64
+}
+
+func (actorV1) TableName() string { return config.ActorTableName }
+
+// filmRoleV1 初始版本的影片角色表结构
+type filmRoleV1 struct {
+	ID          uint   `gorm:"primarykey"`
+	Sid         string `gorm:"size:64;uniqueIndex:idx_film_roles_origin"`
+	OriginId    int64  `gorm:"uniqueIndex:idx_film_roles_origin"`
+	Mid         int64  `gorm:"index"`
+	Name        string
+	Actor       string `gorm:"size:128;index"`
+	Pic         string
+	Remarks     string
+	Content     string
+	Sort        int
+	UpdateStamp int64
+}
+
+func (filmRoleV1) TableName() string { return config.FilmRoleTableName }
+
+// articleV1 初始版本的文章资讯表结构
+type articleV1 struct {
+	ID          uint   `gorm:"primarykey"`
+	Sid         string `gorm:"size:64;uniqueIndex:idx_articles_origin"`
+	OriginId    int64  `gorm:"uniqueIndex:idx_articles_origin"`
+	TypeName    string `gorm:"size:64;index"`
+	Name        string
+	Sub         string
+	From        string
+	Author      string
+	Tag         string
+	Pic         string
+	Blurb       string
+	Remarks     string
+	RelVod      string
+	Content     string
+	UpdateStamp int64 `gorm:"index"`
+}
+
+func (articleV1) TableName() string { return config.ArticleTableName }
+
+// filmTextV1 初始版本的影片全文检索信息表结构
+type filmTextV1 struct {
+	Mid      int64 `gorm:"primaryKey;autoIncrement:false"`
+	Name     string
+	Alias    string
+	EnName   string
+	Actor    string
+	Director string
+	Content  string `gorm:"type:text"`
+}
+
+func (filmTextV1) TableName() string { return config.FilmTextTableName }
+
+// filmTextV2 影片全文检索信息中增加片名的全拼与拼音首字母
+type filmTextV2 struct {
+	filmTextV1
+	Pinyin   string `gorm:"default:''"`
+	Initials string `gorm:"default:''"`
+}
+
+// filmTextV2Columns filmTextV2 中新增的字段
+var filmTextV2Columns = []string{"Pinyin", "Initials"}
+
+// filmTextV3 采集站的别名与影人列表长度不定, 片名等字段改为 text 类型
+type filmTextV3 struct {
+	Mid      int64  `gorm:"primaryKey;autoIncrement:false"`
+	Name     string `gorm:"type:text"`
+	Alias    string `gorm:"type:text"`
+	EnName   string `gorm:"type:text"`
+	Actor    string `gorm:"type:text"`
+	Director string `gorm:"type:text"`
+	Content  string `gorm:"type:text"`
+	Pinyin   string `gorm:"default:''"`
+	Initials string `gorm:"default:''"`
+}
+
+func (filmTextV3) TableName() string { return config.FilmTextTableName }
+
+// filmTextV3Columns filmTextV3 中修改类型的字段
+var filmTextV3Columns = []string{"Name", "Alias", "EnName", "Actor", "Director"}
+
+// searchIndexes search表的常用查询字段索引
+var searchIndexes = []struct {
+	Name    string
+	Columns string
+	Unique  bool
+}{
+	{Name: "idx_mid", Columns: "mid", Unique: true},
+	{Name: "idx_time", Columns: "update_stamp DESC"},
+	{Name: "idx_hits", Columns: "hits DESC"},
+	{Name: "idx_score", Columns: "score DESC"},
+	{Name: "idx_release", Columns: "release_stamp DESC"},
+	{Name: "idx_year", Columns: "year DESC"},
+}
+
+func init() {
+	register(
+		Migration{
+			Version: 1,
+			Name:    "create_users",
+			Up: func(tx *gorm.DB) error {
+				if tx.Migrator().HasTable(&userV1{}) {
+					return nil
+				}
+				if err := tx.Migrator().CreateTable(&userV1{}); err != nil {
+					return err
+				}
+				// 用户ID从 10000 开始递增
+				return db.SetAutoIncrement(tx, config.UserTableName, config.UserIdInitialVal)
+			},
+			Down: func(tx *gorm.DB) error { return tx.Migrator().DropTable(&userV1{}) },
+		},
+		Migration{
+			Version: 2,
+			Name:    "create_search",
+			Up:      createTable(&searchV1{}),
+			Down:    func(tx *gorm.DB) error { return tx.Migrator().DropTable(&searchV1{}) },
+		},
+		Migration{
+			Version: 3,
+			Name:    "add_search_indexes",
+			Up: func(tx *gorm.DB) error {
+				for _, i := range searchIndexes {
+					if err := db.CreateIndex(tx, config.SearchTableName, i.Name, i.Columns, i.Unique); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			Down: func(tx *gorm.DB) error {
+				for _, i := range searchIndexes {
+					if err := db.DropIndex(tx, config.SearchTableName, i.Name); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		Migration{
+			Version: 4,
+			Name:    "create_files",
+			Up:      createTable(&fileV1{}),
+			Down:    func(tx *gorm.DB) error { return tx.Migrator().DropTable(&fileV1{}) },
+		},
+		Migration{
+			Version: 5,
+			Name:    "create_failure_records",
+			Up:      createTable(&failureRecordV1{}),
+			Down:    func(tx *gorm.DB) error { return tx.Migrator().DropTable(&failureRecordV1{}) },
+		},
+		Migration{
+			Version: 6,
+			Name:    "create_film_recycle",
+			Up:      createTable(&filmRecycleV1{}),
+			Down:    func(tx *gorm.DB) error { return tx.Migrator().DropTable(&filmRecycleV1{}) },
+		},
+		Migration{
+			Version: 7,
+			Name:    "create_film_blocks",
+			Up: func(tx *gorm.DB) error {
+				if err := createTable(&filmBlockV1{})(tx); err != nil {
+					return err
+				}
+				return createTable(&filmBlockAuditV1{})(tx)
+			},
+			Down: func(tx *gorm.DB) error { return tx.Migrator().DropTable(&filmBlockAuditV1{}, &filmBlockV1{}) },
+		},
+		Migration{
+			Version: 8,
+			Name:    "restore_hidden_category_search",
+			Up:      restoreHiddenCategorySearch,
+			Down:    func(tx *gorm.DB) error { return nil },
+		},
+		Migration{
+			Version: 9,
+			Name:    "create_film_hidden",
+			Up:      createTable(&filmHiddenV1{}),
+			Down:    func(tx *gorm.DB) error { return tx.Migrator().DropTable(&filmHiddenV1{}) },
+		},
+		Migration{
+			Version: 10,
+			Name:    "add_search_db_id",
+			Up: func(tx *gorm.DB) error {
+				if !tx.Migrator().HasColumn(&searchV2{}, "DbId") {
+					if err := tx.Migrator().AddColumn(&searchV2{}, "DbId"); err != nil {
+						return err
+					}
+				}
+				if err := db.CreateIndex(tx, config.SearchTableName, "idx_db_id", "db_id", false); err != nil {
+					return err
+				}
+				return fillSearchDbId(tx)
+			},
+			Down: func(tx *gorm.DB) error {
+				if err := db.DropIndex(tx, config.SearchTableName, "idx_db_id"); err != nil {
+					return err
+				}
+				return tx.Migrator().DropColumn(&searchV2{}, "DbId")
+			},
+		},
+		Migration{
+			// 已有影片的影人信息在服务启动时于后台补全
+			Version: 11,
+			Name:    "create_persons",
+			Up: func(tx *gorm.DB) error {
+				if err := createTable(&personV1{})(tx); err != nil {
+					return err
+				}
+				return createTable(&filmPersonV1{})(tx)
+			},
+			Down: func(tx *gorm.DB) error { return tx.Migrator().DropTable(&filmPersonV1{}, &personV1{}) },
+		},
+		Migration{
+			Version: 12,
+			Name:    "create_actors_roles_articles",
+			Up: func(tx *gorm.DB) error {
+				for _, m := range []interface{}{&actorV1{}, &filmRoleV1{}, &articleV1{}} {
+					if err := createTable(m)(tx); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			Down: func(tx *gorm.DB) error { return tx.Migrator().DropTable(&articleV1{}, &filmRoleV1{}, &actorV1{}) },
+		},
+		Migration{
+			Version: 13,
+			Name:    "add_search_serial",
+			Up: func(tx *gorm.DB) error {
+				for _, c := range searchV3Columns {
+					if tx.Migrator().HasColumn(&searchV3{}, c) {
+						continue
+					}
+					if err := tx.Migrator().AddColumn(&searchV3{}, c); err != nil {
+						return err
+					}
+				}
+				if err := db.CreateIndex(tx, config.SearchTableName, "idx_is_end", "is_end", false); err != nil {
+					return err
+				}
+				return fillSearchIsEnd(tx)
+			},
+			Down: func(tx *gorm.DB) error {
+				if err := db.DropIndex(tx, config.SearchTableName, "idx_is_end"); err != nil {
+					return err
+				}
+				for _, c := range searchV3Columns {
+					if err := tx.Migrator().DropColumn(&searchV3{}, c); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		Migration{
+			// 已有影片的标签在重新采集或同步检索信息时通过详情数据补全
+			Version: 14,
+			Name:    "add_search_media_tags",
+			Up: func(tx *gorm.DB) error {
+				for _, c := range searchV4Columns {
+					if tx.Migrator().HasColumn(&searchV4{}, c) {
+						continue
+					}
+					if err := tx.Migrator().AddColumn(&searchV4{}, c); err != nil {
+						return err
+					}
+				}
+				return db.CreateIndex(tx, config.SearchTableName, "idx_quality", "quality", false)
+			},
+			Down: func(tx *gorm.DB) error {
+				if err := db.DropIndex(tx, config.SearchTableName, "idx_quality"); err != nil {
+					return err
+				}
+				for _, c := range searchV4Columns {
+					if err := tx.Migrator().DropColumn(&searchV4{}, c); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		Migration{
+			// 已有影片的全文检索信息在服务启动时于后台补全
+			Version: 15,
+			Name:    "create_film_texts",
+			Up: func(tx *gorm.DB) error {
+				if err := createTable(&filmTextV1{})(tx); err != nil {
+					return err
+				}
+				return createFilmTextIndex(tx)
+			},
+			Down: func(tx *gorm.DB) error {
+				if db.Dialect() == db.DialectSqlite {
+					for _, q := range []string{
+						"DROP TRIGGER IF EXISTS film_texts_ai", "DROP TRIGGER IF EXISTS film_texts_ad",
+						"DROP TRIGGER IF EXISTS film_texts_au", fmt.Sprintf("DROP TABLE IF EXISTS %s", config.FilmTextFtsTableName),
+					} {
+						if err := tx.Exec(q).Error; err != nil {
+							return err
+						}
+					}
+				}
+				return tx.Migrator().DropTable(&filmTextV1{})
+			},
+		},
+		Migration{
+			// 清空已有的全文检索信息, 服务启动时于后台重建以补全拼音
+			Version: 16,
+			Name:    "add_film_text_pinyin",
+			Up: func(tx *gorm.DB) error {
+				for _, c := range filmTextV2Columns {
+					if tx.Migrator().HasColumn(&filmTextV2{}, c) {
+						continue
+					}
+					if err := tx.Migrator().AddColumn(&filmTextV2{}, c); err != nil {
+						return err
+					}
+				}
+				return tx.Exec(fmt.Sprintf("DELETE FROM %s", config.FilmTextTableName)).Error
+			},
+			Down: func(tx *gorm.DB) error {
+				for _, c := range filmTextV2Columns {
+					if err := tx.Migrator().DropColumn(&filmTextV2{}, c); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		Migration{
+			// 繁简对照表排除了需结合词语转换的字, 清空已有的全文检索信息, 服务启动时于后台重建
+			Version: 17,
+			Name:    "reset_film_texts",
+			Up: func(tx *gorm.DB) error {
+				return tx.Exec(fmt.Sprintf("DELETE FROM %s", config.FilmTextTableName)).Error
+			},
+			Down: func(tx *gorm.DB) error { return nil },
+		},
+		Migration{
+			// 仅 MySQL 的 string 字段默认为 varchar(191), SQLite 与 PostgreSQL 已是 text 类型
+			// SQLite 修改字段时会重建数据表并丢失全文检索触发器, 因此不做处理
+			Version: 18,
+			Name:    "widen_film_text_columns",
+			Up: func(tx *gorm.DB) error {
+				if db.Dialect() != db.DialectMysql {
+					return nil
+				}
+				for _, c := range filmTextV3Columns {
+					if err := tx.Migrator().AlterColumn(&filmTextV3{}, c); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			// 缩短字段长度可能截断已有数据, 回滚时保持 text 类型
+			Down: func(tx *gorm.DB) error { return nil },
+		},
+		Migration{
+			// 影片名生成 hash key 前改为转换为简体字, 附属站点中繁体片名的播放源迁移到新的 key
+			// 新 key 同样能被繁体片名匹配, 回滚时无需处理
+			Version: 19,
+			Name:    "rekey_multiple_source",
+			Up:      rekeyMultipleSource,
+			Down:    func(tx *gorm.DB) error { return nil },
+		},
+	)
+}
+
+// createFilmTextIndex 创建影片全文检索索引
+// MySQL 使用 ngram 分词的 FULLTEXT 索引, SQLite 使用外部内容的 FTS5 虚拟表并通过触发器与数据表保持同步
+// PostgreSQL 的内置分词不支持中文, 检索时使用模糊匹配
+func createFilmTextIndex(tx *gorm.DB) error {
+	const columns = "name, alias, en_name, actor, director, content"
+	switch db.Dialect() {
+	case db.DialectMysql:
+		if tx.Migrator().HasIndex(config.FilmTextTableName, "idx_film_text") {
+			return nil
+		}
+		return tx.Exec(fmt.Sprintf("CREATE FULLTEXT INDEX idx_film_text ON %s (%s) WITH PARSER ngram", config.FilmTextTableName, columns)).Error
+	case db.DialectSqlite:
+		fts, table := config.FilmTextFtsTableName, config.FilmTextTableName
+		values := "new.name, new.alias, new.en_name, new.actor, new.director, new.content"
+		deleted := "old.name, old.alias, old.en_name, old.actor, old.director, old.content"
+		for _, q := range []string{
+			fmt.Sprintf("CREATE VIRTUAL TABLE IF NOT EXISTS %s USING fts5(%s, content='%s', content_rowid='mid', tokenize='trigram')", fts, columns, table),
+			fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS film_texts_ai AFTER INSERT ON %s BEGIN INSERT INTO %s(rowid, %s) VALUES (new.mid, %s); END", table, fts, columns, values),
+			fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS film_texts_ad AFTER DELETE ON %s BEGIN INSERT INTO %s(%s, rowid, %s) VALUES ('delete', old.mid, %s); END", table, fts, fts, columns, deleted),
+			fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS film_texts_au AFTER UPDATE ON %s BEGIN INSERT INTO %s(%s, rowid, %s) VALUES ('delete', old.mid, %s); INSERT INTO %s(rowid, %s) VALUES (new.mid, %s); END",
+				table, fts, fts, columns, deleted, fts, columns, values),
+		} {
+			if err := tx.Exec(q).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// restoreHiddenCategorySearch 旧版本通过逻辑删除检索信息实现分类隐藏, 分类展示状态改为查询时过滤后恢复这部分检索信息
+func restoreHiddenCategorySearch(tx *gorm.DB) error {
+	type category struct {
+		Id       int64       `json:"id"`
+		Show     bool        `json:"show"`
+		Children []*category `json:"children"`
+	}
+	data, err := db.Rdb.Get(db.Cxt, config.CategoryTreeKey).Bytes()
+	if err != nil {
+		// 分类信息不存在时不存在被隐藏的分类
+		return nil
+	}
+	var tree category
+	if err = json.Unmarshal(data, &tree); err != nil {
+		return nil
+	}
+	var ids []int64
+	for _, c := range tree.Children {
+		for _, subC := range c.Children {
+			if !subC.Show {
+				ids = append(ids, subC.Id)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	// 回收站中的影片保持删除状态
+	recycled := tx.Table(config.FilmRecycleTableName).Select("mid")
+	return tx.Table(config.SearchTableName).Where("deleted_at IS NOT NULL AND cid IN ? AND mid NOT IN (?)", ids, recycled).
+		Update("deleted_at", nil).Error
+}
+
+// fillSearchDbId 从 redis 中的影片详情补全已有检索信息的豆瓣ID
+func fillSearchDbId(tx *gorm.DB) error {
+	type detail struct {
+		Descriptor struct {
+			DbId int64 `json:"dbId"`
+		} `json:"descriptor"`
+	}
+	var batch []searchV2
+	update := tx.Session(&gorm.Session{NewDB: true})
+	return tx.Unscoped().Select("id", "mid", "cid").Where("db_id = 0").FindInBatches(&batch, config.MaxScanCount, func(_ *gorm.DB, _ int) error {
+		for _, s := range batch {
+			var d detail
+			data, err := db.Rdb.Get(db.Cxt, fmt.Sprintf(config.MovieDetailKey, s.Cid, s.Mid)).Bytes()
+			if err != nil || json.Unmarshal(data, &d) != nil || d.Descriptor.DbId == 0 {
+				continue
+			}
+			if err = update.Model(&searchV2{}).Unscoped().Where("id = ?", s.ID).Update("db_id", d.Descriptor.DbId).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+// fillSearchIsEnd 通过更新状态推断已有检索信息的完结状态, 集数等其余连载信息在影片重新采集后补全
+func fillSearchIsEnd(tx *gorm.DB) error {
+	return tx.Model(&searchV3{}).Unscoped().
+		Where("remarks LIKE ? OR remarks LIKE ? OR (remarks NOT LIKE ? AND remarks NOT LIKE ? AND remarks NOT LIKE ? AND remarks NOT LIKE ? AND remarks NOT LIKE ?)",
+			"%完结%", "%全%集%", "%更新%", "%连载%", "%集%", "%话%", "%期%").
+		Update("is_end", true).Error
+}
+
+// multipleSourceKey 版本 19 前后附属站点播放源的 hash key 生成规则, simplified 为 true 时先将片名转换为简体字
+func multipleSourceKey(name string, simplified bool) string {
+	if simplified {
+		name = util.ToSimplified(name)
+	}
+	name = regexp.MustCompile(`\s`).ReplaceAllString(name, "")
+	name = regexp.MustCompile(`～.*～$`).ReplaceAllString(name, "")
+	name = regexp.MustCompile(`^[[:punct:]]+|[[:punct:]]+$`).ReplaceAllString(name, "")
+	name = regexp.MustCompile(`季.*`).ReplaceAllString(name, "季")
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+	return fmt.Sprint(h.Sum32())
+}
+
+// rekeyMultipleSource 附属站点的数据中只保存了片名的 hash 值, 无法直接换算
+// 因此按检索信息中的片名与别名计算新旧 key, 将附属站点中仍使用旧 key 的播放源迁移到新 key 下
+// 未与主站影片匹配的播放源在附属站点重新采集后生成新 key
+func rekeyMultipleSource(tx *gorm.DB) error {
+	hashes, err := db.ScanKeys(config.KeyPattern(config.MultipleSiteDetail))
+	if err != nil || len(hashes) == 0 {
+		return err
+	}
+	// 旧 key -> 新 key, 仅包含繁体字的片名会产生不同的 key
+	rename := make(map[string]string)
+	add := func(name string) {
+		if o, n := multipleSourceKey(name, false), multipleSourceKey(name, true); o != n {
+			rename[o] = n
+		}
+	}
+	var batch []searchV1
+	err = tx.Unscoped().Select("id", "name", "sub_title").FindInBatches(&batch, config.MaxScanCount, func(_ *gorm.DB, _ int) error {
+		for _, s := range batch {
+			add(s.Name)
+			add(regexp.MustCompile(`第一季$`).ReplaceAllString(s.Name, ""))
+			for _, sep := range []string{",", "/"} {
+				if strings.Contains(s.SubTitle, sep) {
+					for _, v := range strings.Split(s.SubTitle, sep) {
+						add(v)
+					}
+				}
+			}
+		}
+		return nil
+	}).Error
+	if err != nil || len(rename) == 0 {
+		return err
+	}
+	for _, h := range hashes {
+		fields, err := db.Rdb.HKeys(db.Cxt, h).Result()
+		if err != nil {
+			return err
+		}
+		exist := make(map[string]bool, len(fields))
+		for _, f := range fields {
+			exist[f] = true
+		}
+		var olds []string
+		for o, n := range rename {
+			// 新 key 已存在时说明附属站点已重新采集, 以新数据为准
+			if exist[o] && !exist[n] {
+				olds = append(olds, o)
+			}
+		}
+		for i := 0; i < len(olds); i += config.MaxScanCount {
+			part := olds[i:min(i+config.MaxScanCount, len(olds))]
+			values, err := db.Rdb.HMGet(db.Cxt, h, part...).Result()
+			if err != nil {
+				return err
+			}
+			pipe := db.Rdb.Pipeline()
+			for j, o := range part {
+				if v, ok := values[j].(string); ok {
+					pipe.HSet(db.Cxt, h, rename[o], v)
+				}
+			}
+			pipe.HDel(db.Cxt, h, part...)
+			if _, err = pipe.Exec(db.Cxt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// createTable 数据表不存在时按照表结构快照创建
+func createTable(model interface{}) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		if tx.Migrator().HasTable(model) {
+			return nil
+		}
+		return tx.Migrator().CreateTable(model)
+	}
+}
@@ -13,6 +13,8 @@ import (
 	"server/model/system"
 	"server/plugin/common/conver"
 	"server/plugin/common/util"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -60,7 +62,7 @@ func HandleCollect(id string, h int) error {
 	log.Printf("[Spider] 站点 %s 任务启动 (reqId: %s)\n", id, reqId)
 
 	// 1. 首先通过ID获取对应采集站信息
-	s := system.FindCollectSourceById(id)
+	s := system.Repo.Source.FindById(id)
 	if s == nil {
 		log.Println("Cannot Find Collect Source Site")
 		return errors.New(" Cannot Find Collect Source Site ")
@@ -69,10 +71,10 @@ func HandleCollect(id string, h int) error {
 		return errors.New(" The acquisition site was disabled ")
 	}
 
-	// 如果是主站点且状态为启用则先获取分类tree信息
-	if s.Grade == system.MasterCollect && s.State {
+	// 如果是影片主站点且状态为启用则先获取分类tree信息
+	if s.CollectType == system.CollectVideo && s.Grade == system.MasterCollect && s.State {
 		// 是否存在分类树信息, 不存在则获取
-		if !system.ExistsCategoryTree() {
+		if !system.Repo.Film.ExistsCategoryTree() {
 			CollectCategory(s)
 		}
 	}
@@ -108,50 +110,33 @@ func HandleCollect(id string, h int) error {
 	switch s.CollectType {
 	case system.CollectVideo:
 		// 采集视频资源
-		if s.Interval > 500 {
-			for i := 1; i <= pageCount; i++ {
-				select {
-				case <-ctx.Done():
-					log.Printf("[Spider] 站点 %s 采集任务被中断(单线程模式)\n", s.Name)
-					return nil
-				default:
-					collectFilm(ctx, s, h, i)
-					time.Sleep(time.Duration(s.Interval) * time.Millisecond)
-				}
-			}
-		} else if pageCount <= config.MAXGoroutine*2 {
-			for i := 1; i <= pageCount; i++ {
-				select {
-				case <-ctx.Done():
-					log.Printf("[Spider] 站点 %s 采集任务被中断(同步模式)\n", s.Name)
-					return nil
-				default:
-					collectFilm(ctx, s, h, i)
-				}
-			}
-		} else {
-			// 并发模式
-			ConcurrentPageSpider(ctx, pageCount, s, h, collectFilm)
+		if !collectPages(ctx, s, h, pageCount, collectFilm) {
+			return nil
 		}
 		// 视频数据采集完成后同步相关信息到mysql
 		if s.Grade == system.MasterCollect {
 			// 执行影片信息更新操作
 			if h > 0 {
 				// 执行数据更新操作
-				system.SyncSearchInfo(1)
+				system.Repo.Search.Sync(1)
 			} else {
 				// 清空searchInfo中的数据并重新添加, 否则执行
-				system.SyncSearchInfo(0)
+				system.Repo.Search.Sync(0)
 			}
 			// 开启图片同步
 			if s.SyncPictures {
-				system.SyncFilmPicture()
+				system.Repo.File.SyncPicture()
 			}
 			// 每次成功执行完都清理redis中的相关API接口数据缓存
 			ClearCache()
 		}
-
-	case system.CollectArticle, system.CollectActor, system.CollectRole, system.CollectWebSite:
+	case system.CollectArticle, system.CollectActor, system.CollectRole:
+		// 采集 文章 | 演员 | 角色 资源
+		if !collectPages(ctx, s, h, pageCount, collectResource) {
+			return nil
+		}
+		ClearCache()
+	case system.CollectWebSite:
 		log.Println("暂未开放此采集功能!!!")
 		return errors.New("暂未开放此采集功能")
 	}
@@ -167,13 +152,48 @@ func CollectCategory(s *system.FilmSource) {
 		log.Println("GetCategoryTree Error: ", err)
 		return
 	}
+	// 已存在分类信息时以现有分类树为准, 仅追加采集站新增的分类, 保留管理员对分类的调整
+	if tree := system.Repo.Film.GetCategoryTree(); tree.Category != nil {
+		tree.MergeCollected(categoryTree, system.Repo.Film.GetCategoryMapping())
+		categoryTree = &tree
+	}
 	// 保存 tree 到redis
-	err = system.SaveCategoryTree(categoryTree)
+	err = system.Repo.Film.SaveCategoryTree(categoryTree)
 	if err != nil {
 		log.Println("SaveCategoryTree Error: ", err)
 	}
 }
 
+// collectPages 按照站点的采集间隔选择 单线程 | 同步 | 并发 模式执行分页采集, 任务被中断时返回 false
+func collectPages(ctx context.Context, s *system.FilmSource, h, pageCount int, collectFunc func(ctx context.Context, s *system.FilmSource, hour, pageNumber int)) bool {
+	if s.Interval > 500 {
+		for i := 1; i <= pageCount; i++ {
+			select {
+			case <-ctx.Done():
+				log.Printf("[Spider] 站点 %s 采集任务被中断(单线程模式)\n", s.Name)
+				return false
+			default:
+				collectFunc(ctx, s, h, i)
+				time.Sleep(time.Duration(s.Interval) * time.Millisecond)
+			}
+		}
+	} else if pageCount <= config.MAXGoroutine*2 {
+		for i := 1; i <= pageCount; i++ {
+			select {
+			case <-ctx.Done():
+				log.Printf("[Spider] 站点 %s 采集任务被中断(同步模式)\n", s.Name)
+				return false
+			default:
+				collectFunc(ctx, s, h, i)
+			}
+		}
+	} else {
+		// 并发模式
+		ConcurrentPageSpider(ctx, pageCount, s, h, collectFunc)
+	}
+	return true
+}
+
 // collectFilm 影视详情采集 (单一源分页全采集)
 func collectFilm(ctx context.Context, s *system.FilmSource, h, pg int) {
 	// 检查取消信号
@@ -196,64 +216,186 @@ func collectFilm(ctx context.Context, s *system.FilmSource, h, pg int) {
 	if err != nil || len(list) <= 0 {
 		// 添加采集失败记录
 		fr := system.FailureRecord{OriginId: s.Id, OriginName: s.Name, Uri: s.Uri, CollectType: system.CollectVideo, PageNumber: pg, Hour: h, Cause: fmt.Sprintln(err), Status: 1}
-		system.SaveFailureRecord(fr)
+		system.Repo.Record.Save(fr)
 		log.Println("GetMovieDetail Error: ", err)
 		return
 	}
+	// 过滤掉已屏蔽(下架)的影片
+	if list = system.Repo.Block.Filter(s.Id, list); len(list) <= 0 {
+		return
+	}
 	// 通过采集站 Grade 类型, 执行不同的存储逻辑
 	switch s.Grade {
 	case system.MasterCollect:
 		// 主站点 	保存完整影片详情信息到 redis
-		if err = system.SaveDetails(list); err != nil {
+		if err = system.Repo.Film.SaveDetails(list); err != nil {
 			log.Println("SaveDetails Error: ", err)
 		}
 		// 如果主站点开启了图片同步, 则将图片url以及对应的mid存入ZSet集合中
 		if s.SyncPictures {
-			if err = system.SaveVirtualPic(conver.ConvertVirtualPicture(list)); err != nil {
+			if err = system.Repo.File.SaveVirtualPic(conver.ConvertVirtualPicture(list)); err != nil {
 				log.Println("SaveVirtualPic Error: ", err)
 			}
 		}
 	case system.SlaveCollect:
 		// 附属站点	仅保存影片播放信息到redis
-		if err = system.SaveSitePlayList(s.Id, list); err != nil {
+		if err = system.Repo.Film.SaveSitePlayList(s.Id, list); err != nil {
 			log.Println("SaveDetails Error: ", err)
 		}
 	}
 }
 
-// collectFilmById 采集指定ID的影片信息
-func collectFilmById(ids string, s *system.FilmSource) {
+// collectResource 文章 | 演员 | 角色 分页采集
+func collectResource(ctx context.Context, s *system.FilmSource, h, pg int) {
+	// 检查取消信号
+	select {
+	case <-ctx.Done():
+		return
+	default:
+	}
+
 	// 生成请求参数
 	r := util.RequestInfo{Uri: s.Uri, Params: url.Values{}}
-	// 设置分页页数
-	r.Params.Set("pg", "1")
-	// 设置影片IDS参数信息
-	r.Params.Set("ids", ids)
+	r.Params.Set("pg", fmt.Sprint(pg))
+	if h > 0 {
+		r.Params.Set("h", fmt.Sprint(h))
+	}
+	// 执行采集方法并保存对应类型的数据
+	var n int
+	var err error
+	switch s.CollectType {
+	case system.CollectActor:
+		var list []system.Actor
+		if list, err = spiderCore.GetActors(r, s.Id); err == nil && len(list) > 0 {
+			n, err = len(list), system.Repo.Person.SaveActors(list)
+		}
+	case system.CollectRole:
+		var list []system.FilmRole
+		if list, err = spiderCore.GetRoles(r, s.Id); err == nil && len(list) > 0 {
+			n, err = len(list), system.Repo.Person.SaveRoles(list)
+		}
+	case system.CollectArticle:
+		var list []system.Article
+		if list, err = spiderCore.GetArticles(r, s.Id); err == nil && len(list) > 0 {
+			n, err = len(list), system.Repo.Article.Save(list)
+		}
+	}
+	if err != nil || n <= 0 {
+		// 添加采集失败记录
+		fr := system.FailureRecord{OriginId: s.Id, OriginName: s.Name, Uri: s.Uri, CollectType: s.CollectType, PageNumber: pg, Hour: h, Cause: fmt.Sprintln(err), Status: 1}
+		system.Repo.Record.Save(fr)
+		log.Println("CollectResource Error: ", err)
+	}
+}
+
+// FetchFilms 获取采集站中指定ID的影片详情, 不保存数据, ids 为多个影片ID时使用 , 分隔
+func FetchFilms(s *system.FilmSource, ids string) ([]system.MovieDetail, error) {
+	// 生成请求参数
+	r := util.RequestInfo{Uri: s.Uri, Params: url.Values{}}
+	return spiderCore.GetSingleFilm(r, ids)
+}
+
+// collectFilmById 采集指定ID的影片信息, ids 为多个影片ID时使用 , 分隔
+func collectFilmById(ids string, s *system.FilmSource) error {
 	// 执行采集方法 获取影片详情list
-	list, err := spiderCore.GetFilmDetail(r)
-	if err != nil || len(list) <= 0 {
+	list, err := FetchFilms(s, ids)
+	if err != nil {
 		log.Println("GetMovieDetail Error: ", err)
-		return
+		return err
+	}
+	if len(list) <= 0 {
+		return errors.New("采集站中未获取到对应的影片信息")
+	}
+	// 过滤掉已屏蔽(下架)的影片
+	return saveFilmList(s, system.Repo.Block.Filter(s.Id, list))
+}
+
+// saveFilmList 通过采集站 Grade 类型, 逐条保存影片信息
+func saveFilmList(s *system.FilmSource, list []system.MovieDetail) (err error) {
+	if len(list) <= 0 {
+		return nil
 	}
 	// 通过采集站 Grade 类型, 执行不同的存储逻辑
 	switch s.Grade {
 	case system.MasterCollect:
 		// 主站点 	保存完整影片详情信息到 redis 和 mysql 中
-		if err = system.SaveDetail(list[0]); err != nil {
-			log.Println("SaveDetails Error: ", err)
+		for _, d := range list {
+			if err = system.Repo.Film.SaveDetail(d); err != nil {
+				log.Println("SaveDetails Error: ", err)
+				return err
+			}
 		}
 		// 如果主站点开启了图片同步, 则将图片url以及对应的mid存入ZSet集合中
 		if s.SyncPictures {
-			if err = system.SaveVirtualPic(conver.ConvertVirtualPicture(list)); err != nil {
+			if err = system.Repo.File.SaveVirtualPic(conver.ConvertVirtualPicture(list)); err != nil {
 				log.Println("SaveVirtualPic Error: ", err)
 			}
 		}
 	case system.SlaveCollect:
 		// 附属站点	仅保存影片播放信息到redis
-		if err = system.SaveSitePlayList(s.Id, list); err != nil {
+		if err = system.Repo.Film.SaveSitePlayList(s.Id, list); err != nil {
 			log.Println("SaveDetails Error: ", err)
+			return err
 		}
 	}
+	return nil
+}
+
+// lookupLimiter 限制同时执行的采集站搜索任务数量
+var lookupLimiter = make(chan struct{}, config.SearchLookupConcurrency)
+
+// LookupFilm 通过关键字在已启用的影视采集站中搜索影片, 主站点保存完整影片信息, 附属站点仅保存播放列表
+func LookupFilm(keyword string) {
+	lookupLimiter <- struct{}{}
+	defer func() { <-lookupLimiter }()
+	l := system.SearchLookup{Keyword: keyword, Status: system.LookupRunning}
+	system.Repo.Search.SaveLookup(l)
+	var total, failed int
+	// 采集站列表按 Grade 排序, 主站点的影片先入库, 附属站点的播放列表才能匹配到对应影片
+	for _, s := range system.Repo.Source.List() {
+		if !s.State || s.CollectType != system.CollectVideo {
+			continue
+		}
+		total++
+		list, err := spiderCore.CustomSearch(util.RequestInfo{Uri: s.Uri, Params: url.Values{}}, keyword)
+		if err != nil {
+			failed++
+			log.Printf("LookupFilm %s Error: %v\n", s.Name, err)
+			continue
+		}
+		// 采集站的 wd 为模糊搜索, 仅保留片名, 别名或英文名包含关键字的影片
+		list = system.Repo.Block.Filter(s.Id, matchLookupFilms(keyword, list))
+		if err = saveFilmList(&s, list); err != nil {
+			log.Printf("LookupFilm %s Error: %v\n", s.Name, err)
+			continue
+		}
+		if s.Grade == system.MasterCollect {
+			l.Found += len(list)
+		}
+	}
+	l.Status = system.LookupDone
+	if total > 0 && failed == total {
+		l.Status = system.LookupFailed
+	}
+	system.Repo.Search.SaveLookup(l)
+}
+
+// matchLookupFilms 筛选片名, 别名或英文名包含关键字的影片
+func matchLookupFilms(keyword string, list []system.MovieDetail) []system.MovieDetail {
+	k := system.NormalizeSuggest(keyword)
+	if k == "" {
+		return nil
+	}
+	var res []system.MovieDetail
+	for _, d := range list {
+		for _, title := range []string{d.Name, d.SubTitle, d.EnName} {
+			if strings.Contains(system.NormalizeSuggest(title), k) {
+				res = append(res, d)
+				break
+			}
+		}
+	}
+	return res
 }
 
 // ConcurrentPageSpider 并发分页采集, 不限类型
@@ -301,7 +443,7 @@ func ConcurrentPageSpider(ctx context.Context, capacity int, s *system.FilmSourc
 func BatchCollect(h int, ids ...string) {
 	for _, id := range ids {
 		// 如果查询到对应Id的资源站信息, 且资源站处于启用状态
-		if fs := system.FindCollectSourceById(id); fs != nil && fs.State {
+		if fs := system.Repo.Source.FindById(id); fs != nil && fs.State {
 			// 采用协程并发执行, 每个站点单独开启一个协程执行
 			go func(sourceId string, hour int, sourceName string) {
 				if err := HandleCollect(sourceId, hour); err != nil {
@@ -315,7 +457,7 @@ func BatchCollect(h int, ids ...string) {
 // AutoCollect 自动进行对所有已启用站点的采集任务
 func AutoCollect(h int) {
 	// 获取采集站中所有站点, 进行遍历
-	for _, s := range system.GetCollectSourceList() {
+	for _, s := range system.Repo.Source.List() {
 		// 如果当前站点为启用状态 则执行 HandleCollect 进行数据采集
 		if s.State {
 			// 为每个站点开启独立的协程执行，实现并发全量采集
@@ -330,30 +472,67 @@ func AutoCollect(h int) {
 
 // ClearSpider  删除所有已采集的影片信息
 func ClearSpider() {
-	system.FilmZero()
+	system.Repo.Film.Zero()
 }
 
 // StarZero 清空站点内所有影片信息并从零开始采集
 func StarZero(h int) {
 	// 1. 清除影视信息
-	system.FilmZero()
+	system.Repo.Film.Zero()
 
 	// 2. 开启自动采集（每个站点的 HandleCollect 会自动抢断同站旧任务）
 	AutoCollect(h)
 }
 
-// CollectSingleFilm 通过影片唯一ID获取影片信息
-func CollectSingleFilm(ids string) {
+// CollectSingleFilm 通过影片唯一ID获取影片信息, ids 为多个影片ID时使用 , 分隔
+func CollectSingleFilm(ids string) error {
 	// 获取采集站列表信息
-	fl := system.GetCollectSourceList()
+	fl := system.Repo.Source.List()
 	// 循环遍历所有采集站信息
 	for _, f := range fl {
 		// 目前仅对主站点进行处理
 		if f.Grade == system.MasterCollect && f.State {
-			collectFilmById(ids, &f)
-			return
+			return collectFilmById(ids, &f)
+		}
+	}
+	return errors.New("未获取到已启用的主采集站信息")
+}
+
+// CollectFilms 从主采集站重新采集指定ID的影片, 返回每部影片的采集结果, 保存成功的影片对应的值为 nil
+func CollectFilms(mids []int64) (map[int64]error, error) {
+	var master *system.FilmSource
+	for _, f := range system.Repo.Source.List() {
+		if f.Grade == system.MasterCollect && f.State {
+			master = &f
+			break
 		}
 	}
+	if master == nil {
+		return nil, errors.New("未获取到已启用的主采集站信息")
+	}
+	var ids []string
+	for _, mid := range mids {
+		ids = append(ids, strconv.FormatInt(mid, 10))
+	}
+	list, err := FetchFilms(master, strings.Join(ids, ","))
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[int64]error, len(mids))
+	for _, d := range list {
+		res[d.Id] = errors.New("影片匹配屏蔽规则, 已跳过")
+	}
+	// 过滤掉已屏蔽(下架)的影片后逐部保存
+	for _, d := range system.Repo.Block.Filter(master.Id, list) {
+		res[d.Id] = saveFilmList(master, []system.MovieDetail{d})
+	}
+	// 采集站未返回的影片
+	for _, mid := range mids {
+		if _, ok := res[mid]; !ok {
+			res[mid] = errors.New("采集站中未获取到该影片")
+		}
+	}
+	return res, nil
 }
 
 // ======================================================= 采集拓展内容  =======================================================
@@ -361,30 +540,39 @@ func CollectSingleFilm(ids string) {
 // SingleRecoverSpider 二次采集
 func SingleRecoverSpider(fr *system.FailureRecord) {
 	// 将记录状态修改为已处理
-	system.ChangeRecord(fr, 0)
+	system.Repo.Record.Change(fr, 0)
 	// 仅对当前失败记录所属站点+失败页进行重试，不干扰正在运行的采集任务
-	s := system.FindCollectSourceById(fr.OriginId)
+	s := system.Repo.Source.FindById(fr.OriginId)
 	if s == nil {
 		log.Printf("[Spider] 重试失败: 站点 %s 不存在\n", fr.OriginId)
 		return
 	}
-	collectFilm(context.Background(), s, fr.Hour, fr.PageNumber)
+	retryFailurePage(s, fr)
 }
 
 // FullRecoverSpider 扫描记录表中的失败记录, 逐条重试对应的失败页
 func FullRecoverSpider() {
-	list := system.PendingRecord()
+	list := system.Repo.Record.Pending()
 	for _, fr := range list {
 		// 将记录状态修改为已处理
-		system.ChangeRecord(&fr, 0)
+		system.Repo.Record.Change(&fr, 0)
 		// 仅对当前失败记录所属站点+失败页进行重试
-		s := system.FindCollectSourceById(fr.OriginId)
+		s := system.Repo.Source.FindById(fr.OriginId)
 		if s == nil {
 			log.Printf("[Spider] 重试失败: 站点 %s 不存在\n", fr.OriginId)
 			continue
 		}
+		retryFailurePage(s, &fr)
+	}
+}
+
+// retryFailurePage 按照失败记录的采集类型重新采集对应的页
+func retryFailurePage(s *system.FilmSource, fr *system.FailureRecord) {
+	if fr.CollectType == system.CollectVideo {
 		collectFilm(context.Background(), s, fr.Hour, fr.PageNumber)
+		return
 	}
+	collectResource(context.Background(), s, fr.Hour, fr.PageNumber)
 }
 
 // ======================================================= 公共方法  =======================================================
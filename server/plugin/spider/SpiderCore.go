@@ -103,7 +103,7 @@ func (jc *JsonCollect) GetFilmDetail(r util.RequestInfo) (list []system.MovieDet
 
 	// 将影视原始详情信息保存到redis中
 	// 获取主站点uri
-	//mc := system.GetCollectSourceListByGrade(system.MasterCollect)[0]
+	//mc := system.Repo.Source.ListByGrade(system.MasterCollect)[0]
 	//if mc.Uri == r.Uri {
 	//	collect.BatchSaveOriginalDetail(detailPage.List)
 	//}
@@ -113,23 +113,54 @@ func (jc *JsonCollect) GetFilmDetail(r util.RequestInfo) (list []system.MovieDet
 	return
 }
 
-// CustomSearch 自定义搜索, 通过特定的搜索参数获取满足条件的影片数据
-func (jc *JsonCollect) CustomSearch(r util.RequestInfo) {
-	// 设置固定参数 ac 请求类型 pg 页数
-	if len(r.Params.Get("ac")) <= 0 {
-		r.Params.Set("ac", "detail")
+// GetActors 获取演员资料
+func (jc *JsonCollect) GetActors(r util.RequestInfo, sid string) ([]system.Actor, error) {
+	list, err := getResourceList[collect.ActorDetail](r)
+	return conver.ConvertActors(sid, list), err
+}
+
+// GetRoles 获取影片角色信息
+func (jc *JsonCollect) GetRoles(r util.RequestInfo, sid string) ([]system.FilmRole, error) {
+	list, err := getResourceList[collect.RoleDetail](r)
+	return conver.ConvertRoles(sid, list), err
+}
+
+// GetArticles 获取文章资讯
+func (jc *JsonCollect) GetArticles(r util.RequestInfo, sid string) ([]system.Article, error) {
+	list, err := getResourceList[collect.ArticleDetail](r)
+	return conver.ConvertArticles(sid, list), err
+}
+
+// getResourceList 请求 演员 | 角色 | 文章 接口的详情数据
+func getResourceList[T any](r util.RequestInfo) ([]T, error) {
+	// MacCMS 通过接口路径区分资源类型, ac=detail 时返回包含图片与介绍的完整信息
+	r.Params.Set(`ac`, `detail`)
+	util.ApiGet(&r)
+	if len(r.Resp) <= 0 {
+		return nil, errors.New(r.Err)
 	}
+	page := collect.ResourcePage[T]{}
+	if err := json.Unmarshal(r.Resp, &page); err != nil {
+		return nil, err
+	}
+	return page.List, nil
+}
+
+// CustomSearch 自定义搜索, 通过特定的搜索参数获取满足条件的影片数据
+func (jc *JsonCollect) CustomSearch(r util.RequestInfo, wd string) ([]system.MovieDetail, error) {
+	// 设置固定参数 pg 页数, ac 请求类型由 GetFilmDetail 设置为 detail
 	r.Params.Set("pg", "1")
 	// 设置搜索参数 wd (影片名模糊搜索)
+	r.Params.Set("wd", wd)
+	return jc.GetFilmDetail(r)
 }
 
 // GetSingleFilm 获取单一影片信息
-func (jc *JsonCollect) GetSingleFilm(r util.RequestInfo, ids string) {
-	// 设置固定参数 ac 请求类型 pg 页数
-	r.Params.Set("ac", "detail")
+func (jc *JsonCollect) GetSingleFilm(r util.RequestInfo, ids string) ([]system.MovieDetail, error) {
+	// 设置固定参数 pg 页数, ac 请求类型由 GetFilmDetail 设置为 detail
 	r.Params.Set("pg", "1")
 	r.Params.Set("ids", ids)
-	//
+	return jc.GetFilmDetail(r)
 }
 
 // FailureRecord 记录失败采集的相关信息, 用于后续采集重试操作
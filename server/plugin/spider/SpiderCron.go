@@ -26,7 +26,7 @@ func AddFilmUpdateCron(id, spec string) (cron.EntryID, error) {
 	}
 	return CronCollect.AddFunc(spec, func() {
 		// 通过创建任务时生成的 Id 获取任务相关数据
-		ft, err := system.GetFilmTaskById(id)
+		ft, err := system.Repo.CronTask.FindById(id)
 		if err != nil {
 			log.Println("FilmCollectCron Exec Failed: ", err)
 		}
@@ -48,7 +48,7 @@ func AddAutoUpdateCron(id, spec string) (cron.EntryID, error) {
 	}
 	return CronCollect.AddFunc(spec, func() {
 		// 通过 Id 获取任务相关数据
-		ft, err := system.GetFilmTaskById(id)
+		ft, err := system.Repo.CronTask.FindById(id)
 		if err != nil {
 			log.Println("FilmCollectCron Exec Failed: ", err)
 		}
@@ -97,7 +97,9 @@ func ValidSpec(spec string) error {
 	return err
 }
 
-// ClearCache 清理API接口数据缓存
+// ClearCache 清理所有站点的API接口数据缓存
 func ClearCache() {
-	system.RemoveCache(config.IndexCacheKey)
+	for _, t := range system.GetTenants() {
+		system.Repo.Cache.Remove(t.Key(config.IndexCacheKey))
+	}
 }
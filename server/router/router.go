@@ -13,6 +13,8 @@ func SetupRouter() *gin.Engine {
 	r := gin.Default()
 	// 开启跨域
 	r.Use(middleware.Cors())
+	// 解析请求所属站点
+	r.Use(middleware.Tenant())
 
 	// 静态资源配置
 	r.Static(config.FilmPictureUrlPath, config.FilmPictureUploadDir)
@@ -24,8 +26,18 @@ func SetupRouter() *gin.Engine {
 	r.GET(`/filmDetail`, controller.FilmDetail)
 	r.GET(`/filmPlayInfo`, controller.FilmPlayInfo)
 	r.GET(`/searchFilm`, controller.SearchFilm)
+	r.GET(`/searchSuggest`, controller.SearchSuggest)
+	r.GET(`/searchHot`, controller.SearchHot)
+	r.GET(`/searchLookup`, controller.SearchLookup)
 	r.GET(`/filmClassify`, controller.FilmClassify)
 	r.GET(`/filmClassifySearch`, controller.FilmTagSearch)
+	r.GET(`/film/today`, controller.FilmToday)
+	r.GET(`/film/calendar`, controller.FilmCalendar)
+	r.GET(`/person`, controller.PersonDetail)
+	r.GET(`/person/films`, controller.PersonFilms)
+	r.GET(`/person/search`, controller.PersonSearch)
+	r.GET(`/article/list`, controller.ArticleList)
+	r.GET(`/article`, controller.ArticleDetail)
 	//r.GET(`/filmCategory`, controller.FilmCategory) 弃用
 	r.POST(`/login`, controller.Login)
 	r.GET(`/logout`, middleware.AuthToken(), controller.Logout)
@@ -108,13 +120,42 @@ func SetupRouter() *gin.Engine {
 		filmRoute := manageRoute.Group(`/film`)
 		{
 			filmRoute.POST(`/add`, controller.FilmAdd)
+			filmRoute.POST(`/import`, controller.FilmImport)
+			filmRoute.POST(`/update`, controller.FilmUpdate)
+			filmRoute.GET(`/edit/find`, controller.FilmEditFind)
+			filmRoute.GET(`/edit/reset`, controller.FilmEditReset)
 			filmRoute.GET(`/search/list`, controller.FilmSearchPage)
 			filmRoute.GET(`/search/del`, controller.FilmDelete)
 
+			filmRoute.POST(`/batch`, controller.FilmBatch)
+			filmRoute.GET(`/batch/list`, controller.FilmBatchList)
+			filmRoute.GET(`/batch/find`, controller.FilmBatchFind)
+
+			filmRoute.GET(`/duplicate/scan`, controller.FilmDuplicateScan)
+			filmRoute.GET(`/duplicate/list`, controller.FilmDuplicateList)
+			filmRoute.POST(`/duplicate/merge`, controller.FilmDuplicateMerge)
+			filmRoute.GET(`/duplicate/ignore`, controller.FilmDuplicateIgnore)
+			filmRoute.GET(`/person/rebuild`, controller.FilmPersonRebuild)
+			filmRoute.GET(`/text/rebuild`, controller.FilmTextRebuild)
+			filmRoute.GET(`/search/zero`, controller.FilmSearchZero)
+
+			filmRoute.GET(`/recycle/list`, controller.FilmRecycleList)
+			filmRoute.GET(`/recycle/restore`, controller.FilmRecycleRestore)
+			filmRoute.GET(`/recycle/purge`, controller.FilmRecyclePurge)
+
+			filmRoute.GET(`/block/list`, controller.FilmBlockList)
+			filmRoute.POST(`/block/add`, controller.FilmBlockAdd)
+			filmRoute.GET(`/block/del`, controller.FilmBlockDel)
+			filmRoute.GET(`/block/audit`, controller.FilmBlockAuditList)
+
 			filmRoute.GET(`/class/tree`, controller.FilmClassTree)
 			filmRoute.GET(`/class/find`, controller.FindFilmClass)
 			filmRoute.POST(`/class/update`, controller.UpdateFilmClass)
 			filmRoute.GET(`/class/del`, controller.DelFilmClass)
+			filmRoute.POST(`/class/add`, controller.AddFilmClass)
+			filmRoute.POST(`/class/sort`, controller.SortFilmClass)
+			filmRoute.GET(`/class/move`, controller.MoveFilmClass)
+			filmRoute.GET(`/class/merge`, controller.MergeFilmClass)
 		}
 
 		// 文件管理
@@ -126,6 +167,27 @@ func SetupRouter() *gin.Engine {
 			fileRoute.GET(`/list`, controller.PhotoWall)
 		}
 
+		// 数据备份 & 恢复
+		backupRoute := manageRoute.Group(`/backup`)
+		{
+			backupRoute.GET(`/list`, controller.BackupList)
+			backupRoute.POST(`/create`, controller.BackupCreate)
+			backupRoute.POST(`/restore`, controller.BackupRestore)
+			backupRoute.POST(`/upload`, controller.BackupUpload)
+			backupRoute.GET(`/download`, controller.BackupDownload)
+			backupRoute.GET(`/del`, controller.BackupDel)
+		}
+
+		// 多站点管理, 站点的网站配置与轮播通过 X-Tenant-Id 请求头指定站点后使用 /config, /banner 接口维护
+		tenantRoute := manageRoute.Group(`/tenant`)
+		{
+			tenantRoute.GET(`/list`, controller.TenantList)
+			tenantRoute.GET(`/find`, controller.TenantFind)
+			tenantRoute.POST(`/add`, controller.TenantAdd)
+			tenantRoute.POST(`/update`, controller.TenantUpdate)
+			tenantRoute.GET(`/del`, controller.TenantDel)
+		}
+
 	}
 
 	// 供第三方采集的API